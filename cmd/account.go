@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/keystore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var keystorePath = homePath + "/.compact-chain/keystore"
+
+var (
+	accountCmd = &cobra.Command{
+		Use:   "account",
+		Short: "Manage the accounts of the keystore",
+	}
+
+	accountNewCmd = &cobra.Command{
+		Use:   "new",
+		Short: "Create a new account",
+		Run: func(cmd *cobra.Command, args []string) {
+			ks := keystoreFromFlags(cmd.Flags())
+
+			password, err := passwordFromFlags(cmd.Flags(), true)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			address, err := ks.NewAccount(password)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			fmt.Println("Address :", address.String())
+		},
+	}
+
+	accountListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the accounts of the keystore",
+		Run: func(cmd *cobra.Command, args []string) {
+			accounts, err := keystoreFromFlags(cmd.Flags()).Accounts()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			for i, address := range accounts {
+				fmt.Printf("Account #%d : %s\n", i, address.String())
+			}
+		},
+	}
+
+	accountImportCmd = &cobra.Command{
+		Use:   "import <privatekey>",
+		Short: "Import a hex encoded private key into the keystore",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ks := keystoreFromFlags(cmd.Flags())
+
+			password, err := passwordFromFlags(cmd.Flags(), true)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			address, err := ks.Import(args[0], password)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			fmt.Println("Address :", address.String())
+		},
+	}
+)
+
+func init() {
+	accountCmd.PersistentFlags().String("keystore", keystorePath, "Keystore directory")
+	accountCmd.PersistentFlags().String("password", "", "Password of the account, prompted for if empty")
+
+	accountCmd.AddCommand(accountNewCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountImportCmd)
+}
+
+func keystoreFromFlags(flags *pflag.FlagSet) *keystore.KeyStore {
+	dir, _ := flags.GetString("keystore")
+
+	return keystore.NewKeyStore(dir)
+}
+
+// passwordFromFlags returns the --password flag, or reads the password from stdin.
+// New passwords are asked twice when read from a terminal.
+func passwordFromFlags(flags *pflag.FlagSet, confirm bool) (string, error) {
+	if password, _ := flags.GetString("password"); password != "" {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no password given")
+		}
+
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	password, err := readPassword(fd, "Password : ")
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		repeated, err := readPassword(fd, "Repeat password : ")
+		if err != nil {
+			return "", err
+		}
+
+		if repeated != password {
+			return "", errors.New("passwords do not match")
+		}
+	}
+
+	return password, nil
+}
+
+func readPassword(fd int, prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	password, err := term.ReadPassword(fd)
+
+	fmt.Println()
+
+	return string(password), err
+}
+
+func exitWithError(err error) {
+	fmt.Println("Error :", err)
+	os.Exit(1)
+}
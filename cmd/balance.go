@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/spf13/cobra"
+)
+
+// balanceDecimals is the number of decimals of the human readable balance, one coin
+// being 10^18 base units.
+const balanceDecimals = 18
+
+var balanceCmd = &cobra.Command{
+	Use:   "balance",
+	Short: "Print the balance of an address, queried from a node",
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+
+		address, _ := flags.GetString("address")
+		rpcAddr, _ := flags.GetString("rpc")
+
+		balance, err := queryBalance(rpcAddr, address)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		printBalance(os.Stdout, balance)
+	},
+}
+
+func init() {
+	balanceCmd.Flags().String("address", "", "Hex encoded address")
+	balanceCmd.Flags().String("rpc", "", "RPC endpoint of node, host:port")
+
+	balanceCmd.MarkFlagRequired("address")
+	balanceCmd.MarkFlagRequired("rpc")
+}
+
+// queryBalance returns the balance of the address from the chain_getBalance method.
+func queryBalance(rpcAddr string, address string) (*big.Int, error) {
+	if _, err := util.HexToAddress(address); err != nil {
+		return nil, err
+	}
+
+	var result string
+	if err := callRPC(rpcAddr, "chain_getBalance", &result, address); err != nil {
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(result, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", result)
+	}
+
+	return balance, nil
+}
+
+func printBalance(w io.Writer, balance *big.Int) {
+	fmt.Fprintln(w, "Balance :", balance.String())
+	fmt.Fprintln(w, "Balance (coins) :", formatDecimals(balance, balanceDecimals))
+}
+
+// formatDecimals formats an amount of base units as a decimal number with the given
+// number of decimals, without trailing zeros.
+func formatDecimals(amount *big.Int, decimals int) string {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(amount), unit, new(big.Int))
+
+	out := whole.String()
+	if frac.Sign() != 0 {
+		out += "." + strings.TrimRight(fmt.Sprintf("%0*s", decimals, frac.String()), "0")
+	}
+
+	if amount.Sign() < 0 {
+		out = "-" + out
+	}
+
+	return out
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDecimals(t *testing.T) {
+	t.Parallel()
+
+	for amount, expected := range map[string]string{
+		"0":                    "0",
+		"1":                    "0.000000000000000001",
+		"1000000000000000000":  "1",
+		"1500000000000000000":  "1.5",
+		"12345678901234567890": "12.34567890123456789",
+		"-2500000000000000000": "-2.5",
+	} {
+		value, _ := new(big.Int).SetString(amount, 10)
+		assert.Equal(t, expected, formatDecimals(value, balanceDecimals), amount)
+	}
+}
+
+// nolint : tparallel
+func TestBalanceCommand(t *testing.T) {
+	cfg := &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             ":1753",
+		P2PPort:             ":6103",
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1500000000000000000),
+		},
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"),
+		BlockTime:        4,
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	var balance *big.Int
+
+	// The RPC server is started in the background.
+	assert.Eventually(t, func() bool {
+		var err error
+		balance, err = queryBalance("localhost:1753", "0xa52c981eee8687b5e4afd69aa5006548c24d7685")
+
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var out bytes.Buffer
+
+	printBalance(&out, balance)
+	assert.Equal(t, "Balance : 1500000000000000000\nBalance (coins) : 1.5\n", out.String())
+
+	_, err = queryBalance("localhost:1753", "0x01")
+	assert.ErrorContains(t, err, "must be 20 bytes")
+
+	_, err = queryBalance("localhost:1", "0xa52c981eee8687b5e4afd69aa5006548c24d7685")
+	assert.ErrorIs(t, err, ErrNodeUnreachable)
+}
@@ -0,0 +1,573 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Keys of the node config file. Command line flags share the same names.
+const (
+	configKeyConsensus  = "consensus"
+	configKeyDifficulty = "difficulty"
+	configKeyBlockTime  = "block-time"
+	configKeyPeers      = "peers"
+	configKeyRPCPort    = "rpc-port"
+	configKeyP2PPort    = "p2p-port"
+	configKeySignerKey  = "signer-key"
+	configKeyCoinbase   = "coinbase"
+	configKeyFeeSink    = "base-fee-sink"
+	configKeyAlloc      = "alloc"
+	configKeyMine       = "mine"
+	configKeyDBDir      = "db-dir"
+	configKeyStateDBDir = "state-db-dir"
+	configKeyDBBackend  = "db-backend"
+	configKeyDBCompress = "db-compression"
+	configKeyDataDir    = "datadir"
+	configKeyNetworkID  = "network-id"
+	configKeyLogLevel   = "log-level"
+	configKeyMetrics    = "metrics-port"
+	configKeyExplorer   = "explorer-port"
+
+	configKeyStateRetention  = "state-retention-blocks"
+	configKeyBlockGasLimit   = "block-gas-limit"
+	configKeyMinDifficulty   = "min-difficulty"
+	configKeyMaxDifficulty   = "max-difficulty"
+	configKeyMiningThreads   = "mining-threads"
+	configKeyMaxBlockBytes   = "max-block-bytes"
+	configKeyMaxBlockTxs     = "max-block-txs"
+	configKeyMaxTxDataBytes  = "max-tx-data-bytes"
+	configKeyMaxPeerBackoff  = "max-peer-backoff"
+	configKeyMaxPeers        = "max-peers"
+	configKeyPeerBanDuration = "peer-ban-duration"
+	configKeyPeerDenylist    = "peer-denylist"
+	configKeyMaxClockDrift   = "max-clock-drift"
+	configKeyLegacyTxBlock   = "legacy-tx-block"
+	configKeyLegacyHashBlock = "legacy-hash-block"
+	configKeyLegacyStateRoot = "legacy-state-root-block"
+	configKeySignatureScheme = "signature-scheme"
+	configKeySignerKeyFile   = "signer-key-file"
+	configKeyBlockReward     = "block-reward"
+	configKeyGenesisFile     = "genesis-file"
+	configKeyTxPoolLifetime  = "txpool-lifetime"
+	configKeyMaxTxPerSender  = "max-tx-per-sender"
+	configKeyMinPeers        = "min-peers-for-healthy"
+	configKeyFinalityDepth   = "finality-depth"
+	configKeyMaxReorgDepth   = "max-reorg-depth"
+
+	configKeyRPCRateLimit          = "rpc-rate-limit"
+	configKeyRPCRateBurst          = "rpc-rate-burst"
+	configKeyRPCRateLimitWhitelist = "rpc-rate-limit-whitelist"
+	configKeyRPCReadTimeout        = "rpc-read-timeout"
+	configKeyRPCWriteTimeout       = "rpc-write-timeout"
+	configKeyRPCMaxBodyBytes       = "rpc-max-body-bytes"
+	configKeyRPCMaxBatchSize       = "rpc-max-batch-size"
+	configKeyDebugRPC              = "debug-rpc"
+	configKeyRPCCORS               = "rpc-cors"
+	configKeyRPCAuthToken          = "rpc-auth-token"
+)
+
+// verbosityFlag is the flag of all the commands overriding log-level with a verbosity
+// from 0 to 5.
+const verbosityFlag = "verbosity"
+
+// signerKeyEnv is the environment variable the signer key is read from when neither
+// signer-key nor signer-key-file is set.
+const signerKeyEnv = "COMPACT_CHAIN_SIGNER_KEY"
+
+// requiredConfigKeys must be present in a node config file. The signer key is required
+// too, from any of its sources.
+var requiredConfigKeys = []string{configKeyRPCPort, configKeyP2PPort}
+
+var errSignerKeyReadable = errors.New("signer key file is world-readable")
+
+// addStartFlags adds the flags overriding node config file values.
+func addStartFlags(flags *pflag.FlagSet) {
+	flags.String("config", "", "Path to a YAML/TOML node config file")
+	flags.Int(configKeyDifficulty, 0, "Consensus difficulty")
+	flags.Int(configKeyBlockTime, 0, "Block time in seconds")
+	flags.StringSlice(configKeyPeers, nil, "Comma separated list of peer addresses")
+	flags.String(configKeyRPCPort, "", "RPC listen address")
+	flags.String(configKeyP2PPort, "", "P2P listen address")
+	flags.String(configKeySignerKeyFile, "", "File holding the hex signer key, which must not be world-readable")
+	flags.StringSlice(configKeyRPCCORS, nil, "Comma separated list of origins allowed to call the RPC from a browser, * for any")
+	flags.StringArray(configKeyAlloc, nil, "Genesis balance as address:amount, repeat the flag for several accounts")
+}
+
+// addDataDirFlag adds the flag of the directory the node databases are kept in, shared
+// by all the commands.
+func addDataDirFlag(flags *pflag.FlagSet) {
+	flags.String(configKeyDataDir, defaultDataDir, "Directory of the node databases")
+}
+
+// addVerbosityFlag adds the flag of the verbosity of the node logs, shared by all the
+// commands.
+func addVerbosityFlag(flags *pflag.FlagSet) {
+	flags.Int(verbosityFlag, 3, "Verbosity of the node logs from 0 to 5 : silent, error, warn, info, debug or trace, overriding log-level")
+}
+
+// applyVerbosity overrides the log level of the config with the one of --verbosity,
+// when given.
+func applyVerbosity(flags *pflag.FlagSet, cfg *config.Config) error {
+	if flag := flags.Lookup(verbosityFlag); flag == nil || !flag.Changed {
+		return nil
+	}
+
+	verbosity, err := flags.GetInt(verbosityFlag)
+	if err != nil {
+		return err
+	}
+
+	level, err := logger.VerbosityLevel(verbosity)
+	if err != nil {
+		return fmt.Errorf("invalid --%s : %w", verbosityFlag, err)
+	}
+
+	cfg.LogLevel = level
+
+	return nil
+}
+
+// newStartViper returns a viper instance with the node config flags bound, so that
+// flags given on the command line take precedence over config file values.
+func newStartViper(flags *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+
+	for _, key := range []string{configKeyDifficulty, configKeyBlockTime, configKeyPeers, configKeyRPCPort, configKeyP2PPort, configKeySignerKeyFile, configKeyRPCCORS} {
+		if err := v.BindPFlag(key, flags.Lookup(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	if flag := flags.Lookup(configKeyDataDir); flag != nil {
+		if err := v.BindPFlag(configKeyDataDir, flag); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// allocFlags parses the --alloc flags into genesis balances, by lowercase address.
+// Each address may only be given once and be funded with a positive amount.
+func allocFlags(flags *pflag.FlagSet) (map[string]*big.Int, error) {
+	entries, err := flags.GetStringArray(configKeyAlloc)
+	if err != nil {
+		return nil, err
+	}
+
+	alloc := make(map[string]*big.Int, len(entries))
+
+	for _, entry := range entries {
+		address, amount, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid --%s %s : expected address:amount", configKeyAlloc, entry)
+		}
+
+		if _, err := util.HexToAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid --%s %s : %w", configKeyAlloc, entry, err)
+		}
+
+		balance, ok := new(big.Int).SetString(amount, 10)
+		if !ok || balance.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid --%s %s : amount %s is not a positive integer", configKeyAlloc, entry, amount)
+		}
+
+		address = strings.ToLower(address)
+		if _, ok := alloc[address]; ok {
+			return nil, fmt.Errorf("invalid --%s : duplicate address %s", configKeyAlloc, address)
+		}
+
+		alloc[address] = balance
+	}
+
+	return alloc, nil
+}
+
+// readConfigFile reads the node config file into the given viper instance and
+// builds the node config from it.
+func readConfigFile(v *viper.Viper, path string) (*config.Config, error) {
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	for _, key := range requiredConfigKeys {
+		if !v.IsSet(key) {
+			return nil, fmt.Errorf("missing required field %q in config file %s", key, path)
+		}
+	}
+
+	if !v.IsSet(configKeySignerKey) && !v.IsSet(configKeySignerKeyFile) && os.Getenv(signerKeyEnv) == "" {
+		return nil, fmt.Errorf("missing required field %q in config file %s, or %q or %s", configKeySignerKey, path, configKeySignerKeyFile, signerKeyEnv)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Mine = true
+
+	if v.IsSet(configKeyDataDir) {
+		cfg.DBDir, cfg.StateDBDir = dataDirDBs(v.GetString(configKeyDataDir), "")
+	}
+
+	return applyConfig(v, cfg)
+}
+
+// applyConfig overrides the fields of the given config with the values set in viper.
+func applyConfig(v *viper.Viper, cfg *config.Config) (*config.Config, error) {
+	if v.IsSet(configKeyConsensus) {
+		cfg.ConsensusName = v.GetString(configKeyConsensus)
+	}
+
+	if v.IsSet(configKeyDifficulty) {
+		cfg.ConsensusDifficulty = v.GetInt(configKeyDifficulty)
+	}
+
+	if v.IsSet(configKeyBlockTime) {
+		cfg.BlockTime = v.GetInt(configKeyBlockTime)
+	}
+
+	if v.IsSet(configKeyPeers) {
+		cfg.Peers = v.GetStringSlice(configKeyPeers)
+	}
+
+	if v.IsSet(configKeyRPCPort) {
+		cfg.RPCPort = listenAddr(v.GetString(configKeyRPCPort))
+	}
+
+	if v.IsSet(configKeyP2PPort) {
+		cfg.P2PPort = listenAddr(v.GetString(configKeyP2PPort))
+	}
+
+	if v.IsSet(configKeyMetrics) {
+		cfg.MetricsPort = listenAddr(v.GetString(configKeyMetrics))
+	}
+
+	if v.IsSet(configKeyExplorer) {
+		cfg.ExplorerPort = listenAddr(v.GetString(configKeyExplorer))
+	}
+
+	if v.IsSet(configKeyMine) {
+		cfg.Mine = v.GetBool(configKeyMine)
+	}
+
+	if v.IsSet(configKeyDBDir) {
+		cfg.DBDir = v.GetString(configKeyDBDir)
+	}
+
+	if v.IsSet(configKeyStateDBDir) {
+		cfg.StateDBDir = v.GetString(configKeyStateDBDir)
+	}
+
+	if v.IsSet(configKeyDBBackend) {
+		backend := v.GetString(configKeyDBBackend)
+		if backend != dbstore.BackendLevelDB && backend != dbstore.BackendMemory {
+			return nil, fmt.Errorf("invalid %q : %w %q", configKeyDBBackend, dbstore.ErrUnknownBackend, backend)
+		}
+
+		cfg.DBBackend = backend
+	}
+
+	if v.IsSet(configKeyDBCompress) {
+		compression := v.GetString(configKeyDBCompress)
+		if compression != dbstore.CompressionNone && compression != dbstore.CompressionSnappy {
+			return nil, fmt.Errorf("invalid %q : %w %q", configKeyDBCompress, dbstore.ErrUnknownCompression, compression)
+		}
+
+		cfg.DBCompression = compression
+	}
+
+	if v.IsSet(configKeyNetworkID) {
+		cfg.NetworkID = v.GetUint64(configKeyNetworkID)
+	}
+
+	if v.IsSet(configKeyStateRetention) {
+		cfg.StateRetentionBlocks = v.GetUint64(configKeyStateRetention)
+
+		// Zero keeps the whole history, rather than the default retention of the config.
+		if cfg.StateRetentionBlocks == 0 {
+			cfg.StateRetentionBlocks = math.MaxUint64
+		}
+	}
+
+	if v.IsSet(configKeyBlockGasLimit) {
+		cfg.BlockGasLimit = v.GetUint64(configKeyBlockGasLimit)
+	}
+
+	if v.IsSet(configKeyMinDifficulty) {
+		cfg.MinDifficulty = v.GetUint64(configKeyMinDifficulty)
+	}
+
+	if v.IsSet(configKeyMaxDifficulty) {
+		cfg.MaxDifficulty = v.GetUint64(configKeyMaxDifficulty)
+	}
+
+	if v.IsSet(configKeyMiningThreads) {
+		cfg.MiningThreads = v.GetInt(configKeyMiningThreads)
+	}
+
+	if v.IsSet(configKeyMaxBlockBytes) {
+		cfg.MaxBlockBytes = v.GetInt(configKeyMaxBlockBytes)
+	}
+
+	if v.IsSet(configKeyMaxBlockTxs) {
+		cfg.MaxBlockTxs = v.GetInt(configKeyMaxBlockTxs)
+	}
+
+	if v.IsSet(configKeyMaxTxDataBytes) {
+		cfg.MaxTxDataBytes = v.GetInt(configKeyMaxTxDataBytes)
+	}
+
+	if v.IsSet(configKeyMaxPeerBackoff) {
+		cfg.MaxPeerBackoff = v.GetDuration(configKeyMaxPeerBackoff)
+	}
+
+	if v.IsSet(configKeyMaxPeers) {
+		cfg.MaxPeers = v.GetInt(configKeyMaxPeers)
+	}
+
+	if v.IsSet(configKeyPeerBanDuration) {
+		cfg.PeerBanDuration = v.GetDuration(configKeyPeerBanDuration)
+	}
+
+	if v.IsSet(configKeyPeerDenylist) {
+		cfg.PeerDenylist = v.GetStringSlice(configKeyPeerDenylist)
+	}
+
+	if v.IsSet(configKeyMaxClockDrift) {
+		cfg.MaxClockDrift = v.GetDuration(configKeyMaxClockDrift)
+	}
+
+	if v.IsSet(configKeyFinalityDepth) {
+		cfg.FinalityDepth = v.GetUint64(configKeyFinalityDepth)
+	}
+
+	if v.IsSet(configKeyMaxReorgDepth) {
+		cfg.MaxReorgDepth = v.GetUint64(configKeyMaxReorgDepth)
+	}
+
+	if v.IsSet(configKeyTxPoolLifetime) {
+		cfg.TxPoolLifetime = v.GetDuration(configKeyTxPoolLifetime)
+	}
+
+	if v.IsSet(configKeyMaxTxPerSender) {
+		cfg.MaxTxPerSender = v.GetInt(configKeyMaxTxPerSender)
+	}
+
+	if v.IsSet(configKeyLegacyTxBlock) {
+		cfg.LegacyTxBlock = v.GetUint64(configKeyLegacyTxBlock)
+	}
+
+	if v.IsSet(configKeyLegacyHashBlock) {
+		cfg.LegacyHashBlock = v.GetUint64(configKeyLegacyHashBlock)
+	}
+
+	if v.IsSet(configKeyLegacyStateRoot) {
+		cfg.LegacyStateRootBlock = v.GetUint64(configKeyLegacyStateRoot)
+	}
+
+	if v.IsSet(configKeyMinPeers) {
+		cfg.MinPeersForHealthy = v.GetInt(configKeyMinPeers)
+	}
+
+	if v.IsSet(configKeyRPCRateLimit) {
+		cfg.RPCRateLimit = v.GetFloat64(configKeyRPCRateLimit)
+	}
+
+	if v.IsSet(configKeyRPCRateBurst) {
+		cfg.RPCRateBurst = v.GetInt(configKeyRPCRateBurst)
+	}
+
+	if v.IsSet(configKeyRPCRateLimitWhitelist) {
+		cfg.RPCRateLimitWhitelist = v.GetStringSlice(configKeyRPCRateLimitWhitelist)
+	}
+
+	if cfg.RPCRateLimit < 0 || cfg.RPCRateBurst < 0 {
+		return nil, fmt.Errorf("invalid %q : the rate and burst must be positive", configKeyRPCRateLimit)
+	}
+
+	if v.IsSet(configKeyRPCReadTimeout) {
+		cfg.RPCReadTimeout = v.GetDuration(configKeyRPCReadTimeout)
+	}
+
+	if v.IsSet(configKeyRPCWriteTimeout) {
+		cfg.RPCWriteTimeout = v.GetDuration(configKeyRPCWriteTimeout)
+	}
+
+	if v.IsSet(configKeyRPCMaxBodyBytes) {
+		cfg.RPCMaxBodyBytes = v.GetInt64(configKeyRPCMaxBodyBytes)
+	}
+
+	if v.IsSet(configKeyRPCMaxBatchSize) {
+		cfg.RPCMaxBatchSize = v.GetInt(configKeyRPCMaxBatchSize)
+	}
+
+	if v.IsSet(configKeyRPCCORS) {
+		cfg.RPCCORSOrigins = v.GetStringSlice(configKeyRPCCORS)
+	}
+
+	if v.IsSet(configKeyRPCAuthToken) {
+		cfg.RPCAuthToken = v.GetString(configKeyRPCAuthToken)
+	}
+
+	if v.IsSet(configKeyDebugRPC) {
+		cfg.DebugRPC = v.GetBool(configKeyDebugRPC)
+	}
+
+	if v.IsSet(configKeyLogLevel) {
+		level := v.GetString(configKeyLogLevel)
+		if _, err := logger.ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("invalid %q : %w", configKeyLogLevel, err)
+		}
+
+		cfg.LogLevel = level
+	}
+
+	if v.IsSet(configKeySignatureScheme) {
+		cfg.SignatureScheme = v.GetString(configKeySignatureScheme)
+	}
+
+	scheme, err := util.SchemeByName(cfg.SignatureScheme)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %q : %w", configKeySignatureScheme, err)
+	}
+
+	signerKey, source, err := signerKeyHex(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if signerKey != "" {
+		key, err := scheme.HexToPrivateKey(strings.TrimPrefix(signerKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", source, err)
+		}
+
+		cfg.SignerPrivateKey = key
+	}
+
+	if v.IsSet(configKeyCoinbase) {
+		coinbase := v.GetString(configKeyCoinbase)
+		if _, err := util.HexToAddress(coinbase); err != nil {
+			return nil, fmt.Errorf("invalid %q : %w", configKeyCoinbase, err)
+		}
+
+		cfg.CoinbaseAddress = strings.ToLower(coinbase)
+	}
+
+	if v.IsSet(configKeyFeeSink) {
+		sink := v.GetString(configKeyFeeSink)
+		if _, err := util.HexToAddress(sink); err != nil {
+			return nil, fmt.Errorf("invalid %q : %w", configKeyFeeSink, err)
+		}
+
+		cfg.BaseFeeSink = strings.ToLower(sink)
+	}
+
+	if v.IsSet(configKeyGenesisFile) {
+		cfg.GenesisFile = v.GetString(configKeyGenesisFile)
+
+		if _, err := core.LoadGenesis(cfg.GenesisFile); err != nil {
+			return nil, fmt.Errorf("invalid %q : %w", configKeyGenesisFile, err)
+		}
+	}
+
+	if v.IsSet(configKeyBlockReward) {
+		reward, ok := new(big.Int).SetString(v.GetString(configKeyBlockReward), 10)
+		if !ok || reward.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %q : must be a positive integer", configKeyBlockReward)
+		}
+
+		cfg.BlockReward = reward
+	}
+
+	if v.IsSet(configKeyAlloc) {
+		alloc := make(map[string]*big.Int)
+
+		for address, amount := range v.GetStringMapString(configKeyAlloc) {
+			if _, err := util.HexToAddress(address); err != nil {
+				return nil, fmt.Errorf("invalid %q : %w", configKeyAlloc, err)
+			}
+
+			balance, ok := new(big.Int).SetString(amount, 10)
+			if !ok || balance.Sign() < 0 {
+				return nil, fmt.Errorf("invalid %q : balance %s of %s is not a positive integer", configKeyAlloc, amount, address)
+			}
+
+			alloc[strings.ToLower(address)] = balance
+		}
+
+		cfg.BalanceAlloc = alloc
+	}
+
+	if cfg.Mine && cfg.SignerPrivateKey == nil {
+		return nil, fmt.Errorf("missing required field %q to mine", configKeySignerKey)
+	}
+
+	return cfg, nil
+}
+
+// signerKeyHex returns the hex signer key set by signer-key, read from signer-key-file or
+// else from the COMPACT_CHAIN_SIGNER_KEY environment variable, along with its source for
+// errors. It is empty when none of them is set.
+func signerKeyHex(v *viper.Viper) (string, string, error) {
+	if v.IsSet(configKeySignerKey) && v.IsSet(configKeySignerKeyFile) {
+		return "", "", fmt.Errorf("only one of %q and %q may be set", configKeySignerKey, configKeySignerKeyFile)
+	}
+
+	if v.IsSet(configKeySignerKey) {
+		return v.GetString(configKeySignerKey), fmt.Sprintf("%q", configKeySignerKey), nil
+	}
+
+	if v.IsSet(configKeySignerKeyFile) {
+		key, err := readSignerKeyFile(v.GetString(configKeySignerKeyFile))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %q : %w", configKeySignerKeyFile, err)
+		}
+
+		return key, fmt.Sprintf("%q", configKeySignerKeyFile), nil
+	}
+
+	return os.Getenv(signerKeyEnv), signerKeyEnv, nil
+}
+
+// readSignerKeyFile reads the hex key held by the file, surrounding whitespace aside.
+// World readable files are refused, as they would leak the key to every user of the
+// machine.
+func readSignerKeyFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if perm := info.Mode().Perm(); perm&0o004 != 0 {
+		return "", fmt.Errorf("%w : %s has mode %s, restrict it with chmod 600", errSignerKeyReadable, path, perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// listenAddr turns a bare port into a listen address.
+func listenAddr(addr string) string {
+	if addr != "" && !strings.Contains(addr, ":") {
+		return ":" + addr
+	}
+
+	return addr
+}
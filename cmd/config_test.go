@@ -0,0 +1,522 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleConfig = `
+consensus: pow
+difficulty: 18
+block-time: 6
+peers:
+  - localhost:60601
+  - localhost:60602
+rpc-port: ":17115"
+p2p-port: "60605"
+metrics-port: "9100"
+explorer-port: "8080"
+signer-key: c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6
+coinbase: "0x5E1BC6A626A0E1B6A7C4A392A1CE93B5D2A6E9F2"
+base-fee-sink: "0x00000000000000000000000000000000000000FE"
+db-dir: /tmp/compact-chain/db
+state-db-dir: /tmp/compact-chain/statedb
+db-backend: memory
+db-compression: snappy
+network-id: 7
+log-level: warn
+state-retention-blocks: 64
+block-gas-limit: 105000
+min-difficulty: 12
+max-difficulty: 24
+mining-threads: 4
+max-block-bytes: 524288
+max-block-txs: 500
+max-tx-data-bytes: 1024
+max-peer-backoff: 10s
+max-peers: 8
+peer-ban-duration: 10m
+peer-denylist:
+  - 10.0.0.1
+  - localhost:60609
+max-clock-drift: 5s
+finality-depth: 12
+max-reorg-depth: 64
+legacy-tx-block: 1000
+legacy-hash-block: 500
+legacy-state-root-block: 400
+txpool-lifetime: 3h
+max-tx-per-sender: 16
+min-peers-for-healthy: 2
+block-reward: "5000000000000000000"
+rpc-rate-limit: 2.5
+rpc-rate-burst: 10
+rpc-rate-limit-whitelist: ["localhost", "10.0.0.1"]
+rpc-read-timeout: 10s
+rpc-write-timeout: 1m
+rpc-max-body-bytes: 1048576
+rpc-max-batch-size: 20
+debug-rpc: true
+rpc-cors: ["https://explorer.example.org"]
+rpc-auth-token: s3cret
+alloc:
+  "0xa52c981eee8687b5e4afd69aa5006548c24d7685": "1000000000000000000000"
+`
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func parseStartFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+	addDataDirFlag(flags)
+	addVerbosityFlag(flags)
+	addStartFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return flags
+}
+
+func TestReadConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "node.yaml", sampleConfig)
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance, _ := new(big.Int).SetString("1000000000000000000000", 10)
+
+	assert.Equal(t, "pow", cfg.ConsensusName)
+	assert.Equal(t, 18, cfg.ConsensusDifficulty)
+	assert.Equal(t, 6, cfg.BlockTime)
+	assert.Equal(t, []string{"localhost:60601", "localhost:60602"}, cfg.Peers)
+	assert.Equal(t, ":17115", cfg.RPCPort)
+	assert.Equal(t, ":60605", cfg.P2PPort)
+	assert.Equal(t, ":9100", cfg.MetricsPort)
+	assert.Equal(t, ":8080", cfg.ExplorerPort)
+	assert.Equal(t, "/tmp/compact-chain/db", cfg.DBDir)
+	assert.Equal(t, "/tmp/compact-chain/statedb", cfg.StateDBDir)
+	assert.Equal(t, "memory", cfg.DBBackend)
+	assert.Equal(t, "snappy", cfg.DBCompression)
+	assert.Equal(t, uint64(7), cfg.NetworkID)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, uint64(64), cfg.StateRetentionBlocks)
+	assert.Equal(t, uint64(105000), cfg.BlockGasLimit)
+	assert.Equal(t, uint64(12), cfg.MinDifficulty)
+	assert.Equal(t, uint64(24), cfg.MaxDifficulty)
+	assert.Equal(t, 4, cfg.MiningThreads)
+	assert.Equal(t, 524288, cfg.MaxBlockBytes)
+	assert.Equal(t, 500, cfg.MaxBlockTxs)
+	assert.Equal(t, 1024, cfg.MaxTxDataBytes)
+	assert.Equal(t, 10*time.Second, cfg.MaxPeerBackoff)
+	assert.Equal(t, 8, cfg.MaxPeers)
+	assert.Equal(t, 10*time.Minute, cfg.PeerBanDuration)
+	assert.Equal(t, []string{"10.0.0.1", "localhost:60609"}, cfg.PeerDenylist)
+	assert.Equal(t, 5*time.Second, cfg.MaxClockDrift)
+	assert.Equal(t, uint64(12), cfg.FinalityDepth)
+	assert.Equal(t, uint64(64), cfg.MaxReorgDepth)
+	assert.Equal(t, uint64(1000), cfg.LegacyTxBlock)
+	assert.Equal(t, uint64(500), cfg.LegacyHashBlock)
+	assert.Equal(t, uint64(400), cfg.LegacyStateRootBlock)
+	assert.Equal(t, 3*time.Hour, cfg.TxPoolLifetime)
+	assert.Equal(t, 16, cfg.MaxTxPerSender)
+	assert.Equal(t, 2, cfg.MinPeersForHealthy)
+	assert.Equal(t, "5000000000000000000", cfg.BlockReward.String())
+	assert.Equal(t, 2.5, cfg.RPCRateLimit)
+	assert.Equal(t, 10, cfg.RPCRateBurst)
+	assert.Equal(t, []string{"localhost", "10.0.0.1"}, cfg.RPCRateLimitWhitelist)
+	assert.Equal(t, 10*time.Second, cfg.RPCReadTimeout)
+	assert.Equal(t, time.Minute, cfg.RPCWriteTimeout)
+	assert.Equal(t, int64(1048576), cfg.RPCMaxBodyBytes)
+	assert.Equal(t, 20, cfg.RPCMaxBatchSize)
+	assert.True(t, cfg.DebugRPC)
+	assert.Equal(t, []string{"https://explorer.example.org"}, cfg.RPCCORSOrigins)
+	assert.Equal(t, "s3cret", cfg.RPCAuthToken)
+	assert.Equal(t, map[string]*big.Int{"0xa52c981eee8687b5e4afd69aa5006548c24d7685": balance}, cfg.BalanceAlloc)
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", util.NewUnlockedAccount(cfg.SignerPrivateKey).Address().String())
+	assert.Equal(t, "0x5e1bc6a626a0e1b6a7c4a392a1ce93b5d2a6e9f2", cfg.CoinbaseAddress)
+	assert.Equal(t, "0x00000000000000000000000000000000000000fe", cfg.BaseFeeSink)
+	assert.True(t, cfg.Mine)
+}
+
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "node.yaml", sampleConfig)
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", path, "--difficulty", "12", "--rpc-port", ":17116", "--rpc-cors", "*"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 12, cfg.ConsensusDifficulty)
+	assert.Equal(t, ":17116", cfg.RPCPort)
+	assert.Equal(t, []string{"*"}, cfg.RPCCORSOrigins)
+	assert.Equal(t, 6, cfg.BlockTime)
+}
+
+func TestConfigFileMissingField(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "node.toml", "rpc-port = \":17115\"\np2p-port = \":60605\"\n")
+
+	_, err := startConfig(parseStartFlags(t, "--config", path), nil)
+	assert.ErrorContains(t, err, `missing required field "signer-key"`)
+}
+
+func TestConfigSignerKeyFile(t *testing.T) {
+	t.Parallel()
+
+	content := "rpc-port: \":17115\"\np2p-port: \":60605\"\n"
+	path := writeConfigFile(t, "node.yaml", content)
+	keyFile := writeConfigFile(t, "signer.key", "0xc3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\n")
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", path, "--signer-key-file", keyFile), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", util.NewUnlockedAccount(cfg.SignerPrivateKey).Address().String())
+
+	// The file may also be set in the config file, but not along with the key itself.
+	cfg, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content+"signer-key-file: "+keyFile+"\n")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", util.NewUnlockedAccount(cfg.SignerPrivateKey).Address().String())
+
+	_, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", sampleConfig), "--signer-key-file", keyFile), nil)
+	assert.ErrorContains(t, err, `only one of "signer-key" and "signer-key-file" may be set`)
+
+	// World readable files are refused.
+	assert.NoError(t, os.Chmod(keyFile, 0644))
+
+	_, err = startConfig(parseStartFlags(t, "--config", path, "--signer-key-file", keyFile), nil)
+	assert.ErrorIs(t, err, errSignerKeyReadable)
+}
+
+// nolint : paralleltest
+func TestConfigSignerKeyEnv(t *testing.T) {
+	t.Setenv(signerKeyEnv, "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", "rpc-port: \":17115\"\np2p-port: \":60605\"\n")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", util.NewUnlockedAccount(cfg.SignerPrivateKey).Address().String())
+
+	// The config file takes precedence over the environment.
+	cfg, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", strings.Replace(sampleConfig, "8738a6", "8738a1", 1))), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", util.NewUnlockedAccount(cfg.SignerPrivateKey).Address().String())
+}
+
+func TestConfigSignatureScheme(t *testing.T) {
+	t.Parallel()
+
+	content := "rpc-port: \":17115\"\np2p-port: \":60605\"\nsignature-scheme: ed25519\nsigner-key: c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\n"
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, util.SchemeEd25519, cfg.SignatureScheme)
+	assert.Equal(t, util.SchemeEd25519, util.NewUnlockedAccount(cfg.SignerPrivateKey).Scheme().Name())
+
+	_, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", strings.Replace(content, "ed25519", "rsa", 1))), nil)
+	assert.ErrorIs(t, err, util.ErrUnknownScheme)
+}
+
+func TestConfigInvalidCoinbase(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "node.yaml", strings.Replace(sampleConfig, "0x5E1BC6A626A0E1B6A7C4A392A1CE93B5D2A6E9F2", "0x5e1bc6a626", 1))
+
+	_, err := startConfig(parseStartFlags(t, "--config", path), nil)
+	assert.ErrorContains(t, err, `invalid "coinbase" : address 0x5e1bc6a626 must be 20 bytes, got 5`)
+}
+
+func TestConfigStateRetention(t *testing.T) {
+	t.Parallel()
+
+	content := "rpc-port: \":17115\"\np2p-port: \":60605\"\nsigner-key: \"0xc3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\"\n"
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(128), cfg.StateRetentionBlocks)
+
+	// Zero keeps the whole history.
+	cfg, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content+"state-retention-blocks: 0\n")), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), cfg.StateRetentionBlocks)
+}
+
+func TestConfigGenesisFile(t *testing.T) {
+	t.Parallel()
+
+	genesis := writeConfigFile(t, "genesis.json", `{"chainId": 7, "difficulty": 8, "alloc": {"0xa52c981eee8687b5e4afd69aa5006548c24d7685": "1000"}}`)
+	content := "rpc-port: \":17115\"\np2p-port: \":60605\"\nsigner-key: c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\ngenesis-file: " + genesis + "\n"
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, genesis, cfg.GenesisFile)
+
+	invalid := writeConfigFile(t, "invalid.json", `{"chainId": 7}`)
+
+	_, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", strings.Replace(content, genesis, invalid, 1))), nil)
+	assert.ErrorIs(t, err, core.ErrInvalidGenesis)
+}
+
+// nolint : tparallel
+func TestStartDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+
+	cfg, err := startConfig(parseStartFlags(t, "--datadir", dataDir, "--rpc-port", ":1764", "--p2p-port", ":6114"), []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, filepath.Join(dataDir, "db1"), cfg.DBDir)
+	assert.Equal(t, filepath.Join(dataDir, "statedb1"), cfg.StateDBDir)
+
+	cfg.Peers = nil
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.Close()
+
+	for _, dir := range []string{cfg.DBDir, cfg.StateDBDir} {
+		info, err := os.Stat(dir)
+		assert.NoError(t, err)
+		assert.True(t, info.IsDir())
+	}
+
+	// The node id picks the directories without --datadir.
+	cfg, err = startConfig(parseStartFlags(t), []string{"2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, filepath.Join(defaultDataDir, "db2"), cfg.DBDir)
+
+	// A config file keeps the databases in the data directory, unless its db-dir is set.
+	content := "rpc-port: \":17115\"\np2p-port: \":60605\"\nsigner-key: c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\n"
+
+	cfg, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content), "--datadir", dataDir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, filepath.Join(dataDir, "db"), cfg.DBDir)
+	assert.Equal(t, filepath.Join(dataDir, "statedb"), cfg.StateDBDir)
+
+	cfg, err = startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", content+"db-dir: /tmp/compact-chain/db\n"), "--datadir", dataDir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "/tmp/compact-chain/db", cfg.DBDir)
+	assert.Equal(t, filepath.Join(dataDir, "statedb"), cfg.StateDBDir)
+}
+
+// nolint : tparallel
+func TestReloadConfigOnSignal(t *testing.T) {
+	content := fmt.Sprintf("rpc-port: \":1772\"\np2p-port: \":6122\"\nsigner-key: c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6\ndifficulty: 8\ndb-dir: %s\nstate-db-dir: %s\n", t.TempDir(), t.TempDir())
+	path := writeConfigFile(t, "node.yaml", content)
+	flags := parseStartFlags(t, "--config", path)
+
+	cfg, err := startConfig(flags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	peerCfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "peer.yaml", content), "--rpc-port", ":1773", "--p2p-port", ":6123"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerCfg.DBDir, peerCfg.StateDBDir = t.TempDir(), t.TempDir()
+
+	peer, err := core.NewBlockchain(peerCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer peer.Close()
+
+	chain.ReloadOnSignal(func() (*config.Config, error) {
+		return startConfig(flags, nil)
+	})
+
+	// Add a peer, stop mining, raise the verbosity and try to change the chain id.
+	content += "peers: [\"localhost:6123\"]\nmine: false\nlog-level: debug\nnetwork-id: 9\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new peer gets dialed and handshaked with.
+	assert.Eventually(t, func() bool { return chain.P2PServer.Downloader.ConnectedPeers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "localhost:6123", chain.P2PServer.PeerInfos()[0].Addr)
+
+	assert.Eventually(t, func() bool { return !chain.Miner.Mining() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "debug", chain.Config.LogLevel)
+	assert.Equal(t, uint64(1), chain.Config.NetworkID)
+
+	// Removed peers are disconnected.
+	if err := os.WriteFile(path, []byte(strings.Replace(content, "localhost:6123", "localhost:6124", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool {
+		peers := chain.P2PServer.Downloader.GetPeers()
+		return len(peers) == 1 && peers[0].Addr == "localhost:6124"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, chain.P2PServer.Downloader.ConnectedPeers())
+}
+
+func TestStartAllocFlags(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "node.yaml", sampleConfig)
+
+	cfg, err := startConfig(parseStartFlags(t, "--config", path,
+		"--alloc", "0xA52C981EEE8687B5E4AFD69AA5006548C24D7685:1000",
+		"--alloc", "0x5e1bc6a626a0e1b6a7c4a392a1ce93b5d2a6e9f2:2500"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]*big.Int{
+		"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1000),
+		"0x5e1bc6a626a0e1b6a7c4a392a1ce93b5d2a6e9f2": big.NewInt(2500),
+	}, cfg.BalanceAlloc)
+
+	// Both accounts are funded at genesis.
+	db, err := dbstore.NewMemoryDBInstance()
+	assert.NoError(t, err)
+
+	core.CreateGenesisBlock(cfg.BalanceAlloc, db)
+
+	for address, amount := range map[string]int64{"0xa52c981eee8687b5e4afd69aa5006548c24d7685": 1000, "0x5e1bc6a626a0e1b6a7c4a392a1ce93b5d2a6e9f2": 2500} {
+		balance, err := db.Get(dbstore.PrefixKey(dbstore.BalanceKey, address))
+		assert.NoError(t, err)
+		assert.Equal(t, amount, new(big.Int).SetBytes(balance).Int64())
+	}
+
+	for _, allocs := range [][]string{
+		{"0xa52c981eee8687b5e4afd69aa5006548c24d7685:1000", "0xa52c981eee8687b5e4afd69aa5006548c24d7685:2000"},
+		{"0xa52c981eee8687b5e4afd69aa5006548c24d7685"},
+		{"0xa52c98:1000"},
+		{"0xa52c981eee8687b5e4afd69aa5006548c24d7685:0"},
+		{"0xa52c981eee8687b5e4afd69aa5006548c24d7685:-5"},
+	} {
+		args := []string{"--config", path}
+		for _, alloc := range allocs {
+			args = append(args, "--alloc", alloc)
+		}
+
+		_, err := startConfig(parseStartFlags(t, args...), nil)
+		assert.Error(t, err, allocs)
+	}
+}
+
+// nolint : tparallel
+func TestStartVerbosity(t *testing.T) {
+	// The verbosity overrides the log level of the config file.
+	cfg, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", sampleConfig), "--verbosity", "4"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "debug", cfg.LogLevel)
+
+	for _, verbosity := range []string{"-1", "6"} {
+		_, err := startConfig(parseStartFlags(t, "--config", writeConfigFile(t, "node.yaml", sampleConfig), "--verbosity", verbosity), nil)
+		assert.Error(t, err, verbosity)
+	}
+
+	cfg, err = startConfig(parseStartFlags(t, "--datadir", t.TempDir(), "--rpc-port", ":1816", "--p2p-port", ":6168", "--verbosity", "1"), []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "error", cfg.LogLevel)
+
+	var logs strings.Builder
+
+	cfg.Peers = nil
+	cfg.LogOutput = &logs
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	// Only error records are logged.
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), cfg.SignerPrivateKey))
+	chain.Logger.Warn("Invalid block seal", "number", 2)
+	chain.Logger.Error("Failed to prune state", "number", 1)
+
+	assert.NotContains(t, logs.String(), "level=INFO")
+	assert.NotContains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), `level=ERROR msg="Failed to prune state" number=1`)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseDemoFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("demo", pflag.ContinueOnError)
+	addDemoFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return flags
+}
+
+func TestDemoFlags(t *testing.T) {
+	t.Parallel()
+
+	// The defaults are kept without flags.
+	difficulty, blocks, err := demoFlags(parseDemoFlags(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 16, difficulty)
+	assert.Equal(t, 10, blocks)
+
+	difficulty, blocks, err = demoFlags(parseDemoFlags(t, "--difficulty", "12", "--blocks", "3"))
+	assert.NoError(t, err)
+	assert.Equal(t, 12, difficulty)
+	assert.Equal(t, 3, blocks)
+
+	for _, args := range [][]string{
+		{"--difficulty", "0"},
+		{"--difficulty", "29"},
+		{"--blocks", "0"},
+		{"--blocks", "-1"},
+		{"--blocks", "1001"},
+	} {
+		_, _, err := demoFlags(parseDemoFlags(t, args...))
+		assert.Error(t, err, args)
+	}
+}
+
+// nolint : tparallel
+func TestRunDemo(t *testing.T) {
+	difficulty, blocks, err := demoFlags(parseDemoFlags(t, "--blocks", "3", "--difficulty", "8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := demoConfig(t.TempDir(), difficulty)
+	cfg.RPCPort = ":1804"
+	cfg.P2PPort = ":6156"
+	cfg.Peers = nil
+	cfg.BlockTime = 0
+
+	chain, err := runDemo(cfg, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	assert.Equal(t, int64(3), chain.LastBlock.Number.Int64())
+
+	for n := int64(1); n <= 3; n++ {
+		block, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(n))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(8), block.Difficulty)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a range of blocks of the chain to a file",
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+
+		dbDir, _ := flags.GetString("db")
+		if dbDir == "" {
+			dbDir, _ = dataDirDBs(viper.GetString(configKeyDataDir), "")
+		}
+		from, _ := flags.GetUint64("from")
+		to, _ := flags.GetUint64("to")
+		out, _ := flags.GetString("out")
+
+		if err := exportChain(dbDir, from, to, out); err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Println("Exported blocks", from, "to", to, "to", out)
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("db", "", "Blockchain DB directory of the node, defaults to the one of --datadir")
+	exportCmd.Flags().Uint64("from", 0, "First block to export")
+	exportCmd.Flags().Uint64("to", 0, "Last block to export")
+	exportCmd.Flags().String("out", "", "File to write the blocks to")
+
+	exportCmd.MarkFlagRequired("to")
+	exportCmd.MarkFlagRequired("out")
+}
+
+func exportChain(dbDir string, from uint64, to uint64, out string) error {
+	if _, err := os.Stat(dbDir); err != nil {
+		return fmt.Errorf("no chain found in %s : %w", dbDir, err)
+	}
+
+	db, err := dbstore.NewDBInstance(dbDir)
+	if err != nil {
+		return err
+	}
+
+	// nolint : errcheck
+	defer db.Close()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+
+	if err := core.ExportChain(dbstore.NewBlockchainDB(db), w, from, to); err != nil {
+		// nolint : errcheck
+		f.Close()
+		// nolint : errcheck
+		os.Remove(out)
+
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		// nolint : errcheck
+		f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [node id]",
+	Short: "Import blocks exported to a file into the chain of a node",
+	Run: func(cmd *cobra.Command, args []string) {
+		in, _ := cmd.Flags().GetString("in")
+
+		cfg, err := startConfig(cmd.Flags(), args)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		// Blocks only come from the file while importing.
+		cfg.Peers = nil
+
+		f, err := os.Open(in)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		// nolint : errcheck
+		defer f.Close()
+
+		chain, err := core.NewBlockchain(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		imported, skipped, err := chain.ImportChain(bufio.NewReader(f))
+
+		chain.Close()
+
+		fmt.Println("Imported", imported, "blocks, skipped", skipped, "known blocks")
+
+		if err != nil {
+			exitWithError(err)
+		}
+
+		fmt.Println("LastNumber : ", chain.LastBlock.Number, "LastHash : ", chain.LastBlock.DeriveHash().String())
+	},
+}
+
+func init() {
+	addStartFlags(importCmd.Flags())
+	importCmd.Flags().String("in", "", "File to read the blocks from")
+
+	importCmd.MarkFlagRequired("in")
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"text/tabwriter"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var errBlockNotFound = errors.New("block not found")
+
+var inspectBlockCmd = &cobra.Command{
+	Use:   "inspect-block",
+	Short: "Print a block read from the blockchain DB, without a running node",
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+
+		dbDir, _ := flags.GetString("db")
+		if dbDir == "" {
+			dbDir, _ = dataDirDBs(viper.GetString(configKeyDataDir), "")
+		}
+		hash, _ := flags.GetString("hash")
+
+		var number *big.Int
+
+		if flags.Changed("number") {
+			n, _ := flags.GetUint64("number")
+			number = new(big.Int).SetUint64(n)
+		}
+
+		if (number == nil) == (hash == "") {
+			exitWithError(errors.New("exactly one of --number and --hash is required"))
+		}
+
+		block, err := inspectBlock(dbDir, number, hash)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		printBlock(os.Stdout, block)
+	},
+}
+
+func init() {
+	inspectBlockCmd.Flags().String("db", "", "Blockchain DB directory of the node, defaults to the one of --datadir")
+	inspectBlockCmd.Flags().Uint64("number", 0, "Number of the canonical block to print")
+	inspectBlockCmd.Flags().String("hash", "", "0x-prefixed hex hash of the block to print, on the canonical chain or not")
+}
+
+// inspectBlock reads the block with the given number, or else the given hash, from the
+// blockchain DB. The DB is opened read-only.
+func inspectBlock(dbDir string, number *big.Int, hash string) (*types.Block, error) {
+	db, err := dbstore.OpenReadOnlyDBInstance(dbDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s read-only, it must exist and not be open in a running node : %w", dbDir, err)
+	}
+
+	// nolint : errcheck
+	defer db.Close()
+
+	blockchainDB := dbstore.NewBlockchainDB(db)
+
+	var block *types.Block
+
+	if number != nil {
+		block, err = blockchainDB.GetBlockByNumber(number)
+	} else {
+		h, herr := util.HexToHash(hash)
+		if herr != nil {
+			return nil, herr
+		}
+
+		block, err = blockchainDB.GetBlockByHash(h)
+	}
+
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, errBlockNotFound
+	}
+
+	return block, err
+}
+
+// printBlock prints the header fields of the block followed by its transactions, one
+// per row.
+func printBlock(w io.Writer, block *types.Block) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	optional := func(v fmt.Stringer, isNil bool) string {
+		if isNil {
+			return "-"
+		}
+
+		return v.String()
+	}
+
+	signer := "-"
+	if block.PublicKey != nil {
+		signer = block.PublicKey.Address().String()
+	}
+
+	fmt.Fprintf(tw, "Number\t%s\n", block.Number)
+	fmt.Fprintf(tw, "Hash\t%s\n", block.DeriveHash().String())
+	fmt.Fprintf(tw, "Parent hash\t%s\n", block.ParentHash.String())
+	fmt.Fprintf(tw, "Timestamp\t%d\n", block.Timestamp)
+	fmt.Fprintf(tw, "Difficulty\t%d\n", block.Difficulty)
+	fmt.Fprintf(tw, "Base fee\t%s\n", optional(block.BaseFee, block.BaseFee == nil))
+	fmt.Fprintf(tw, "Gas used\t%d\n", block.GasUsed())
+	fmt.Fprintf(tw, "Nonce\t%s\n", block.Nonce)
+	fmt.Fprintf(tw, "Extra data\t%q\n", block.ExtraData)
+	fmt.Fprintf(tw, "Transactions root\t%s\n", block.TxRootHash().String())
+	fmt.Fprintf(tw, "State root\t%s\n", optional(block.StateRoot, block.StateRoot == nil))
+	fmt.Fprintf(tw, "Coinbase\t%s\n", optional(block.Coinbase(), block.Coinbase() == nil))
+	fmt.Fprintf(tw, "Signer\t%s\n", signer)
+	fmt.Fprintf(tw, "Transactions\t%d\n", len(block.Transactions))
+
+	// nolint : errcheck
+	tw.Flush()
+
+	if len(block.Transactions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "INDEX\tHASH\tFROM\tTO\tVALUE\tFEE\tNONCE\tMSG\tDATA")
+
+	for i, tx := range block.Transactions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%q\t0x%x\n", i, tx.Hash().String(), tx.From.String(), tx.To.String(), tx.Value, tx.EffectiveFee(block.BaseFee), tx.Nonce, tx.Msg, tx.Data)
+	}
+
+	// nolint : errcheck
+	tw.Flush()
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestBlock writes a signed block with a transaction to a new blockchain DB and
+// returns the DB directory and the block.
+func writeTestBlock(t *testing.T) (string, *types.Block) {
+	t.Helper()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := &types.Transaction{
+		From:  *ua.Address(),
+		To:    *util.BytesToAddress([]byte{0x01}),
+		Msg:   []byte("hello"),
+		Data:  []byte{0xca, 0xfe},
+		Fee:   big.NewInt(300),
+		Value: big.NewInt(1000),
+		Nonce: big.NewInt(0),
+	}
+	tx.Sign(ua)
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte("Block 1"))
+	block.Timestamp = 1700000000
+	block.Difficulty = 8
+	block.Transactions = []*types.Transaction{tx}
+	block.SetTxRoot()
+	block.StateRoot = util.HashData([]byte("state"))
+	block.Sign(util.NewUnlockedAccount(util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")))
+
+	dbDir := t.TempDir()
+
+	db, err := dbstore.NewDBInstance(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := db.NewBatch()
+	batch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, block.DeriveHash().String())), block.Serialize())
+	batch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())), block.DeriveHash().Bytes())
+	assert.NoError(t, db.WriteBatch(batch))
+	assert.NoError(t, db.Close())
+
+	return dbDir, block
+}
+
+func TestInspectBlock(t *testing.T) {
+	t.Parallel()
+
+	dbDir, block := writeTestBlock(t)
+	hash := block.DeriveHash().String()
+
+	byNumber, err := inspectBlock(dbDir, big.NewInt(1), "")
+	assert.NoError(t, err)
+	assert.Equal(t, hash, byNumber.DeriveHash().String())
+
+	byHash, err := inspectBlock(dbDir, nil, hash)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, byHash.DeriveHash().String())
+
+	var out bytes.Buffer
+
+	printBlock(&out, byNumber)
+
+	for _, line := range []string{
+		"Number             1\n",
+		"Hash               " + hash + "\n",
+		"Parent hash        " + block.ParentHash.String() + "\n",
+		"Extra data         \"Block 1\"\n",
+		"State root         " + block.StateRoot.String() + "\n",
+		"Transactions       1\n",
+	} {
+		assert.Contains(t, out.String(), line)
+	}
+
+	tx := block.Transactions[0]
+	assert.Contains(t, out.String(), tx.Hash().String()+"  "+tx.From.String()+"  "+tx.To.String()+"  1000   300  0      \"hello\"  0xcafe\n")
+
+	_, err = inspectBlock(dbDir, big.NewInt(2), "")
+	assert.ErrorIs(t, err, errBlockNotFound)
+
+	_, err = inspectBlock(dbDir, nil, "0x1234")
+	assert.Error(t, err)
+}
+
+func TestInspectBlockReadOnly(t *testing.T) {
+	t.Parallel()
+
+	dbDir, _ := writeTestBlock(t)
+
+	// Readers share the DB.
+	reader, err := dbstore.OpenReadOnlyDBInstance(dbDir)
+	assert.NoError(t, err)
+
+	_, err = inspectBlock(dbDir, big.NewInt(1), "")
+	assert.NoError(t, err)
+
+	assert.Error(t, reader.Put("key", []byte("value")))
+	assert.NoError(t, reader.Close())
+
+	// A node holds an exclusive lock on its DB.
+	db, err := dbstore.NewDBInstance(dbDir)
+	assert.NoError(t, err)
+
+	// nolint : errcheck
+	defer db.Close()
+
+	_, err = inspectBlock(dbDir, big.NewInt(1), "")
+	assert.Error(t, err)
+
+	// Missing DBs aren't created.
+	_, err = inspectBlock(t.TempDir()+"/missing", big.NewInt(1), "")
+	assert.Error(t, err)
+}
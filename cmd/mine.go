@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// mineSignerKey signs the blocks mined on the chain of --datadir when no signer key is
+// configured. It is the key of the demo chain, only fit for fixtures.
+const mineSignerKey = "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1"
+
+var mineCmd = &cobra.Command{
+	Use:   "mine [node id]",
+	Short: "Mine a number of blocks on the chain of a node, without peers, and exit",
+	Run: func(cmd *cobra.Command, args []string) {
+		blocks, _ := cmd.Flags().GetInt("blocks")
+		if blocks < 1 {
+			exitWithError(fmt.Errorf("--blocks must be at least 1, got %d", blocks))
+		}
+
+		cfg, err := mineConfig(cmd.Flags(), args)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		chain, err := core.NewBlockchain(cfg)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		err = mineBlocks(chain, blocks, os.Stdout)
+
+		chain.Close()
+
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	addMineFlags(mineCmd.Flags())
+
+	mineCmd.MarkFlagRequired("blocks")
+}
+
+// addMineFlags adds the flags of the mine command.
+func addMineFlags(flags *pflag.FlagSet) {
+	addStartFlags(flags)
+	flags.Int("blocks", 0, "Number of blocks to mine")
+}
+
+// mineConfig returns the config of the node whose chain is mined, from the --config file
+// or the node id given as argument, or else the default config keeping the chain in
+// --datadir. No peer is dialed and the servers only listen on ephemeral loopback ports,
+// so that mining doesn't clash with a running node.
+func mineConfig(flags *pflag.FlagSet, args []string) (*config.Config, error) {
+	var (
+		cfg *config.Config
+		err error
+	)
+
+	if path, _ := flags.GetString("config"); path != "" || len(args) > 0 {
+		cfg, err = startConfig(flags, args)
+	} else {
+		cfg, err = dataDirMineConfig(flags)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Peers = nil
+	cfg.Mine = false
+	cfg.RPCPort = "localhost:0"
+	cfg.P2PPort = "localhost:0"
+	cfg.MetricsPort = ""
+	cfg.ExplorerPort = ""
+
+	return cfg, nil
+}
+
+// dataDirMineConfig returns the default config with the databases in --datadir, the
+// blocks being signed by mineSignerKey unless a signer key is given. The flags override it.
+func dataDirMineConfig(flags *pflag.FlagSet) (*config.Config, error) {
+	v, err := newStartViper(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	dataDir := v.GetString(configKeyDataDir)
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DBDir, cfg.StateDBDir = dataDirDBs(dataDir, "")
+	cfg.SignerPrivateKey = util.HexToPrivateKey(mineSignerKey)
+
+	cfg, err = applyConfig(v, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyVerbosity(flags, cfg); err != nil {
+		return nil, err
+	}
+
+	if flags.Changed(configKeyAlloc) {
+		alloc, err := allocFlags(flags)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.BalanceAlloc = alloc
+	}
+
+	return cfg, nil
+}
+
+// mineBlocks seals the given number of empty blocks on top of the chain, printing the
+// number and hash of each of them. Blocks past the allowed clock drift wait for the clock
+// to catch up. It stops at the first block failing to be added.
+func mineBlocks(chain *core.Blockchain, blocks int, w io.Writer) error {
+	for i := 0; i < blocks; i++ {
+		number := chain.Current().Number.Int64() + 1
+
+		if err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", number)), []*types.Transaction{}, make(chan bool), chain.Config.SignerPrivateKey); err != nil {
+			return fmt.Errorf("failed to mine block %d : %w", number, err)
+		}
+
+		block := chain.Current()
+		fmt.Fprintln(w, "Mined block", block.Number, block.DeriveHash().String())
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestMineBlocks(t *testing.T) {
+	dataDir := t.TempDir()
+
+	flags := pflag.NewFlagSet("mine", pflag.ContinueOnError)
+	addDataDirFlag(flags)
+	addMineFlags(flags)
+
+	if err := flags.Parse([]string{"--datadir", dataDir, "--blocks", "2", "--difficulty", "8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := mineConfig(flags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, filepath.Join(dataDir, "db"), cfg.DBDir)
+	assert.Empty(t, cfg.Peers)
+
+	blocks, _ := flags.GetInt("blocks")
+
+	var out bytes.Buffer
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mineBlocks(chain, blocks, &out)
+	chain.Close()
+
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	// The DB left behind holds the mined blocks.
+	db, err := dbstore.OpenReadOnlyDBInstance(cfg.DBDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nolint : errcheck
+	defer db.Close()
+
+	latest, err := dbstore.NewBlockchainDB(db).GetLatestBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), latest.Number.Uint64())
+	assert.Equal(t, uint64(8), latest.Difficulty)
+	assert.Equal(t, "Mined block 2 "+latest.DeriveHash().String(), lines[1])
+}
+
+// nolint : tparallel
+func TestMineBlocksFasterThanClock(t *testing.T) {
+	flags := pflag.NewFlagSet("mine", pflag.ContinueOnError)
+	addDataDirFlag(flags)
+	addMineFlags(flags)
+
+	// More blocks than seconds of allowed clock drift, each mined well within a second.
+	if err := flags.Parse([]string{"--datadir", t.TempDir(), "--blocks", "24", "--difficulty", "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := mineConfig(flags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	var out bytes.Buffer
+
+	assert.NoError(t, mineBlocks(chain, 24, &out))
+	assert.Equal(t, uint64(24), chain.Current().Number.Uint64())
+	assert.LessOrEqual(t, chain.Current().Timestamp, uint64(time.Now().Add(cfg.MaxClockDrift).Unix()))
+}
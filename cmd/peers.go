@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/spf13/cobra"
+)
+
+var peersCmd = &cobra.Command{
+	Use:   "peers",
+	Short: "Print the peers a node is connected to",
+	Run: func(cmd *cobra.Command, args []string) {
+		rpcAddr, _ := cmd.Flags().GetString("rpc")
+
+		var peers []*rpc.Peer
+		if err := callRPC(rpcAddr, "chain_peers", &peers); err != nil {
+			exitWithError(err)
+		}
+
+		printPeers(os.Stdout, peers)
+	},
+}
+
+func init() {
+	peersCmd.Flags().String("rpc", "", "RPC endpoint of node, host:port")
+
+	peersCmd.MarkFlagRequired("rpc")
+}
+
+// printPeers prints the peers as a table, one peer per row.
+func printPeers(w io.Writer, peers []*rpc.Peer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ADDRESS\tDIRECTION\tPROTOCOL\tHEIGHT")
+
+	for _, peer := range peers {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", peer.Addr, peer.Direction, peer.ProtocolVersion, peer.Height)
+	}
+
+	// nolint : errcheck
+	tw.Flush()
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintPeers(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	printPeers(&out, []*rpc.Peer{
+		{Addr: "localhost:6061", Direction: rpc.PeerOutbound, ProtocolVersion: 1, Height: 120},
+		{Addr: "127.0.0.1:52814", Direction: rpc.PeerInbound, ProtocolVersion: 1},
+	})
+
+	expected := "ADDRESS          DIRECTION  PROTOCOL  HEIGHT\n" +
+		"localhost:6061   outbound   1         120\n" +
+		"127.0.0.1:52814  inbound    1         0\n"
+
+	assert.Equal(t, expected, out.String())
+}
@@ -8,10 +8,14 @@ package cmd // root.go
 // It provides commands to start the node, send transactions, and display the version.
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	//	"github.com/0xsharma/compact-chain/cmd/sendtx"
@@ -21,6 +25,7 @@ import (
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -39,7 +44,8 @@ var (
 		// Run: func(cmd *cobra.Command, args []string) { // Run is the function that gets executed when this command is called.
 		Short: "Print the version number of Compact-Chain", //	 Short is a brief description of the command.
 		Run: func(cmd *cobra.Command, args []string) { // Run is the function that gets executed when this command is called.
-			fmt.Println(version) // Print the version number of Compact-Chain
+			full, _ := cmd.Flags().GetBool("full")
+			printVersion(cmd.OutOrStdout(), full) // Print the version number of Compact-Chain
 		},
 	}
 
@@ -48,8 +54,19 @@ var (
 		Short: "Start the Compact-Chain node",
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Printf("Starting Compact-Chain node\n\n")
-			nodeID, _ := strconv.ParseInt(args[0], 10, 0)
-			startBlockchainNode(nodeID)
+
+			cfg, err := startConfig(cmd.Flags(), args)
+			if err != nil {
+				fmt.Println("Error :", err)
+				os.Exit(1)
+			}
+
+			err = core.StartBlockchain(cfg, func() (*config.Config, error) {
+				return startConfig(cmd.Flags(), args)
+			})
+			if err != nil {
+				exitWithError(err)
+			}
 		},
 	}
 
@@ -62,20 +79,72 @@ var (
 			flags := cmd.Flags()
 
 			to, _ := flags.GetString("to")
-			value, _ := flags.GetInt64("value")
+			valueStr, _ := flags.GetString("value")
+			dataHex, _ := flags.GetString("data")
 			privateKey, _ := flags.GetString("privatekey")
-			nonce, _ := flags.GetInt64("nonce")
+			scheme, _ := flags.GetString("scheme")
+			from, _ := flags.GetString("from")
+			gasLimit, _ := flags.GetUint64("gas-limit")
+			chainID, _ := flags.GetUint64("chain-id")
 			rpcAddr, _ := flags.GetString("rpc")
+			keystoreDir, _ := flags.GetString("keystore")
+			wait, _ := flags.GetBool("wait")
+			timeout, _ := flags.GetDuration("timeout")
+
+			if (from == "") == (privateKey == "") {
+				exitWithError(errors.New("exactly one of --from or --privatekey is required"))
+			}
+
+			value, err := parseValue(valueStr)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			data, err := hex.DecodeString(strings.TrimPrefix(dataHex, "0x"))
+			if err != nil {
+				exitWithError(fmt.Errorf("invalid --data : %w", err))
+			}
 
 			sendTxCfg := &sendTxConfig{
-				To:         to,
-				Value:      value,
-				PrivateKey: privateKey,
-				Nonce:      nonce,
-				RPCAddr:    rpcAddr,
+				To:          to,
+				Value:       value,
+				Data:        data,
+				PrivateKey:  privateKey,
+				Scheme:      scheme,
+				From:        from,
+				KeystoreDir: keystoreDir,
+				GasLimit:    gasLimit,
+				ChainID:     chainID,
+				RPCAddr:     rpcAddr,
 			}
 
-			SendTx(sendTxCfg)
+			if flags.Changed("nonce") {
+				nonce, _ := flags.GetInt64("nonce")
+				sendTxCfg.Nonce = big.NewInt(nonce)
+			}
+
+			if from != "" {
+				password, err := passwordFromFlags(flags, false)
+				if err != nil {
+					exitWithError(err)
+				}
+
+				sendTxCfg.Password = password
+			}
+
+			hash := SendTx(sendTxCfg)
+
+			if !wait {
+				return
+			}
+
+			receipt, err := waitForTx(rpcAddr, hash, timeout)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			number, _ := new(big.Int).SetString(strings.TrimPrefix(receipt.BlockNumber, "0x"), 16)
+			fmt.Println("Transaction", hash.String(), "included in block", number)
 		},
 	}
 
@@ -83,8 +152,21 @@ var (
 		Use:   "demo",
 		Short: "Demo the Compact-Chain node",
 		Run: func(cmd *cobra.Command, args []string) {
+			difficulty, blocks, err := demoFlags(cmd.Flags())
+			if err != nil {
+				exitWithError(err)
+			}
+
+			cfg := demoConfig(viper.GetString(configKeyDataDir), difficulty)
+			if err := applyVerbosity(cmd.Flags(), cfg); err != nil {
+				exitWithError(err)
+			}
+
 			fmt.Printf("Starting Compact-Chain node\n\n")
-			demoBlockchain()
+
+			if _, err := runDemo(cfg, blocks); err != nil {
+				exitWithError(err)
+			}
 		},
 	}
 )
@@ -100,22 +182,48 @@ func init() {
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(demoCmd)
 	rootCmd.AddCommand(sendTxCmd)
+	rootCmd.AddCommand(accountCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(balanceCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(peersCmd)
+	rootCmd.AddCommand(inspectBlockCmd)
+	rootCmd.AddCommand(mineCmd)
+
+	addDataDirFlag(rootCmd.PersistentFlags())
+	viper.BindPFlag(configKeyDataDir, rootCmd.PersistentFlags().Lookup(configKeyDataDir))
+	addVerbosityFlag(rootCmd.PersistentFlags())
+
+	addStartFlags(startCmd.PersistentFlags())
+	addDemoFlags(demoCmd.Flags())
 
-	sendTxCmd.PersistentFlags().String("to", "", "To Address")
+	sendTxCmd.PersistentFlags().String("to", "", "0x-prefixed hex address to send to, checked against its checksum if mixed-case")
 	viper.BindPFlag("to", sendTxCmd.PersistentFlags().Lookup("to"))
 	cobra.MarkFlagRequired(sendTxCmd.PersistentFlags(), "to")
 
-	sendTxCmd.PersistentFlags().Int64("value", 0, "Value to send")
+	sendTxCmd.PersistentFlags().String("value", "", "Decimal value to send, which may exceed the int64 range")
 	viper.BindPFlag("value", sendTxCmd.PersistentFlags().Lookup("value"))
 	cobra.MarkFlagRequired(sendTxCmd.PersistentFlags(), "value")
 
+	sendTxCmd.PersistentFlags().String("data", "", "0x-prefixed hex data payload of transaction")
+
 	sendTxCmd.PersistentFlags().String("privatekey", "", "Private key to sign transaction")
 	viper.BindPFlag("privatekey", sendTxCmd.PersistentFlags().Lookup("privatekey"))
-	cobra.MarkFlagRequired(sendTxCmd.PersistentFlags(), "privatekey")
 
-	sendTxCmd.PersistentFlags().Int64("nonce", 0, "Nonce of transaction")
+	sendTxCmd.PersistentFlags().String("scheme", util.SchemeSecp256k1, "Signature scheme of --privatekey, secp256k1 or ed25519")
+	sendTxCmd.PersistentFlags().String("from", "", "Keystore account to sign transaction, instead of --privatekey")
+	sendTxCmd.PersistentFlags().String("password", "", "Password of the --from account, prompted for if empty")
+	sendTxCmd.PersistentFlags().String("keystore", keystorePath, "Keystore directory")
+
+	sendTxCmd.PersistentFlags().Int64("nonce", 0, "Nonce of transaction, defaults to the next nonce of the sender on the node")
 	viper.BindPFlag("nonce", sendTxCmd.PersistentFlags().Lookup("nonce"))
-	cobra.MarkFlagRequired(sendTxCmd.PersistentFlags(), "nonce")
+
+	sendTxCmd.PersistentFlags().Uint64("gas-limit", 0, "Gas limit of transaction, 0 for the intrinsic gas")
+	sendTxCmd.PersistentFlags().Uint64("chain-id", 1, "Chain id the transaction is signed for, the network id of the node")
+
+	sendTxCmd.PersistentFlags().Bool("wait", false, "Wait until the transaction is mined")
+	sendTxCmd.PersistentFlags().Duration("timeout", time.Minute, "Maximum time to --wait for")
 
 	sendTxCmd.PersistentFlags().String("rpc", "", "RPC endpoint of node")
 	viper.BindPFlag("rpc", sendTxCmd.PersistentFlags().Lookup("rpc"))
@@ -123,17 +231,57 @@ func init() {
 }
 
 var (
-	homePath, _ = os.UserHomeDir()
-	dbPath      = homePath + "/.compact-chain/db"
-	stateDbPath = homePath + "/.compact-chain/statedb"
+	homePath, _    = os.UserHomeDir()
+	defaultDataDir = homePath + "/.compact-chain"
 )
 
-func demoBlockchain() {
-	config := &config.Config{
-		ConsensusDifficulty: 16,
+// dataDirDBs returns the blockchain and state DB directories of the named node in the
+// data directory.
+func dataDirDBs(dataDir string, name string) (string, string) {
+	return filepath.Join(dataDir, "db"+name), filepath.Join(dataDir, "statedb"+name)
+}
+
+// Defaults and bounds of the demo flags. Proof of work gets exponentially slower with
+// the difficulty, the maximum still mining a block within minutes.
+const (
+	defaultDemoDifficulty = 16
+	defaultDemoBlocks     = 10
+	maxDemoDifficulty     = 28
+	maxDemoBlocks         = 1000
+)
+
+// addDemoFlags adds the flags of the demo command.
+func addDemoFlags(flags *pflag.FlagSet) {
+	flags.Int("difficulty", defaultDemoDifficulty, fmt.Sprintf("Proof of work difficulty of the demo chain, from 1 to %d", maxDemoDifficulty))
+	flags.Int("blocks", defaultDemoBlocks, fmt.Sprintf("Number of blocks to mine, from 1 to %d", maxDemoBlocks))
+}
+
+// demoFlags returns the difficulty and the number of blocks of the demo flags, failing
+// if they are out of their bounds.
+func demoFlags(flags *pflag.FlagSet) (int, int, error) {
+	difficulty, _ := flags.GetInt("difficulty")
+	if difficulty < 1 || difficulty > maxDemoDifficulty {
+		return 0, 0, fmt.Errorf("--difficulty must be between 1 and %d, got %d", maxDemoDifficulty, difficulty)
+	}
+
+	blocks, _ := flags.GetInt("blocks")
+	if blocks < 1 || blocks > maxDemoBlocks {
+		return 0, 0, fmt.Errorf("--blocks must be between 1 and %d, got %d", maxDemoBlocks, blocks)
+	}
+
+	return difficulty, blocks, nil
+}
+
+// demoConfig returns the config of the demo node, keeping its databases in the data
+// directory.
+func demoConfig(dataDir string, difficulty int) *config.Config {
+	dbDir, stateDBDir := dataDirDBs(dataDir, "demo")
+
+	return &config.Config{
+		ConsensusDifficulty: difficulty,
 		ConsensusName:       "pow",
-		DBDir:               dbPath + "demo",
-		StateDBDir:          stateDbPath + "demo",
+		DBDir:               dbDir,
+		StateDBDir:          stateDBDir,
 		MinFee:              big.NewInt(100),
 		RPCPort:             ":1711",
 		BalanceAlloc:        map[string]*big.Int{},
@@ -142,36 +290,102 @@ func demoBlockchain() {
 		BlockTime:           2,
 		SignerPrivateKey:    util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1"),
 	}
+}
 
-	chain := core.NewBlockchain(config)
-	if chain.LastBlock.Number.Int64() == 0 {
-		fmt.Println("Number : ", chain.LastBlock.Number, "Hash : ", chain.LastBlock.DeriveHash().String())
-	} else {
-		fmt.Println("LastNumber : ", chain.LastBlock.Number, "LastHash : ", chain.LastBlock.DeriveHash().String())
+// runDemo mines the given number of empty blocks on top of the chain of the config, one
+// every block time, and returns the chain. It fails if the chain can't be opened.
+func runDemo(config *config.Config, blocks int) (*core.Blockchain, error) {
+	chain, err := core.NewBlockchain(config)
+	if err != nil {
+		return nil, err
 	}
 
-	lastNumber := chain.LastBlock.Number
+	chain.Logger.Info("Loaded chain", "number", chain.LastBlock.Number, "hash", chain.LastBlock.DeriveHash().String())
 
-	for i := lastNumber.Int64() + 1; i <= lastNumber.Int64()+10; i++ {
-		time.Sleep(2 * time.Second)
+	lastNumber := chain.LastBlock.Number.Int64()
+
+	for i := lastNumber + 1; i <= lastNumber+int64(blocks); i++ {
+		time.Sleep(time.Duration(config.BlockTime) * time.Second)
 
 		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
 		if err != nil {
-			fmt.Println("Error Adding Block", err)
+			chain.Logger.Error("Failed to add block", "number", i, "err", err)
+			continue
 		}
 
-		fmt.Println("Number : ", chain.LastBlock.Number, "Hash : ", chain.LastBlock.DeriveHash().String())
+		chain.Logger.Info("Demo progress", "number", chain.LastBlock.Number, "hash", chain.LastBlock.DeriveHash().String(), "remaining", lastNumber+int64(blocks)-i)
 	}
+
+	return chain, nil
 }
 
-func startBlockchainNode(nodeId int64) {
+// startConfig returns the node config from the --config file, or the default
+// config of the node id given as argument. Flags override both.
+func startConfig(flags *pflag.FlagSet, args []string) (*config.Config, error) {
+	v, err := newStartViper(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := startConfigFrom(v, flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyVerbosity(flags, cfg); err != nil {
+		return nil, err
+	}
+
+	// The --alloc flags replace the balances of the config.
+	if flags.Changed(configKeyAlloc) {
+		alloc, err := allocFlags(flags)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.BalanceAlloc = alloc
+	}
+
+	return cfg, nil
+}
+
+// startConfigFrom returns the node config from the --config file, or the default
+// config of the node id given as argument, with the flags bound to the viper instance
+// applied.
+func startConfigFrom(v *viper.Viper, flags *pflag.FlagSet, args []string) (*config.Config, error) {
+	if path, _ := flags.GetString("config"); path != "" {
+		return readConfigFile(v, path)
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("either a node id or --config is required")
+	}
+
+	nodeID, err := strconv.ParseInt(args[0], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node id %s", args[0])
+	}
+
+	dataDir := v.GetString(configKeyDataDir)
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
+
+	return applyConfig(v, nodeConfig(dataDir, nodeID))
+}
+
+// nodeConfig returns the default config of the node id, keeping its databases in the
+// data directory.
+func nodeConfig(dataDir string, nodeId int64) *config.Config {
 	fmt.Println("Starting node", nodeId)
 
+	dbDir, stateDBDir := dataDirDBs(dataDir, fmt.Sprint(nodeId))
+
 	config := &config.Config{
 		ConsensusDifficulty: 20,
 		ConsensusName:       "pow",
-		DBDir:               dbPath + fmt.Sprint(nodeId),
-		StateDBDir:          stateDbPath + fmt.Sprint(nodeId),
+		DBDir:               dbDir,
+		StateDBDir:          stateDBDir,
 		MinFee:              big.NewInt(100),
 		RPCPort:             ":1711" + fmt.Sprint(nodeId),
 		BalanceAlloc: map[string]*big.Int{
@@ -180,9 +394,12 @@ func startBlockchainNode(nodeId int64) {
 		P2PPort:          ":6060" + fmt.Sprint(nodeId),
 		Peers:            []string{"localhost:60601", "localhost:60602", "localhost:60603"},
 		BlockTime:        4,
+		NetworkID:        1,
 		SignerPrivateKey: util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a" + fmt.Sprint(nodeId)),
 		Mine:             true,
+
+		DifficultyAdjustmentInterval: 10,
 	}
 
-	core.StartBlockchain(config)
+	return config
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// rpcTimeout bounds a single JSON-RPC call to a node.
+var rpcTimeout = 10 * time.Second
+
+// txWaitInterval is how often the receipt of a sent transaction is polled for.
+var txWaitInterval = 500 * time.Millisecond
+
+var (
+	// ErrNodeUnreachable is returned when the RPC endpoint of a node can't be reached.
+	ErrNodeUnreachable = errors.New("node unreachable")
+
+	errTxWaitTimeout = errors.New("timed out waiting for the transaction to be mined")
+)
+
+type rpcCallRequest struct {
+	Version string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcCallResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpc.Error      `json:"error"`
+}
+
+// callRPC calls a JSON-RPC method of the node at rpcAddr (host:port) and decodes the
+// result into result.
+func callRPC(rpcAddr string, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(&rpcCallRequest{Version: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+rpcAddr, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w at %s : %s", ErrNodeUnreachable, rpcAddr, err)
+	}
+
+	// nolint : errcheck
+	defer res.Body.Close()
+
+	var out rpcCallResponse
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return fmt.Errorf("invalid response from %s : %w", rpcAddr, err)
+	}
+
+	if out.Error != nil {
+		return fmt.Errorf("%s failed : %w", method, out.Error)
+	}
+
+	return json.Unmarshal(out.Result, result)
+}
+
+// waitForTx polls the node for the receipt of the transaction with the given hash until
+// the transaction is mined, failing with errTxWaitTimeout once the timeout elapses.
+func waitForTx(rpcAddr string, hash *util.Hash, timeout time.Duration) (*rpc.RPCReceipt, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var receipt *rpc.RPCReceipt
+
+		if err := callRPC(rpcAddr, "chain_getTransactionReceipt", &receipt, hash.String()); err != nil {
+			return nil, err
+		}
+
+		if receipt != nil {
+			return receipt, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w : %s after %s", errTxWaitTimeout, hash.String(), timeout)
+		}
+
+		time.Sleep(txWaitInterval)
+	}
+}
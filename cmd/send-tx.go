@@ -1,34 +1,73 @@
 package cmd
 
 import (
+	"crypto"
 	"fmt"
 	"log"
 	"math/big"
 	"net/rpc"
+	"os"
+	"strings"
 
+	"github.com/0xsharma/compact-chain/keystore"
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
 )
 
 type sendTxConfig struct {
-	PrivateKey string
-	To         string
-	Value      int64
-	RPCAddr    string
-	Nonce      int64
+	PrivateKey  string
+	Scheme      string
+	From        string
+	Password    string
+	KeystoreDir string
+	To          string
+	Value       *big.Int
+	Data        []byte
+	RPCAddr     string
+	Nonce       *big.Int // Nil to use the next nonce of the sender on the node
+	GasLimit    uint64
+	ChainID     uint64
 }
 
-func SendTx(sendTxCfg *sendTxConfig) {
-	ua := util.NewUnlockedAccount(util.HexToPrivateKey(sendTxCfg.PrivateKey))
+// SendTx signs the transaction and sends it to the node, returning its hash.
+func SendTx(sendTxCfg *sendTxConfig) *util.Hash {
+	// A mistyped recipient would lose the funds, it is checked before anything is signed.
+	to, err := util.ChecksumHexToAddress(sendTxCfg.To)
+	if err != nil {
+		fmt.Println("Error : invalid --to address :", err)
+		os.Exit(1)
+	}
+
+	key, err := signingKey(sendTxCfg)
+	if err != nil {
+		fmt.Println("Error :", err)
+		os.Exit(1)
+	}
+
+	ua := util.NewUnlockedAccount(key)
 	from := ua.Address()
 
+	fmt.Println("Sending", sendTxCfg.Value, "from", from.Checksum(), "to", to.Checksum())
+
+	nonce := sendTxCfg.Nonce
+	if nonce == nil {
+		nonce, err = nextNonce(sendTxCfg.RPCAddr, from)
+		if err != nil {
+			fmt.Println("Error : failed to get the nonce of", from.Checksum(), ":", err)
+			os.Exit(1)
+		}
+	}
+
 	tx := &types.Transaction{
-		From:  *from,
-		To:    *util.StringToAddress(sendTxCfg.To),
-		Value: big.NewInt(sendTxCfg.Value),
-		Msg:   []byte("hello"),
-		Fee:   big.NewInt(1000),
-		Nonce: big.NewInt(sendTxCfg.Nonce),
+		From:     *from,
+		To:       *to,
+		Value:    sendTxCfg.Value,
+		Msg:      []byte("hello"),
+		Data:     sendTxCfg.Data,
+		Fee:      big.NewInt(1000),
+		Nonce:    nonce,
+		GasLimit: sendTxCfg.GasLimit,
+		ChainID:  sendTxCfg.ChainID,
 	}
 	tx.Sign(ua)
 
@@ -40,6 +79,58 @@ func SendTx(sendTxCfg *sendTxConfig) {
 	}
 
 	fmt.Println(res)
+
+	return tx.Hash()
+}
+
+// parseValue parses the decimal value of a transaction, which can exceed the int64 range
+// like balances can.
+func parseValue(value string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid --value %q : not a decimal integer", value)
+	}
+
+	if v.Sign() < 0 {
+		return nil, fmt.Errorf("invalid --value %s : negative", value)
+	}
+
+	return v, nil
+}
+
+// nextNonce returns the nonce of the next transaction of the sender, as reported by the
+// node following the transactions of the sender in its txpool.
+func nextNonce(rpcAddr string, from *util.Address) (*big.Int, error) {
+	var result string
+	if err := callRPC(rpcAddr, "chain_getTransactionCount", &result, from.String()); err != nil {
+		return nil, err
+	}
+
+	nonce, ok := new(big.Int).SetString(strings.TrimPrefix(result, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid nonce %s", result)
+	}
+
+	return nonce, nil
+}
+
+// signingKey returns the raw private key if given, or unlocks the --from keystore account.
+func signingKey(sendTxCfg *sendTxConfig) (crypto.Signer, error) {
+	if sendTxCfg.From == "" {
+		scheme, err := util.SchemeByName(sendTxCfg.Scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		return scheme.HexToPrivateKey(strings.TrimPrefix(sendTxCfg.PrivateKey, "0x"))
+	}
+
+	address, err := util.HexToAddress(sendTxCfg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	return keystore.NewKeyStore(sendTxCfg.KeystoreDir).Unlock(address, sendTxCfg.Password)
 }
 
 func SendRpcRequest(method string, params interface{}, rpcAddr string) (interface{}, error) {
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestSendTxWait(t *testing.T) {
+	cfg := &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             ":1781",
+		P2PPort:             ":6132",
+		NetworkID:           1,
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1000000),
+		},
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"),
+		BlockTime:        4,
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	// The RPC server is started in the background.
+	assert.Eventually(t, func() bool {
+		var number string
+		return callRPC("localhost:1781", "chain_getBlockNumber", &number) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	hash := SendTx(&sendTxConfig{
+		PrivateKey: "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6",
+		Scheme:     util.SchemeSecp256k1,
+		To:         "0x0000000000000000000000000000000000000002",
+		Value:      big.NewInt(10),
+		RPCAddr:    "localhost:1781",
+		ChainID:    1,
+	})
+
+	type waitResult struct {
+		receipt *rpc.RPCReceipt
+		err     error
+	}
+
+	done := make(chan waitResult, 1)
+
+	go func() {
+		receipt, err := waitForTx("localhost:1781", hash, 10*time.Second)
+		done <- waitResult{receipt, err}
+	}()
+
+	// The wait lasts until the transaction is mined.
+	select {
+	case <-done:
+		t.Fatal("waitForTx returned before the transaction was mined")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), cfg.SignerPrivateKey))
+
+	select {
+	case res := <-done:
+		assert.NoError(t, res.err)
+		assert.Equal(t, hash.String(), res.receipt.TransactionHash)
+		assert.Equal(t, "0x1", res.receipt.BlockNumber)
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForTx didn't return once the transaction was mined")
+	}
+
+	// Transactions which are never mined time out.
+	_, err = waitForTx("localhost:1781", util.HashData([]byte("unknown")), 100*time.Millisecond)
+	assert.ErrorIs(t, err, errTxWaitTimeout)
+}
+
+// nolint : tparallel
+func TestSendTxAutoNonce(t *testing.T) {
+	cfg := &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             ":1795",
+		P2PPort:             ":6146",
+		NetworkID:           1,
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1000000),
+		},
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"),
+		BlockTime:        4,
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	// The RPC server is started in the background.
+	assert.Eventually(t, func() bool {
+		var number string
+		return callRPC("localhost:1795", "chain_getBlockNumber", &number) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	sendTx := func(nonce *big.Int) *util.Hash {
+		return SendTx(&sendTxConfig{
+			PrivateKey: "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6",
+			Scheme:     util.SchemeSecp256k1,
+			To:         "0x0000000000000000000000000000000000000002",
+			Value:      big.NewInt(10),
+			RPCAddr:    "localhost:1795",
+			Nonce:      nonce,
+			ChainID:    1,
+		})
+	}
+
+	// Without --nonce, the transactions follow the pending ones of the sender.
+	first := sendTx(nil)
+	second := sendTx(nil)
+
+	nonces := []int64{}
+	for _, tx := range chain.Txpool.Pending() {
+		nonces = append(nonces, tx.Nonce.Int64())
+	}
+
+	assert.ElementsMatch(t, []int64{0, 1}, nonces)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), cfg.SignerPrivateKey))
+
+	for _, hash := range []*util.Hash{first, second} {
+		receipt, err := waitForTx("localhost:1795", hash, 5*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, "0x1", receipt.BlockNumber)
+	}
+
+	// A given nonce overrides the one of the node.
+	sendTx(big.NewInt(5))
+
+	queued := chain.Txpool.QueuedTxs()
+	assert.Len(t, queued, 1)
+	assert.Equal(t, int64(5), queued[0].Nonce.Int64())
+
+	var next string
+	assert.NoError(t, callRPC("localhost:1795", "chain_getTransactionCount", &next, "0xa52c981eee8687b5e4afd69aa5006548c24d7685"))
+	assert.Equal(t, "0x2", next)
+}
+
+func TestParseValue(t *testing.T) {
+	t.Parallel()
+
+	value, err := parseValue("18446744073709551616")
+	assert.NoError(t, err)
+	assert.Equal(t, "18446744073709551616", value.String())
+
+	value, err = parseValue("0")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, value.Sign())
+
+	for _, invalid := range []string{"", "-1", "1.5", "0x10", "ten"} {
+		_, err := parseValue(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// nolint : tparallel
+func TestSendTxLargeValue(t *testing.T) {
+	funds, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+
+	cfg := &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             ":1805",
+		P2PPort:             ":6157",
+		NetworkID:           1,
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": funds,
+		},
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"),
+		BlockTime:        4,
+	}
+
+	chain, err := core.NewBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer chain.Close()
+
+	// The RPC server is started in the background.
+	assert.Eventually(t, func() bool {
+		var number string
+		return callRPC("localhost:1805", "chain_getBlockNumber", &number) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// One more than math.MaxUint64, so well beyond math.MaxInt64.
+	value, err := parseValue("18446744073709551616")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := SendTx(&sendTxConfig{
+		PrivateKey: "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6",
+		Scheme:     util.SchemeSecp256k1,
+		To:         "0x0000000000000000000000000000000000000002",
+		Value:      value,
+		RPCAddr:    "localhost:1805",
+		ChainID:    1,
+	})
+
+	pending := chain.Txpool.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "18446744073709551616", pending[0].Value.String())
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), cfg.SignerPrivateKey))
+
+	_, err = waitForTx("localhost:1805", hash, 5*time.Second)
+	assert.NoError(t, err)
+
+	balance, err := queryBalance("localhost:1805", "0x0000000000000000000000000000000000000002")
+	assert.NoError(t, err)
+	assert.Equal(t, "18446744073709551616", balance.String())
+}
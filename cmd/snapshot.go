@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/0xsharma/compact-chain/core"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export or import the state of the chain at a block",
+	}
+
+	snapshotExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export the state of the chain at a block to a file",
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+
+			defaultDBDir, defaultStateDBDir := dataDirDBs(viper.GetString(configKeyDataDir), "")
+
+			dbDir, _ := flags.GetString("db")
+			if dbDir == "" {
+				dbDir = defaultDBDir
+			}
+
+			stateDBDir, _ := flags.GetString("state-db")
+			if stateDBDir == "" {
+				stateDBDir = defaultStateDBDir
+			}
+			height, _ := flags.GetUint64("height")
+			out, _ := flags.GetString("out")
+
+			if err := exportSnapshot(dbDir, stateDBDir, height, out); err != nil {
+				exitWithError(err)
+			}
+
+			fmt.Println("Exported the state at block", height, "to", out)
+		},
+	}
+
+	snapshotImportCmd = &cobra.Command{
+		Use:   "import [node id]",
+		Short: "Start the chain of a node from a snapshot file",
+		Run: func(cmd *cobra.Command, args []string) {
+			in, _ := cmd.Flags().GetString("in")
+
+			cfg, err := startConfig(cmd.Flags(), args)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			snap, err := importSnapshot(cfg.DBDir, cfg.StateDBDir, in)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			fmt.Println("Imported the state at block", snap.Number, "hash", snap.BlockHash.String())
+		},
+	}
+)
+
+func init() {
+	snapshotExportCmd.Flags().String("db", "", "Blockchain DB directory of the node, defaults to the one of --datadir")
+	snapshotExportCmd.Flags().String("state-db", "", "State DB directory of the node, defaults to the one of --datadir")
+	snapshotExportCmd.Flags().Uint64("height", 0, "Block to export the state at")
+	snapshotExportCmd.Flags().String("out", "", "File to write the snapshot to")
+
+	snapshotExportCmd.MarkFlagRequired("height")
+	snapshotExportCmd.MarkFlagRequired("out")
+
+	addStartFlags(snapshotImportCmd.Flags())
+	snapshotImportCmd.Flags().String("in", "", "File to read the snapshot from")
+
+	snapshotImportCmd.MarkFlagRequired("in")
+
+	snapshotCmd.AddCommand(snapshotExportCmd)
+	snapshotCmd.AddCommand(snapshotImportCmd)
+}
+
+// openChainDBs opens the blockchain and state databases of a node.
+func openChainDBs(dbDir string, stateDBDir string) (*dbstore.BlockchainDB, *dbstore.StateDB, func(), error) {
+	db, err := dbstore.NewDBInstance(dbDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	stateDB, err := dbstore.NewDBInstance(stateDBDir)
+	if err != nil {
+		// nolint : errcheck
+		db.Close()
+
+		return nil, nil, nil, err
+	}
+
+	closeDBs := func() {
+		// nolint : errcheck
+		db.Close()
+		// nolint : errcheck
+		stateDB.Close()
+	}
+
+	return dbstore.NewBlockchainDB(db), dbstore.NewStateDB(stateDB), closeDBs, nil
+}
+
+func exportSnapshot(dbDir string, stateDBDir string, height uint64, out string) error {
+	for _, dir := range []string{dbDir, stateDBDir} {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("no chain found in %s : %w", dir, err)
+		}
+	}
+
+	blockchainDB, stateDB, closeDBs, err := openChainDBs(dbDir, stateDBDir)
+	if err != nil {
+		return err
+	}
+
+	defer closeDBs()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+
+	if err := core.ExportSnapshot(blockchainDB, stateDB, w, height); err != nil {
+		// nolint : errcheck
+		f.Close()
+		// nolint : errcheck
+		os.Remove(out)
+
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		// nolint : errcheck
+		f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+func importSnapshot(dbDir string, stateDBDir string, in string) (*core.Snapshot, error) {
+	f, err := os.Open(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint : errcheck
+	defer f.Close()
+
+	snap, err := core.ReadSnapshot(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	blockchainDB, stateDB, closeDBs, err := openChainDBs(dbDir, stateDBDir)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeDBs()
+
+	if err := core.ImportSnapshot(blockchainDB, stateDB, snap); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
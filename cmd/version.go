@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+//
+//	-ldflags "-X github.com/0xsharma/compact-chain/cmd.gitCommit=<commit> -X github.com/0xsharma/compact-chain/cmd.buildDate=<date>"
+//
+// When unset, they are read from the VCS information embedded by the Go toolchain, if any.
+var (
+	gitCommit string
+	buildDate string
+)
+
+func init() {
+	versionCmd.Flags().Bool("full", false, "Also print the git commit, build date, Go version and OS/arch of the build")
+}
+
+// buildSetting returns the value if set, or else the build setting of the given key
+// embedded in the binary, or "unknown".
+func buildSetting(value string, key string) string {
+	if value != "" {
+		return value
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == key && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// printVersion prints the version, followed by the build metadata if full is set.
+func printVersion(w io.Writer, full bool) {
+	fmt.Fprintln(w, version)
+
+	if !full {
+		return
+	}
+
+	fmt.Fprintln(w, "Git commit :", buildSetting(gitCommit, "vcs.revision"))
+	fmt.Fprintln(w, "Build date :", buildSetting(buildDate, "vcs.time"))
+	fmt.Fprintln(w, "Go version :", runtime.Version())
+	fmt.Fprintln(w, "OS/Arch :", runtime.GOOS+"/"+runtime.GOARCH)
+}
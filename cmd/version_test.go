@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionFull(t *testing.T) {
+	t.Parallel()
+
+	assert.NotNil(t, versionCmd.Flags().Lookup("full"))
+
+	var out bytes.Buffer
+
+	printVersion(&out, true)
+
+	assert.Contains(t, out.String(), version+"\n")
+	assert.Contains(t, out.String(), "Go version : "+runtime.Version()+"\n")
+	assert.Contains(t, out.String(), "OS/Arch : "+runtime.GOOS+"/"+runtime.GOARCH+"\n")
+	assert.Contains(t, out.String(), "Git commit : ")
+
+	out.Reset()
+	printVersion(&out, false)
+	assert.Equal(t, version+"\n", out.String())
+}
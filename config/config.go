@@ -1,9 +1,13 @@
 package config
 
 import (
-	"crypto/ecdsa"
+	"crypto"
+	"io"
 	"math/big"
 	"os"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
 )
 
 var (
@@ -18,14 +22,200 @@ type Config struct {
 	ConsensusName       string
 	DBDir               string
 	StateDBDir          string
+	DBBackend           string // "leveldb" (default) stores the databases in DBDir and StateDBDir, "memory" in memory
+	DBCompression       string // "snappy" compresses the blocks written to DBDir, "none" (default) stores them as serialized
 	MinFee              *big.Int
+	BlockReward         *big.Int // Credited to the coinbase of every block on top of the fees, nil for none
 	RPCPort             string
-	SignerPrivateKey    *ecdsa.PrivateKey
+	SignerPrivateKey    crypto.Signer
+	CoinbaseAddress     string // Credited with the fees and the reward of mined blocks instead of the signer when set
+	BaseFeeSink         string // Credited with the base fee part of the fees of dynamic fee transactions when set, burning it otherwise
 	Mine                bool
 	BalanceAlloc        map[string]*big.Int
 	P2PPort             string
 	Peers               []string
 	BlockTime           int
+
+	// GenesisFile is the path of a JSON genesis file. When set, the chain id, the initial
+	// difficulty and the balance allocation are read from it instead of NetworkID,
+	// ConsensusDifficulty and BalanceAlloc.
+	GenesisFile string
+
+	// SignatureScheme is the scheme of SignerPrivateKey, "secp256k1" or "ed25519".
+	// Transactions signed under either scheme are accepted.
+	SignatureScheme string
+
+	// RPCRateLimit is the number of RPC requests per second served per client IP, with
+	// bursts of up to RPCRateBurst requests. Clients over the limit get a 429 status.
+	// Zero disables the limit.
+	RPCRateLimit float64
+	RPCRateBurst int
+
+	// RPCRateLimitWhitelist are the client IPs, or "localhost" for loopback clients,
+	// which bypass the RPC rate limit.
+	RPCRateLimitWhitelist []string
+
+	// RPCReadTimeout and RPCWriteTimeout bound the time the RPC server takes to read a
+	// request and to write its response, and RPCMaxBodyBytes the size of a request body.
+	// Larger bodies get a 413 status. Zero uses 30 seconds timeouts and a 5 MiB maximum.
+	RPCReadTimeout  time.Duration
+	RPCWriteTimeout time.Duration
+	RPCMaxBodyBytes int64
+
+	// RPCMaxBatchSize is the maximum number of requests of a JSON-RPC batch, and of
+	// transactions of a chain_sendRawTransactions call. Zero uses 100.
+	RPCMaxBatchSize int
+
+	// RPCCORSOrigins are the origins, such as "https://explorer.example.org", whose pages
+	// may call the RPC server from a browser. "*" allows any origin, empty none.
+	RPCCORSOrigins []string
+
+	// RPCAuthToken is the token RPC requests must carry in an "Authorization: Bearer"
+	// header, requests without it getting a 401 status. Empty serves any request.
+	RPCAuthToken string
+
+	// DebugRPC serves the debug_ namespace, such as debug_dumpState. It must stay off on
+	// public nodes.
+	DebugRPC bool
+
+	// MinPeersForHealthy is the number of connected peers below which the health check
+	// reports the node as unhealthy. Zero keeps a node without peers healthy.
+	MinPeersForHealthy int
+
+	// MetricsPort is the listen address of the Prometheus metrics endpoint. Empty
+	// disables the endpoint.
+	MetricsPort string
+
+	// ExplorerPort is the listen address of the block explorer web UI. Empty disables the
+	// explorer.
+	ExplorerPort string
+
+	// NetworkID identifies the network of the node. Peers on a different network are refused.
+	// It is also the chain id transactions are signed for.
+	NetworkID uint64
+
+	// LegacyTxBlock is the last block transactions signed without a chain id are accepted
+	// in, giving wallets a window to migrate to chain ids. Zero refuses them.
+	LegacyTxBlock uint64
+
+	// LegacyHashBlock is the last block hashing its joined header fields rather than their
+	// RLP encoding, letting the nodes of a chain started before upgrade ahead of it. The
+	// genesis block always does. Zero hashes the blocks after genesis from their encoding.
+	LegacyHashBlock uint64
+
+	// LegacyStateRootBlock is the last block which may leave out its state root, its root
+	// not being checked either, as the blocks of chains started before the state
+	// commitment committed to another root. Zero requires the root of every block.
+	LegacyStateRootBlock uint64
+
+	// DifficultyAdjustmentInterval is the number of blocks after which the
+	// proof of work difficulty is retargeted. Zero disables retargeting.
+	DifficultyAdjustmentInterval int
+
+	// MinDifficulty and MaxDifficulty bound the proof of work difficulty retargeting can
+	// reach. The initial difficulty must be within them. Zero leaves the bound unset.
+	MinDifficulty uint64
+	MaxDifficulty uint64
+
+	// MiningThreads is the number of goroutines searching for the proof of work nonce of
+	// the blocks mined, each over its share of the nonces. Zero uses one.
+	MiningThreads int
+
+	// FinalityDepth is the number of blocks a block has to be buried under to be final.
+	// Branches rewriting a final block are refused, however heavy. Zero disables
+	// finality, any branch may replace the canonical chain.
+	FinalityDepth uint64
+
+	// MaxReorgDepth is the number of canonical blocks a reorg may drop at most. Branches
+	// forking deeper are refused, however heavy, and the peers offering them banned, so
+	// that blocks buried deeper are effectively final. Zero allows reorgs of any depth.
+	MaxReorgDepth uint64
+
+	// MaxPoolSize is the maximum number of transactions kept in the txpool.
+	MaxPoolSize int
+
+	// MaxTxPerSender is the maximum number of pending and queued transactions of a sender
+	// in the txpool. Further transactions of the sender are refused, replacements aside.
+	// Zero accepts any number.
+	MaxTxPerSender int
+
+	// TxPoolLifetime is how long a transaction which can't be mined, because of a nonce
+	// gap or a fee below the base fee, is kept in the txpool. Zero keeps it until it is
+	// evicted by a better paying transaction.
+	TxPoolLifetime time.Duration
+
+	// TxValidator is run on the transactions entering the txpool once they passed the
+	// standard checks, rejecting those it returns an error for. Nil accepts them all.
+	TxValidator types.TxValidator
+
+	// PriceBumpPercent is the minimum fee increase, in percent, required to replace
+	// a pending transaction with the same sender and nonce. Nil is 10 percent, while
+	// zero lets a transaction be replaced by one paying the same fee.
+	PriceBumpPercent *int
+
+	// TargetBlockGas is the gas used per block the base fee steers towards, raising
+	// the base fee after fuller blocks and lowering it after emptier ones. Zero keeps
+	// the base fee at MinFee.
+	TargetBlockGas uint64
+
+	// BlockGasLimit is the maximum gas the transactions of a block may use. Transactions
+	// whose gas limit alone exceeds it are refused. Zero disables the limit.
+	BlockGasLimit uint64
+
+	// MaxBlockBytes is the maximum size of a serialized block, and MaxBlockTxs its maximum
+	// number of transactions. Blocks received over the limits are refused, mined blocks
+	// are packed within them. Zero uses a 1 MiB and a 2000 transactions maximum.
+	MaxBlockBytes int
+	MaxBlockTxs   int
+
+	// MaxTxDataBytes is the maximum size of the data of a transaction entering the txpool.
+	// Zero uses a 32 KiB maximum.
+	MaxTxDataBytes int
+
+	// MaxClockDrift is how far ahead of the local clock a block timestamp may be.
+	// Zero uses a 15 seconds maximum.
+	MaxClockDrift time.Duration
+
+	// MaxPeerBackoff is the maximum delay between two dials of an unreachable peer. The
+	// delay doubles after every failed dial. Zero uses a 30 seconds maximum.
+	MaxPeerBackoff time.Duration
+
+	// MaxPeers is the number of inbound peer connections accepted at a time. Connections
+	// over the limit are refused, the configured peers are still dialed. Zero accepts any
+	// number of connections.
+	MaxPeers int
+
+	// PeerBanDuration is how long a peer which sent an invalid block or a malformed
+	// message is refused, outbound peers being dialed again once it expires. Zero bans
+	// peers for an hour.
+	PeerBanDuration time.Duration
+
+	// PeerDenylist are the peer addresses always refused, as host:port or as a host for
+	// any port. Denylisted configured peers are dropped without being dialed.
+	PeerDenylist []string
+
+	// StateRetentionBlocks is the number of blocks below the head the state history is
+	// kept for. Older history is pruned, the head state is always kept. Zero is 128
+	// blocks, math.MaxUint64 keeps the whole history. Every block retained costs a
+	// record of about 100 bytes per state key it touches : the sender balance and nonce
+	// and the recipient balance of each transaction, and the coinbase balance.
+	StateRetentionBlocks uint64
+
+	// LogLevel is the minimum level of the node logs : trace, debug, info, warn, error or
+	// silent.
+	LogLevel string
+
+	// LogOutput receives the node logs. Nil logs to stdout.
+	LogOutput io.Writer
+
+	// Authorities are the addresses taking turns sealing blocks in proof of authority mode.
+	// They vote others in or out of the set with transactions to the candidate carrying
+	// poa.VoteAuthorize or poa.VoteDeauthorize as data.
+	Authorities []string
+
+	// AuthorityEpoch is the number of blocks authority votes are tallied over, votes not
+	// reaching a majority within it being dropped. Zero uses poa.DefaultEpoch.
+	AuthorityEpoch uint64
 }
 
 func DefaultConfig() *Config {
@@ -34,10 +224,24 @@ func DefaultConfig() *Config {
 		ConsensusName:       "pow",
 		DBDir:               dbPath,
 		StateDBDir:          stateDbPath,
+		DBBackend:           "leveldb",
 		MinFee:              big.NewInt(100),
 		RPCPort:             ":1711",
 		P2PPort:             ":6060",
 		BlockTime:           4,
+		NetworkID:           1,
+		SignatureScheme:     "secp256k1",
+
+		DifficultyAdjustmentInterval: 10,
+		MaxPoolSize:                  5000,
+		MaxTxPerSender:               64,
+		TargetBlockGas:               210000, // 10 transactions
+		BlockGasLimit:                420000, // 20 transactions
+		StateRetentionBlocks:         128,
+		MaxPeerBackoff:               30 * time.Second,
+		MaxPeers:                     50,
+		MaxClockDrift:                15 * time.Second,
+		LogLevel:                     "info",
 	}
 
 	return cfg
@@ -13,4 +13,5 @@ type Consensus interface {
 	GetTarget() *big.Int
 	Mine(b *types.Block, mineInterrupt chan bool) *types.Block
 	Validate(b *types.Block) bool
+	VerifySeal(b *types.Block) bool
 }
@@ -0,0 +1,158 @@
+package poa
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/executer"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+type POA struct {
+	Authorities []string // Authorities of the genesis block
+	Epoch       uint64   // Number of blocks votes are tallied over
+	Chain       ChainReader
+	TxProcessor *executer.TxProcessor
+
+	snapshots *snapshots
+}
+
+// NewPOA creates a new proof of authority consensus where the given authorities
+// take turns sealing blocks in round-robin order. The authorities vote others in or out
+// of the set through the blocks read from the chain. Without a chain the set never
+// changes. A zero epoch uses DefaultEpoch.
+func NewPOA(authorities []string, epoch uint64, chain ChainReader, txProcessor *executer.TxProcessor) *POA {
+	normalized := make([]string, len(authorities))
+	for i, authority := range authorities {
+		normalized[i] = strings.ToLower(authority)
+	}
+
+	if epoch == 0 {
+		epoch = DefaultEpoch
+	}
+
+	return &POA{
+		Authorities: normalized,
+		Epoch:       epoch,
+		Chain:       chain,
+		TxProcessor: txProcessor,
+		snapshots:   newSnapshots(snapshotCacheSize),
+	}
+}
+
+// GetDifficulty returns the difficulty of the proof of authority consensus, which is always zero.
+func (c *POA) GetDifficulty() *big.Int {
+	return big.NewInt(0)
+}
+
+// SetDifficulty is a no-op as proof of authority has no difficulty.
+func (c *POA) SetDifficulty(d *big.Int) {}
+
+// GetTarget returns the target of the proof of authority consensus, which is always zero.
+func (c *POA) GetTarget() *big.Int {
+	return big.NewInt(0)
+}
+
+// Snapshot returns the authority set after the block of the given hash, derived from the
+// genesis authorities and the votes of the chain ending at the block. The snapshot must
+// not be modified.
+func (c *POA) Snapshot(hash *util.Hash) (*Snapshot, error) {
+	genesis := &Snapshot{Authorities: c.Authorities, Votes: make(map[string]map[string]bool)}
+	if c.Chain == nil {
+		return genesis, nil
+	}
+
+	// Walk back to the latest block with a known snapshot, then replay the votes forward.
+	blocks := []*types.Block{}
+
+	var snap *Snapshot
+
+	for {
+		if cached, ok := c.snapshots.get(hash); ok {
+			snap = cached
+			break
+		}
+
+		block, err := c.Chain.GetBlockByHash(hash)
+		if err != nil {
+			return nil, fmt.Errorf("missing block %s : %w", hash.String(), err)
+		}
+
+		if block.Number.Sign() == 0 {
+			snap = genesis
+			c.snapshots.add(hash, snap)
+
+			break
+		}
+
+		blocks = append(blocks, block)
+		hash = block.ParentHash
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		snap = snap.apply(blocks[i], c.Epoch)
+		c.snapshots.add(blocks[i].DeriveHash(), snap)
+	}
+
+	return snap, nil
+}
+
+// ExpectedSigner returns the address of the authority in turn to seal the block, out of
+// the authority set after its parent.
+func (c *POA) ExpectedSigner(b *types.Block) (string, error) {
+	snap, err := c.Snapshot(b.ParentHash)
+	if err != nil {
+		return "", err
+	}
+
+	return snap.ExpectedSigner(b.Number.Uint64()), nil
+}
+
+// Mine executes the transactions of the block. Sealing happens by signing the block.
+func (c *POA) Mine(b *types.Block, mineInterrupt chan bool) *types.Block {
+	b.Transactions = c.TxProcessor.ProcessTxs(b.Transactions, c.TxProcessor.Coinbase, b.BaseFee)
+	b.SetTxRoot()
+	b.StateRoot = c.TxProcessor.StateRoot()
+
+	select {
+	case <-mineInterrupt:
+		c.TxProcessor.RollbackTxs(b.Transactions, c.TxProcessor.Coinbase, b.BaseFee)
+
+		return nil
+	default:
+		return b
+	}
+}
+
+// Validate validates the block with the proof of authority consensus.
+func (c *POA) Validate(b *types.Block) bool {
+	if !c.VerifySeal(b) {
+		return false
+	}
+
+	return c.TxProcessor.ProcessImportTxs(b.Transactions, b.Coinbase(), b.BaseFee)
+}
+
+// VerifySeal verifies that the block is signed by the authority in turn for its height.
+func (c *POA) VerifySeal(b *types.Block) bool {
+	if b.PublicKey == nil || !b.Verify() {
+		return false
+	}
+
+	signer := b.PublicKey.Address().String()
+
+	expected, err := c.ExpectedSigner(b)
+	if err != nil {
+		fmt.Println("Unknown authority set :", "number :", b.Number, "error :", err)
+		return false
+	}
+
+	if signer != expected {
+		fmt.Println("Block sealed out of turn :", "number :", b.Number, "signer :", signer, "expected :", expected)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,176 @@
+package poa
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/executer"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthorities(t *testing.T) ([]*util.UnlockedAccount, []string) {
+	t.Helper()
+
+	keys := []string{
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a2",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a3",
+	}
+
+	accounts := make([]*util.UnlockedAccount, len(keys))
+	addresses := make([]string, len(keys))
+
+	for i, key := range keys {
+		accounts[i] = util.NewUnlockedAccount(util.HexToPrivateKey(key))
+		addresses[i] = accounts[i].Address().String()
+	}
+
+	return accounts, addresses
+}
+
+func TestPOAVerifySeal(t *testing.T) {
+	t.Parallel()
+
+	accounts, addresses := newAuthorities(t)
+	c := NewPOA(addresses, 0, nil, executer.NewTxProcessor(nil, nil, nil, nil))
+
+	for height := int64(1); height <= 6; height++ {
+		for i, ua := range accounts {
+			block := types.NewBlock(big.NewInt(height), util.HashData([]byte("parent")), []byte{})
+			block.Sign(ua)
+
+			// Only the authority in turn for the height is allowed to seal.
+			inTurn := int64(i) == height%int64(len(accounts))
+			assert.Equal(t, inTurn, c.VerifySeal(block), "height %d authority %d", height, i)
+			assert.Equal(t, inTurn, c.Validate(block), "height %d authority %d", height, i)
+		}
+	}
+}
+
+func TestPOARejectsUnknownSigner(t *testing.T) {
+	t.Parallel()
+
+	_, addresses := newAuthorities(t)
+	c := NewPOA(addresses, 0, nil, nil)
+
+	outsider := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{})
+	block.Sign(outsider)
+	assert.False(t, c.VerifySeal(block))
+
+	// Unsigned blocks are rejected as well.
+	unsigned := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{})
+	assert.False(t, c.VerifySeal(unsigned))
+}
+
+func TestPOAEd25519Authority(t *testing.T) {
+	t.Parallel()
+
+	edKey, err := util.HexToEd25519PrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ua := util.NewUnlockedAccount(edKey)
+	c := NewPOA([]string{ua.Address().String()}, 0, nil, nil)
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{})
+	block.Sign(ua)
+	assert.True(t, c.VerifySeal(block))
+
+	block.ExtraData = []byte("tampered")
+	assert.False(t, c.VerifySeal(block))
+}
+
+// testChain is a chain of blocks kept by hash.
+type testChain map[string]*types.Block
+
+func (tc testChain) GetBlockByHash(hash *util.Hash) (*types.Block, error) {
+	block, ok := tc[hash.String()]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return block, nil
+}
+
+// extend appends a block carrying the votes, given as voter and candidate pairs, to the
+// block of the hash and returns the hash of the new block.
+func (tc testChain) extend(parent *util.Hash, votes ...interface{}) *util.Hash {
+	number := big.NewInt(0)
+	if block, ok := tc[parent.String()]; ok {
+		number.Add(block.Number, big.NewInt(1))
+	}
+
+	block := types.NewBlock(number, parent, []byte{})
+
+	for i := 0; i < len(votes); i += 3 {
+		tx := &types.Transaction{
+			From:  *votes[i].(*util.Address),
+			To:    *votes[i+1].(*util.Address),
+			Value: big.NewInt(0),
+			Fee:   big.NewInt(0),
+			Nonce: big.NewInt(0),
+			Data:  votes[i+2].([]byte),
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	tc[block.DeriveHash().String()] = block
+
+	return block.DeriveHash()
+}
+
+func TestPOAVotes(t *testing.T) {
+	t.Parallel()
+
+	accounts, addresses := newAuthorities(t)
+	a0, a1, a2 := accounts[0].Address(), accounts[1].Address(), accounts[2].Address()
+	candidate := util.BytesToAddress([]byte{0x04})
+	outsider := util.BytesToAddress([]byte{0x05})
+
+	chain := testChain{}
+	c := NewPOA(addresses, 4, chain, nil)
+
+	authorities := func(hash *util.Hash) []string {
+		snap, err := c.Snapshot(hash)
+		assert.NoError(t, err)
+
+		return snap.Authorities
+	}
+
+	genesis := chain.extend(util.HashData([]byte("parent")))
+	assert.Equal(t, addresses, authorities(genesis))
+
+	// Outsiders don't vote, and votes not reaching a majority are dropped at the end of
+	// the epoch, block 4. Without that, the vote of block 5 would make a majority.
+	head := chain.extend(genesis, outsider, candidate, VoteAuthorize, a0, candidate, VoteAuthorize)
+	head = chain.extend(head)
+	head = chain.extend(head)
+	head = chain.extend(head)
+	head = chain.extend(head, a1, candidate, VoteAuthorize)
+	assert.Equal(t, addresses, authorities(head))
+
+	head = chain.extend(head, a2, candidate, VoteAuthorize)
+	assert.Equal(t, append(addresses, candidate.String()), authorities(head))
+
+	// Out of four authorities, three have to vote one out. The votes of the removed
+	// authority go with it.
+	head = chain.extend(head, a1, a2, VoteDeauthorize, a2, a0, VoteDeauthorize, a0, a2, VoteDeauthorize, candidate, a2, VoteDeauthorize)
+	assert.Equal(t, []string{addresses[0], addresses[1], candidate.String()}, authorities(head))
+
+	snap, err := c.Snapshot(head)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{}, snap.Votes[addresses[0]])
+
+	// The set is derived from the branch of the block.
+	side := chain.extend(genesis, a0, candidate, VoteAuthorize, a1, candidate, VoteAuthorize)
+	assert.Equal(t, append(addresses, candidate.String()), authorities(side))
+
+	_, err = c.Snapshot(util.HashData([]byte("unknown")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package poa
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/golang/groupcache/lru"
+)
+
+// DefaultEpoch is the number of blocks votes are tallied over when no epoch is configured.
+const DefaultEpoch uint64 = 30000
+
+// snapshotCacheSize is the number of authority snapshots remembered, by block hash.
+var snapshotCacheSize = 1024
+
+// A vote is a transaction from an authority to the candidate address, carrying one of
+// these payloads as its data.
+var (
+	VoteAuthorize   = []byte("poa:authorize")
+	VoteDeauthorize = []byte("poa:deauthorize")
+)
+
+// ChainReader reads the blocks the authority set is derived from.
+type ChainReader interface {
+	GetBlockByHash(hash *util.Hash) (*types.Block, error)
+}
+
+// parseVote returns whether the transaction votes its recipient in or out, ok being false
+// if it isn't a vote.
+func parseVote(tx *types.Transaction) (authorize bool, ok bool) {
+	switch {
+	case bytes.Equal(tx.Data, VoteAuthorize):
+		return true, true
+	case bytes.Equal(tx.Data, VoteDeauthorize):
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// Snapshot is the authority set after a block, along with the votes cast since the start
+// of the epoch.
+type Snapshot struct {
+	Authorities []string                   // In sealing order
+	Votes       map[string]map[string]bool // By candidate then voter, whether the voter votes the candidate in
+}
+
+// IsAuthority reports whether the address belongs to the authority set.
+func (s *Snapshot) IsAuthority(address string) bool {
+	for _, authority := range s.Authorities {
+		if authority == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpectedSigner returns the address of the authority in turn to seal the block at the
+// given height.
+func (s *Snapshot) ExpectedSigner(number uint64) string {
+	return s.Authorities[number%uint64(len(s.Authorities))]
+}
+
+func (s *Snapshot) copy() *Snapshot {
+	cpy := &Snapshot{
+		Authorities: append([]string{}, s.Authorities...),
+		Votes:       make(map[string]map[string]bool, len(s.Votes)),
+	}
+
+	for candidate, votes := range s.Votes {
+		cpy.Votes[candidate] = make(map[string]bool, len(votes))
+		for voter, authorize := range votes {
+			cpy.Votes[candidate][voter] = authorize
+		}
+	}
+
+	return cpy
+}
+
+// apply returns the snapshot after the block. The votes of the authorities are tallied
+// in transaction order. A candidate is voted in, appended to the sealing order, or out
+// once more than half of the authorities agree, and the votes about it are cleared. The
+// votes are cleared at the end of every epoch.
+func (s *Snapshot) apply(block *types.Block, epoch uint64) *Snapshot {
+	next := s.copy()
+
+	for _, tx := range block.Transactions {
+		authorize, ok := parseVote(tx)
+		if !ok {
+			continue
+		}
+
+		voter := tx.From.String()
+		candidate := tx.To.String()
+
+		// Votes from outsiders and votes which wouldn't change the set are ignored.
+		if !next.IsAuthority(voter) || next.IsAuthority(candidate) == authorize {
+			continue
+		}
+
+		if next.Votes[candidate] == nil {
+			next.Votes[candidate] = make(map[string]bool)
+		}
+
+		next.Votes[candidate][voter] = authorize
+
+		if next.tally(candidate, authorize) <= len(next.Authorities)/2 {
+			continue
+		}
+
+		if authorize {
+			next.Authorities = append(next.Authorities, candidate)
+		} else if len(next.Authorities) > 1 {
+			next.remove(candidate)
+		}
+
+		delete(next.Votes, candidate)
+	}
+
+	if block.Number.Uint64()%epoch == 0 {
+		next.Votes = make(map[string]map[string]bool)
+	}
+
+	return next
+}
+
+// tally returns the number of authorities voting the candidate in, or out.
+func (s *Snapshot) tally(candidate string, authorize bool) int {
+	count := 0
+
+	for voter, vote := range s.Votes[candidate] {
+		if vote == authorize && s.IsAuthority(voter) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// remove drops the authority from the set, along with the votes it cast.
+func (s *Snapshot) remove(authority string) {
+	authorities := []string{}
+
+	for _, a := range s.Authorities {
+		if a != authority {
+			authorities = append(authorities, a)
+		}
+	}
+
+	s.Authorities = authorities
+
+	for _, votes := range s.Votes {
+		delete(votes, authority)
+	}
+}
+
+// snapshots remembers the snapshots after the recent blocks.
+type snapshots struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+func newSnapshots(size int) *snapshots {
+	return &snapshots{cache: lru.New(size)}
+}
+
+func (ss *snapshots) get(hash *util.Hash) (*Snapshot, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	snap, ok := ss.cache.Get(hash.String())
+	if !ok {
+		return nil, false
+	}
+
+	return snap.(*Snapshot), true
+}
+
+func (ss *snapshots) add(hash *util.Hash, snap *Snapshot) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.cache.Add(hash.String(), snap)
+}
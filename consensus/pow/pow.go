@@ -1,17 +1,36 @@
 package pow
 
 import (
-	"crypto/rand"
 	"fmt"
+	"log/slog"
 	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/0xsharma/compact-chain/executer"
 	"github.com/0xsharma/compact-chain/types"
 )
 
+// DefaultProgressInterval is the interval between mining progress reports when none
+// is configured.
+const DefaultProgressInterval = 10 * time.Second
+
+// MiningProgress reports how the nonce search of a block is going.
+type MiningProgress struct {
+	Attempts uint64        // Nonces tried so far
+	Elapsed  time.Duration // Time since the search started
+	Hashrate float64       // Nonces tried per second
+}
+
 type POW struct {
 	difficulty  *big.Int
 	TxProcessor *executer.TxProcessor
+
+	Progress         func(MiningProgress) // Called with the progress of the nonce search, logged if nil
+	ProgressInterval time.Duration        // Interval between progress reports, DefaultProgressInterval if zero
+	Threads          int                  // Goroutines searching for a nonce, one if zero
+	Logger           *slog.Logger         // Logs the progress when Progress is nil, none is reported if nil too
 }
 
 // NewPOW creates a new proof of work consensus.
@@ -34,89 +53,176 @@ func (c *POW) SetDifficulty(d *big.Int) {
 
 // GetTarget returns the target of the proof of work consensus.
 func (c *POW) GetTarget() *big.Int {
+	return targetForDifficulty(c.difficulty.Uint64())
+}
+
+// blockTarget returns the target the given block has to be mined against. Blocks
+// which don't carry a difficulty fall back to the consensus difficulty.
+func (c *POW) blockTarget(b *types.Block) *big.Int {
+	if b.Difficulty == 0 {
+		return c.GetTarget()
+	}
+
+	return targetForDifficulty(b.Difficulty)
+}
+
+// targetForDifficulty returns the target for a difficulty expressed in leading zero bits.
+func targetForDifficulty(difficulty uint64) *big.Int {
 	target := big.NewInt(1)
-	return target.Lsh(target, uint(256-c.difficulty.Int64()))
+	return target.Lsh(target, uint(256-difficulty))
 }
 
 // Mine mines the block with the proof of work consensus with the given difficulty.
+// Nonces are split between the threads, each trying its own from zero on, the progress
+// being reported at every progress interval. Mine returns nil, with the transactions of
+// the block rolled back, as soon as mineInterrupt fires.
 func (c *POW) Mine(b *types.Block, mineInterrupt chan bool) *types.Block {
-	nonce := big.NewInt(0)
+	b.Transactions = c.TxProcessor.ProcessTxs(b.Transactions, c.TxProcessor.Coinbase, b.BaseFee)
+	b.SetTxRoot()
+	b.StateRoot = c.TxProcessor.StateRoot()
 
-	validTxs := []*types.Transaction{}
+	if c.seal(b, c.blockTarget(b), mineInterrupt) {
+		return b
+	}
 
-	for _, tx := range b.Transactions {
-		if c.TxProcessor.IsValid(tx) {
-			err := c.TxProcessor.ProcessTx(tx)
-			if err == nil {
-				validTxs = append(validTxs, tx)
-			} else {
-				fmt.Println("Failed to execute Tx :", "tx :", tx, "error", err)
-			}
-		} else {
-			fmt.Println("Invalid Tx :", "tx :", tx)
-		}
+	c.TxProcessor.RollbackTxs(b.Transactions, c.TxProcessor.Coinbase, b.BaseFee)
+
+	return nil
+}
+
+// seal searches for a nonce giving the block a hash below the target, returning false
+// if mineInterrupt fires first. The search stops on every thread before seal returns.
+// With a single thread the nonces are tried in order, so the same block is always
+// sealed with the same nonce.
+func (c *POW) seal(b *types.Block, target *big.Int, mineInterrupt chan bool) bool {
+	interval := c.ProgressInterval
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+
+	threads := c.Threads
+	if threads < 1 {
+		threads = 1
 	}
 
-	b.Transactions = validTxs
+	var (
+		attempts atomic.Uint64
+		wg       sync.WaitGroup
+	)
+
+	stop := make(chan struct{})
+	found := make(chan *big.Int, threads)
+
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+
+		go func(first int64) {
+			defer wg.Done()
+
+			search(b, target, first, int64(threads), &attempts, found, stop)
+		}(int64(i))
+	}
 
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+
+	var nonce *big.Int
+
+wait:
 	for {
 		select {
+		case nonce = <-found:
+			break wait
+
 		case <-mineInterrupt:
-			for _, tx := range b.Transactions {
-				err := c.TxProcessor.RollbackTx(tx)
-				if err != nil {
-					fmt.Println("Failed to rollback Tx :", "tx :", tx, "error", err)
-				}
-			}
+			break wait
+
+		case now := <-ticker.C:
+			c.reportProgress(attempts.Load(), now.Sub(start))
+		}
+	}
 
-			return nil
+	close(stop)
+	wg.Wait()
 
-		default:
-			b.SetNonce(nonce)
-			hash := b.DeriveHash()
+	if nonce == nil {
+		return false
+	}
+
+	b.SetNonce(nonce)
+
+	return true
+}
 
-			hashBytes := hash.Bytes()
-			hashBig := new(big.Int).SetBytes(hashBytes)
+// search tries the nonces from first on, skipping the ones of the other threads, until
+// one gives the block a hash below the target or stop is closed. The block is hashed
+// through a copy of its header, so that the threads don't race on its nonce.
+func search(b *types.Block, target *big.Int, first, step int64, attempts *atomic.Uint64, found chan<- *big.Int, stop <-chan struct{}) {
+	header := *b
+	header.Nonce = big.NewInt(first)
+	increment := big.NewInt(step)
 
-			if hashBig.Cmp(c.GetTarget()) < 0 {
-				return b
+	for {
+		select {
+		case <-stop:
+			return
+
+		default:
+			attempts.Add(1)
+
+			hashBig := new(big.Int).SetBytes(header.DeriveHash().Bytes())
+			if hashBig.Cmp(target) < 0 {
+				found <- header.Nonce
+				return
 			}
 
-			n, _ := rand.Int(rand.Reader, big.NewInt(100))
-			nonce.Add(nonce, big.NewInt(n.Int64()))
+			header.Nonce.Add(header.Nonce, increment)
 		}
 	}
 }
 
-// Validate validates the block with the proof of work consensus.
-func (c *POW) Validate(b *types.Block) bool {
-	validTxs := []*types.Transaction{}
-
-	for _, tx := range b.Transactions {
-		if c.TxProcessor.IsValidImport(tx) {
-			err := c.TxProcessor.ProcessTx(tx)
-			if err == nil {
-				validTxs = append(validTxs, tx)
-			} else {
-				fmt.Println("Failed to execute Tx :", "tx :", tx, "error", err)
-				return false
-			}
-		} else {
-			fmt.Println("Invalid Tx :", "tx :", tx)
-			return false
-		}
+func (c *POW) reportProgress(attempts uint64, elapsed time.Duration) {
+	progress := MiningProgress{
+		Attempts: attempts,
+		Elapsed:  elapsed,
+		Hashrate: float64(attempts) / elapsed.Seconds(),
+	}
+
+	if c.Progress != nil {
+		c.Progress(progress)
+		return
 	}
 
-	b.Transactions = validTxs
+	if c.Logger != nil {
+		c.Logger.Info("Mining progress", "attempts", progress.Attempts, "elapsed", progress.Elapsed.Round(time.Second), "hashrate", fmt.Sprintf("%.0f H/s", progress.Hashrate))
+	}
+}
 
+// Validate validates the block with the proof of work consensus.
+func (c *POW) Validate(b *types.Block) bool {
 	hash := b.DeriveHash()
 	hashBytes := hash.Bytes()
 	hashBig := new(big.Int).SetBytes(hashBytes)
 
-	if hashBig.Cmp(c.GetTarget()) > 0 {
-		fmt.Println("Invalid block hash for POW :", hashBig, "target :", c.GetTarget())
+	target := c.blockTarget(b)
+
+	if hashBig.Cmp(target) > 0 {
+		fmt.Println("Invalid block hash for POW :", hashBig, "target :", target)
 		return false
 	}
 
-	return true
+	return c.TxProcessor.ProcessImportTxs(b.Transactions, b.Coinbase(), b.BaseFee)
+}
+
+// VerifySeal verifies that the block hash meets its target and that the block is
+// signed by the public key it carries.
+func (c *POW) VerifySeal(b *types.Block) bool {
+	hashBig := new(big.Int).SetBytes(b.DeriveHash().Bytes())
+	if hashBig.Cmp(c.blockTarget(b)) > 0 {
+		return false
+	}
+
+	return b.PublicKey != nil && b.Verify()
 }
@@ -0,0 +1,161 @@
+package pow
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/executer"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMineInterrupt(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewMemoryDBInstance()
+	assert.NoError(t, err)
+
+	coinbase := util.BytesToAddress([]byte{0x01})
+
+	// No nonce meets the target of the highest difficulty in practice.
+	c := NewPOW(255, executer.NewTxProcessor(db, big.NewInt(100), big.NewInt(1000), coinbase))
+	c.ProgressInterval = time.Millisecond
+
+	reports := make(chan MiningProgress, 100)
+	c.Progress = func(progress MiningProgress) {
+		select {
+		case reports <- progress:
+		default:
+		}
+	}
+
+	interrupt := make(chan bool)
+	mined := make(chan *types.Block)
+
+	go func() {
+		mined <- c.Mine(types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{}), interrupt)
+	}()
+
+	var first, second MiningProgress
+
+	select {
+	case first = <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mining progress reported")
+	}
+
+	// Reports may come before the search got scheduled again, wait for one with more attempts.
+	for second.Attempts <= first.Attempts {
+		select {
+		case second = <-reports:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no mining progress reported")
+		}
+	}
+
+	assert.Greater(t, second.Attempts, first.Attempts)
+	assert.Greater(t, second.Elapsed, first.Elapsed)
+	assert.Greater(t, second.Hashrate, float64(0))
+
+	close(interrupt)
+
+	select {
+	case block := <-mined:
+		assert.Nil(t, block)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mining didn't stop")
+	}
+
+	// The block reward is rolled back along with the block.
+	balance, err := db.Get(dbstore.PrefixKey(dbstore.BalanceKey, coinbase.String()))
+	if err == nil {
+		assert.Equal(t, int64(0), new(big.Int).SetBytes(balance).Int64())
+	}
+}
+
+func TestReportProgressLogged(t *testing.T) {
+	t.Parallel()
+
+	for level, logged := range map[string]bool{"info": true, "warn": false, "silent": false} {
+		var out bytes.Buffer
+
+		log, err := logger.New(&out, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := NewPOW(8, nil)
+		c.Logger = log
+		c.reportProgress(2000, 2*time.Second)
+
+		if logged {
+			assert.Contains(t, out.String(), `msg="Mining progress" attempts=2000 elapsed=2s hashrate="1000 H/s"`)
+		} else {
+			assert.Empty(t, out.String(), level)
+		}
+	}
+
+	// Without a logger, nothing is reported.
+	NewPOW(8, nil).reportProgress(2000, 2*time.Second)
+}
+
+func TestMineDeterministic(t *testing.T) {
+	t.Parallel()
+
+	c := NewPOW(8, nil)
+
+	// The nonce search is deterministic : the same block is sealed with the same nonce.
+	nonces := []string{}
+
+	for i := 0; i < 2; i++ {
+		block := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte("data"))
+		assert.True(t, c.seal(block, c.GetTarget(), make(chan bool)))
+		assert.True(t, new(big.Int).SetBytes(block.DeriveHash().Bytes()).Cmp(c.GetTarget()) < 0)
+
+		nonces = append(nonces, block.Nonce.String())
+	}
+
+	assert.Equal(t, nonces[0], nonces[1])
+}
+
+func TestMineThreads(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewMemoryDBInstance()
+	assert.NoError(t, err)
+
+	c := NewPOW(12, executer.NewTxProcessor(db, big.NewInt(100), big.NewInt(1000), util.BytesToAddress([]byte{0x01})))
+	c.Threads = 4
+
+	block := c.Mine(types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte("data")), make(chan bool))
+	if !assert.NotNil(t, block) {
+		return
+	}
+
+	// Whichever thread found the nonce, it meets the target.
+	assert.True(t, new(big.Int).SetBytes(block.DeriveHash().Bytes()).Cmp(c.GetTarget()) < 0)
+
+	// An interrupt stops all the threads.
+	c.SetDifficulty(big.NewInt(255))
+
+	interrupt := make(chan bool)
+	sealed := make(chan bool)
+
+	go func() {
+		sealed <- c.seal(types.NewBlock(big.NewInt(2), block.DeriveHash(), []byte{}), c.GetTarget(), interrupt)
+	}()
+
+	interrupt <- true
+
+	select {
+	case ok := <-sealed:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mining didn't stop")
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/p2p"
+	"github.com/0xsharma/compact-chain/protos"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// invalidBlockPeer is a peer announcing a single block on top of the genesis, which it
+// serves to whoever requests it.
+type invalidBlockPeer struct {
+	status     *p2p.Status
+	block      *types.Block
+	handshakes atomic.Int64
+
+	protos.UnimplementedP2PServer
+}
+
+func (p *invalidBlockPeer) Handshake(ctx context.Context, in *protos.HandshakeRequest) (*protos.HandshakeResponse, error) {
+	p.handshakes.Add(1)
+
+	return &protos.HandshakeResponse{
+		ProtocolVersion: p.status.ProtocolVersion,
+		NetworkId:       p.status.NetworkID,
+		GenesisHash:     p.status.GenesisHash.Bytes(),
+		Height:          1,
+	}, nil
+}
+
+func (p *invalidBlockPeer) LatestBlock(ctx context.Context, in *protos.LatestBlockRequest) (*protos.LatestBlockResponse, error) {
+	return &protos.LatestBlockResponse{Height: 1, Hash: p.block.DeriveHash().Bytes()}, nil
+}
+
+func (p *invalidBlockPeer) BlocksInRange(ctx context.Context, in *protos.BlocksInRangeRequest) (*protos.BlocksInRangeResponse, error) {
+	return &protos.BlocksInRangeResponse{Hashes: [][]byte{p.block.DeriveHash().Bytes()}}, nil
+}
+
+func (p *invalidBlockPeer) BlocksByHash(ctx context.Context, in *protos.BlocksByHashRequest) (*protos.BlocksByHashResponse, error) {
+	return &protos.BlocksByHashResponse{EncodedBlocks: [][]byte{p.block.Serialize()}}, nil
+}
+
+func (p *invalidBlockPeer) TxPoolPending(ctx context.Context, in *protos.TxpoolPendingRequest) (*protos.TxpoolPendingResponse, error) {
+	return &protos.TxpoolPendingResponse{}, nil
+}
+
+// nolint : tparallel
+func TestBanPeerSendingInvalidBlock(t *testing.T) {
+	config := newRPCTestConfig(t, ":1800", ":6151")
+	config.Mine = false
+	config.Peers = []string{"localhost:6152"}
+	config.MaxPeerBackoff = 100 * time.Millisecond
+	config.PeerBanDuration = 2 * time.Second
+
+	chain := newTestBlockchain(t, config)
+
+	defer chain.Close()
+
+	go chain.ImportBlockLoop()
+
+	// The block doesn't have the difficulty its parent requires.
+	genesis := chain.LastBlock
+	block := types.NewBlock(big.NewInt(1), genesis.DeriveHash(), []byte("Block 1"))
+	block.Timestamp = nextTimestamp(genesis)
+	block.Difficulty = chain.CalcNextDifficulty(genesis) + 1
+	block.BaseFee = chain.CalcBaseFee(genesis)
+
+	peer := &invalidBlockPeer{status: chain.P2PServer.Status, block: block}
+
+	lis, err := net.Listen("tcp", "localhost:6152")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	protos.RegisterP2PServer(srv, peer)
+
+	go srv.Serve(lis) // nolint : errcheck
+
+	defer srv.Stop()
+
+	// The peer gets banned once the block fails to import, and disconnected.
+	assert.Eventually(t, func() bool { return chain.P2PServer.Downloader.Bans.Banned("localhost:6152") }, 10*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return chain.P2PServer.Downloader.ConnectedPeers() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), chain.Current().Number.Uint64())
+
+	// It isn't dialed again while banned, then is once the ban expires.
+	assert.Never(t, func() bool { return peer.handshakes.Load() > 1 }, time.Second, 50*time.Millisecond)
+	assert.Eventually(t, func() bool { return peer.handshakes.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+// nolint : tparallel
+func TestBanPeerOfferingTooDeepReorg(t *testing.T) {
+	// The peer is a node whose heavier branch forks at genesis.
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1814", ":6166"))
+	defer fork.Close()
+
+	signerKey := fork.Config.SignerPrivateKey
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, fork.AddBlock([]byte(fmt.Sprintf("Block %d B", i)), []*types.Transaction{}, make(chan bool), signerKey))
+	}
+
+	config := newRPCTestConfig(t, ":1813", ":6165")
+	config.Mine = false
+	config.Peers = []string{"localhost:6166"}
+	config.MaxReorgDepth = 2
+	config.PeerBanDuration = time.Minute
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d A", i)), []*types.Transaction{}, make(chan bool), signerKey))
+	}
+
+	head := chain.LastBlock
+
+	go chain.ImportBlockLoop()
+
+	// Switching to the branch would drop 3 blocks, so the peer gets banned and disconnected.
+	assert.Eventually(t, func() bool { return chain.P2PServer.Downloader.Bans.Banned("localhost:6166") }, 10*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return chain.P2PServer.Downloader.ConnectedPeers() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, head.DeriveHash(), chain.Current().DeriveHash())
+}
+
+func TestIsInvalidBlock(t *testing.T) {
+	t.Parallel()
+
+	// Consensus errors ban the peer, however wrapped.
+	for _, err := range []error{ErrInvalidDifficulty, ErrInvalidStateRoot, fmt.Errorf("%w : transaction 0", types.ErrInvalidSignature)} {
+		assert.True(t, isInvalidBlock(err), err)
+	}
+
+	// Any other error doesn't, not even one the node doesn't expect.
+	for _, err := range []error{nil, ErrKnownBlock, ErrUnknownParent, ErrFutureTimestamp, ErrBlockchainClosed, ErrReadOnly, errors.New("leveldb: closed")} {
+		assert.False(t, isInvalidBlock(err), err)
+	}
+}
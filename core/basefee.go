@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrInvalidBaseFee is returned when a block doesn't carry the base fee derived from its parent.
+var ErrInvalidBaseFee = errors.New("invalid block base fee")
+
+// ErrUnderpricedTx is returned when a block includes a transaction whose fee, or maximum fee
+// for dynamic fee transactions, is below the base fee it owes for its gas.
+var ErrUnderpricedTx = errors.New("transaction fee below block base fee")
+
+// baseFeeChangeDenominator bounds the base fee change between two blocks to 1/8 (12.5%).
+var baseFeeChangeDenominator = big.NewInt(8)
+
+// CalcBaseFee returns the base fee the child of the given parent block has to carry.
+// The base fee rises when the parent used more gas than TargetBlockGas and falls when
+// it used less, by at most 1/8 of the parent base fee, and never drops below MinFee.
+// It is the base fee owed by a transaction using TxGas, transactions using more gas
+// owing proportionally more.
+func (bc *Blockchain) CalcBaseFee(parent *types.Block) *big.Int {
+	minFee := big.NewInt(0)
+	if bc.Config.MinFee != nil {
+		minFee.Set(bc.Config.MinFee)
+	}
+
+	// The first block after genesis starts at the minimum fee.
+	if parent.BaseFee == nil {
+		return minFee
+	}
+
+	target := bc.Config.TargetBlockGas
+	gasUsed := parent.GasUsed()
+
+	baseFee := new(big.Int).Set(parent.BaseFee)
+
+	switch {
+	case target == 0 || gasUsed == target:
+	case gasUsed > target:
+		// delta = parentBaseFee * (gasUsed - target) / target / 8, at least 1
+		delta := new(big.Int).Mul(parent.BaseFee, new(big.Int).SetUint64(gasUsed-target))
+		delta.Div(delta, new(big.Int).SetUint64(target))
+		delta.Div(delta, baseFeeChangeDenominator)
+
+		if delta.Sign() == 0 {
+			delta.SetInt64(1)
+		}
+
+		baseFee.Add(baseFee, delta)
+	default:
+		// delta = parentBaseFee * (target - gasUsed) / target / 8
+		delta := new(big.Int).Mul(parent.BaseFee, new(big.Int).SetUint64(target-gasUsed))
+		delta.Div(delta, new(big.Int).SetUint64(target))
+		delta.Div(delta, baseFeeChangeDenominator)
+
+		baseFee.Sub(baseFee, delta)
+	}
+
+	if baseFee.Cmp(minFee) < 0 {
+		return minFee
+	}
+
+	return baseFee
+}
+
+// verifyBaseFee checks that the block carries the base fee derived from its parent
+// and that all of its transactions can pay at least that base fee.
+func (bc *Blockchain) verifyBaseFee(block *types.Block, parent *types.Block) error {
+	expected := bc.CalcBaseFee(parent)
+	if block.BaseFee == nil || block.BaseFee.Cmp(expected) != 0 {
+		return ErrInvalidBaseFee
+	}
+
+	for _, tx := range block.Transactions {
+		if !tx.CoversBaseFee(block.BaseFee) {
+			return ErrUnderpricedTx
+		}
+	}
+
+	return nil
+}
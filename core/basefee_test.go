@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/txpool"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBaseFeeTestBlock(t *testing.T, baseFee int64, txCount int) *types.Block {
+	t.Helper()
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{})
+	block.BaseFee = big.NewInt(baseFee)
+
+	for i := 0; i < txCount; i++ {
+		block.Transactions = append(block.Transactions, newTransaction(t, []byte{0x01}, []byte{0x02}, "", baseFee, 1, int64(i)))
+	}
+
+	return block
+}
+
+func TestCalcBaseFee(t *testing.T) {
+	t.Parallel()
+
+	// The target is two transactions per block.
+	bc := &Blockchain{Config: &config.Config{MinFee: big.NewInt(100), TargetBlockGas: 2 * types.TxGas}}
+
+	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), []byte("Genesis Block"))
+	assert.Equal(t, big.NewInt(100), bc.CalcBaseFee(genesis))
+
+	// At the target the base fee holds.
+	assert.Equal(t, big.NewInt(800), bc.CalcBaseFee(newBaseFeeTestBlock(t, 800, 2)))
+
+	// Twice the target raises the base fee by 1/8.
+	assert.Equal(t, big.NewInt(900), bc.CalcBaseFee(newBaseFeeTestBlock(t, 800, 4)))
+
+	// An empty block lowers the base fee by 1/8.
+	assert.Equal(t, big.NewInt(700), bc.CalcBaseFee(newBaseFeeTestBlock(t, 800, 0)))
+
+	// The base fee doesn't drop below MinFee.
+	assert.Equal(t, big.NewInt(100), bc.CalcBaseFee(newBaseFeeTestBlock(t, 105, 0)))
+
+	// A tiny base fee still rises after a fuller block.
+	bc.Config.MinFee = big.NewInt(1)
+	assert.Equal(t, big.NewInt(8), bc.CalcBaseFee(newBaseFeeTestBlock(t, 7, 3)))
+
+	// Without a target the base fee stays put.
+	bc.Config.TargetBlockGas = 0
+	assert.Equal(t, big.NewInt(800), bc.CalcBaseFee(newBaseFeeTestBlock(t, 800, 4)))
+}
+
+func TestVerifyBaseFee(t *testing.T) {
+	t.Parallel()
+
+	bc := &Blockchain{Config: &config.Config{MinFee: big.NewInt(100), TargetBlockGas: 2 * types.TxGas}}
+	parent := newBaseFeeTestBlock(t, 800, 4)
+
+	block := newBaseFeeTestBlock(t, 900, 1)
+	assert.NoError(t, bc.verifyBaseFee(block, parent))
+
+	block.BaseFee = big.NewInt(800)
+	assert.ErrorIs(t, bc.verifyBaseFee(block, parent), ErrInvalidBaseFee)
+
+	block.BaseFee = nil
+	assert.ErrorIs(t, bc.verifyBaseFee(block, parent), ErrInvalidBaseFee)
+
+	block.BaseFee = big.NewInt(900)
+	block.Transactions[0].Fee = big.NewInt(899)
+	assert.ErrorIs(t, bc.verifyBaseFee(block, parent), ErrUnderpricedTx)
+
+	// The maximum fee of dynamic fee transactions has to cover the base fee.
+	block.Transactions[0].Fee = nil
+	block.Transactions[0].MaxFee = big.NewInt(900)
+	block.Transactions[0].MaxPriorityFee = big.NewInt(0)
+	assert.NoError(t, bc.verifyBaseFee(block, parent))
+
+	block.Transactions[0].MaxFee = big.NewInt(899)
+	assert.ErrorIs(t, bc.verifyBaseFee(block, parent), ErrUnderpricedTx)
+}
+
+// nolint : tparallel
+func TestBaseFeeFollowsBlockGas(t *testing.T) {
+	config := newRPCTestConfig(t, ":1728", ":6078")
+	config.TargetBlockGas = types.TxGas
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	nonce := int64(0)
+
+	addBlock := func(txCount int) {
+		for i := 0; i < txCount; i++ {
+			tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+			tx.Sign(ua)
+			assert.NoError(t, chain.Txpool.AddTx(tx))
+
+			nonce++
+		}
+
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", chain.LastBlock.Number.Int64()+1)), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+		assert.NoError(t, err)
+		assert.Equal(t, txCount, len(chain.LastBlock.Transactions))
+	}
+
+	// The first block starts at MinFee.
+	addBlock(3)
+	assert.Equal(t, big.NewInt(100), chain.LastBlock.BaseFee)
+
+	// The txpool refuses transactions paying less than the next base fee.
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 120, 1000, nonce)
+	tx.Sign(ua)
+	assert.ErrorIs(t, chain.Txpool.AddTx(tx), txpool.ErrUnderpriced)
+
+	// Three times the target : 100 + 100 * 2 / 8
+	addBlock(0)
+	assert.Equal(t, big.NewInt(125), chain.LastBlock.BaseFee)
+
+	// Below the target : 125 - 125 / 8
+	addBlock(0)
+	assert.Equal(t, big.NewInt(110), chain.LastBlock.BaseFee)
+}
+
+func newDynamicFeeTx(t *testing.T, ua *util.UnlockedAccount, maxFee, maxPriorityFee, value, nonce int64) *types.Transaction {
+	t.Helper()
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "tip", 0, value, nonce)
+	tx.Fee = nil
+	tx.MaxFee = big.NewInt(maxFee)
+	tx.MaxPriorityFee = big.NewInt(maxPriorityFee)
+	tx.Sign(ua)
+
+	return tx
+}
+
+// nolint : tparallel
+func TestDynamicFeeTip(t *testing.T) {
+	sink := util.BytesToAddress([]byte{0xfe})
+
+	for _, tc := range []struct {
+		name     string
+		rpcPort  string
+		p2pPort  string
+		sink     string
+		sinkGain int64
+	}{
+		{name: "burned", rpcPort: ":1806", p2pPort: ":6158"},
+		{name: "sink", rpcPort: ":1807", p2pPort: ":6159", sink: sink.String(), sinkGain: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newRPCTestConfig(t, tc.rpcPort, tc.p2pPort)
+			config.Mine = false
+			config.BaseFeeSink = tc.sink
+
+			chain := newTestBlockchain(t, config)
+			defer chain.Close()
+
+			ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+			coinbase := util.NewUnlockedAccount(config.SignerPrivateKey).Address()
+			to := util.BytesToAddress([]byte{0x01})
+
+			assert.ErrorIs(t, chain.Txpool.AddTx(newDynamicFeeTx(t, ua, 150, 151, 10, 0)), txpool.ErrTipAboveFeeCap)
+
+			tx := newDynamicFeeTx(t, ua, 150, 30, 10, 0)
+			assert.NoError(t, chain.Txpool.AddTx(tx))
+
+			senderBefore := stateBalance(t, chain, ua.Address())
+			coinbaseBefore := stateBalance(t, chain, coinbase)
+			sinkBefore := stateBalance(t, chain, sink)
+
+			// The first block has a base fee of MinFee, 100.
+			assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+			assert.Len(t, chain.LastBlock.Transactions, 1)
+			assert.Equal(t, big.NewInt(100), chain.LastBlock.BaseFee)
+
+			// The sender pays the base fee plus the tip, the coinbase only gets the tip.
+			assert.Equal(t, new(big.Int).Sub(senderBefore, big.NewInt(10+130)), stateBalance(t, chain, ua.Address()))
+			assert.Equal(t, big.NewInt(10), stateBalance(t, chain, to))
+			assert.Equal(t, new(big.Int).Add(coinbaseBefore, big.NewInt(30)), stateBalance(t, chain, coinbase))
+			assert.Equal(t, new(big.Int).Add(sinkBefore, big.NewInt(tc.sinkGain)), stateBalance(t, chain, sink))
+
+			receipt, err := chain.BlockchainDb.GetReceipt(tx.Hash())
+			assert.NoError(t, err)
+			assert.Equal(t, big.NewInt(130), receipt.Fee)
+		})
+	}
+}
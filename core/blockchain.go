@@ -1,18 +1,28 @@
 package core
 
 import (
-	"crypto/ecdsa"
+	"bytes"
+	"crypto"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
+	"os"
+	"os/signal"
+	"sort"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/0xsharma/compact-chain/config"
 	"github.com/0xsharma/compact-chain/consensus"
+	"github.com/0xsharma/compact-chain/consensus/poa"
 	"github.com/0xsharma/compact-chain/consensus/pow"
 	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/executer"
+	"github.com/0xsharma/compact-chain/explorer"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/metrics"
 	"github.com/0xsharma/compact-chain/p2p"
 	"github.com/0xsharma/compact-chain/rpc"
 	"github.com/0xsharma/compact-chain/txpool"
@@ -21,6 +31,7 @@ import (
 )
 
 type Blockchain struct {
+	Config       *config.Config
 	LastBlock    *types.Block
 	Consensus    consensus.Consensus
 	Mutex        *sync.RWMutex
@@ -32,6 +43,14 @@ type Blockchain struct {
 	TxProcessor  *executer.TxProcessor
 	Signer       *util.Address
 	P2PServer    *p2p.P2PServer
+	Logger       *slog.Logger
+	Metrics      *metrics.Metrics
+	Explorer     *explorer.Explorer
+	Miner        *Miner
+
+	orphans *orphanPool
+	known   *knownBlocks
+	valid   *validBlocks
 
 	TxpoolCh     chan *types.Transaction
 	BlockCh      chan *types.Block
@@ -40,8 +59,34 @@ type Blockchain struct {
 
 	MineInterrupt     chan bool
 	MineInterruptSize int
+
+	logLevel  *slog.LevelVar
+	readOnly  bool
+	quit      chan struct{}
+	closeDone chan struct{}
+	closeOnce sync.Once
+	closed    bool
+	wg        sync.WaitGroup
 }
 
+// ErrInvalidSeal is returned when a block is not sealed by a valid signer.
+var ErrInvalidSeal = errors.New("invalid block seal")
+
+// ErrInvalidTxRoot is returned when the transactions of a block don't match its root.
+var ErrInvalidTxRoot = errors.New("invalid block transactions root")
+
+// ErrInvalidBlockNumber is returned when a block doesn't follow the number of its parent.
+var ErrInvalidBlockNumber = errors.New("invalid block number")
+
+// ErrInvalidDifficulty is returned when a block doesn't carry the difficulty derived from its parent.
+var ErrInvalidDifficulty = errors.New("invalid block difficulty")
+
+// ErrInvalidBlockTxs is returned when the transactions of a block fail to apply to the state.
+var ErrInvalidBlockTxs = errors.New("invalid block transactions")
+
+// ErrBlockchainClosed is returned when adding blocks to a closed blockchain.
+var ErrBlockchainClosed = errors.New("blockchain is closed")
+
 // defaultConsensusDifficulty is the default difficulty for the proof of work consensus.
 var defaultConsensusDifficulty = 10
 
@@ -54,70 +99,133 @@ var defaultBlockChSize = 100
 // defaultMineInterruptSize is the default size of the mine interrupt channel.
 var defaultMineInterruptSize = 100
 
-// NewBlockchain creates a new blockchain with the given config.
-func NewBlockchain(c *config.Config) *Blockchain {
-	dbInstance, err := dbstore.NewDBInstance(c.DBDir)
+// NewBlockchain creates a new blockchain with the given config. If the config has a
+// genesis file, the chain id, initial difficulty and balance allocation of the config
+// are taken from it. Missing DB directories are created, DBs which can't be opened fail
+// with ErrDBUnavailable and corrupted ones with ErrDBCorrupted.
+func NewBlockchain(c *config.Config) (_ *Blockchain, err error) {
+	level, err := logger.ParseLevel(c.LogLevel)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	// The level is kept in a variable, so that reloading the config can change it.
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+
+	log := logger.NewWithLevel(c.LogOutput, logLevel)
+
+	var genesisSpec *Genesis
+
+	if c.GenesisFile != "" {
+		genesisSpec, err = LoadGenesis(c.GenesisFile)
+		if err != nil {
+			return nil, err
+		}
+
+		c = genesisSpec.Configure(c)
+	}
+
+	dbInstance, err := openDB(c.DBBackend, "blockchain db", c.DBDir)
+	if err != nil {
+		return nil, err
 	}
 
+	// The DBs are closed again if the blockchain fails to start.
+	defer func() {
+		if err != nil {
+			// nolint : errcheck
+			dbInstance.Close()
+		}
+	}()
+
 	blockchainDB := dbstore.NewBlockchainDB(dbInstance)
 
-	stateDBInstance, err := dbstore.NewDBInstance(c.StateDBDir)
+	if err := blockchainDB.SetCompression(c.DBCompression); err != nil {
+		return nil, err
+	}
+
+	stateDBInstance, err := openDB(c.DBBackend, "state db", c.StateDBDir)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	defer func() {
+		if err != nil {
+			// nolint : errcheck
+			stateDBInstance.Close()
+		}
+	}()
+
 	stateDB := dbstore.NewStateDB(stateDBInstance)
 
 	var genesis, lastBlock *types.Block
 
 	lastBlockHashBytes, err := blockchainDB.DB.Get(dbstore.LastHashKey)
 	if err != nil {
-		genesis = CreateGenesisBlock(c.BalanceAlloc, stateDB.DB)
+		if genesisSpec != nil {
+			genesis = genesisSpec.CreateBlock(stateDB.DB)
+		} else {
+			genesis = CreateGenesisBlock(c.BalanceAlloc, stateDB.DB)
+		}
 		lastHash := genesis.DeriveHash()
 
 		dbBatch := blockchainDB.DB.NewBatch()
 
 		// Batch write to db
 		dbBatch.Put([]byte(dbstore.LastHashKey), lastHash.Bytes())
-		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, lastHash.String())), genesis.Serialize())
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, lastHash.String())), blockchainDB.EncodeBlock(genesis))
 		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, genesis.Number.String())), lastHash.Bytes())
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, lastHash.String())), blockWork(genesis).Bytes())
 
 		// Commit batch to db
 		err = blockchainDB.DB.WriteBatch(dbBatch)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		lastBlock = genesis
 	} else {
-		lastHash := util.ByteToHash(lastBlockHashBytes)
-		lastBlockBytes, err := blockchainDB.DB.Get(dbstore.PrefixKey(dbstore.HashesKey, lastHash.String()))
+		lastBlock, err = blockchainDB.GetBlockByHash(util.ByteToHash(lastBlockHashBytes))
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		lastBlock = types.DeserializeBlock(lastBlockBytes)
 	}
 
-	var txProcessor *executer.TxProcessor
+	if err := stateDB.InitStateHistory(lastBlock.Number.Uint64()); err != nil {
+		return nil, err
+	}
+
+	// The coinbase of mined blocks is the coinbase address if set or else the signer, it is
+	// set even if mining is off so that mining can be started later on.
+	var coinbase *util.Address
 
-	if c.Mine && c.SignerPrivateKey != nil {
-		p := c.SignerPrivateKey.PublicKey
-		txProcessor = executer.NewTxProcessor(stateDB.DB, c.MinFee, util.PublicKeyToAddress(&p))
+	if c.CoinbaseAddress != "" {
+		coinbase, err = util.HexToAddress(c.CoinbaseAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coinbase address : %w", err)
+		}
+	} else if c.SignerPrivateKey != nil {
+		ua, err := util.UnlockAccount(c.SignerPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer key : %w", err)
+		}
+
+		coinbase = ua.Address()
 	}
 
-	var consensus consensus.Consensus
+	txProcessor := executer.NewTxProcessor(stateDB.DB, c.MinFee, c.BlockReward, coinbase)
 
-	switch c.ConsensusName {
-	case "pow":
-		if c.ConsensusDifficulty > 0 {
-			consensus = pow.NewPOW(c.ConsensusDifficulty, txProcessor)
-		} else {
-			consensus = pow.NewPOW(defaultConsensusDifficulty, txProcessor)
+	if c.BaseFeeSink != "" {
+		txProcessor.BaseFeeSink, err = util.HexToAddress(c.BaseFeeSink)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base fee sink address : %w", err)
 		}
-	default:
-		panic("Invalid consensus algorithm")
+	}
+
+	consensus, err := newConsensus(c, blockchainDB, txProcessor, log)
+	if err != nil {
+		return nil, err
 	}
 
 	txpoolChSize := defaultTxpoolChSize
@@ -128,17 +236,83 @@ func NewBlockchain(c *config.Config) *Blockchain {
 	blockCh := make(chan *types.Block, blockChSize)
 	mineInterrupt := make(chan bool, mineInterruptSize)
 
-	bc_txpool := txpool.NewTxPool(c.MinFee, stateDB.DB, txpoolCh)
+	bc_txpool := txpool.NewTxPool(c, stateDB.DB, txpoolCh)
 
-	rpcDomains := &rpc.RPCDomains{
-		TxPool: bc_txpool,
+	defer func() {
+		if err != nil {
+			bc_txpool.Close()
+		}
+	}()
+
+	miner := newMiner(c.Mine, c.SignerPrivateKey != nil, mineInterrupt)
+
+	genesis, err = blockchainDB.GetBlockByNumber(big.NewInt(0))
+	if err != nil {
+		return nil, err
 	}
-	rpcServer := rpc.NewRPCServer(c.RPCPort, rpcDomains)
 
-	p2pServer := p2p.NewServer(c.P2PPort, c.Peers, stateDB, blockchainDB, bc_txpool, txpoolCh, blockCh)
+	p2pStatus := p2p.NewStatus(c.NetworkID, genesis.DeriveHash())
+
+	bans := p2p.NewBans(c.PeerBanDuration, c.PeerDenylist)
+	bans.Logger = log
+
+	p2pServer := p2p.NewServer(c.P2PPort, c.Peers, p2pStatus, c.MaxPeerBackoff, c.MaxPeers, bans, stateDB, blockchainDB, bc_txpool, txpoolCh, blockCh, log)
+	p2pServer.Downloader.MaxBlockBytes = c.MaxBlockBytes
+	p2pServer.Downloader.MaxBlockTxs = c.MaxBlockTxs
+
 	go p2pServer.StartServer()
 
+	rpcDomains := &rpc.RPCDomains{
+		TxPool:        bc_txpool,
+		BlockchainDB:  blockchainDB,
+		StateDB:       stateDB,
+		Miner:         miner,
+		Node:          &nodeStatus{blockchainDB: blockchainDB, p2pServer: p2pServer, miner: miner},
+		ChainID:       c.NetworkID,
+		FinalityDepth: finalityDepth(c),
+	}
+	rpcOptions := &rpc.ServerOptions{
+		RateLimit:          c.RPCRateLimit,
+		RateBurst:          c.RPCRateBurst,
+		RateLimitWhitelist: c.RPCRateLimitWhitelist,
+		MinPeersForHealthy: c.MinPeersForHealthy,
+		ReadTimeout:        c.RPCReadTimeout,
+		WriteTimeout:       c.RPCWriteTimeout,
+		MaxBodyBytes:       c.RPCMaxBodyBytes,
+		MaxBatchSize:       c.RPCMaxBatchSize,
+		Debug:              c.DebugRPC,
+		CORSOrigins:        c.RPCCORSOrigins,
+		AuthToken:          c.RPCAuthToken,
+	}
+	rpcServer := rpc.NewRPCServer(c.RPCPort, rpcDomains, rpcOptions)
+
+	// Transactions dropped because a block used up their nonce are mostly included in
+	// it, their txStatus subscribers are notified along with the block instead.
+	bc_txpool.SetDropHook(func(tx, replacement *types.Transaction) {
+		if _, err := blockchainDB.GetTxLookup(tx.Hash()); err == nil {
+			return
+		}
+
+		rpcServer.NotifyTxDropped(tx, replacement)
+	})
+
+	nodeMetrics := metrics.New(&metrics.Sources{
+		PeerCount: p2pServer.Downloader.ConnectedPeers,
+		TxPool:    bc_txpool.Stats,
+	})
+	nodeMetrics.BlockHeight.Set(float64(lastBlock.Number.Uint64()))
+
+	if c.MetricsPort != "" {
+		nodeMetrics.Start(c.MetricsPort)
+	}
+
+	nodeExplorer := explorer.New(blockchainDB)
+	if c.ExplorerPort != "" {
+		nodeExplorer.Start(c.ExplorerPort)
+	}
+
 	bc := &Blockchain{LastBlock: lastBlock,
+		Config:        c,
 		Consensus:     consensus,
 		Mutex:         new(sync.RWMutex),
 		BlockchainDb:  blockchainDB,
@@ -148,37 +322,81 @@ func NewBlockchain(c *config.Config) *Blockchain {
 		TxProcessor:   txProcessor,
 		RPCServer:     rpcServer,
 		P2PServer:     p2pServer,
+		Logger:        log,
+		Metrics:       nodeMetrics,
+		Explorer:      nodeExplorer,
+		Miner:         miner,
 		TxpoolCh:      txpoolCh,
 		BlockCh:       blockCh,
 		MineInterrupt: mineInterrupt,
+		orphans:       newOrphanPool(defaultOrphanPoolSize, defaultOrphanTTL),
+		known:         newKnownBlocks(defaultKnownBlocksSize),
+		valid:         newValidBlocks(defaultValidBlocksSize),
+		logLevel:      logLevel,
+		quit:          make(chan struct{}),
+		closeDone:     make(chan struct{}),
 	}
 
-	return bc
+	bc_txpool.SetBaseFee(bc.CalcBaseFee(lastBlock))
+	bc_txpool.SetLegacyTxs(bc.allowLegacyTxs(new(big.Int).Add(lastBlock.Number, big.NewInt(1))))
+
+	return bc, nil
 }
 
-func StartBlockchain(config *config.Config) {
-	chain := NewBlockchain(config)
-	if chain.LastBlock.Number.Int64() == 0 {
-		fmt.Println("Number : ", chain.LastBlock.Number, "Hash : ", chain.LastBlock.DeriveHash().String())
-	} else {
-		fmt.Println("LastNumber : ", chain.LastBlock.Number, "LastHash : ", chain.LastBlock.DeriveHash().String())
+// StartBlockchain runs a node with the given config until it receives SIGINT or SIGTERM.
+// On SIGHUP, the config returned by reload is applied, if reload isn't nil. It fails if
+// the blockchain can't be created.
+func StartBlockchain(config *config.Config, reload func() (*config.Config, error)) error {
+	chain, err := NewBlockchain(config)
+	if err != nil {
+		return err
+	}
+
+	chain.Logger.Info("Loaded chain", "number", chain.LastBlock.Number, "hash", chain.LastBlock.DeriveHash().String())
+
+	if reload != nil {
+		chain.ReloadOnSignal(reload)
 	}
 
-	blockTime := config.BlockTime
+	// Close waits for both the import and the mining loop to return.
+	chain.wg.Add(2)
+
+	go func() {
+		defer chain.wg.Done()
+		chain.ImportBlockLoop()
+	}()
+
+	go chain.closeOnSignal()
+
+	chain.mineLoop(config.SignerPrivateKey, config.BlockTime)
+	chain.wg.Done()
 
-	go chain.ImportBlockLoop()
+	// Wait for Close to flush the databases before returning.
+	<-chain.closeDone
 
+	return nil
+}
+
+// mineLoop keeps mining blocks on top of the chain until the blockchain is closed. It
+// waits while the miner is stopped.
+func (bc *Blockchain) mineLoop(signerPrivateKey crypto.Signer, blockTime int) {
 	// Manual sleep to let it connect to peers
-	time.Sleep(4 * time.Second)
+	if !bc.sleep(4 * time.Second) {
+		return
+	}
 
 	for {
+		if !bc.Miner.wait(bc.quit) {
+			return
+		}
+
 		start := time.Now()
-		lastBlockNumber := chain.LastBlock.Number
+		lastBlockNumber := bc.LastBlock.Number
 
 		shouldSleep := true
 
-		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", lastBlockNumber.Int64()+1)), chain.Txpool.GetTxs(), chain.MineInterrupt, config.SignerPrivateKey)
-		if err != nil {
+		err := bc.AddBlock([]byte(fmt.Sprintf("Block %d", lastBlockNumber.Int64()+1)), bc.Txpool.GetTxs(), bc.MineInterrupt, signerPrivateKey)
+		if err != nil && !errors.Is(err, ErrInvalidSeal) {
 			shouldSleep = false
 		}
 
@@ -190,34 +408,211 @@ func StartBlockchain(config *config.Config) {
 			delay = float64(blockTime) - elapsed.Seconds()
 
 			if shouldSleep && delay > 0 {
-				time.Sleep(time.Duration(delay) * time.Second)
+				if !bc.sleep(time.Duration(delay) * time.Second) {
+					return
+				}
 			}
 		}
+
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+	}
+}
+
+// sleep waits for the given duration and returns false if the blockchain got closed meanwhile.
+func (bc *Blockchain) sleep(d time.Duration) bool {
+	select {
+	case <-bc.quit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// closeOnSignal closes the blockchain on SIGINT or SIGTERM.
+func (bc *Blockchain) closeOnSignal() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	defer signal.Stop(sigCh)
+
+	select {
+	case sig := <-sigCh:
+		bc.Logger.Info("Shutting down", "signal", sig.String())
+		bc.Close()
+	case <-bc.quit:
 	}
 }
 
+// Close stops mining and block import, shuts down the RPC and P2P servers and closes
+// both databases. It is safe to call Close more than once.
+func (bc *Blockchain) Close() {
+	bc.closeOnce.Do(func() {
+		close(bc.quit)
+
+		// Interrupt the block being mined, if any.
+		select {
+		case bc.MineInterrupt <- true:
+		default:
+		}
+
+		bc.wg.Wait()
+
+		// A read-only blockchain has no txpool.
+		if bc.Txpool != nil {
+			bc.Txpool.Close()
+		}
+
+		// A read-only blockchain runs no servers.
+		if !bc.readOnly {
+			if err := bc.RPCServer.Stop(); err != nil {
+				bc.Logger.Error("Failed to stop RPC server", "err", err)
+			}
+
+			bc.P2PServer.Stop()
+
+			if err := bc.Metrics.Stop(); err != nil {
+				bc.Logger.Error("Failed to stop metrics server", "err", err)
+			}
+
+			if err := bc.Explorer.Stop(); err != nil {
+				bc.Logger.Error("Failed to stop explorer server", "err", err)
+			}
+		}
+
+		bc.Mutex.Lock()
+		defer bc.Mutex.Unlock()
+
+		bc.closed = true
+
+		if err := bc.BlockchainDb.DB.Close(); err != nil {
+			bc.Logger.Error("Failed to close blockchain db", "err", err)
+		}
+
+		if err := bc.StateDB.DB.Close(); err != nil {
+			bc.Logger.Error("Failed to close state db", "err", err)
+		}
+
+		close(bc.closeDone)
+	})
+}
+
 func (bc *Blockchain) ImportBlockLoop() {
-	// nolint : gosimple
 	for {
 		select {
 		case block := <-bc.BlockCh:
-			err := bc.AddExternalBlock(block)
-			if err == nil {
-				bc.MineInterrupt <- true
+			head := bc.Current().DeriveHash().String()
+
+			// Restart mining only if the block moved the head of the chain. A full channel
+			// already holds an interrupt for the block being mined, so none is added.
+			err := bc.importBlock(block)
+			if err == nil && bc.Current().DeriveHash().String() != head {
+				select {
+				case bc.MineInterrupt <- true:
+				default:
+				}
 			}
+
+			if isInvalidBlock(err) {
+				bc.P2PServer.Downloader.ReportInvalidBlock(block.DeriveHash(), err)
+			}
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// consensusErrors are the import errors making a block invalid under the consensus rules,
+// whichever node validates it. Only they get the peer which sent the block banned.
+var consensusErrors = []error{
+	ErrInvalidBlockNumber,
+	ErrInvalidBlockVersion,
+	ErrFinalizedBlock,
+	ErrReorgTooDeep,
+	ErrInvalidDifficulty,
+	ErrInvalidSeal,
+	ErrInvalidTxRoot,
+	ErrDuplicateTx,
+	ErrInvalidTxOrder,
+	ErrOldTimestamp,
+	ErrInvalidBaseFee,
+	ErrUnderpricedTx,
+	ErrBlockGasLimit,
+	types.ErrInvalidChainID,
+	types.ErrInvalidSignature,
+	ErrInvalidBlockTxs,
+	ErrInvalidStateRoot,
+}
+
+// isInvalidBlock reports whether the import error makes the block invalid under the
+// consensus rules. Other errors, such as the block being known, not importable yet, too
+// far in the future for the local clock or failing on a local fault, don't.
+func isInvalidBlock(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, target := range consensusErrors {
+		if errors.Is(err, target) {
+			return true
 		}
 	}
+
+	return false
 }
 
 // AddBlock mines and adds a new block to the blockchain.
-func (bc *Blockchain) AddBlock(data []byte, txs []*types.Transaction, mineInterrupt chan bool, signerPrivateKey *ecdsa.PrivateKey) error {
+func (bc *Blockchain) AddBlock(data []byte, txs []*types.Transaction, mineInterrupt chan bool, signerPrivateKey crypto.Signer) error {
+	select {
+	case <-bc.quit:
+		return ErrBlockchainClosed
+	default:
+	}
+
+	if bc.readOnly {
+		return ErrReadOnly
+	}
+
+	ua, err := util.UnlockAccount(signerPrivateKey)
+	if err != nil {
+		return err
+	}
+
 	start := time.Now()
 
 	prevBlock := bc.LastBlock
 	blockNumber := big.NewInt(0).Add(prevBlock.Number, big.NewInt(1))
 	block := types.NewBlock(blockNumber, prevBlock.DeriveHash(), data)
 
-	block.Transactions = txs
+	block.Version = bc.blockVersion(blockNumber)
+	block.Timestamp = nextTimestamp(prevBlock)
+	block.Difficulty = bc.CalcNextDifficulty(prevBlock)
+	block.BaseFee = bc.CalcBaseFee(prevBlock)
+
+	// A head from the current second, or ahead of the clock, holds the block back until its
+	// timestamp is within the allowed drift.
+	if err := bc.waitTimestamp(block.Timestamp, mineInterrupt); err != nil {
+		return err
+	}
+
+	if err := bc.verifyTimestamp(block, prevBlock); err != nil {
+		bc.Logger.Warn("Invalid block timestamp", "number", block.Number, "timestamp", block.Timestamp, "parentTimestamp", prevBlock.Timestamp, "err", err)
+		return err
+	}
+
+	if bc.Config.CoinbaseAddress != "" {
+		block.CoinbaseAddr = bc.TxProcessor.Coinbase
+	}
+
+	block.Transactions = bc.packTxs(txs, block)
+
+	if err := verifyUniqueTxs(block); err != nil {
+		bc.Logger.Warn("Refusing to mine block with a duplicate transaction", "number", block.Number, "err", err)
+		return err
+	}
 
 	// Mine block
 	minedBlock := bc.Consensus.Mine(block, mineInterrupt)
@@ -225,26 +620,44 @@ func (bc *Blockchain) AddBlock(data []byte, txs []*types.Transaction, mineInterr
 		return errors.New("Mining interrupted")
 	}
 
-	ua := util.NewUnlockedAccount(signerPrivateKey)
 	minedBlock.Sign(ua)
 
+	if !bc.verifySeal(minedBlock) {
+		bc.TxProcessor.RollbackTxs(minedBlock.Transactions, bc.TxProcessor.Coinbase, minedBlock.BaseFee)
+		return ErrInvalidSeal
+	}
+
 	bc.Mutex.Lock()
 	defer bc.Mutex.Unlock()
 
+	if bc.closed {
+		return ErrBlockchainClosed
+	}
+
+	// An imported block may have replaced the parent while mining.
+	if minedBlock.ParentHash.String() != bc.LastBlock.DeriveHash().String() {
+		bc.TxProcessor.RollbackTxs(minedBlock.Transactions, bc.TxProcessor.Coinbase, minedBlock.BaseFee)
+		return errors.New("Parent block is no longer the head of the chain")
+	}
+
+	td := new(big.Int).Add(bc.TotalDifficulty(prevBlock), blockWork(minedBlock))
+
 	dbBatch := bc.BlockchainDb.DB.NewBatch()
 
 	// Batch write to db
-	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, minedBlock.DeriveHash().String())), minedBlock.Serialize())
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, minedBlock.DeriveHash().String())), bc.BlockchainDb.EncodeBlock(minedBlock))
 	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, minedBlock.Number.String())), minedBlock.DeriveHash().Bytes())
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, minedBlock.DeriveHash().String())), td.Bytes())
 	dbBatch.Put([]byte(dbstore.LastHashKey), minedBlock.DeriveHash().Bytes())
+	dbstore.WriteTxLookups(dbBatch, minedBlock)
+	dbstore.WriteReceipts(dbBatch, minedBlock)
 
 	// Commit batch to db
-	err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+	err = bc.BlockchainDb.DB.WriteBatch(dbBatch)
 	if err != nil {
 		panic(err)
 	}
 
-	bc.LastBlock = minedBlock
 	elapsed := time.Since(start)
 
 	for _, tx := range minedBlock.Transactions {
@@ -252,93 +665,182 @@ func (bc *Blockchain) AddBlock(data []byte, txs []*types.Transaction, mineInterr
 		bc.Txpool.RemoveTx(tx)
 	}
 
-	fmt.Println("Mined block", block.Number, block.DeriveHash().String(), "Elapsed", prettySeconds(elapsed.Seconds()), "data", string(block.ExtraData), "TxCount", len(block.Transactions))
+	bc.recordStateHistory(minedBlock)
+	bc.setHead(minedBlock)
+	bc.known.add(minedBlock.DeriveHash())
+	bc.Metrics.BlocksMined.Inc()
+
+	bc.Logger.Info("Mined block", "number", block.Number, "hash", block.DeriveHash().String(), "elapsed", prettySeconds(elapsed.Seconds()), "data", string(block.ExtraData), "txs", len(block.Transactions))
 
 	return nil
 }
 
+// setHead makes the block the head of the chain and notifies the RPC subscribers, of
+// the block and of its transactions, and the txpool, which admits transactions against
+// the base fee of the next block. The state history falling out of the retention window
+// is pruned. The block interval metric is only observed for blocks extending the
+// previous head.
+func (bc *Blockchain) setHead(block *types.Block) {
+	// The genesis block isn't mined, so the interval after it is meaningless.
+	if bc.LastBlock.Number.Sign() > 0 && block.ParentHash.String() == bc.LastBlock.DeriveHash().String() {
+		bc.Metrics.BlockInterval.Observe(float64(block.Timestamp - bc.LastBlock.Timestamp))
+	}
+
+	bc.Metrics.BlockHeight.Set(float64(block.Number.Uint64()))
+
+	bc.LastBlock = block
+	bc.RPCServer.NotifyNewHead(block)
+	bc.RPCServer.NotifyTxsIncluded(block)
+	bc.Txpool.SetBaseFee(bc.CalcBaseFee(block))
+	bc.Txpool.SetLegacyTxs(bc.allowLegacyTxs(new(big.Int).Add(block.Number, big.NewInt(1))))
+	bc.pruneStateHistory()
+}
+
 func prettySeconds(f float64) string {
 	return fmt.Sprintf("%.2fsec", f)
 }
 
-func (bc *Blockchain) RemoveLastBlock() {
-	if bc.LastBlock.Number.Int64() == 0 {
-		// Cannot remove genesis block
-		return
+// AddExternalBlock adds a block received from a peer. A block extending the head of the
+// chain is validated and appended. A block extending any other known block is stored
+// on a side branch, and the chain reorganizes to that branch once its total difficulty
+// exceeds the one of the canonical chain.
+func (bc *Blockchain) AddExternalBlock(block *types.Block) (err error) {
+	bc.Mutex.Lock()
+	defer bc.Mutex.Unlock()
+
+	if bc.closed {
+		return ErrBlockchainClosed
 	}
 
-	lastBlockParentHash := bc.LastBlock.ParentHash
+	if bc.readOnly {
+		return ErrReadOnly
+	}
 
-	dbBatch := bc.BlockchainDb.DB.NewBatch()
+	hash := block.DeriveHash()
 
-	// Batch write to db
-	dbBatch.Delete([]byte(dbstore.PrefixKey(dbstore.HashesKey, bc.LastBlock.DeriveHash().String())))
-	dbBatch.Delete([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, bc.LastBlock.Number.String())))
-	dbBatch.Put([]byte(dbstore.LastHashKey), lastBlockParentHash.Bytes())
+	if known, _ := bc.BlockchainDb.DB.Has(dbstore.PrefixKey(dbstore.HashesKey, hash.String())); known {
+		return ErrKnownBlock
+	}
 
-	// Commit batch to db
-	err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+	parent, err := bc.BlockchainDb.GetBlockByHash(block.ParentHash)
 	if err != nil {
-		panic(err)
+		bc.Logger.Warn("Invalid parent hash", "number", block.Number, "parentHash", block.ParentHash.String(), "headNumber", bc.LastBlock.Number, "headHash", bc.LastBlock.DeriveHash().String())
+		return ErrUnknownParent
 	}
 
-	for _, tx := range bc.LastBlock.Transactions {
-		err := bc.TxProcessor.RollbackTx(tx)
+	if new(big.Int).Add(parent.Number, big.NewInt(1)).Cmp(block.Number) != 0 {
+		bc.Logger.Warn("Invalid block number", "number", block.Number, "hash", hash.String(), "parentNumber", parent.Number, "parentHash", parent.DeriveHash().String())
+		return ErrInvalidBlockNumber
+	}
+
+	if err := bc.verifyBlockVersion(block); err != nil {
+		bc.Logger.Warn("Invalid block version", "number", block.Number, "hash", hash.String(), "version", block.Version, "legacyHashBlock", bc.Config.LegacyHashBlock)
+		return err
+	}
+
+	if err := bc.verifyFinality(block); err != nil {
+		bc.Logger.Warn("Block conflicts with a finalized block", "number", block.Number, "hash", hash.String(), "headNumber", bc.LastBlock.Number, "finalityDepth", bc.Config.FinalityDepth, "maxReorgDepth", bc.Config.MaxReorgDepth)
+		return err
+	}
+
+	if expected := bc.CalcNextDifficulty(parent); block.Difficulty != expected {
+		bc.Logger.Warn("Invalid block difficulty", "number", block.Number, "difficulty", block.Difficulty, "expected", expected)
+		return ErrInvalidDifficulty
+	}
+
+	if !bc.verifySeal(block) {
+		bc.Logger.Warn("Invalid block seal", "number", block.Number, "hash", hash.String())
+		return ErrInvalidSeal
+	}
+
+	// The seal stays verified only if the block is valid in the context of its parent too.
+	defer func() {
 		if err != nil {
-			fmt.Println("Failed to rollback Tx :", "tx :", tx, "error", err)
+			bc.valid.remove(block)
 		}
+	}()
+
+	if !block.VerifyTxRoot() {
+		bc.Logger.Warn("Invalid block transactions root", "number", block.Number, "hash", hash.String(), "txRoot", block.TxRoot.String(), "txs", len(block.Transactions))
+		return ErrInvalidTxRoot
 	}
 
-	newLastBlock, err := bc.BlockchainDb.GetBlockByHash(lastBlockParentHash)
-	if err != nil {
-		panic(err)
+	if err := verifyUniqueTxs(block); err != nil {
+		bc.Logger.Warn("Invalid block duplicate transaction", "number", block.Number, "hash", hash.String(), "err", err)
+		return err
 	}
 
-	bc.LastBlock = newLastBlock
-}
+	if err := verifyTxOrder(block); err != nil {
+		bc.Logger.Warn("Invalid block transaction order", "number", block.Number, "hash", hash.String())
+		return err
+	}
 
-// AddBlock mines and adds a new block to the blockchain.
-func (bc *Blockchain) AddExternalBlock(block *types.Block) error {
-	bc.Mutex.Lock()
-	defer bc.Mutex.Unlock()
+	if err := bc.verifyTimestamp(block, parent); err != nil {
+		bc.Logger.Warn("Invalid block timestamp", "number", block.Number, "hash", hash.String(), "timestamp", block.Timestamp, "parentTimestamp", parent.Timestamp, "err", err)
+		return err
+	}
 
-	currentLatestBlock := bc.LastBlock
-	externalBlock := block
+	if err := bc.verifyBaseFee(block, parent); err != nil {
+		bc.Logger.Warn("Invalid block base fee", "number", block.Number, "baseFee", block.BaseFee, "err", err)
+		return err
+	}
 
-	if currentLatestBlock.Number.Int64() > externalBlock.Number.Int64() {
-		fmt.Println("Invalid block number", block.Number, block.DeriveHash().String(), "LastBlock", bc.LastBlock.Number, bc.LastBlock.DeriveHash().String())
-		return fmt.Errorf("Invalid block number")
+	if err := bc.verifyGasLimit(block); err != nil {
+		bc.Logger.Warn("Invalid block gas used", "number", block.Number, "gasUsed", block.GasUsed(), "gasLimit", bc.Config.BlockGasLimit)
+		return err
 	}
 
-	if currentLatestBlock.Number.Int64() == externalBlock.Number.Int64() {
-		if currentLatestBlock.Nonce.Int64() > externalBlock.Nonce.Int64() {
-			return fmt.Errorf("Better Block already exists")
-		} else if currentLatestBlock.Nonce.Int64() < externalBlock.Nonce.Int64() {
-			fmt.Println("REORG : Better remote Block found", block.Number, block.DeriveHash().String())
-			bc.RemoveLastBlock()
-		}
+	if err := bc.verifyChainIDs(block); err != nil {
+		bc.Logger.Warn("Invalid block transaction chain id", "number", block.Number, "hash", hash.String(), "chainID", bc.Config.NetworkID)
+		return err
 	}
 
-	if block.ParentHash.String() != bc.LastBlock.DeriveHash().String() {
-		fmt.Println("Invalid parent hash", block.Number, block.ParentHash, "LastBlock", bc.LastBlock.Number, bc.LastBlock.DeriveHash().String())
-		return fmt.Errorf("Invalid parent hash")
+	if err := bc.TxProcessor.VerifySignatures(block.Transactions); err != nil {
+		bc.Logger.Warn("Invalid block transaction signature", "number", block.Number, "hash", hash.String(), "err", err)
+		return err
+	}
+
+	td := new(big.Int).Add(bc.TotalDifficulty(parent), blockWork(block))
+
+	if parent.DeriveHash().String() != bc.LastBlock.DeriveHash().String() {
+		dbBatch := bc.BlockchainDb.DB.NewBatch()
+
+		// Batch write to db
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, hash.String())), bc.BlockchainDb.EncodeBlock(block))
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, hash.String())), td.Bytes())
+
+		// Commit batch to db
+		err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+		if err != nil {
+			panic(err)
+		}
+
+		bc.Logger.Info("Stored side block", "number", block.Number, "hash", hash.String())
+
+		if td.Cmp(bc.TotalDifficulty(bc.LastBlock)) <= 0 {
+			return nil
+		}
+
+		return bc.reorg(block)
 	}
 
 	// Validate block
-	if valid := bc.Consensus.Validate(block); !valid {
-		fmt.Println("Invalid block", block.Number, block.DeriveHash().String())
-		return fmt.Errorf("Invalid block")
+	if err := bc.applyBlock(block); err != nil {
+		return err
 	}
 
 	dbBatch := bc.BlockchainDb.DB.NewBatch()
 
 	// Batch write to db
-	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, block.DeriveHash().String())), block.Serialize())
-	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())), block.DeriveHash().Bytes())
-	dbBatch.Put([]byte(dbstore.LastHashKey), block.DeriveHash().Bytes())
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, hash.String())), bc.BlockchainDb.EncodeBlock(block))
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())), hash.Bytes())
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, hash.String())), td.Bytes())
+	dbBatch.Put([]byte(dbstore.LastHashKey), hash.Bytes())
+	dbstore.WriteTxLookups(dbBatch, block)
+	dbstore.WriteReceipts(dbBatch, block)
 
 	// Commit batch to db
-	err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+	err = bc.BlockchainDb.DB.WriteBatch(dbBatch)
 	if err != nil {
 		panic(err)
 	}
@@ -348,16 +850,65 @@ func (bc *Blockchain) AddExternalBlock(block *types.Block) error {
 		bc.Txpool.RemoveTx(tx)
 	}
 
-	bc.LastBlock = block
-	fmt.Println("Imported block", block.Number, block.DeriveHash().String(), "TxCount", len(block.Transactions))
+	bc.recordStateHistory(block)
+	bc.setHead(block)
+
+	bc.Logger.Info("Imported block", "number", block.Number, "hash", hash.String(), "txs", len(block.Transactions))
 
 	return nil
 }
 
+// newConsensus returns the consensus engine named by the config, logging the mining
+// progress to log.
+func newConsensus(c *config.Config, blockchainDB *dbstore.BlockchainDB, txProcessor *executer.TxProcessor, log *slog.Logger) (consensus.Consensus, error) {
+	switch c.ConsensusName {
+	case "pow":
+		difficulty := defaultConsensusDifficulty
+		if c.ConsensusDifficulty > 0 {
+			difficulty = c.ConsensusDifficulty
+		}
+
+		engine := pow.NewPOW(difficulty, txProcessor)
+		engine.Threads = c.MiningThreads
+		engine.Logger = log
+
+		if err := checkDifficultyBounds(c, engine.GetDifficulty().Uint64()); err != nil {
+			return nil, err
+		}
+
+		return engine, nil
+	case "poa":
+		if len(c.Authorities) == 0 {
+			return nil, errors.New("No authorities configured for proof of authority")
+		}
+
+		return poa.NewPOA(c.Authorities, c.AuthorityEpoch, blockchainDB, txProcessor), nil
+	default:
+		return nil, errors.New("Invalid consensus algorithm")
+	}
+}
+
 // Mine the genesis block and do initial balance allocation.
 func CreateGenesisBlock(balanceAlloc map[string]*big.Int, db *dbstore.DB) *types.Block {
-	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), []byte("Genesis Block"))
+	// The extra data commits to the balance allocation, so that chains with different
+	// allocations have different genesis hashes.
+	extraData := append([]byte("Genesis Block"), allocHash(balanceAlloc).Bytes()...)
+	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), extraData)
+
+	// The genesis block keeps the hash it had before block versions.
+	genesis.Version = 0
+
+	// The timestamp is fixed rather than the time of the first start, so that nodes given
+	// the same allocation get the same block.
+	genesis.Timestamp = 0
+
+	allocBalances(balanceAlloc, db)
 
+	return genesis
+}
+
+// allocBalances writes the initial balances to the state.
+func allocBalances(balanceAlloc map[string]*big.Int, db *dbstore.DB) {
 	dbBatch := db.NewBatch()
 
 	for address, balance := range balanceAlloc {
@@ -370,8 +921,24 @@ func CreateGenesisBlock(balanceAlloc map[string]*big.Int, db *dbstore.DB) *types
 	if err != nil {
 		panic(err)
 	}
+}
+
+// allocHash returns the hash of the balance allocation, in address order.
+func allocHash(balanceAlloc map[string]*big.Int) *util.Hash {
+	addresses := make([]string, 0, len(balanceAlloc))
+	for address := range balanceAlloc {
+		addresses = append(addresses, address)
+	}
 
-	return genesis
+	sort.Strings(addresses)
+
+	var buf bytes.Buffer
+
+	for _, address := range addresses {
+		fmt.Fprintf(&buf, "%s:%s;", address, balanceAlloc[address].String())
+	}
+
+	return util.HashData(buf.Bytes())
 }
 
 // Current returns the current block in the blockchain.
@@ -401,10 +968,5 @@ func (bc *Blockchain) GetBlockByNumber(b *big.Int) (*types.Block, error) {
 
 // GetBlockByHash returns the block with the given block hash.
 func (bc *Blockchain) GetBlockByHash(h *util.Hash) (*types.Block, error) {
-	blockBytes, err := bc.BlockchainDb.DB.Get(dbstore.PrefixKey(dbstore.HashesKey, h.String()))
-	if err != nil {
-		return nil, err
-	}
-
-	return types.DeserializeBlock(blockBytes), nil
+	return bc.BlockchainDb.GetBlockByHash(h)
 }
@@ -38,7 +38,7 @@ func TestP2P(t *testing.T) {
 		BlockTime:        4,
 	}
 
-	chain := NewBlockchain(config)
+	chain := newTestBlockchain(t, config)
 	if chain.LastBlock.Number.Int64() == 0 {
 		fmt.Println("Number : ", chain.LastBlock.Number, "Hash : ", chain.LastBlock.DeriveHash().String())
 	} else {
@@ -78,6 +78,16 @@ func TestP2P(t *testing.T) {
 
 	// ASSERTIONS
 
+	// Test Handshake, required before any other call
+	_, err := client.Handshake(context.Background(), &protos.HandshakeRequest{
+		ProtocolVersion: chain.P2PServer.Status.ProtocolVersion,
+		NetworkId:       chain.P2PServer.Status.NetworkID,
+		GenesisHash:     chain.P2PServer.Status.GenesisHash.Bytes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Test LatestBlock
 	r, err := client.LatestBlock(context.Background(), &protos.LatestBlockRequest{})
 	if err != nil {
@@ -129,3 +139,42 @@ func TestP2P(t *testing.T) {
 	assert.Equal(t, chainBlocks[1].DeriveHash(), blocksInRange[1].DeriveHash())
 	assert.Equal(t, chainBlocks[0].DeriveHash(), blocksInRange[0].DeriveHash())
 }
+
+// nolint : tparallel
+func TestP2PHandshakeGenesisMismatch(t *testing.T) {
+	configA := newRPCTestConfig(t, ":1730", ":6080")
+	configA.Peers = []string{"localhost:6081"}
+
+	// Node B allocates different balances, so it starts from a different genesis block.
+	configB := newRPCTestConfig(t, ":1731", ":6081")
+	configB.Peers = []string{"localhost:6080"}
+	configB.BalanceAlloc = map[string]*big.Int{
+		"0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": big.NewInt(1000),
+	}
+
+	// Node C shares the genesis block of node A.
+	configC := newRPCTestConfig(t, ":1732", ":6082")
+	configC.Peers = []string{"localhost:6080"}
+
+	chainA := newTestBlockchain(t, configA)
+	defer chainA.Close()
+
+	chainB := newTestBlockchain(t, configB)
+	defer chainB.Close()
+
+	chainC := newTestBlockchain(t, configC)
+	defer chainC.Close()
+
+	assert.NotEqual(t, chainA.LastBlock.DeriveHash(), chainB.LastBlock.DeriveHash())
+
+	dropped := func(chain *Blockchain) func() bool {
+		return func() bool {
+			return len(chain.P2PServer.Downloader.GetPeers()) == 0
+		}
+	}
+
+	assert.Eventually(t, dropped(chainA), 15*time.Second, 100*time.Millisecond)
+	assert.Eventually(t, dropped(chainB), 15*time.Second, 100*time.Millisecond)
+
+	assert.Equal(t, 1, len(chainC.P2PServer.Downloader.GetPeers()))
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/consensus/poa"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestPOAOutOfTurnBlockRejected(t *testing.T) {
+	keys := []string{
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a2",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a3",
+	}
+
+	authorities := make([]string, len(keys))
+	for i, key := range keys {
+		authorities[i] = util.NewUnlockedAccount(util.HexToPrivateKey(key)).Address().String()
+	}
+
+	config := &config.Config{
+		ConsensusName:    "poa",
+		Authorities:      authorities,
+		DBDir:            t.TempDir(),
+		StateDBDir:       t.TempDir(),
+		MinFee:           big.NewInt(100),
+		RPCPort:          ":1721",
+		P2PPort:          ":6071",
+		Mine:             true,
+		SignerPrivateKey: util.HexToPrivateKey(keys[0]),
+		BlockTime:        1,
+	}
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	// Block 1 has to be sealed by the second authority.
+	err := chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), util.HexToPrivateKey(keys[0]))
+	assert.ErrorIs(t, err, ErrInvalidSeal)
+	assert.Equal(t, int64(0), chain.LastBlock.Number.Int64())
+
+	err = chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), util.HexToPrivateKey(keys[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), chain.LastBlock.Number.Int64())
+
+	// An externally received block sealed out of turn is rejected too.
+	block := types.NewBlock(big.NewInt(2), chain.LastBlock.DeriveHash(), []byte("Block 2"))
+	block.Sign(util.NewUnlockedAccount(util.HexToPrivateKey(keys[1])))
+
+	err = chain.AddExternalBlock(block)
+	assert.ErrorIs(t, err, ErrInvalidSeal)
+	assert.Equal(t, int64(1), chain.LastBlock.Number.Int64())
+}
+
+// nolint : tparallel
+func TestPOAVoteInAuthority(t *testing.T) {
+	keys := []string{
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a1",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a2",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a3",
+		"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a4",
+	}
+
+	accounts := make([]*util.UnlockedAccount, len(keys))
+	for i, key := range keys {
+		accounts[i] = util.NewUnlockedAccount(util.HexToPrivateKey(key))
+	}
+
+	candidate := accounts[3].Address()
+
+	config := &config.Config{
+		ConsensusName: "poa",
+		Authorities:   []string{accounts[0].Address().String(), accounts[1].Address().String(), accounts[2].Address().String()},
+		DBDir:         t.TempDir(),
+		StateDBDir:    t.TempDir(),
+		MinFee:        big.NewInt(100),
+		RPCPort:       ":1790",
+		P2PPort:       ":6141",
+		BalanceAlloc: map[string]*big.Int{
+			accounts[0].Address().String(): big.NewInt(1000000),
+			accounts[1].Address().String(): big.NewInt(1000000),
+		},
+		SignerPrivateKey: util.HexToPrivateKey(keys[0]),
+		BlockTime:        1,
+	}
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	consensus, ok := chain.Consensus.(*poa.POA)
+	assert.True(t, ok)
+
+	addBlock := func(sealer int) error {
+		return chain.AddBlock([]byte("Block"), chain.Txpool.GetTxs(), make(chan bool), util.HexToPrivateKey(keys[sealer]))
+	}
+
+	vote := func(voter int) {
+		tx := newTransaction(t, accounts[voter].Address().Bytes(), candidate.Bytes(), "", 100, 0, 0)
+		tx.Data = poa.VoteAuthorize
+		tx.Sign(accounts[voter])
+
+		assert.NoError(t, chain.Txpool.AddTx(tx))
+	}
+
+	authorities := func() []string {
+		snap, err := consensus.Snapshot(chain.LastBlock.DeriveHash())
+		assert.NoError(t, err)
+
+		return snap.Authorities
+	}
+
+	// The candidate isn't an authority yet.
+	assert.ErrorIs(t, addBlock(3), ErrInvalidSeal)
+
+	// A single vote out of three isn't a majority.
+	vote(0)
+	assert.NoError(t, addBlock(1))
+	assert.Len(t, chain.LastBlock.Transactions, 1)
+	assert.Len(t, authorities(), 3)
+
+	// The second one is, the candidate joins the end of the sealing order.
+	vote(1)
+	assert.NoError(t, addBlock(2))
+	assert.Len(t, chain.LastBlock.Transactions, 1)
+	assert.Equal(t, append(config.Authorities, candidate.String()), authorities())
+
+	// Block 3 now goes to the fourth authority rather than the first one.
+	assert.ErrorIs(t, addBlock(0), ErrInvalidSeal)
+	assert.NoError(t, addBlock(3))
+	assert.Equal(t, int64(3), chain.LastBlock.Number.Int64())
+}
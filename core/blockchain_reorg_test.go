@@ -0,0 +1,337 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func stateBalance(t *testing.T, chain *Blockchain, address *util.Address) *big.Int {
+	t.Helper()
+
+	balance, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, address.String()))
+	if err != nil {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).SetBytes(balance)
+}
+
+// nolint : tparallel
+func TestReorgToHeavierBranch(t *testing.T) {
+	chain := newTestBlockchain(t, newRPCTestConfig(t, ":1725", ":6075"))
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1726", ":6076"))
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+		fork.RPCServer.HttpServer.Shutdown(context.Background())
+		fork.P2PServer.GRPCSrv.Stop()
+	}()
+
+	assert.Equal(t, chain.LastBlock.DeriveHash(), fork.LastBlock.DeriveHash())
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+	signerKey := chain.Config.SignerPrivateKey
+
+	to1 := util.BytesToAddress([]byte{0x01})
+	to2 := util.BytesToAddress([]byte{0x02})
+
+	tx1 := newTransaction(t, ua.Address().Bytes(), to1.Bytes(), "hello", 100, 1000, 0)
+	tx1.Sign(ua)
+
+	tx2 := newTransaction(t, ua.Address().Bytes(), to2.Bytes(), "hello", 200, 2000, 0)
+	tx2.Sign(ua)
+
+	// The local chain mines 2 blocks, the first one including tx1.
+	assert.NoError(t, chain.AddBlock([]byte("Block 1 A"), []*types.Transaction{tx1}, make(chan bool), signerKey))
+	assert.NoError(t, chain.AddBlock([]byte("Block 2 A"), []*types.Transaction{}, make(chan bool), signerKey))
+	assert.Equal(t, big.NewInt(1000), stateBalance(t, chain, to1))
+
+	_, block, _, err := chain.BlockchainDb.GetTransactionByHash(tx1.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), block.Number)
+
+	// The competing chain mines 3 blocks, the first one including tx2 instead.
+	forkBlocks := []*types.Block{}
+
+	for i, txs := range [][]*types.Transaction{{tx2}, {}, {}} {
+		assert.NoError(t, fork.AddBlock([]byte("Block "+big.NewInt(int64(i+1)).String()+" B"), txs, make(chan bool), signerKey))
+		forkBlocks = append(forkBlocks, fork.LastBlock)
+	}
+
+	head := chain.LastBlock
+
+	// Branches up to the same total difficulty are stored without switching.
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[0]))
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[1]))
+	assert.Equal(t, head.DeriveHash(), chain.LastBlock.DeriveHash())
+	assert.Equal(t, big.NewInt(1000), stateBalance(t, chain, to1))
+
+	// The third block makes the competing branch heavier.
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[2]))
+	assert.Equal(t, forkBlocks[2].DeriveHash(), chain.LastBlock.DeriveHash())
+	assert.Equal(t, 0, chain.TotalDifficulty(chain.LastBlock).Cmp(fork.TotalDifficulty(fork.LastBlock)))
+
+	for i, block := range forkBlocks {
+		canonical, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(int64(i + 1)))
+		assert.NoError(t, err)
+		assert.Equal(t, block.DeriveHash(), canonical.DeriveHash())
+	}
+
+	// The state matches the one of the competing chain.
+	for _, address := range []*util.Address{ua.Address(), to1, to2, fork.TxProcessor.Coinbase} {
+		assert.Equal(t, stateBalance(t, fork, address), stateBalance(t, chain, address))
+	}
+
+	assert.Equal(t, big.NewInt(0), stateBalance(t, chain, to1))
+	assert.Equal(t, big.NewInt(2000), stateBalance(t, chain, to2))
+
+	// The tx index follows the new branch.
+	_, _, _, err = chain.BlockchainDb.GetTransactionByHash(tx1.Hash())
+	assert.Error(t, err)
+
+	_, block, _, err = chain.BlockchainDb.GetTransactionByHash(tx2.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, forkBlocks[0].DeriveHash(), block.DeriveHash())
+
+	// Blocks of the abandoned branch are not imported again.
+	assert.Error(t, chain.AddExternalBlock(head))
+}
+
+// nolint : tparallel
+func TestReorgBelowFinalityRefused(t *testing.T) {
+	config := newRPCTestConfig(t, ":1796", ":6147")
+	config.FinalityDepth = 2
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1797", ":6148"))
+	defer fork.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	signerKey := chain.Config.SignerPrivateKey
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d A", i)), []*types.Transaction{}, make(chan bool), signerKey))
+	}
+
+	// The competing branch forks at genesis and is heavier.
+	forkBlocks := []*types.Block{}
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, fork.AddBlock([]byte(fmt.Sprintf("Block %d B", i)), []*types.Transaction{}, make(chan bool), signerKey))
+		forkBlocks = append(forkBlocks, fork.LastBlock)
+	}
+
+	head := chain.LastBlock
+
+	// Block 1 is buried under 2 blocks, blocks competing with it are refused.
+	assert.True(t, chain.IsFinalized(big.NewInt(1)))
+	assert.False(t, chain.IsFinalized(big.NewInt(2)))
+
+	for number, final := range map[string]bool{"0x1": true, "2": false, "latest": false, "100": false} {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_isFinalized", number)
+		assert.Nil(t, res.Error)
+		assert.Equal(t, fmt.Sprint(final), string(res.Result), number)
+	}
+
+	assert.ErrorIs(t, chain.AddExternalBlock(forkBlocks[0]), ErrFinalizedBlock)
+
+	// Side blocks stored before the finality depth applied can't make the chain reorg below it either.
+	chain.Config.FinalityDepth = 0
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[0]))
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[1]))
+
+	chain.Config.FinalityDepth = 2
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[2]))
+	assert.ErrorIs(t, chain.AddExternalBlock(forkBlocks[3]), ErrFinalizedBlock)
+	assert.Equal(t, head.DeriveHash(), chain.Current().DeriveHash())
+
+	canonical, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.NotEqual(t, forkBlocks[0].DeriveHash(), canonical.DeriveHash())
+
+	// Without finality the heavier branch wins.
+	chain.Config.FinalityDepth = 0
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[4]))
+	assert.Equal(t, forkBlocks[4].DeriveHash(), chain.Current().DeriveHash())
+}
+
+// nolint : tparallel
+func TestReorgDeeperThanMaxRefused(t *testing.T) {
+	config := newRPCTestConfig(t, ":1811", ":6163")
+	config.MaxReorgDepth = 2
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1812", ":6164"))
+	defer fork.Close()
+
+	signerKey := chain.Config.SignerPrivateKey
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d A", i)), []*types.Transaction{}, make(chan bool), signerKey))
+	}
+
+	// The competing branch forks at genesis and is heavier.
+	forkBlocks := []*types.Block{}
+
+	for i := 1; i <= 6; i++ {
+		assert.NoError(t, fork.AddBlock([]byte(fmt.Sprintf("Block %d B", i)), []*types.Transaction{}, make(chan bool), signerKey))
+		forkBlocks = append(forkBlocks, fork.LastBlock)
+	}
+
+	head := chain.LastBlock
+
+	// Dropping the 3 canonical blocks exceeds the maximum depth, so block 1 is final.
+	assert.True(t, chain.IsFinalized(big.NewInt(1)))
+
+	err := chain.AddExternalBlock(forkBlocks[0])
+	assert.ErrorIs(t, err, ErrReorgTooDeep)
+	assert.True(t, isInvalidBlock(err))
+
+	// Side blocks stored before the limit applied can't make the chain reorg that deep either.
+	chain.Config.MaxReorgDepth = 0
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[0]))
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[1]))
+
+	chain.Config.MaxReorgDepth = 2
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[2]))
+	assert.ErrorIs(t, chain.AddExternalBlock(forkBlocks[3]), ErrReorgTooDeep)
+	assert.Equal(t, head.DeriveHash(), chain.Current().DeriveHash())
+
+	// A smaller finality depth takes precedence.
+	chain.Config.FinalityDepth = 1
+
+	assert.ErrorIs(t, chain.AddExternalBlock(forkBlocks[4]), ErrFinalizedBlock)
+
+	// Within the limit the heavier branch wins.
+	chain.Config.FinalityDepth = 0
+	chain.Config.MaxReorgDepth = 3
+
+	assert.NoError(t, chain.AddExternalBlock(forkBlocks[5]))
+	assert.Equal(t, forkBlocks[5].DeriveHash(), chain.Current().DeriveHash())
+}
+
+// nolint : tparallel
+func TestReorgReinjectsDroppedTxs(t *testing.T) {
+	chain := newTestBlockchain(t, newRPCTestConfig(t, ":1802", ":6154"))
+	defer chain.Close()
+
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1803", ":6155"))
+	defer fork.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	signerKey := chain.Config.SignerPrivateKey
+	to := util.BytesToAddress([]byte{0x01})
+
+	tx1 := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 100, 1000, 0)
+	tx1.Sign(ua)
+
+	tx2 := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 100, 2000, 1)
+	tx2.Sign(ua)
+
+	// The local block includes both transactions, the heavier competing branch only tx1.
+	assert.NoError(t, chain.AddBlock([]byte("Block 1 A"), []*types.Transaction{tx1, tx2}, make(chan bool), signerKey))
+	assert.Empty(t, chain.Txpool.Pending())
+
+	assert.NoError(t, fork.AddBlock([]byte("Block 1 B"), []*types.Transaction{tx1}, make(chan bool), signerKey))
+	assert.NoError(t, fork.AddBlock([]byte("Block 2 B"), []*types.Transaction{}, make(chan bool), signerKey))
+
+	for _, number := range []int64{1, 2} {
+		block, err := fork.BlockchainDb.GetBlockByNumber(big.NewInt(number))
+		assert.NoError(t, err)
+		assert.NoError(t, chain.AddExternalBlock(block))
+	}
+
+	assert.Equal(t, fork.LastBlock.DeriveHash(), chain.LastBlock.DeriveHash())
+
+	// tx2 is pending again with its nonce next after tx1, tx1 being part of the new branch.
+	assert.Equal(t, []*types.Transaction{tx2}, chain.Txpool.Pending())
+	assert.Equal(t, big.NewInt(1000), stateBalance(t, chain, to))
+
+	// It gets mined on top of the new branch.
+	assert.NoError(t, chain.AddBlock([]byte("Block 3"), chain.Txpool.GetTxs(), make(chan bool), signerKey))
+	assert.Equal(t, big.NewInt(3000), stateBalance(t, chain, to))
+	assert.Empty(t, chain.Txpool.Pending())
+}
+
+// nolint : tparallel
+func TestReorgForgetsInvalidBranch(t *testing.T) {
+	chain := newTestBlockchain(t, newRPCTestConfig(t, ":1829", ":6181"))
+	defer chain.Close()
+
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1830", ":6182"))
+	defer fork.Close()
+
+	signerKey := chain.Config.SignerPrivateKey
+	signer := util.NewUnlockedAccount(signerKey)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1 A"), []*types.Transaction{}, make(chan bool), signerKey))
+
+	head := chain.LastBlock
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, fork.AddBlock([]byte(fmt.Sprintf("Block %d B", i)), []*types.Transaction{}, make(chan bool), signerKey))
+	}
+
+	// The first block of the competing branch commits to a wrong state, which only shows
+	// once the branch is heavier and gets applied.
+	branch := []*types.Block{}
+
+	var parent *types.Block
+
+	for i := int64(1); i <= 3; i++ {
+		block, err := fork.BlockchainDb.GetBlockByNumber(big.NewInt(i))
+		assert.NoError(t, err)
+
+		if i == 1 {
+			block.StateRoot = util.HashData([]byte("wrong state"))
+		} else {
+			block.ParentHash = parent.DeriveHash()
+		}
+
+		for nonce := int64(0); ; nonce++ {
+			block.SetNonce(big.NewInt(nonce))
+			block.Sign(signer)
+
+			if chain.Consensus.VerifySeal(block) {
+				break
+			}
+		}
+
+		branch = append(branch, block)
+		parent = block
+	}
+
+	assert.NoError(t, chain.AddExternalBlock(branch[0]))
+	assert.ErrorIs(t, chain.AddExternalBlock(branch[1]), ErrInvalidStateRoot)
+	assert.Equal(t, head.DeriveHash(), chain.Current().DeriveHash())
+
+	// The invalid block and its descendant are gone, and known so as not to be validated again.
+	for _, block := range branch[:2] {
+		assert.False(t, chain.BlockchainDb.HasBlock(block.DeriveHash()))
+		assert.ErrorIs(t, chain.importBlock(block), ErrKnownBlock)
+	}
+
+	// No block can extend them anymore.
+	assert.ErrorIs(t, chain.AddExternalBlock(branch[2]), ErrUnknownParent)
+}
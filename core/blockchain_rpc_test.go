@@ -0,0 +1,1277 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+type jsonrpcTestResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpc.Error      `json:"error"`
+}
+
+func sendJSONRPCRequest(t *testing.T, addr string, method string, params ...interface{}) *jsonrpcTestResponse {
+	t.Helper()
+
+	return sendAuthJSONRPCRequest(t, addr, "", method, params...)
+}
+
+// sendAuthJSONRPCRequest sends the request with the auth token as bearer token, none if empty.
+func sendAuthJSONRPCRequest(t *testing.T, addr string, token string, method string, params ...interface{}) *jsonrpcTestResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost"+addr, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var out jsonrpcTestResponse
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	return &out
+}
+
+func newRPCTestConfig(t *testing.T, rpcPort, p2pPort string) *config.Config {
+	t.Helper()
+
+	return &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             rpcPort,
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1000000000000000000), // Allocating funds to 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+		},
+		P2PPort:          p2pPort,
+		Mine:             true,
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"), // Address = 0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e
+		BlockTime:        4,
+	}
+}
+
+// newTestBlockchain returns the blockchain of the config, failing the test if it can't
+// be created.
+func newTestBlockchain(t *testing.T, c *config.Config) *Blockchain {
+	t.Helper()
+
+	chain, err := NewBlockchain(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chain
+}
+
+// nolint : tparallel
+func TestRPCGetBlockByNumber(t *testing.T) {
+	config := newRPCTestConfig(t, ":1722", ":6072")
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	for i := 1; i <= 3; i++ {
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	block1, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, number := range []interface{}{1, "1", "0x1"} {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByNumber", number)
+		assert.Nil(t, res.Error)
+
+		var block rpc.RPCBlock
+		if err := json.Unmarshal(res.Result, &block); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "0x1", block.Number)
+		assert.Equal(t, block1.DeriveHash().String(), block.Hash)
+		assert.Equal(t, block1.ParentHash.String(), block.ParentHash)
+		assert.Equal(t, fmt.Sprintf("0x%x", block1.Timestamp), block.Timestamp)
+		assert.Empty(t, block.Transactions)
+	}
+
+	// Heights past the head return null.
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByNumber", 4)
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "null", string(res.Result))
+
+	// Negative heights return an invalid params error.
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByNumber", -1)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCGetBalance(t *testing.T) {
+	config := newRPCTestConfig(t, ":1723", ":6073")
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	getBalance := func(address string) string {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBalance", address)
+		assert.Nil(t, res.Error)
+
+		var balance string
+		if err := json.Unmarshal(res.Result, &balance); err != nil {
+			t.Fatal(err)
+		}
+
+		return balance
+	}
+
+	assert.Equal(t, "1000000000000000000", getBalance(ua.Address().String()))
+
+	// Unknown addresses have a zero balance.
+	assert.Equal(t, "0", getBalance("0x0000000000000000000000000000000000000001"))
+
+	// Malformed addresses are invalid params.
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBalance", "0x01")
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 0)
+	tx.Sign(ua)
+
+	if err := chain.Txpool.AddTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	err := chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(chain.LastBlock.Transactions))
+
+	// Sender paid value + fee.
+	assert.Equal(t, "999999999999998800", getBalance(ua.Address().String()))
+}
+
+// nolint : tparallel
+func TestRPCGetTransactionByHash(t *testing.T) {
+	config := newRPCTestConfig(t, ":1729", ":6079")
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	getTransaction := func(hash string) *rpc.RPCTransaction {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactionByHash", hash)
+		assert.Nil(t, res.Error)
+
+		var tx *rpc.RPCTransaction
+		if err := json.Unmarshal(res.Result, &tx); err != nil {
+			t.Fatal(err)
+		}
+
+		return tx
+	}
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	tx1 := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 0)
+	tx1.Sign(ua)
+
+	tx2 := newTransaction(t, ua.Address().Bytes(), []byte{0x02}, "hello", 100, 2000, 1)
+	tx2.Sign(ua)
+
+	chain.Txpool.AddTxs([]*types.Transaction{tx1, tx2})
+
+	// Pending transactions are not returned.
+	assert.Nil(t, getTransaction(tx2.Hash().String()))
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 2"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, 2, len(chain.LastBlock.Transactions))
+
+	tx := getTransaction(tx2.Hash().String())
+	assert.Equal(t, "0x2", tx.BlockNumber)
+	assert.Equal(t, chain.LastBlock.DeriveHash().String(), tx.BlockHash)
+	assert.Equal(t, "0x1", tx.TransactionIndex)
+	assert.Equal(t, ua.Address().String(), tx.From)
+	assert.Equal(t, "2000", tx.Value)
+	assert.Equal(t, "0x1", tx.Nonce)
+
+	// Unknown hashes return null, malformed ones are invalid params.
+	assert.Nil(t, getTransaction(util.HashData([]byte("unknown")).String()))
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactionByHash", "0x01")
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCTransactionData(t *testing.T) {
+	config := newRPCTestConfig(t, ":1779", ":6130")
+	config.MaxTxDataBytes = 64
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	data := util.HashData([]byte("document")).Bytes()
+
+	sendRawTx := func(tx *types.Transaction) *rpc.RPCSendResult {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_sendRawTransactions", []string{fmt.Sprintf("0x%x", tx.Serialize())})
+		assert.Nil(t, res.Error)
+
+		var results []*rpc.RPCSendResult
+		if err := json.Unmarshal(res.Result, &results); err != nil {
+			t.Fatal(err)
+		}
+
+		return results[0]
+	}
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 0)
+	tx.Data = data
+	tx.Sign(ua)
+
+	// The data is signed.
+	tampered := *tx
+	tampered.Data = []byte("other document")
+	assert.Equal(t, &rpc.RPCSendResult{Error: types.ErrInvalidSignature.Error()}, sendRawTx(&tampered))
+
+	large := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 1)
+	large.Data = make([]byte, 65)
+	large.Sign(ua)
+	assert.Equal(t, &rpc.RPCSendResult{Error: "transaction data too large : 65 bytes, maximum 64"}, sendRawTx(large))
+
+	assert.Equal(t, &rpc.RPCSendResult{Hash: tx.Hash().String()}, sendRawTx(tx))
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+
+	// The data is stored in the block and returned with the transaction.
+	block, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Len(t, block.Transactions, 1)
+	assert.Equal(t, data, block.Transactions[0].Data)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactionByHash", tx.Hash().String())
+	assert.Nil(t, res.Error)
+
+	var out *rpc.RPCTransaction
+	if err := json.Unmarshal(res.Result, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, fmt.Sprintf("0x%x", data), out.Data)
+	assert.Equal(t, "0x1", out.BlockNumber)
+}
+
+// nolint : tparallel
+func TestRPCGetBlockByHash(t *testing.T) {
+	config := newRPCTestConfig(t, ":1738", ":6088")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	getBlock := func(hash string) *rpc.RPCBlock {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByHash", hash)
+		assert.Nil(t, res.Error)
+
+		var block *rpc.RPCBlock
+		if err := json.Unmarshal(res.Result, &block); err != nil {
+			t.Fatal(err)
+		}
+
+		return block
+	}
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	hash := chain.LastBlock.DeriveHash()
+
+	block := getBlock(hash.String())
+	assert.Equal(t, "0x1", block.Number)
+	assert.Equal(t, hash.String(), block.Hash)
+	assert.Equal(t, chain.LastBlock.ParentHash.String(), block.ParentHash)
+
+	// The result matches the one of chain_getBlockByNumber.
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByNumber", "0x1")
+	assert.Nil(t, res.Error)
+
+	var byNumber *rpc.RPCBlock
+	if err := json.Unmarshal(res.Result, &byNumber); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, byNumber, block)
+
+	// Unknown hashes return null, malformed ones are invalid params.
+	assert.Nil(t, getBlock(util.HashData([]byte("unknown")).String()))
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockByHash", "0xzz")
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCPendingTransactions(t *testing.T) {
+	config := newRPCTestConfig(t, ":1741", ":6091")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	txs := []*types.Transaction{}
+
+	// Nonces 0 and 1 are ready, nonce 3 waits for nonce 2.
+	for _, nonce := range []int64{1, 0, 3} {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+		tx.Sign(ua)
+		assert.NoError(t, chain.Txpool.AddTx(tx))
+
+		txs = append(txs, tx)
+	}
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_pendingTransactions")
+	assert.Nil(t, res.Error)
+
+	var out *rpc.RPCPendingTransactions
+	if err := json.Unmarshal(res.Result, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []*rpc.RPCPendingTransaction{rpc.NewRPCPendingTransaction(txs[1]), rpc.NewRPCPendingTransaction(txs[0])}, out.Pending)
+	assert.Equal(t, []*rpc.RPCPendingTransaction{rpc.NewRPCPendingTransaction(txs[2])}, out.Queued)
+
+	assert.Equal(t, "0x0", out.Pending[0].Nonce)
+	assert.Equal(t, ua.Address().String(), out.Pending[0].From)
+	assert.Equal(t, "1000", out.Pending[0].Value)
+	assert.Equal(t, "200", out.Pending[0].Fee)
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_pendingTransactions", "latest")
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCSendRawTransactions(t *testing.T) {
+	config := newRPCTestConfig(t, ":1745", ":6095")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	newRawTx := func(nonce int64, tamper bool) (*types.Transaction, string) {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+		tx.Sign(ua)
+
+		if tamper {
+			tx.Value = big.NewInt(2000)
+		}
+
+		return tx, fmt.Sprintf("0x%x", tx.Serialize())
+	}
+
+	tx0, raw0 := newRawTx(0, false)
+	_, bad := newRawTx(2, true)
+	tx1, raw1 := newRawTx(1, false)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_sendRawTransactions", []string{raw0, bad, "0xzz", raw1})
+	assert.Nil(t, res.Error)
+
+	var results []*rpc.RPCSendResult
+	if err := json.Unmarshal(res.Result, &results); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []*rpc.RPCSendResult{
+		{Hash: tx0.Hash().String()},
+		{Error: types.ErrInvalidSignature.Error()},
+		{Error: "invalid hex string"},
+		{Hash: tx1.Hash().String()},
+	}, results)
+
+	// Only the valid transactions made it to the txpool.
+	assert.Len(t, chain.Txpool.Pending(), 2)
+	assert.Empty(t, chain.Txpool.QueuedTxs())
+
+	// Resubmitting reports the transactions as known.
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_sendRawTransactions", []string{raw0})
+	assert.Nil(t, res.Error)
+	assert.JSONEq(t, `[{"error":"transaction already known"}]`, string(res.Result))
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_sendRawTransactions", raw0)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// blockedSender refuses the transactions of a sender.
+type blockedSender util.Address
+
+func (b blockedSender) ValidateTx(tx *types.Transaction) error {
+	if tx.From == util.Address(b) {
+		return errors.New("sender is blocked by policy")
+	}
+
+	return nil
+}
+
+// nolint : tparallel
+func TestRPCTxValidator(t *testing.T) {
+	allowed := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	blocked := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a9"))
+
+	config := newRPCTestConfig(t, ":1778", ":6129")
+	config.BalanceAlloc[blocked.Address().String()] = big.NewInt(1000000)
+	config.TxValidator = blockedSender(*blocked.Address())
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	newRawTx := func(ua *util.UnlockedAccount) (*types.Transaction, string) {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 0)
+		tx.Sign(ua)
+
+		return tx, fmt.Sprintf("0x%x", tx.Serialize())
+	}
+
+	allowedTx, allowedRaw := newRawTx(allowed)
+	_, blockedRaw := newRawTx(blocked)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_sendRawTransactions", []string{blockedRaw, allowedRaw})
+	assert.Nil(t, res.Error)
+
+	var results []*rpc.RPCSendResult
+	if err := json.Unmarshal(res.Result, &results); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []*rpc.RPCSendResult{
+		{Error: "sender is blocked by policy"},
+		{Hash: allowedTx.Hash().String()},
+	}, results)
+
+	assert.Equal(t, []*types.Transaction{allowedTx}, chain.Txpool.Pending())
+}
+
+// nolint : tparallel
+func TestRPCSimulateTransaction(t *testing.T) {
+	config := newRPCTestConfig(t, ":1769", ":6119")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	funded := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	empty := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a9"))
+
+	simulate := func(ua *util.UnlockedAccount, nonce int64) *rpc.RPCSimulateResult {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+		tx.Sign(ua)
+
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_simulateTransaction", fmt.Sprintf("0x%x", tx.Serialize()))
+		assert.Nil(t, res.Error)
+
+		var result *rpc.RPCSimulateResult
+		if err := json.Unmarshal(res.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, &rpc.RPCSimulateResult{
+		Error: "insufficient funds : balance 0, cost 1200",
+		Gas:   "0x5208",
+		Fee:   "200",
+		Cost:  "1200",
+	}, simulate(empty, 0))
+
+	balance := stateBalance(t, chain, funded.Address())
+
+	assert.Equal(t, &rpc.RPCSimulateResult{Success: true, Gas: "0x5208", Fee: "200", Cost: "1200"}, simulate(funded, 0))
+	assert.Equal(t, &rpc.RPCSimulateResult{Success: true, Queued: true, Gas: "0x5208", Fee: "200", Cost: "1200"}, simulate(funded, 2))
+
+	// Nothing got applied nor added to the txpool.
+	assert.Equal(t, balance, stateBalance(t, chain, funded.Address()))
+	assert.Empty(t, chain.Txpool.Pending())
+	assert.Empty(t, chain.Txpool.QueuedTxs())
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_simulateTransaction", "0xzz")
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+type wsTestNotification struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string         `json:"subscription"`
+		Result       *rpc.RPCHeader `json:"result"`
+	} `json:"params"`
+}
+
+// nolint : tparallel
+func TestWebSocketNewHeads(t *testing.T) {
+	config := newRPCTestConfig(t, ":1727", ":6077")
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.Stop()
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ws, err := websocket.Dial("ws://localhost"+config.RPCPort+rpc.WebSocketPath, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	err = websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": []string{rpc.NewHeadsTopic}})
+	assert.NoError(t, err)
+
+	var res jsonrpcTestResponse
+	assert.NoError(t, websocket.JSON.Receive(ws, &res))
+	assert.Nil(t, res.Error)
+
+	var subscription string
+	assert.NoError(t, json.Unmarshal(res.Result, &subscription))
+
+	mined := []*types.Block{}
+
+	for i := 1; i <= 2; i++ {
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
+		assert.NoError(t, err)
+
+		mined = append(mined, chain.LastBlock)
+	}
+
+	assert.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	// Both blocks are notified in order.
+	for _, block := range mined {
+		var notification wsTestNotification
+		assert.NoError(t, websocket.JSON.Receive(ws, &notification))
+		assert.Equal(t, "subscription", notification.Method)
+		assert.Equal(t, subscription, notification.Params.Subscription)
+		assert.Equal(t, fmt.Sprintf("0x%x", block.Number), notification.Params.Result.Number)
+		assert.Equal(t, block.DeriveHash().String(), notification.Params.Result.Hash)
+	}
+}
+
+// nolint : tparallel
+func TestWebSocketTxStatus(t *testing.T) {
+	config := newRPCTestConfig(t, ":1809", ":6161")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ws, err := websocket.Dial("ws://localhost"+config.RPCPort+rpc.WebSocketPath, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	call := func(method string, params ...string) json.RawMessage {
+		err := websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		assert.NoError(t, err)
+
+		var res jsonrpcTestResponse
+		assert.NoError(t, websocket.JSON.Receive(ws, &res))
+		assert.Nil(t, res.Error)
+
+		return res.Result
+	}
+
+	subscribe := func(tx *types.Transaction) string {
+		var id string
+		assert.NoError(t, json.Unmarshal(call("subscribe", rpc.TxStatusTopic, tx.Hash().String()), &id))
+
+		return id
+	}
+
+	receive := func() (string, *rpc.RPCTxStatus) {
+		var notification struct {
+			Params struct {
+				Subscription string           `json:"subscription"`
+				Result       *rpc.RPCTxStatus `json:"result"`
+			} `json:"params"`
+		}
+
+		assert.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+		assert.NoError(t, websocket.JSON.Receive(ws, &notification))
+
+		return notification.Params.Subscription, notification.Params.Result
+	}
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+
+	newTx := func(fee int64, nonce int64) *types.Transaction {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", fee, 1000, nonce)
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	tx0 := newTx(200, 0)
+	tx1 := newTx(200, 1)
+	tx1b := newTx(300, 1)
+
+	included := subscribe(tx0)
+	replaced := subscribe(tx1)
+	replacement := subscribe(tx1b)
+
+	assert.NoError(t, chain.Txpool.AddTx(tx0))
+	assert.NoError(t, chain.Txpool.AddTx(tx1))
+
+	// Replacing a pending transaction notifies its subscribers.
+	assert.NoError(t, chain.Txpool.AddTx(tx1b))
+
+	id, status := receive()
+	assert.Equal(t, replaced, id)
+	assert.Equal(t, &rpc.RPCTxStatus{Hash: tx1.Hash().String(), Status: rpc.TxStatusReplaced, ReplacedBy: tx1b.Hash().String()}, status)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, 2, len(chain.LastBlock.Transactions))
+
+	// Both mined transactions are notified as included, though the nonce of the second
+	// one got used up when the first one left the txpool.
+	statuses := map[string]*rpc.RPCTxStatus{}
+
+	for i := 0; i < 2; i++ {
+		id, status := receive()
+		statuses[id] = status
+	}
+
+	for id, tx := range map[string]*types.Transaction{included: tx0, replacement: tx1b} {
+		assert.Equal(t, &rpc.RPCTxStatus{
+			Hash:        tx.Hash().String(),
+			Status:      rpc.TxStatusIncluded,
+			BlockNumber: "0x1",
+			BlockHash:   chain.LastBlock.DeriveHash().String(),
+		}, statuses[id])
+	}
+
+	// The subscriptions are closed after their notification.
+	assert.Equal(t, json.RawMessage("false"), call("unsubscribe", included))
+	assert.Equal(t, json.RawMessage("false"), call("unsubscribe", replaced))
+}
+
+// nolint : tparallel
+func TestRPCGetTransactionProof(t *testing.T) {
+	config := newRPCTestConfig(t, ":1748", ":6098")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+
+	getProof := func(hash string) *rpc.RPCTransactionProof {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactionProof", hash)
+		assert.Nil(t, res.Error)
+
+		var proof *rpc.RPCTransactionProof
+		if err := json.Unmarshal(res.Result, &proof); err != nil {
+			t.Fatal(err)
+		}
+
+		return proof
+	}
+
+	txs := []*types.Transaction{}
+
+	for nonce := int64(0); nonce < 3; nonce++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 1000-nonce, 1000, nonce)
+		tx.Sign(ua)
+
+		txs = append(txs, tx)
+	}
+
+	chain.Txpool.AddTxs(txs)
+
+	// Pending transactions have no proof.
+	assert.Nil(t, getProof(txs[0].Hash().String()))
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 3)
+
+	for _, tx := range txs {
+		proof := getProof(tx.Hash().String())
+		assert.Equal(t, chain.LastBlock.DeriveHash().String(), proof.BlockHash)
+		assert.Equal(t, chain.LastBlock.TxRoot.String(), proof.TxRoot)
+
+		branch := &types.TxProof{Right: proof.Right}
+
+		for _, sibling := range proof.Branch {
+			hash, err := util.HexToHash(sibling)
+			assert.NoError(t, err)
+
+			branch.Branch = append(branch.Branch, hash)
+		}
+
+		root, err := util.HexToHash(proof.TxRoot)
+		assert.NoError(t, err)
+		assert.True(t, types.VerifyTxProof(root, tx.Hash(), branch))
+
+		// A tampered branch doesn't lead to the root.
+		branch.Branch[0] = util.HashData([]byte("tampered"))
+		assert.False(t, types.VerifyTxProof(root, tx.Hash(), branch))
+	}
+
+	assert.Nil(t, getProof(util.HashData([]byte("unknown")).String()))
+}
+
+// nolint : tparallel
+func TestRPCGetBlockNumberAndChainID(t *testing.T) {
+	config := newRPCTestConfig(t, ":1756", ":6106")
+	config.NetworkID = 42
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	blockNumber := func() string {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockNumber")
+		assert.Nil(t, res.Error)
+
+		var number string
+		assert.NoError(t, json.Unmarshal(res.Result, &number))
+
+		return number
+	}
+
+	assert.Equal(t, "0x0", blockNumber())
+
+	for i := 1; i <= 2; i++ {
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, fmt.Sprintf("0x%x", i), blockNumber())
+	}
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_chainId")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, `"0x2a"`, string(res.Result))
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_getBlockNumber", 1)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCBatchRequest(t *testing.T) {
+	config := newRPCTestConfig(t, ":1780", ":6131")
+	config.NetworkID = 42
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	send := func(body string) (int, []byte) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://localhost"+config.RPCPort, bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(res.Body); err != nil {
+			t.Fatal(err)
+		}
+
+		return res.StatusCode, buf.Bytes()
+	}
+
+	// The notification in the middle gets no response.
+	_, body := send(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "chain_getBlockNumber"},
+		{"jsonrpc": "2.0", "method": "chain_getBlockNumber"},
+		{"jsonrpc": "2.0", "id": "two", "method": "chain_chainId"},
+		{"jsonrpc": "2.0", "id": 3, "method": "unknown"}
+	]`)
+
+	var responses []struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpc.Error      `json:"error"`
+	}
+
+	assert.NoError(t, json.Unmarshal(body, &responses))
+	assert.Len(t, responses, 3)
+
+	assert.Equal(t, `1`, string(responses[0].ID))
+	assert.Nil(t, responses[0].Error)
+	assert.Equal(t, `"0x0"`, string(responses[0].Result))
+
+	assert.Equal(t, `"two"`, string(responses[1].ID))
+	assert.Nil(t, responses[1].Error)
+	assert.Equal(t, `"0x2a"`, string(responses[1].Result))
+
+	assert.Equal(t, `3`, string(responses[2].ID))
+	assert.Equal(t, rpc.ErrCodeMethodNotFound, responses[2].Error.Code)
+
+	// A batch of notifications gets an empty body.
+	status, body := send(`[{"jsonrpc": "2.0", "method": "chain_chainId"}]`)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Empty(t, body)
+
+	// An empty batch is an invalid request.
+	_, body = send(`[]`)
+
+	var res jsonrpcTestResponse
+	assert.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, rpc.ErrCodeInvalidRequest, res.Error.Code)
+
+	// Single requests are still answered with a single response.
+	single := sendJSONRPCRequest(t, config.RPCPort, "chain_chainId")
+	assert.Nil(t, single.Error)
+	assert.Equal(t, `"0x2a"`, string(single.Result))
+}
+
+// nolint : tparallel
+func TestRPCDumpState(t *testing.T) {
+	config := newRPCTestConfig(t, ":1787", ":6138")
+	config.DebugRPC = true
+	config.BalanceAlloc = map[string]*big.Int{
+		"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(100),
+		"0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": big.NewInt(200),
+		"0x0000000000000000000000000000000000000001": big.NewInt(300),
+	}
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	dumpState := func(params ...interface{}) *rpc.StateDump {
+		res := sendJSONRPCRequest(t, config.RPCPort, "debug_dumpState", params...)
+		assert.Nil(t, res.Error)
+
+		var dump rpc.StateDump
+		assert.NoError(t, json.Unmarshal(res.Result, &dump))
+
+		return &dump
+	}
+
+	dump := dumpState()
+	assert.Empty(t, dump.Next)
+
+	for address, balance := range config.BalanceAlloc {
+		assert.Equal(t, balance.String(), dump.Accounts[address])
+	}
+
+	// Paging through the state returns every account once.
+	first := dumpState("0x0000000000000000000000000000000000000000", 2)
+	assert.Len(t, first.Accounts, 2)
+	assert.NotEmpty(t, first.Next)
+
+	second := dumpState(first.Next)
+
+	for address := range second.Accounts {
+		assert.NotContains(t, first.Accounts, address)
+		first.Accounts[address] = second.Accounts[address]
+	}
+
+	assert.Equal(t, dump.Accounts, first.Accounts)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "debug_dumpState", "0x0000000000000000000000000000000000000000", 0)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCEstimateFee(t *testing.T) {
+	config := newRPCTestConfig(t, ":1788", ":6139")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	estimateFee := func() *rpc.RPCFeeEstimate {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_estimateFee")
+		assert.Nil(t, res.Error)
+
+		var estimate rpc.RPCFeeEstimate
+		assert.NoError(t, json.Unmarshal(res.Result, &estimate))
+
+		return &estimate
+	}
+
+	// Without recent transactions every suggestion is the minimum fee.
+	assert.Equal(t, &rpc.RPCFeeEstimate{Low: "100", Medium: "100", High: "100", Floor: "100"}, estimateFee())
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	nonce := int64(0)
+
+	for _, fees := range [][]int64{{100, 150, 200}, {300, 400}, {500, 800, 1000}} {
+		for _, fee := range fees {
+			tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", fee, 1000, nonce)
+			tx.Sign(ua)
+			assert.NoError(t, chain.Txpool.AddTx(tx))
+
+			nonce++
+		}
+
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", chain.LastBlock.Number.Int64()+1)), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+		assert.NoError(t, err)
+		assert.Len(t, chain.LastBlock.Transactions, len(fees))
+	}
+
+	estimate := estimateFee()
+
+	low, _ := new(big.Int).SetString(estimate.Low, 10)
+	medium, _ := new(big.Int).SetString(estimate.Medium, 10)
+	high, _ := new(big.Int).SetString(estimate.High, 10)
+
+	assert.Equal(t, "150", estimate.Low)
+	assert.Equal(t, "300", estimate.Medium)
+	assert.Equal(t, "800", estimate.High)
+	assert.True(t, low.Cmp(medium) < 0 && medium.Cmp(high) < 0)
+}
+
+// nolint : tparallel
+func TestRPCGetTransactions(t *testing.T) {
+	config := newRPCTestConfig(t, ":1793", ":6144")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	recipient := util.BytesToAddress([]byte{0x02})
+	expected := []string{}
+
+	nonce := int64(0)
+
+	for i := 0; i < 3; i++ {
+		for _, to := range [][]byte{{0x01}, {0x02}} {
+			tx := newTransaction(t, ua.Address().Bytes(), to, "hello", 300, 1000, nonce)
+			tx.Sign(ua)
+			assert.NoError(t, chain.Txpool.AddTx(tx))
+
+			if to[0] == 0x02 && i > 0 {
+				expected = append(expected, tx.Hash().String())
+			}
+
+			nonce++
+		}
+
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", i+1)), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+		assert.NoError(t, err)
+	}
+
+	getTransactions := func(filter map[string]interface{}) []*rpc.RPCTransaction {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactions", filter)
+		assert.Nil(t, res.Error)
+
+		var txs []*rpc.RPCTransaction
+		assert.NoError(t, json.Unmarshal(res.Result, &txs))
+
+		return txs
+	}
+
+	txs := getTransactions(map[string]interface{}{"to": recipient.String(), "fromBlock": "0x2", "toBlock": 3})
+
+	hashes := []string{}
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash)
+		assert.Equal(t, recipient.String(), tx.To)
+	}
+
+	assert.Equal(t, expected, hashes)
+	assert.Equal(t, "0x2", txs[0].BlockNumber)
+	assert.Equal(t, "0x3", txs[1].BlockNumber)
+
+	// The range defaults to the head block.
+	assert.Len(t, getTransactions(map[string]interface{}{"from": ua.Address().String()}), 2)
+	assert.Len(t, getTransactions(map[string]interface{}{"from": recipient.String(), "fromBlock": 0}), 0)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactions", map[string]interface{}{"fromBlock": 0, "toBlock": 1000})
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Message, "block range exceeds 1000 blocks")
+}
+
+// nolint : tparallel
+func TestRPCTxPoolContent(t *testing.T) {
+	config := newRPCTestConfig(t, ":1794", ":6145")
+
+	// Address = 0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e
+	other := util.NewUnlockedAccount(util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	config.BalanceAlloc[other.Address().String()] = big.NewInt(1000000000000000000)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+
+	newTx := func(from *util.UnlockedAccount, nonce int64) *types.Transaction {
+		tx := newTransaction(t, from.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+		tx.Sign(from)
+		assert.NoError(t, chain.Txpool.AddTx(tx))
+
+		return tx
+	}
+
+	// The nonces of the senders are interleaved, and each one has a gap.
+	a1 := newTx(ua, 1)
+	b0 := newTx(other, 0)
+	a0 := newTx(ua, 0)
+	b2 := newTx(other, 2)
+	a3 := newTx(ua, 3)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "txpool_content")
+	assert.Nil(t, res.Error)
+
+	var content *rpc.RPCTxPoolContent
+	if err := json.Unmarshal(res.Result, &content); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string][]*rpc.RPCPendingTransaction{
+		ua.Address().String():    {rpc.NewRPCPendingTransaction(a0), rpc.NewRPCPendingTransaction(a1)},
+		other.Address().String(): {rpc.NewRPCPendingTransaction(b0)},
+	}, content.Pending)
+	assert.Equal(t, map[string][]*rpc.RPCPendingTransaction{
+		ua.Address().String():    {rpc.NewRPCPendingTransaction(a3)},
+		other.Address().String(): {rpc.NewRPCPendingTransaction(b2)},
+	}, content.Queued)
+
+	// Filling the gap of a sender empties its queue.
+	newTx(other, 1)
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "txpool_content")
+	assert.Nil(t, res.Error)
+
+	content = nil
+	if err := json.Unmarshal(res.Result, &content); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, content.Pending[other.Address().String()], 3)
+	assert.NotContains(t, content.Queued, other.Address().String())
+	assert.Len(t, content.Queued, 1)
+}
+
+// nolint : tparallel
+func TestRPCGetAccounts(t *testing.T) {
+	config := newRPCTestConfig(t, ":1808", ":6160")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	recipient := util.BytesToAddress([]byte{0x01}).String()
+	unknown := "0x0000000000000000000000000000000000000002"
+
+	getAccounts := func(addresses ...string) []*rpc.RPCAccount {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getAccounts", addresses)
+		assert.Nil(t, res.Error)
+
+		var accounts []*rpc.RPCAccount
+		if err := json.Unmarshal(res.Result, &accounts); err != nil {
+			t.Fatal(err)
+		}
+
+		return accounts
+	}
+
+	assert.Equal(t, []*rpc.RPCAccount{
+		{Address: ua.Address().String(), Balance: "1000000000000000000", Nonce: "0x0"},
+		{Address: unknown, Balance: "0", Nonce: "0x0"},
+	}, getAccounts(ua.Address().String(), unknown))
+
+	for nonce := int64(0); nonce < 2; nonce++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, nonce)
+		tx.Sign(ua)
+		assert.NoError(t, chain.Txpool.AddTx(tx))
+	}
+
+	// Pending transactions don't change the head state.
+	assert.Equal(t, "0x0", getAccounts(ua.Address().String())[0].Nonce)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, 2, len(chain.LastBlock.Transactions))
+
+	assert.Equal(t, []*rpc.RPCAccount{
+		{Address: unknown, Balance: "0", Nonce: "0x0"},
+		{Address: ua.Address().String(), Balance: "999999999999997600", Nonce: "0x2"},
+		{Address: recipient, Balance: "2000", Nonce: "0x0"},
+	}, getAccounts(unknown, ua.Address().String(), recipient))
+
+	// Malformed addresses and params are invalid params.
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getAccounts", []string{ua.Address().String(), "0x01"})
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_getAccounts", ua.Address().String())
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestRPCGetBalanceAt(t *testing.T) {
+	config := newRPCTestConfig(t, ":1810", ":6162")
+	config.StateRetentionBlocks = 2
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	to := util.BytesToAddress([]byte{0x01})
+
+	getBalanceAt := func(address *util.Address, number interface{}) string {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBalanceAt", address.String(), number)
+		assert.Nil(t, res.Error)
+
+		var balance string
+		if err := json.Unmarshal(res.Result, &balance); err != nil {
+			t.Fatal(err)
+		}
+
+		return balance
+	}
+
+	before := getBalanceAt(ua.Address(), "latest")
+	assert.Equal(t, "1000000000000000000", before)
+
+	tx := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 200, 1000, 0)
+	tx.Sign(ua)
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+
+	// The state before the transaction is still served.
+	assert.Equal(t, before, getBalanceAt(ua.Address(), 0))
+	assert.Equal(t, "0", getBalanceAt(to, "0x0"))
+	assert.Equal(t, "999999999999998800", getBalanceAt(ua.Address(), 1))
+	assert.Equal(t, "1000", getBalanceAt(to, "latest"))
+
+	// Blocks past the head are unknown.
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_getBalanceAt", ua.Address().String(), 2)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+	assert.Equal(t, "unknown block 2, the head is block 1", res.Error.Message)
+
+	// Mining past the retention depth prunes the state of block 0.
+	for i := 2; i <= 4; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	res = sendJSONRPCRequest(t, config.RPCPort, "chain_getBalanceAt", ua.Address().String(), 0)
+	assert.Equal(t, rpc.ErrCodeStatePruned, res.Error.Code)
+	assert.Contains(t, res.Error.Message, "state is pruned")
+
+	assert.Equal(t, "999999999999998800", getBalanceAt(ua.Address(), 2))
+}
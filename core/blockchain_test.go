@@ -1,16 +1,22 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"math/big"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/0xsharma/compact-chain/config"
 	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/metrics"
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,7 +41,7 @@ func TestBlockchainStateBalance(t *testing.T) {
 		BlockTime:        4,
 	}
 
-	chain := NewBlockchain(config)
+	chain := newTestBlockchain(t, config)
 	if chain.LastBlock.Number.Int64() == 0 {
 		fmt.Println("Number : ", chain.LastBlock.Number, "Hash : ", chain.LastBlock.DeriveHash().String())
 	} else {
@@ -80,7 +86,7 @@ func TestBlockchainStateBalance(t *testing.T) {
 	}
 
 	balanceSenderBig := new(big.Int).SetBytes(balanceSender)
-	assert.Equal(t, big.NewInt(999999999999997000), balanceSenderBig)
+	assert.Equal(t, big.NewInt(999999999999996700), balanceSenderBig)
 
 	balanceTo1, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, to1.String()))
 	if err != nil {
@@ -121,3 +127,517 @@ func newTransaction(t *testing.T, from, to []byte, msg string, fee, value int64,
 		Nonce: big.NewInt(nonce),
 	}
 }
+
+// nolint : tparallel
+func TestBlockchainClose(t *testing.T) {
+	config := &config.Config{
+		ConsensusDifficulty: 8,
+		ConsensusName:       "pow",
+		DBDir:               t.TempDir(),
+		StateDBDir:          t.TempDir(),
+		MinFee:              big.NewInt(100),
+		RPCPort:             ":1724",
+		BalanceAlloc: map[string]*big.Int{
+			"0xa52c981eee8687b5e4afd69aa5006548c24d7685": big.NewInt(1000000000000000000), // Allocating funds to 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+		},
+		P2PPort:          ":6074",
+		Mine:             true,
+		SignerPrivateKey: util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"), // Address = 0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e
+		BlockTime:        4,
+	}
+
+	chain := newTestBlockchain(t, config)
+
+	err := chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastHash := chain.LastBlock.DeriveHash()
+
+	// Closing twice is safe.
+	chain.Close()
+	chain.Close()
+
+	err = chain.AddBlock([]byte("Block 2"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey)
+	assert.ErrorIs(t, err, ErrBlockchainClosed)
+
+	// The databases can be opened again and hold the chain.
+	reopened := newTestBlockchain(t, config)
+	defer reopened.Close()
+
+	assert.Equal(t, int64(1), reopened.LastBlock.Number.Int64())
+	assert.Equal(t, lastHash, reopened.LastBlock.DeriveHash())
+
+	balance, err := reopened.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, "0xa52c981eee8687b5e4afd69aa5006548c24d7685"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, big.NewInt(1000000000000000000), new(big.Int).SetBytes(balance))
+}
+
+// nolint : tparallel
+func TestBlockchainLogLevel(t *testing.T) {
+	var logs bytes.Buffer
+
+	config := newRPCTestConfig(t, ":1737", ":6087")
+	config.LogLevel = "warn"
+	config.LogOutput = &logs
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	// Info level block messages are suppressed.
+	assert.NotContains(t, logs.String(), "Mined block")
+
+	block := types.NewBlock(big.NewInt(2), chain.LastBlock.DeriveHash(), []byte("Block 2"))
+	block.Difficulty = chain.CalcNextDifficulty(chain.LastBlock)
+
+	assert.ErrorIs(t, chain.AddExternalBlock(block), ErrInvalidSeal)
+
+	// Warnings are logged along with the block fields.
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), `msg="Invalid block seal" number=2 hash=`+block.DeriveHash().String())
+}
+
+func scrapeMetrics(t *testing.T, addr string) string {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost"+addr+metrics.Path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+// nolint : tparallel
+func TestBlockchainMetrics(t *testing.T) {
+	config := newRPCTestConfig(t, ":1743", ":6093")
+	config.MetricsPort = ":9093"
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Let the metrics server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Contains(t, scrapeMetrics(t, config.MetricsPort), "compactchain_block_height 0\n")
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 200, 1000, 1)
+	tx.Sign(ua)
+	assert.NoError(t, chain.Txpool.AddTx(tx))
+
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	out := scrapeMetrics(t, config.MetricsPort)
+
+	assert.Contains(t, out, fmt.Sprintf("compactchain_block_height %d\n", chain.LastBlock.Number.Int64()))
+	assert.Contains(t, out, "compactchain_blocks_mined_total 2\n")
+	assert.Contains(t, out, "compactchain_block_interval_seconds_count 1\n")
+	assert.Contains(t, out, "compactchain_peers 0\n")
+	assert.Contains(t, out, "compactchain_txpool_pending 0\n")
+	assert.Contains(t, out, "compactchain_txpool_queued 1\n")
+}
+
+// nolint : tparallel
+func TestBlockchainMetricsDisabled(t *testing.T) {
+	config := newRPCTestConfig(t, ":1744", ":6094")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, 1.0, testutil.ToFloat64(chain.Metrics.BlockHeight))
+	assert.Nil(t, chain.Metrics.Stop())
+}
+
+// nolint : tparallel
+func TestRejectTamperedTxRoot(t *testing.T) {
+	config := newRPCTestConfig(t, ":1749", ":6099")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	parent := chain.LastBlock
+
+	block := types.NewBlock(big.NewInt(1), parent.DeriveHash(), []byte("Block 1"))
+	block.Timestamp = nextTimestamp(parent)
+	block.Difficulty = chain.CalcNextDifficulty(parent)
+	block.BaseFee = chain.CalcBaseFee(parent)
+
+	block = chain.Consensus.Mine(block, make(chan bool))
+	block.Sign(util.NewUnlockedAccount(config.SignerPrivateKey))
+
+	// Adding a transaction to the body keeps the sealed header valid, but not its root.
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 1000, 1000, 0)
+	tx.Sign(ua)
+
+	tampered := *block
+	tampered.Transactions = []*types.Transaction{tx}
+
+	assert.ErrorIs(t, chain.AddExternalBlock(&tampered), ErrInvalidTxRoot)
+	assert.Equal(t, parent.DeriveHash(), chain.Current().DeriveHash())
+
+	assert.NoError(t, chain.AddExternalBlock(block))
+	assert.Equal(t, block.DeriveHash(), chain.Current().DeriveHash())
+}
+
+// nolint : tparallel
+func TestRejectInvalidStateRoot(t *testing.T) {
+	config := newRPCTestConfig(t, ":1776", ":6127")
+	config.BlockReward = big.NewInt(5000)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importerConfig := newRPCTestConfig(t, ":1777", ":6128")
+	importerConfig.BlockReward = config.BlockReward
+
+	importer := newTestBlockchain(t, importerConfig)
+	defer importer.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+
+	block := chain.LastBlock
+	assert.Equal(t, chain.TxProcessor.StateRoot(), block.StateRoot)
+
+	// A block claiming another state root, sealed again so that only its state is wrong.
+	tampered := *block
+	tampered.StateRoot = util.HashData([]byte("corrupt"))
+
+	signer := util.NewUnlockedAccount(config.SignerPrivateKey)
+
+	for nonce := int64(0); ; nonce++ {
+		tampered.SetNonce(big.NewInt(nonce))
+		tampered.Sign(signer)
+
+		if importer.Consensus.VerifySeal(&tampered) {
+			break
+		}
+	}
+
+	parent := importer.LastBlock
+	root := importer.TxProcessor.StateRoot()
+
+	assert.ErrorIs(t, importer.AddExternalBlock(&tampered), ErrInvalidStateRoot)
+	assert.Equal(t, parent.DeriveHash(), importer.Current().DeriveHash())
+
+	// The transactions of the rejected block are rolled back.
+	assert.Equal(t, root, importer.TxProcessor.StateRoot())
+	assert.Equal(t, big.NewInt(1000000000000000000), stateBalance(t, importer, ua.Address()))
+
+	assert.NoError(t, importer.AddExternalBlock(block))
+	assert.Equal(t, block.DeriveHash(), importer.Current().DeriveHash())
+	assert.Equal(t, block.StateRoot, importer.TxProcessor.StateRoot())
+}
+
+// nolint : tparallel
+func TestRequireStateRoot(t *testing.T) {
+	config := newRPCTestConfig(t, ":1823", ":6175")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importerConfig := newRPCTestConfig(t, ":1824", ":6176")
+	importerConfig.LegacyStateRootBlock = 1
+
+	importer := newTestBlockchain(t, importerConfig)
+	defer importer.Close()
+
+	signer := util.NewUnlockedAccount(config.SignerPrivateKey)
+
+	withoutRoot := func(chain *Blockchain, block *types.Block, parent *types.Block) *types.Block {
+		stripped := *block
+		stripped.StateRoot = nil
+		stripped.ParentHash = parent.DeriveHash()
+
+		for nonce := int64(0); ; nonce++ {
+			stripped.SetNonce(big.NewInt(nonce))
+			stripped.Sign(signer)
+
+			if chain.Consensus.VerifySeal(&stripped) {
+				return &stripped
+			}
+		}
+	}
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.NoError(t, chain.AddBlock([]byte("Block 2"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	block1, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+
+	block2 := chain.LastBlock
+
+	// Up to LegacyStateRootBlock, blocks may leave out their state root.
+	assert.NoError(t, importer.AddExternalBlock(withoutRoot(importer, block1, importer.LastBlock)))
+
+	// After it, they can't, however valid their state.
+	parent := importer.LastBlock
+
+	err = importer.AddExternalBlock(withoutRoot(importer, block2, parent))
+	assert.ErrorIs(t, err, ErrInvalidStateRoot)
+	assert.True(t, isInvalidBlock(err))
+	assert.Equal(t, parent.DeriveHash(), importer.Current().DeriveHash())
+
+	// Nor can any block after genesis by default.
+	other := newTestBlockchain(t, newRPCTestConfig(t, ":1825", ":6177"))
+	defer other.Close()
+
+	assert.ErrorIs(t, other.AddExternalBlock(withoutRoot(other, block1, other.LastBlock)), ErrInvalidStateRoot)
+}
+
+// nolint : tparallel
+func TestStateCommitmentMatchesState(t *testing.T) {
+	config := newRPCTestConfig(t, ":1826", ":6178")
+	config.BlockReward = big.NewInt(5000)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	for nonce := int64(0); nonce < 3; nonce++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{byte(nonce + 1)}, "hello", 300, 1000, nonce)
+		tx.Sign(ua)
+
+		assert.NoError(t, chain.AddBlock([]byte("Block"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	// The commitment updated on every write matches the one of a database holding the same
+	// balances and nonces, committed to from a scan of them.
+	scanned, err := dbstore.NewMemoryDBInstance()
+	assert.NoError(t, err)
+
+	iter := chain.StateDB.DB.LevelDb.NewIterator(nil, nil)
+	for iter.Next() {
+		key := string(iter.Key())
+
+		if strings.HasPrefix(key, dbstore.BalanceKey) || strings.HasPrefix(key, dbstore.NonceKey) {
+			assert.NoError(t, scanned.LevelDb.Put(iter.Key(), iter.Value(), nil))
+		}
+	}
+
+	iter.Release()
+
+	root, err := dbstore.StateRoot(scanned)
+	assert.NoError(t, err)
+	assert.Equal(t, chain.LastBlock.StateRoot, root)
+	assert.Equal(t, root, chain.TxProcessor.StateRoot())
+
+	// Rolling a transaction back brings the commitment back too.
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x09}, "hello", 300, 1000, 3)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.TxProcessor.ProcessTx(tx, chain.TxProcessor.Coinbase, chain.LastBlock.BaseFee))
+	assert.NotEqual(t, root, chain.TxProcessor.StateRoot())
+
+	assert.NoError(t, chain.TxProcessor.RollbackTx(tx, chain.TxProcessor.Coinbase, chain.LastBlock.BaseFee))
+	assert.Equal(t, root, chain.TxProcessor.StateRoot())
+}
+
+// nolint : tparallel
+func TestBlockRewardAndFees(t *testing.T) {
+	config := newRPCTestConfig(t, ":1750", ":6100")
+	config.BlockReward = big.NewInt(5000)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// A second node with another signer, importing the blocks of the first one.
+	importerConfig := newRPCTestConfig(t, ":1751", ":6101")
+	importerConfig.BlockReward = config.BlockReward
+	importerConfig.SignerPrivateKey = util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7")
+
+	importer := newTestBlockchain(t, importerConfig)
+	defer importer.Close()
+
+	miner := util.NewUnlockedAccount(config.SignerPrivateKey).Address()
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+
+	balance := func(chain *Blockchain, address *util.Address) *big.Int {
+		value, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, address.String()))
+		if err != nil {
+			return big.NewInt(0)
+		}
+
+		return new(big.Int).SetBytes(value)
+	}
+
+	tx1 := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, 0)
+	tx1.Sign(ua)
+
+	tx2 := newTransaction(t, ua.Address().Bytes(), []byte{0x02}, "hello", 200, 1000, 1)
+	tx2.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx1, tx2}, make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 2)
+
+	// The miner gets the reward plus the fees paid by the sender.
+	assert.Equal(t, big.NewInt(5000+300+200), balance(chain, miner))
+	assert.Equal(t, big.NewInt(1000000000000000000-2000-500), balance(chain, ua.Address()))
+
+	// Importing nodes credit the signer of the block, not their own.
+	assert.NoError(t, importer.AddExternalBlock(chain.LastBlock))
+	assert.Equal(t, big.NewInt(5000+300+200), balance(importer, miner))
+	assert.Equal(t, big.NewInt(0), balance(importer, importer.TxProcessor.Coinbase))
+
+	// Empty blocks still pay the reward.
+	assert.NoError(t, chain.AddBlock([]byte("Block 2"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, big.NewInt(2*5000+300+200), balance(chain, miner))
+}
+
+// nolint : tparallel
+func TestCoinbaseAddress(t *testing.T) {
+	coinbase, err := util.HexToAddress("0x5e1bc6a626a0e1b6a7c4a392a1ce93b5d2a6e9f2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := newRPCTestConfig(t, ":1767", ":6117")
+	config.BlockReward = big.NewInt(5000)
+	config.CoinbaseAddress = coinbase.String()
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importerConfig := newRPCTestConfig(t, ":1768", ":6118")
+	importerConfig.BlockReward = config.BlockReward
+
+	importer := newTestBlockchain(t, importerConfig)
+	defer importer.Close()
+
+	signer := util.NewUnlockedAccount(config.SignerPrivateKey).Address()
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, coinbase, chain.LastBlock.Coinbase())
+	assert.Equal(t, signer, chain.LastBlock.PublicKey.Address())
+
+	// The coinbase gets the reward and the fees, the signer only seals.
+	assert.Equal(t, big.NewInt(5000+300), stateBalance(t, chain, coinbase))
+	assert.Equal(t, big.NewInt(0), stateBalance(t, chain, signer))
+
+	// The coinbase is part of the header, importing nodes credit it as well.
+	assert.NoError(t, importer.AddExternalBlock(chain.LastBlock))
+	assert.Equal(t, big.NewInt(5000+300), stateBalance(t, importer, coinbase))
+	assert.Equal(t, big.NewInt(0), stateBalance(t, importer, signer))
+}
+
+// nolint : tparallel
+func TestSelfAndZeroValueTransfers(t *testing.T) {
+	config := newRPCTestConfig(t, ":1815", ":6167")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	miner := util.NewUnlockedAccount(config.SignerPrivateKey).Address()
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	to := util.BytesToAddress([]byte{0x02})
+
+	nonce := func() *big.Int {
+		value, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.NonceKey, ua.Address().String()))
+		if err != nil {
+			return nil
+		}
+
+		return new(big.Int).SetBytes(value)
+	}
+
+	// A self-transfer only pays the fee, a zero-value transfer only bumps the nonce and pays the fee.
+	tx1 := newTransaction(t, ua.Address().Bytes(), ua.Address().Bytes(), "hello", 300, 1000, 0)
+	tx1.Sign(ua)
+
+	tx2 := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 200, 0, 1)
+	tx2.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx1, tx2}, make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 2)
+
+	assert.Equal(t, big.NewInt(1000000000000000000-300-200), stateBalance(t, chain, ua.Address()))
+	assert.Equal(t, big.NewInt(0), stateBalance(t, chain, to))
+	assert.Equal(t, big.NewInt(300+200), stateBalance(t, chain, miner))
+	assert.Equal(t, big.NewInt(1), nonce())
+
+	// Rolling the block back restores the balances and the nonce.
+	block := chain.LastBlock
+	chain.TxProcessor.RollbackTxs(block.Transactions, block.Coinbase(), block.BaseFee)
+
+	assert.Equal(t, big.NewInt(1000000000000000000), stateBalance(t, chain, ua.Address()))
+	assert.Equal(t, big.NewInt(0), stateBalance(t, chain, miner))
+	assert.Nil(t, nonce())
+}
+
+// nolint : tparallel
+func TestRejectInvalidTxSignature(t *testing.T) {
+	config := newRPCTestConfig(t, ":1791", ":6142")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importer := newTestBlockchain(t, newRPCTestConfig(t, ":1792", ":6143"))
+	defer importer.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	txs := []*types.Transaction{}
+
+	for nonce := int64(0); nonce < 3; nonce++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, nonce)
+		tx.Sign(ua)
+		txs = append(txs, tx)
+	}
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), txs, make(chan bool), config.SignerPrivateKey))
+
+	block := chain.LastBlock
+	assert.Len(t, block.Transactions, 3)
+
+	// The signatures aren't part of the transactions root, a corrupted one keeps the
+	// header valid.
+	tampered := *block
+	tampered.Transactions = append([]*types.Transaction{}, block.Transactions...)
+
+	corrupt := *tampered.Transactions[1]
+	corrupt.R = new(big.Int).Add(corrupt.R, big.NewInt(1))
+	tampered.Transactions[1] = &corrupt
+
+	parent := importer.LastBlock
+
+	err := importer.AddExternalBlock(&tampered)
+	assert.ErrorIs(t, err, types.ErrInvalidSignature)
+	assert.Contains(t, err.Error(), "transaction 1 "+corrupt.Hash().String())
+	assert.Equal(t, parent.DeriveHash(), importer.Current().DeriveHash())
+
+	assert.NoError(t, importer.AddExternalBlock(block))
+	assert.Equal(t, block.DeriveHash(), importer.Current().DeriveHash())
+}
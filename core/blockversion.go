@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrInvalidBlockVersion is returned when a block doesn't have the version of its height.
+var ErrInvalidBlockVersion = errors.New("invalid block version")
+
+// blockVersion returns the version of the block with the given number : zero up to
+// LegacyHashBlock, the blocks hashing their joined header fields, and RLPHashVersion
+// after it. The genesis block is always of version zero.
+func (bc *Blockchain) blockVersion(number *big.Int) uint64 {
+	if number.Cmp(new(big.Int).SetUint64(bc.Config.LegacyHashBlock)) <= 0 {
+		return 0
+	}
+
+	return types.RLPHashVersion
+}
+
+// verifyBlockVersion checks that the block has the version of its height.
+func (bc *Blockchain) verifyBlockVersion(block *types.Block) error {
+	if expected := bc.blockVersion(block.Number); block.Version != expected {
+		return fmt.Errorf("%w : %d, expected %d", ErrInvalidBlockVersion, block.Version, expected)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestBlockVersionFork(t *testing.T) {
+	config := newRPCTestConfig(t, ":1822", ":6174")
+	config.LegacyHashBlock = 1
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// The genesis block and the blocks up to LegacyHashBlock hash their joined fields.
+	assert.Equal(t, uint64(0), chain.LastBlock.Version)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, uint64(0), chain.LastBlock.Version)
+
+	parent := chain.LastBlock
+
+	// Later blocks of a peer still hashing their joined fields are refused as invalid.
+	block := types.NewBlock(big.NewInt(2), parent.DeriveHash(), []byte("Block 2"))
+	block.Version = 0
+	block.Timestamp = nextTimestamp(parent)
+	block.Difficulty = chain.CalcNextDifficulty(parent)
+	block.BaseFee = chain.CalcBaseFee(parent)
+	block = chain.Consensus.Mine(block, make(chan bool))
+	block.Sign(util.NewUnlockedAccount(config.SignerPrivateKey))
+
+	err := chain.AddExternalBlock(block)
+	assert.ErrorIs(t, err, ErrInvalidBlockVersion)
+	assert.True(t, isInvalidBlock(err))
+	assert.Equal(t, parent.DeriveHash(), chain.Current().DeriveHash())
+
+	// Mined ones hash their RLP encoding.
+	assert.NoError(t, chain.AddBlock([]byte("Block 2"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, types.RLPHashVersion, chain.LastBlock.Version)
+	assert.Equal(t, parent.DeriveHash(), chain.LastBlock.ParentHash)
+}
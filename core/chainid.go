@@ -0,0 +1,26 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// allowLegacyTxs reports whether transactions signed without a chain id are accepted
+// in the block with the given number.
+func (bc *Blockchain) allowLegacyTxs(number *big.Int) bool {
+	return number.Cmp(new(big.Int).SetUint64(bc.Config.LegacyTxBlock)) <= 0
+}
+
+// verifyChainIDs checks that the transactions of the block are signed for this network.
+func (bc *Blockchain) verifyChainIDs(block *types.Block) error {
+	allowLegacy := bc.allowLegacyTxs(block.Number)
+
+	for _, tx := range block.Transactions {
+		if err := tx.VerifyChainID(bc.Config.NetworkID, allowLegacy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChainIDs(t *testing.T) {
+	t.Parallel()
+
+	bc := &Blockchain{Config: &config.Config{NetworkID: 7, LegacyTxBlock: 1}}
+
+	// Block 1 is still in the legacy window.
+	block := newBaseFeeTestBlock(t, 100, 2)
+	assert.NoError(t, bc.verifyChainIDs(block))
+
+	block.Transactions[1].ChainID = 7
+	assert.NoError(t, bc.verifyChainIDs(block))
+
+	block.Number = big.NewInt(2)
+	assert.ErrorIs(t, bc.verifyChainIDs(block), types.ErrInvalidChainID)
+
+	block.Transactions[0].ChainID = 7
+	assert.NoError(t, bc.verifyChainIDs(block))
+
+	block.Transactions[0].ChainID = 3
+	assert.ErrorIs(t, bc.verifyChainIDs(block), types.ErrInvalidChainID)
+}
+
+// nolint : tparallel
+func TestChainIDReplayProtection(t *testing.T) {
+	config := newRPCTestConfig(t, ":1746", ":6096")
+	config.NetworkID = 7
+	config.LegacyTxBlock = 1
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+
+	newTx := func(chainID uint64, nonce int64) *types.Transaction {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 1000, 1000, nonce)
+		tx.ChainID = chainID
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	// A transaction signed for another network is refused.
+	assert.ErrorIs(t, chain.Txpool.AddTx(newTx(3, 0)), types.ErrInvalidChainID)
+
+	// Legacy transactions are accepted until the end of the migration window.
+	legacy := newTx(0, 0)
+	signed := newTx(7, 1)
+	queuedLegacy := newTx(0, 3)
+
+	assert.NoError(t, chain.Txpool.AddTx(legacy))
+	assert.NoError(t, chain.Txpool.AddTx(signed))
+	assert.NoError(t, chain.Txpool.AddTx(queuedLegacy))
+
+	err := chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+	assert.NoError(t, err)
+	assert.Len(t, chain.LastBlock.Transactions, 2)
+
+	// Past the window, pooled legacy transactions are dropped and new ones refused.
+	pending, queued := chain.Txpool.Stats()
+	assert.Equal(t, 0, pending)
+	assert.Equal(t, 0, queued)
+
+	assert.ErrorIs(t, chain.Txpool.AddTx(newTx(0, 2)), types.ErrInvalidChainID)
+	assert.NoError(t, chain.Txpool.AddTx(newTx(7, 2)))
+}
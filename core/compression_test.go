@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func storedBlock(t *testing.T, chain *Blockchain, block *types.Block) []byte {
+	t.Helper()
+
+	value, err := chain.BlockchainDb.DB.Get(dbstore.PrefixKey(dbstore.HashesKey, block.DeriveHash().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+// nolint : tparallel
+func TestDBCompression(t *testing.T) {
+	config := newRPCTestConfig(t, ":1798", ":6149")
+	config.DBCompression = dbstore.CompressionSnappy
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	data := bytes.Repeat([]byte("compact-chain "), 1000)
+	assert.NoError(t, chain.AddBlock(data, []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	block := chain.LastBlock
+
+	// The value is compressed, and read back as the block.
+	value := storedBlock(t, chain, block)
+	assert.Less(t, len(value), len(block.Serialize())/4)
+
+	read, err := chain.BlockchainDb.GetBlockByHash(block.DeriveHash())
+	assert.NoError(t, err)
+	assert.Equal(t, block.DeriveHash(), read.DeriveHash())
+	assert.Equal(t, data, read.ExtraData)
+
+	read, err = chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, block.DeriveHash(), read.DeriveHash())
+
+	// Corrupted compressed values are reported.
+	_, err = dbstore.DecodeBlock(append(value[:1:1], 0xff, 0xff, 0xff))
+	assert.ErrorIs(t, err, dbstore.ErrInvalidBlockValue)
+}
+
+// nolint : tparallel
+func TestDBCompressionMigration(t *testing.T) {
+	config := newRPCTestConfig(t, ":1799", ":6150")
+
+	chain := newTestBlockchain(t, config)
+
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	uncompressed := chain.LastBlock
+	assert.Equal(t, uncompressed.Serialize(), storedBlock(t, chain, uncompressed))
+
+	chain.Close()
+
+	// The blocks stored before compression was turned on stay readable.
+	config.DBCompression = dbstore.CompressionSnappy
+
+	reopened := newTestBlockchain(t, config)
+	defer reopened.Close()
+
+	assert.Equal(t, uncompressed.DeriveHash(), reopened.LastBlock.DeriveHash())
+
+	assert.NoError(t, reopened.AddBlock([]byte("Block 3"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.NotEqual(t, reopened.LastBlock.Serialize(), storedBlock(t, reopened, reopened.LastBlock))
+
+	for i := int64(0); i <= 3; i++ {
+		block, err := reopened.BlockchainDb.GetBlockByNumber(big.NewInt(i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, block.Number.Int64())
+	}
+
+	assert.Equal(t, uncompressed.Serialize(), storedBlock(t, reopened, uncompressed))
+}
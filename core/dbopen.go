@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+)
+
+var (
+	// ErrDBUnavailable is returned when a DB directory of the node can't be created or
+	// opened, because of its permissions or another process using it.
+	ErrDBUnavailable = errors.New("db unavailable")
+
+	// ErrDBCorrupted is returned when a DB of the node is corrupted.
+	ErrDBCorrupted = errors.New("db corrupted")
+)
+
+// openDB opens the named DB of the node with the given backend, creating its directory
+// if missing. Errors tell a directory the node can't access from a corrupted DB, along
+// with what to do about it.
+func openDB(backend string, name string, dir string) (*dbstore.DB, error) {
+	if backend == "" || backend == dbstore.BackendLevelDB {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("%w : failed to create the %s directory %s, check that its parent is writable : %w", ErrDBUnavailable, name, dir, err)
+		}
+	}
+
+	db, err := dbstore.OpenDBInstance(backend, dir)
+
+	switch {
+	case err == nil:
+		return db, nil
+	case leveldberrors.IsCorrupted(err):
+		return nil, fmt.Errorf("%w : the %s %s can't be read, restore it from a backup or move it away and import the chain exported by another node : %w", ErrDBCorrupted, name, dir, err)
+	case errors.Is(err, dbstore.ErrUnknownBackend):
+		return nil, err
+	default:
+		return nil, fmt.Errorf("%w : failed to open the %s %s, check its permissions and that no other node has it open : %w", ErrDBUnavailable, name, dir, err)
+	}
+}
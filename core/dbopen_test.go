@@ -0,0 +1,62 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestNewBlockchainStateDBUnavailable(t *testing.T) {
+	// A missing state DB directory is created, along with its parents.
+	config := newRPCTestConfig(t, ":1818", ":6170")
+	config.StateDBDir = filepath.Join(t.TempDir(), "missing", "statedb")
+
+	chain := newTestBlockchain(t, config)
+	chain.Close()
+
+	info, err := os.Stat(config.StateDBDir)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	// Paths the node can't write to fail with an error naming them, rather than a panic.
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, []byte{}, 0o600))
+
+	readOnlyPaths := []string{filepath.Join(file, "statedb")}
+
+	// Permissions don't hold back root.
+	if os.Geteuid() != 0 {
+		dir := t.TempDir()
+		assert.NoError(t, os.Chmod(dir, 0o500))
+
+		readOnlyPaths = append(readOnlyPaths, filepath.Join(dir, "statedb"))
+	}
+
+	for _, path := range readOnlyPaths {
+		config := newRPCTestConfig(t, ":1819", ":6171")
+		config.StateDBDir = path
+
+		chain, err := NewBlockchain(config)
+		assert.Nil(t, chain)
+		assert.ErrorIs(t, err, ErrDBUnavailable, path)
+		assert.ErrorContains(t, err, "state db directory "+path)
+
+		// The blockchain DB opened before is closed again.
+		db, err := dbstore.NewDBInstance(config.DBDir)
+		assert.NoError(t, err)
+		assert.NoError(t, db.Close())
+	}
+
+	// A corrupted state DB fails with guidance.
+	config = newRPCTestConfig(t, ":1820", ":6172")
+	assert.NoError(t, os.WriteFile(filepath.Join(config.StateDBDir, "CURRENT"), []byte("MANIFEST-000001\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(config.StateDBDir, "MANIFEST-000001"), []byte("corrupt"), 0o600))
+
+	_, err = NewBlockchain(config)
+	assert.ErrorIs(t, err, ErrDBCorrupted)
+	assert.ErrorContains(t, err, "restore it from a backup")
+}
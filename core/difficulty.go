@@ -0,0 +1,198 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrDifficultyOutOfBounds is returned when the initial difficulty of the chain is not
+// within MinDifficulty and MaxDifficulty.
+var ErrDifficultyOutOfBounds = errors.New("difficulty out of bounds")
+
+// medianTimeBlocks is the number of blocks whose median timestamp stands for the time of
+// the last of them when retargeting, so that a few miners can't move it by stamping
+// their blocks.
+var medianTimeBlocks uint64 = 5
+
+// CalcNextDifficulty returns the difficulty the child of the given parent block has to
+// be mined with. Every DifficultyAdjustmentInterval blocks the time spanned by the last
+// window is compared against the expected BlockTime * DifficultyAdjustmentInterval. The
+// time of each end of the window is the median time of the blocks ending at it.
+//
+// The difficulty is expressed in leading zero bits, so a change of one bit doubles or
+// halves the expected work. The adjustment is therefore clamped to a single bit per
+// window and only applied once the observed block time is off by more than a factor
+// of sqrt(2), i.e. when the nearest whole difficulty differs from the current one.
+//
+// The difficulty is then clamped within MinDifficulty and MaxDifficulty.
+func (bc *Blockchain) CalcNextDifficulty(parent *types.Block) uint64 {
+	difficulty := bc.retargetDifficulty(parent)
+
+	// Proof of authority has no difficulty.
+	if difficulty == 0 {
+		return 0
+	}
+
+	if lower := bc.Config.MinDifficulty; lower > 0 && difficulty < lower {
+		return lower
+	}
+
+	if upper := bc.Config.MaxDifficulty; upper > 0 && difficulty > upper {
+		return upper
+	}
+
+	return difficulty
+}
+
+// retargetDifficulty returns the difficulty of the child of the parent block before
+// it is clamped within the bounds.
+func (bc *Blockchain) retargetDifficulty(parent *types.Block) uint64 {
+	initial := bc.Consensus.GetDifficulty().Uint64()
+
+	// The first block after genesis keeps the configured difficulty.
+	if parent.Number.Sign() == 0 || parent.Difficulty == 0 {
+		return initial
+	}
+
+	interval := uint64(bc.Config.DifficultyAdjustmentInterval)
+	next := parent.Number.Uint64() + 1
+
+	// Keep the parent difficulty outside retarget heights and until a full window
+	// of mined blocks (excluding genesis) is available.
+	if interval == 0 || next%interval != 0 || parent.Number.Uint64() <= interval {
+		return parent.Difficulty
+	}
+
+	first := parent
+	for i := uint64(0); i < interval; i++ {
+		block, err := bc.BlockchainDb.GetBlockByHash(first.ParentHash)
+		if err != nil {
+			return parent.Difficulty
+		}
+
+		first = block
+	}
+
+	// Both ends take the median over the same number of blocks, so that they lag behind
+	// their block by as much. Windows close to genesis have fewer blocks below them.
+	count := medianTimeBlocks
+	if first.Number.Uint64() < count {
+		count = first.Number.Uint64()
+	}
+
+	end, ok := bc.medianTime(parent, count)
+	if !ok {
+		return parent.Difficulty
+	}
+
+	start, ok := bc.medianTime(first, count)
+	if !ok {
+		return parent.Difficulty
+	}
+
+	var actual uint64
+	if end > start {
+		actual = end - start
+	}
+
+	expected := uint64(bc.Config.BlockTime) * interval
+
+	switch {
+	case 2*actual*actual < expected*expected:
+		// Blocks are produced too fast, double the work.
+		return parent.Difficulty + 1
+	case actual*actual > 2*expected*expected && parent.Difficulty > 1:
+		// Blocks are produced too slow, halve the work.
+		return parent.Difficulty - 1
+	}
+
+	return parent.Difficulty
+}
+
+// checkDifficultyBounds checks that the bounds of the config are consistent and that the
+// initial difficulty is within them.
+func checkDifficultyBounds(c *config.Config, initial uint64) error {
+	lower, upper := c.MinDifficulty, c.MaxDifficulty
+
+	if lower > 0 && upper > 0 && lower > upper {
+		return fmt.Errorf("%w : minimum %d above maximum %d", ErrDifficultyOutOfBounds, lower, upper)
+	}
+
+	if (lower > 0 && initial < lower) || (upper > 0 && initial > upper) {
+		return fmt.Errorf("%w : initial difficulty %d, bounds %d to %d", ErrDifficultyOutOfBounds, initial, lower, upper)
+	}
+
+	return nil
+}
+
+// medianTime returns the median of the timestamps of the count blocks ending at the
+// given block, and false if they can't all be read. Each timestamp is clamped to be no
+// earlier than the one of the block before it. Only the headers are read, not the local
+// clock, so that every node derives the same difficulty for the block.
+func (bc *Blockchain) medianTime(block *types.Block, count uint64) (uint64, bool) {
+	timestamps := make([]uint64, count)
+
+	for i := int(count) - 1; i >= 0; i-- {
+		timestamps[i] = block.Timestamp
+
+		if i == 0 {
+			break
+		}
+
+		parent, err := bc.BlockchainDb.GetBlockByHash(block.ParentHash)
+		if err != nil {
+			return 0, false
+		}
+
+		block = parent
+	}
+
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i] < timestamps[i-1] {
+			timestamps[i] = timestamps[i-1]
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+
+	return timestamps[count/2], true
+}
+
+// blockWork returns the expected number of hashes needed to mine the block, 2^difficulty.
+func blockWork(b *types.Block) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(b.Difficulty))
+}
+
+// TotalDifficulty returns the cumulative work of the chain ending at the given block.
+func (bc *Blockchain) TotalDifficulty(b *types.Block) *big.Int {
+	td, err := bc.BlockchainDb.GetTotalDifficulty(b.DeriveHash())
+	if err == nil {
+		return td
+	}
+
+	// Blocks stored without a total difficulty are summed up to the closest known one.
+	total := blockWork(b)
+
+	for b.Number.Sign() > 0 {
+		parent, err := bc.BlockchainDb.GetBlockByHash(b.ParentHash)
+		if err != nil {
+			break
+		}
+
+		if td, err := bc.BlockchainDb.GetTotalDifficulty(parent.DeriveHash()); err == nil {
+			return total.Add(total, td)
+		}
+
+		total.Add(total, blockWork(parent))
+		b = parent
+	}
+
+	return total
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/consensus/pow"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDifficultyTestChain(t *testing.T, interval int) *Blockchain {
+	t.Helper()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Blockchain{
+		Config: &config.Config{
+			BlockTime:                    4,
+			DifficultyAdjustmentInterval: interval,
+		},
+		Consensus:    pow.NewPOW(16, nil),
+		BlockchainDb: dbstore.NewBlockchainDB(db),
+	}
+}
+
+// writeTestBlocks stores count blocks on top of a genesis block, spaced by the
+// given block time, and returns the last one.
+func writeTestBlocks(t *testing.T, bc *Blockchain, count int, blockTime uint64) *types.Block {
+	t.Helper()
+
+	timestamps := make([]uint64, count)
+	for i := range timestamps {
+		timestamps[i] = 1700000000 + uint64(i)*blockTime
+	}
+
+	return writeTestBlocksAt(t, bc, timestamps)
+}
+
+// writeTestBlocksAt stores a block with each of the timestamps on top of a genesis
+// block, and returns the last one.
+func writeTestBlocksAt(t *testing.T, bc *Blockchain, timestamps []uint64) *types.Block {
+	t.Helper()
+
+	parent := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), []byte("Genesis Block"))
+	if err := bc.BlockchainDb.DB.Put(dbstore.PrefixKey(dbstore.HashesKey, parent.DeriveHash().String()), parent.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, timestamp := range timestamps {
+		block := types.NewBlock(big.NewInt(int64(i+1)), parent.DeriveHash(), []byte{})
+		block.Timestamp = timestamp
+		block.Difficulty = bc.CalcNextDifficulty(parent)
+
+		if err := bc.BlockchainDb.DB.Put(dbstore.PrefixKey(dbstore.HashesKey, block.DeriveHash().String()), block.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+
+		parent = block
+	}
+
+	return parent
+}
+
+func TestCalcNextDifficultyAfterGenesis(t *testing.T) {
+	t.Parallel()
+
+	bc := newDifficultyTestChain(t, 1)
+	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), []byte("Genesis Block"))
+
+	assert.Equal(t, uint64(16), bc.CalcNextDifficulty(genesis))
+}
+
+func TestCalcNextDifficultyFastBlocks(t *testing.T) {
+	t.Parallel()
+
+	bc := newDifficultyTestChain(t, 5)
+
+	// One second blocks against a four second block time.
+	parent := writeTestBlocks(t, bc, 9, 1)
+	assert.Equal(t, uint64(16), parent.Difficulty)
+	assert.Equal(t, uint64(17), bc.CalcNextDifficulty(parent))
+}
+
+func TestCalcNextDifficultySlowBlocks(t *testing.T) {
+	t.Parallel()
+
+	bc := newDifficultyTestChain(t, 5)
+
+	// Thirty second blocks against a four second block time, clamped to a single step.
+	parent := writeTestBlocks(t, bc, 9, 30)
+	assert.Equal(t, uint64(16), parent.Difficulty)
+	assert.Equal(t, uint64(15), bc.CalcNextDifficulty(parent))
+}
+
+func TestCalcNextDifficultyOnTarget(t *testing.T) {
+	t.Parallel()
+
+	bc := newDifficultyTestChain(t, 5)
+
+	parent := writeTestBlocks(t, bc, 9, 4)
+	assert.Equal(t, uint64(16), bc.CalcNextDifficulty(parent))
+
+	// Difficulty is kept between retarget heights.
+	parent = writeTestBlocks(t, newDifficultyTestChain(t, 5), 7, 1)
+	assert.Equal(t, uint64(16), parent.Difficulty)
+}
+
+func TestCalcNextDifficultyManipulatedTimestamp(t *testing.T) {
+	t.Parallel()
+
+	timestamps := make([]uint64, 9)
+	for i := range timestamps {
+		timestamps[i] = 1700000000 + uint64(i)*4
+	}
+
+	// A last block stamped an hour late, or before its parent, would make the window look
+	// slow or instant. The median of the last blocks isn't moved by it.
+	for _, manipulated := range []uint64{timestamps[8] + 3600, timestamps[0]} {
+		timestamps[8] = manipulated
+
+		parent := writeTestBlocksAt(t, newDifficultyTestChain(t, 5), timestamps)
+		assert.Equal(t, uint64(16), newDifficultyTestChain(t, 5).CalcNextDifficulty(parent), "timestamp %d", manipulated)
+	}
+}
+
+func TestMedianTimeClamp(t *testing.T) {
+	t.Parallel()
+
+	bc := newDifficultyTestChain(t, 5)
+
+	// A block stamped before its parent is taken at the time of its parent.
+	parent := writeTestBlocksAt(t, bc, []uint64{1700000000, 1700000100, 1700000050})
+
+	median, ok := bc.medianTime(parent, 2)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1700000100), median)
+
+	// A block stamped a day ahead is taken at its timestamp, whatever the local clock, so
+	// that nodes with different clocks agree on the difficulty.
+	future := uint64(time.Now().Add(24 * time.Hour).Unix())
+	parent = writeTestBlocksAt(t, bc, []uint64{future})
+
+	median, ok = bc.medianTime(parent, 1)
+	assert.True(t, ok)
+	assert.Equal(t, future, median)
+}
+
+func TestCalcNextDifficultyBounds(t *testing.T) {
+	t.Parallel()
+
+	// Fast blocks can't raise the difficulty above the maximum.
+	bc := newDifficultyTestChain(t, 5)
+	bc.Config.MaxDifficulty = 16
+
+	parent := writeTestBlocks(t, bc, 9, 1)
+	assert.Equal(t, uint64(16), bc.CalcNextDifficulty(parent))
+
+	// Slow blocks can't lower it below the minimum.
+	bc = newDifficultyTestChain(t, 5)
+	bc.Config.MinDifficulty = 16
+
+	parent = writeTestBlocks(t, bc, 9, 30)
+	assert.Equal(t, uint64(16), bc.CalcNextDifficulty(parent))
+
+	// A parent out of the bounds, after they changed, is brought back within them.
+	bc = newDifficultyTestChain(t, 5)
+	bc.Config.MinDifficulty = 18
+	bc.Config.MaxDifficulty = 20
+
+	parent = writeTestBlocks(t, bc, 7, 4)
+	assert.Equal(t, uint64(18), parent.Difficulty)
+
+	parent.Difficulty = 24
+	assert.Equal(t, uint64(20), bc.CalcNextDifficulty(parent))
+}
+
+func TestCheckDifficultyBounds(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		min, max, initial uint64
+		valid             bool
+	}{
+		{0, 0, 16, true},
+		{8, 0, 16, true},
+		{0, 24, 16, true},
+		{16, 16, 16, true},
+		{17, 24, 16, false},
+		{8, 15, 16, false},
+		{24, 8, 16, false},
+	} {
+		err := checkDifficultyBounds(&config.Config{MinDifficulty: tc.min, MaxDifficulty: tc.max}, tc.initial)
+
+		if tc.valid {
+			assert.NoError(t, err, "bounds %d to %d", tc.min, tc.max)
+		} else {
+			assert.ErrorIs(t, err, ErrDifficultyOutOfBounds, "bounds %d to %d", tc.min, tc.max)
+		}
+	}
+}
+
+// nolint : tparallel
+func TestDifficultyBoundsAtStartup(t *testing.T) {
+	config := newRPCTestConfig(t, ":1784", ":6135")
+	config.MinDifficulty = uint64(config.ConsensusDifficulty) + 1
+
+	_, err := NewBlockchain(config)
+	assert.ErrorIs(t, err, ErrDifficultyOutOfBounds)
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrDuplicateTx is returned for blocks including the same transaction more than once.
+var ErrDuplicateTx = errors.New("duplicate transaction in block")
+
+// verifyUniqueTxs checks that no transaction hash appears more than once in the block,
+// which would apply and charge the transaction twice.
+func verifyUniqueTxs(block *types.Block) error {
+	seen := make(map[string]bool, len(block.Transactions))
+
+	for i, tx := range block.Transactions {
+		hash := tx.Hash().String()
+		if seen[hash] {
+			return fmt.Errorf("%w : transaction %d %s", ErrDuplicateTx, i, hash)
+		}
+
+		seen[hash] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyUniqueTxs(t *testing.T) {
+	t.Parallel()
+
+	tx1 := newTransaction(t, []byte{0x01}, []byte{0x02}, "", 100, 1, 0)
+	tx2 := newTransaction(t, []byte{0x01}, []byte{0x02}, "", 100, 1, 1)
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{})
+	block.Transactions = []*types.Transaction{tx1, tx2}
+
+	assert.NoError(t, verifyUniqueTxs(block))
+
+	block.Transactions = []*types.Transaction{tx1, tx2, tx1}
+
+	err := verifyUniqueTxs(block)
+	assert.ErrorIs(t, err, ErrDuplicateTx)
+	assert.Contains(t, err.Error(), "transaction 2 "+tx1.Hash().String())
+}
+
+// nolint : tparallel
+func TestRejectDuplicateTx(t *testing.T) {
+	config := newRPCTestConfig(t, ":1817", ":6169")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, 0)
+	tx.Sign(ua)
+
+	parent := chain.LastBlock
+
+	// The transaction is neither mined nor charged twice.
+	assert.ErrorIs(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx, tx}, make(chan bool), config.SignerPrivateKey), ErrDuplicateTx)
+	assert.Equal(t, parent.DeriveHash(), chain.Current().DeriveHash())
+	assert.Equal(t, big.NewInt(1000000000000000000), stateBalance(t, chain, ua.Address()))
+
+	// A sealed block of a peer including it twice is refused as invalid.
+	block := types.NewBlock(big.NewInt(1), parent.DeriveHash(), []byte("Block 1"))
+	block.Timestamp = nextTimestamp(parent)
+	block.Difficulty = chain.CalcNextDifficulty(parent)
+	block.BaseFee = chain.CalcBaseFee(parent)
+	block.Transactions = []*types.Transaction{tx, tx}
+	block.SetTxRoot()
+
+	// Sealed without executing the transactions, as mining would drop the copy.
+	signer := util.NewUnlockedAccount(config.SignerPrivateKey)
+
+	for nonce := int64(0); ; nonce++ {
+		block.SetNonce(big.NewInt(nonce))
+		block.Sign(signer)
+
+		if chain.Consensus.VerifySeal(block) {
+			break
+		}
+	}
+
+	err := chain.AddExternalBlock(block)
+	assert.ErrorIs(t, err, ErrDuplicateTx)
+	assert.True(t, isInvalidBlock(err))
+	assert.Equal(t, parent.DeriveHash(), chain.Current().DeriveHash())
+	assert.Equal(t, big.NewInt(1000000000000000000), stateBalance(t, chain, ua.Address()))
+
+	// Included once, it is mined.
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 1)
+}
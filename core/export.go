@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// maxExportedBlockSize bounds the length prefix accepted when reading an exported chain.
+const maxExportedBlockSize = 32 * 1024 * 1024
+
+var (
+	ErrInvalidExportRange   = errors.New("invalid export range")
+	ErrInvalidExportedBlock = errors.New("invalid exported block")
+)
+
+// ExportChain writes the canonical blocks from..to to w. Each block is serialized and
+// prefixed with its length as a big endian uint32.
+func ExportChain(blockchainDB *dbstore.BlockchainDB, w io.Writer, from uint64, to uint64) error {
+	latest, err := blockchainDB.GetLatestBlock()
+	if err != nil {
+		return fmt.Errorf("failed to read the latest block : %w", err)
+	}
+
+	if from > to || to > latest.Number.Uint64() {
+		return fmt.Errorf("%w : from %d to %d, latest block is %d", ErrInvalidExportRange, from, to, latest.Number.Uint64())
+	}
+
+	for number := from; number <= to; number++ {
+		block, err := blockchainDB.GetBlockByNumber(new(big.Int).SetUint64(number))
+		if err != nil {
+			return fmt.Errorf("failed to read block %d : %w", number, err)
+		}
+
+		if err := WriteExportedBlock(w, block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteExportedBlock writes a single length prefixed block to w.
+func WriteExportedBlock(w io.Writer, block *types.Block) error {
+	data := block.Serialize()
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+
+	return err
+}
+
+// ReadExportedBlock reads the next block written by ExportChain. It returns io.EOF once
+// all blocks were read.
+func ReadExportedBlock(r io.Reader) (*types.Block, error) {
+	var size uint32
+
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w : truncated length prefix", ErrInvalidExportedBlock)
+		}
+
+		return nil, err
+	}
+
+	if size > maxExportedBlockSize {
+		return nil, fmt.Errorf("%w : block of %d bytes", ErrInvalidExportedBlock, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w : truncated block", ErrInvalidExportedBlock)
+	}
+
+	block, err := types.DecodeBlock(data, maxExportedBlockSize, math.MaxInt)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidExportedBlock, err)
+	}
+
+	return block, nil
+}
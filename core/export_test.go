@@ -0,0 +1,54 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestExportChain(t *testing.T) {
+	chain := newTestBlockchain(t, newRPCTestConfig(t, ":1733", ":6083"))
+	defer chain.Close()
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), chain.Config.SignerPrivateKey))
+	}
+
+	var buf bytes.Buffer
+
+	assert.NoError(t, ExportChain(chain.BlockchainDb, &buf, 1, 3))
+
+	blocks := []*types.Block{}
+
+	for {
+		block, err := ReadExportedBlock(&buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		assert.NoError(t, err)
+
+		blocks = append(blocks, block)
+	}
+
+	assert.Equal(t, 3, len(blocks))
+	assert.Equal(t, uint64(1), blocks[0].Number.Uint64())
+	assert.Equal(t, chain.LastBlock.DeriveHash(), blocks[2].DeriveHash())
+
+	// Ranges must be ordered and end at most at the latest block.
+	assert.ErrorIs(t, ExportChain(chain.BlockchainDb, &buf, 2, 1), ErrInvalidExportRange)
+	assert.ErrorIs(t, ExportChain(chain.BlockchainDb, &buf, 0, 4), ErrInvalidExportRange)
+
+	// Truncated files are reported.
+	buf.Reset()
+	assert.NoError(t, ExportChain(chain.BlockchainDb, &buf, 0, 0))
+
+	_, err := ReadExportedBlock(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, ErrInvalidExportedBlock)
+}
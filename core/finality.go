@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrFinalizedBlock is returned for blocks of a branch which would rewrite a final block.
+var ErrFinalizedBlock = errors.New("block conflicts with a finalized block")
+
+// ErrReorgTooDeep is returned for blocks of a branch which would drop more canonical blocks
+// than the maximum reorg depth.
+var ErrReorgTooDeep = errors.New("reorg deeper than the maximum reorg depth")
+
+// finalityDepth returns the number of blocks a block has to be buried under to be final,
+// the smaller of the finality depth and the maximum reorg depth of the config, zero if
+// neither is set.
+func finalityDepth(c *config.Config) uint64 {
+	if c.MaxReorgDepth != 0 && (c.FinalityDepth == 0 || c.MaxReorgDepth < c.FinalityDepth) {
+		return c.MaxReorgDepth
+	}
+
+	return c.FinalityDepth
+}
+
+// finalityError returns the error refusing the branches which rewrite a final block,
+// ErrReorgTooDeep when the maximum reorg depth makes it final.
+func finalityError(c *config.Config) error {
+	if finalityDepth(c) != c.FinalityDepth {
+		return ErrReorgTooDeep
+	}
+
+	return ErrFinalizedBlock
+}
+
+// finalizedNumber returns the number of the highest final block of the chain with the
+// given head, buried under depth blocks, or nil if no block is final.
+func finalizedNumber(head *big.Int, depth uint64) *big.Int {
+	if depth == 0 {
+		return nil
+	}
+
+	number := new(big.Int).Sub(head, new(big.Int).SetUint64(depth))
+	if number.Sign() < 0 {
+		return nil
+	}
+
+	return number
+}
+
+// IsFinalized reports whether the canonical block with the given number is buried under
+// FinalityDepth, or MaxReorgDepth if smaller, blocks, so that no reorg will rewrite it.
+func (bc *Blockchain) IsFinalized(number *big.Int) bool {
+	bc.Mutex.RLock()
+	defer bc.Mutex.RUnlock()
+
+	finalized := finalizedNumber(bc.LastBlock.Number, finalityDepth(bc.Config))
+
+	return finalized != nil && number.Cmp(finalized) <= 0
+}
+
+// verifyFinality checks that the block is above the final blocks, blocks at their heights
+// competing with them. The caller must hold the blockchain lock.
+func (bc *Blockchain) verifyFinality(block *types.Block) error {
+	finalized := finalizedNumber(bc.LastBlock.Number, finalityDepth(bc.Config))
+	if finalized == nil || block.Number.Cmp(finalized) > 0 {
+		return nil
+	}
+
+	return finalityError(bc.Config)
+}
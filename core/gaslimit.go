@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// ErrBlockGasLimit is returned when the transactions of a block use more gas than BlockGasLimit.
+var ErrBlockGasLimit = errors.New("block gas limit exceeded")
+
+// blockSealBytes bounds the size the seal, the signature and public key of the signer,
+// adds to a packed block.
+var blockSealBytes = 256
+
+// packTxs returns the transactions to include in the block, in their canonical order so
+// that the same transactions always make the same block. Transactions signed for another
+// chain or paying less than the base fee of the block are skipped, as well as those which
+// would take the gas used by the block above BlockGasLimit, or the block over MaxBlockTxs
+// transactions or MaxBlockBytes. Once a transaction is skipped, the later ones of the
+// same sender are too, as their nonces can't be executed anymore.
+func (bc *Blockchain) packTxs(txs []*types.Transaction, block *types.Block) []*types.Transaction {
+	baseFee := block.BaseFee
+	allowLegacy := bc.allowLegacyTxs(block.Number)
+	gasLimit := bc.Config.BlockGasLimit
+	gasUsed := uint64(0)
+	maxBytes, maxTxs := bc.blockLimits()
+	size := len(block.Serialize()) + blockSealBytes
+	skipped := make(map[util.Address]bool)
+	packed := []*types.Transaction{}
+
+	for _, tx := range types.CanonicalOrder(txs) {
+		txSize := len(tx.Serialize())
+
+		switch {
+		case skipped[tx.From]:
+			continue
+		case tx.VerifyChainID(bc.Config.NetworkID, allowLegacy) != nil:
+			bc.Logger.Debug("Skipping tx of another chain", "hash", tx.Hash().String(), "chainID", tx.ChainID)
+		case !tx.CoversBaseFee(baseFee):
+			bc.Logger.Debug("Skipping underpriced tx", "hash", tx.Hash().String(), "fee", tx.FeeCap(), "baseFeeCost", tx.BaseFeeCost(baseFee))
+		case gasLimit != 0 && gasUsed+tx.Gas() > gasLimit:
+			bc.Logger.Debug("Skipping tx over the block gas limit", "hash", tx.Hash().String(), "gas", tx.Gas(), "gasUsed", gasUsed, "gasLimit", gasLimit)
+		case len(packed) >= maxTxs:
+			bc.Logger.Debug("Skipping tx over the block transactions limit", "hash", tx.Hash().String(), "maxTxs", maxTxs)
+		case size+txSize > maxBytes:
+			bc.Logger.Debug("Skipping tx over the block size limit", "hash", tx.Hash().String(), "size", txSize, "blockSize", size, "maxBytes", maxBytes)
+		default:
+			gasUsed += tx.Gas()
+			size += txSize
+			packed = append(packed, tx)
+
+			continue
+		}
+
+		skipped[tx.From] = true
+	}
+
+	return packed
+}
+
+// blockLimits returns the maximum size and number of transactions of a block.
+func (bc *Blockchain) blockLimits() (int, int) {
+	maxBytes, maxTxs := bc.Config.MaxBlockBytes, bc.Config.MaxBlockTxs
+
+	if maxBytes <= 0 {
+		maxBytes = types.DefaultMaxBlockBytes
+	}
+
+	if maxTxs <= 0 {
+		maxTxs = types.DefaultMaxBlockTxs
+	}
+
+	return maxBytes, maxTxs
+}
+
+// verifyGasLimit checks that the transactions of the block fit in BlockGasLimit.
+func (bc *Blockchain) verifyGasLimit(block *types.Block) error {
+	if bc.Config.BlockGasLimit != 0 && block.GasUsed() > bc.Config.BlockGasLimit {
+		return ErrBlockGasLimit
+	}
+
+	return nil
+}
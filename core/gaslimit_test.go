@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/txpool"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyGasLimit(t *testing.T) {
+	t.Parallel()
+
+	bc := &Blockchain{Config: &config.Config{BlockGasLimit: 3 * types.TxGas}}
+
+	block := newBaseFeeTestBlock(t, 100, 3)
+	assert.NoError(t, bc.verifyGasLimit(block))
+
+	block.Transactions[0].GasLimit = types.TxGas + 1
+	assert.ErrorIs(t, bc.verifyGasLimit(block), ErrBlockGasLimit)
+
+	bc.Config.BlockGasLimit = 0
+	assert.NoError(t, bc.verifyGasLimit(block))
+}
+
+func TestPackTxsBlockLimits(t *testing.T) {
+	t.Parallel()
+
+	log, err := logger.New(io.Discard, "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	txs := []*types.Transaction{}
+
+	for nonce := int64(0); nonce < 10; nonce++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 100, 1, nonce)
+		tx.Sign(ua)
+
+		txs = append(txs, tx)
+	}
+
+	pack := func(c *config.Config) *types.Block {
+		bc := &Blockchain{Config: c, Logger: log}
+
+		block := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte("Block 1"))
+		block.BaseFee = big.NewInt(100)
+		block.Transactions = bc.packTxs(txs, block)
+
+		return block
+	}
+
+	assert.Len(t, pack(&config.Config{}).Transactions, 10)
+	assert.Len(t, pack(&config.Config{MaxBlockTxs: 4}).Transactions, 4)
+
+	// The sealed block stays within the size limit.
+	block := pack(&config.Config{MaxBlockBytes: 1500})
+	assert.NotEmpty(t, block.Transactions)
+	assert.Less(t, len(block.Transactions), 10)
+
+	block.Sign(ua)
+	assert.LessOrEqual(t, len(block.Serialize()), 1500)
+}
+
+// nolint : tparallel
+func TestBlockGasLimitSpillsTxs(t *testing.T) {
+	config := newRPCTestConfig(t, ":1740", ":6090")
+	config.BlockGasLimit = 60000
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ub := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+	config.BalanceAlloc[ub.Address().String()] = big.NewInt(1000000000000000000)
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.GRPCSrv.Stop()
+	}()
+
+	newTx := func(ua *util.UnlockedAccount, fee int64, gasLimit uint64, nonce int64) *types.Transaction {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", fee, 1000, nonce)
+		tx.GasLimit = gasLimit
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	// Fees per gas : b0 > a0 > a1 > b1
+	a0 := newTx(ua, 1000, 0, 0)
+	a1 := newTx(ua, 500, 0, 1)
+	b0 := newTx(ub, 1500, 30000, 0)
+	b1 := newTx(ub, 600, 30000, 1)
+
+	for _, tx := range []*types.Transaction{a0, a1, b0, b1} {
+		assert.NoError(t, chain.Txpool.AddTx(tx))
+	}
+
+	// Transactions which can't fit in any block are refused.
+	assert.ErrorIs(t, chain.Txpool.AddTx(newTx(ua, 5000, 60001, 2)), txpool.ErrGasLimit)
+	assert.ErrorIs(t, chain.Txpool.AddTx(newTx(ua, 5000, types.TxGas-1, 2)), txpool.ErrIntrinsicGas)
+
+	hashes := func(txs []*types.Transaction) []string {
+		out := []string{}
+		for _, tx := range txs {
+			out = append(out, tx.Hash().String())
+		}
+
+		return out
+	}
+
+	expected := [][]*types.Transaction{{b0, a0}, {a1, b1}, {}}
+
+	for _, txs := range expected {
+		err := chain.AddBlock([]byte(fmt.Sprintf("Block %d", chain.LastBlock.Number.Int64()+1)), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey)
+		assert.NoError(t, err)
+
+		assert.Equal(t, hashes(txs), hashes(chain.LastBlock.Transactions))
+		assert.LessOrEqual(t, chain.LastBlock.GasUsed(), config.BlockGasLimit)
+	}
+}
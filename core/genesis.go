@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// ErrInvalidGenesis is returned when a genesis file can't be loaded.
+var ErrInvalidGenesis = errors.New("invalid genesis file")
+
+// Genesis is the content of a genesis file. Balances are decimal strings, so that they
+// aren't limited to the precision of JSON numbers.
+type Genesis struct {
+	ChainID    uint64            `json:"chainId"`
+	Difficulty uint64            `json:"difficulty"`
+	Timestamp  uint64            `json:"timestamp"` // Unix time of the genesis block, zero if unset
+	Alloc      map[string]string `json:"alloc"`
+
+	balanceAlloc map[string]*big.Int
+}
+
+// LoadGenesis reads and validates the genesis file at the given path.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file %s : %w", path, err)
+	}
+
+	var genesis Genesis
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&genesis); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidGenesis, err)
+	}
+
+	if genesis.Difficulty == 0 || genesis.Difficulty > 255 {
+		return nil, fmt.Errorf("%w : difficulty must be between 1 and 255, got %d", ErrInvalidGenesis, genesis.Difficulty)
+	}
+
+	genesis.balanceAlloc = make(map[string]*big.Int)
+
+	for address, amount := range genesis.Alloc {
+		if _, err := util.HexToAddress(address); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidGenesis, err)
+		}
+
+		balance, ok := new(big.Int).SetString(amount, 10)
+		if !ok || balance.Sign() < 0 {
+			return nil, fmt.Errorf("%w : balance %s of %s is not a positive integer", ErrInvalidGenesis, amount, address)
+		}
+
+		genesis.balanceAlloc[strings.ToLower(address)] = balance
+	}
+
+	return &genesis, nil
+}
+
+// Configure returns a copy of the config with the chain id, the initial difficulty and
+// the balance allocation of the genesis. The given config is left unchanged.
+func (g *Genesis) Configure(c *config.Config) *config.Config {
+	cfg := *c
+	cfg.NetworkID = g.ChainID
+	cfg.ConsensusDifficulty = int(g.Difficulty)
+	cfg.BalanceAlloc = make(map[string]*big.Int, len(g.balanceAlloc))
+
+	for address, balance := range g.balanceAlloc {
+		cfg.BalanceAlloc[address] = new(big.Int).Set(balance)
+	}
+
+	return &cfg
+}
+
+// Hash returns the hash of the genesis content the genesis block commits to. The timestamp
+// is only hashed when set, keeping the hash of the genesis files without one.
+func (g *Genesis) Hash() *util.Hash {
+	chainID := binary.BigEndian.AppendUint64(nil, g.ChainID)
+	difficulty := binary.BigEndian.AppendUint64(nil, g.Difficulty)
+	content := [][]byte{chainID, difficulty, allocHash(g.balanceAlloc).Bytes()}
+
+	if g.Timestamp != 0 {
+		content = append(content, binary.BigEndian.AppendUint64(nil, g.Timestamp))
+	}
+
+	return util.HashData(bytes.Join(content, []byte{}))
+}
+
+// CreateBlock allocates the genesis balances in the state and returns the genesis block.
+// Nodes given the same genesis get the same block, the block hash committing to the
+// timestamp of the genesis both through the header and the hash of the genesis.
+func (g *Genesis) CreateBlock(db *dbstore.DB) *types.Block {
+	extraData := append([]byte("Genesis Block"), g.Hash().Bytes()...)
+
+	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("0x0")), extraData)
+	genesis.Version = 0
+	genesis.Difficulty = g.Difficulty
+	genesis.Timestamp = g.Timestamp
+
+	allocBalances(g.balanceAlloc, db)
+
+	return genesis
+}
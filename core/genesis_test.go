@@ -0,0 +1,172 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGenesis = `{
+  "chainId": 7,
+  "difficulty": 8,
+  "alloc": {
+    "0xA52C981EEE8687B5E4AFD69AA5006548C24D7685": "1000000000000000000000",
+    "0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": "5"
+  }
+}`
+
+func writeGenesisFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func newGenesisTestDB(t *testing.T) *dbstore.DB {
+	t.Helper()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// nolint : errcheck
+		db.Close()
+	})
+
+	return db
+}
+
+func TestLoadGenesis(t *testing.T) {
+	t.Parallel()
+
+	genesis, err := LoadGenesis(writeGenesisFile(t, testGenesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := &config.Config{NetworkID: 1}
+	cfg := genesis.Configure(original)
+
+	// The given config is left unchanged.
+	assert.Equal(t, &config.Config{NetworkID: 1}, original)
+
+	assert.Equal(t, uint64(7), cfg.NetworkID)
+	assert.Equal(t, 8, cfg.ConsensusDifficulty)
+
+	balance, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	assert.Equal(t, map[string]*big.Int{"0xa52c981eee8687b5e4afd69aa5006548c24d7685": balance, "0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": big.NewInt(5)}, cfg.BalanceAlloc)
+
+	for _, content := range []string{
+		`{"chainId": 7, "difficulty": 8, "alloc": {"0x01": "5"}}`,
+		`{"chainId": 7, "difficulty": 8, "alloc": {"0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": "-5"}}`,
+		`{"chainId": 7, "difficulty": 0}`,
+		`{"chainId": 7, "difficulty": 8, "unknown": true}`,
+		`not json`,
+	} {
+		_, err := LoadGenesis(writeGenesisFile(t, content))
+		assert.ErrorIs(t, err, ErrInvalidGenesis, content)
+	}
+
+	_, err = LoadGenesis(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestGenesisHashStable(t *testing.T) {
+	t.Parallel()
+
+	genesis, err := LoadGenesis(writeGenesisFile(t, testGenesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file gives the same genesis block, whatever the order of the allocation.
+	reordered, err := LoadGenesis(writeGenesisFile(t, `{"alloc": {"0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e": "5", "0xa52c981eee8687b5e4afd69aa5006548c24d7685": "1000000000000000000000"}, "difficulty": 8, "chainId": 7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := genesis.CreateBlock(newGenesisTestDB(t)).DeriveHash()
+	assert.Equal(t, hash, genesis.CreateBlock(newGenesisTestDB(t)).DeriveHash())
+	assert.Equal(t, hash, reordered.CreateBlock(newGenesisTestDB(t)).DeriveHash())
+	assert.Equal(t, "0x405ee4500ee527cbe01b279545ae66e72acfb840c1763e6a5d4fb1599236510f", hash.String())
+
+	// Any change of the chain id, the difficulty, the timestamp or the allocation changes the hash.
+	for _, content := range []string{
+		strings.Replace(testGenesis, `"chainId": 7`, `"chainId": 8`, 1),
+		strings.Replace(testGenesis, `"difficulty": 8`, `"difficulty": 9`, 1),
+		strings.Replace(testGenesis, `"difficulty": 8`, `"difficulty": 8, "timestamp": 1700000000`, 1),
+		strings.Replace(testGenesis, `"5"`, `"6"`, 1),
+	} {
+		other, err := LoadGenesis(writeGenesisFile(t, content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NotEqual(t, hash, other.CreateBlock(newGenesisTestDB(t)).DeriveHash())
+		assert.NotEqual(t, genesis.Hash(), other.Hash())
+	}
+}
+
+// nolint : tparallel
+func TestBlockchainGenesisFile(t *testing.T) {
+	config := newRPCTestConfig(t, ":1752", ":6102")
+	config.GenesisFile = writeGenesisFile(t, testGenesis)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	genesis, err := LoadGenesis(config.GenesisFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, genesis.CreateBlock(newGenesisTestDB(t)).DeriveHash(), chain.LastBlock.DeriveHash())
+	assert.Equal(t, uint64(7), chain.P2PServer.Status.NetworkID)
+	assert.Equal(t, chain.LastBlock.DeriveHash(), chain.P2PServer.Status.GenesisHash)
+
+	balance, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, "0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e"))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(5), new(big.Int).SetBytes(balance))
+
+	// Blocks are mined with the difficulty of the genesis file.
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), nil, make(chan bool), config.SignerPrivateKey))
+	assert.Equal(t, uint64(8), chain.LastBlock.Difficulty)
+}
+
+// nolint : tparallel
+func TestGenesisDeterministic(t *testing.T) {
+	genesisFile := writeGenesisFile(t, strings.Replace(testGenesis, `"difficulty": 8`, `"difficulty": 8, "timestamp": 1700000000`, 1))
+
+	for i, genesisFile := range []string{"", genesisFile} {
+		config := newRPCTestConfig(t, ":1770", ":6120")
+		config.GenesisFile = genesisFile
+
+		chain := newTestBlockchain(t, config)
+		genesis := chain.LastBlock.Serialize()
+		chain.Close()
+
+		// The second node starts on a later second of the clock, from empty databases.
+		time.Sleep(1100 * time.Millisecond)
+
+		config = newRPCTestConfig(t, ":1771", ":6121")
+		config.GenesisFile = genesisFile
+
+		other := newTestBlockchain(t, config)
+		assert.Equal(t, genesis, other.LastBlock.Serialize(), i)
+		assert.Equal(t, uint64(i*1700000000), other.LastBlock.Timestamp)
+		other.Close()
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestBlockGossipLine(t *testing.T) {
+	// node1 <-> node2 <-> node3
+	configs := []*struct {
+		rpcPort, p2pPort string
+		peers            []string
+	}{
+		{":1761", ":6111", []string{"localhost:6112"}},
+		{":1762", ":6112", []string{"localhost:6111", "localhost:6113"}},
+		{":1763", ":6113", []string{"localhost:6112"}},
+	}
+
+	nodes := []*Blockchain{}
+
+	for _, c := range configs {
+		config := newRPCTestConfig(t, c.rpcPort, c.p2pPort)
+		config.Peers = c.peers
+		config.MaxPeerBackoff = 200 * time.Millisecond
+
+		node := newTestBlockchain(t, config)
+		defer node.Close()
+
+		go node.ImportBlockLoop()
+
+		nodes = append(nodes, node)
+	}
+
+	const blocks = 3
+
+	for i := 1; i <= blocks; i++ {
+		assert.NoError(t, nodes[0].AddBlock([]byte(fmt.Sprintf("Block %d", i)), nil, make(chan bool), nodes[0].Config.SignerPrivateKey))
+	}
+
+	head := nodes[0].LastBlock.DeriveHash().String()
+
+	// The blocks reach the other end of the line through node2.
+	assert.Eventually(t, func() bool {
+		latest, err := nodes[2].BlockchainDb.GetLatestBlock()
+		return err == nil && latest.DeriveHash().String() == head
+	}, 20*time.Second, 50*time.Millisecond)
+
+	// Give the nodes time to poll each other again.
+	time.Sleep(time.Second)
+
+	// Each body was received once by each node lacking it, the announcements of the
+	// blocks a node has already don't make it download them again.
+	assert.Equal(t, uint64(0), nodes[0].P2PServer.Downloader.BlockBodies())
+	assert.Equal(t, uint64(blocks), nodes[1].P2PServer.Downloader.BlockBodies())
+	assert.Equal(t, uint64(blocks), nodes[2].P2PServer.Downloader.BlockBodies())
+}
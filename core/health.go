@@ -0,0 +1,65 @@
+package core
+
+import (
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/p2p"
+	"github.com/0xsharma/compact-chain/rpc"
+)
+
+// nodeStatus reports the state of the node to the RPC health check.
+type nodeStatus struct {
+	blockchainDB *dbstore.BlockchainDB
+	p2pServer    *p2p.P2PServer
+	miner        *Miner
+}
+
+// Height returns the number of the head block.
+func (s *nodeStatus) Height() uint64 {
+	latest, err := s.blockchainDB.GetLatestBlock()
+	if err != nil {
+		return 0
+	}
+
+	return latest.Number.Uint64()
+}
+
+// PeerCount returns the number of connected peers.
+func (s *nodeStatus) PeerCount() int {
+	return s.p2pServer.Downloader.ConnectedPeers()
+}
+
+// Peers returns the connected peers.
+func (s *nodeStatus) Peers() []*rpc.Peer {
+	peers := []*rpc.Peer{}
+
+	for _, info := range s.p2pServer.PeerInfos() {
+		direction := rpc.PeerOutbound
+		if info.Inbound {
+			direction = rpc.PeerInbound
+		}
+
+		peers = append(peers, &rpc.Peer{
+			Addr:            info.Addr,
+			Direction:       direction,
+			ProtocolVersion: info.ProtocolVersion,
+			Height:          info.Height,
+		})
+	}
+
+	return peers
+}
+
+// HighestPeerHeight returns the highest height advertised by the connected peers.
+func (s *nodeStatus) HighestPeerHeight() uint64 {
+	return s.p2pServer.HighestPeerHeight()
+}
+
+// Syncing reports whether a connected peer is ahead of the head block.
+func (s *nodeStatus) Syncing() bool {
+	return s.HighestPeerHeight() > s.Height()
+}
+
+// Mining reports whether the node seals blocks.
+func (s *nodeStatus) Mining() bool {
+	return s.miner.Mining()
+}
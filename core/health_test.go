@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestHealthRequiresPeers(t *testing.T) {
+	config := newRPCTestConfig(t, ":1754", ":6104")
+	config.Peers = []string{"localhost:6105"}
+	config.MinPeersForHealthy = 1
+	config.MaxPeerBackoff = 200 * time.Millisecond
+
+	chain := newTestBlockchain(t, config)
+
+	defer func() {
+		chain.RPCServer.HttpServer.Shutdown(context.Background())
+		chain.P2PServer.Stop()
+	}()
+
+	health := func() (int, *rpc.Health) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost"+config.RPCPort+rpc.HealthPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, nil
+		}
+		defer res.Body.Close()
+
+		var out rpc.Health
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+
+		return res.StatusCode, &out
+	}
+
+	// The peer isn't running yet.
+	assert.Eventually(t, func() bool { code, _ := health(); return code != 0 }, 5*time.Second, 10*time.Millisecond)
+
+	code, status := health()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, &rpc.Health{Healthy: false, Peers: 0, Height: 0, Mining: true}, status)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_status")
+	assert.Nil(t, res.Error)
+	assert.JSONEq(t, `{"healthy":false,"syncing":false,"peers":0,"height":0,"mining":true}`, string(res.Result))
+
+	peerConfig := newRPCTestConfig(t, ":1755", ":6105")
+	peer := newTestBlockchain(t, peerConfig)
+
+	defer func() {
+		peer.RPCServer.HttpServer.Shutdown(context.Background())
+		peer.P2PServer.Stop()
+	}()
+
+	assert.Eventually(t, func() bool { code, _ := health(); return code == http.StatusOK }, 10*time.Second, 50*time.Millisecond)
+
+	_, status = health()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, 1, status.Peers)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+)
+
+var ErrGenesisMismatch = errors.New("genesis block does not match the local chain")
+
+// ImportChain reads blocks written by ExportChain and adds them to the chain through
+// the same validation as blocks received from peers. Blocks the chain already has are
+// skipped. The import stops at the first invalid block.
+func (bc *Blockchain) ImportChain(r io.Reader) (imported int, skipped int, err error) {
+	for {
+		block, err := ReadExportedBlock(r)
+		if errors.Is(err, io.EOF) {
+			return imported, skipped, nil
+		}
+
+		if err != nil {
+			return imported, skipped, fmt.Errorf("after %d blocks : %w", imported+skipped, err)
+		}
+
+		known, err := bc.BlockchainDb.DB.Has(dbstore.PrefixKey(dbstore.HashesKey, block.DeriveHash().String()))
+		if err != nil {
+			return imported, skipped, err
+		}
+
+		if known {
+			skipped++
+			continue
+		}
+
+		if block.Number.Sign() == 0 {
+			return imported, skipped, fmt.Errorf("block 0 : %w", ErrGenesisMismatch)
+		}
+
+		if err := bc.AddExternalBlock(block); err != nil {
+			return imported, skipped, fmt.Errorf("block %d : %w", block.Number, err)
+		}
+
+		imported++
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestImportChain(t *testing.T) {
+	chain := newTestBlockchain(t, newRPCTestConfig(t, ":1734", ":6084"))
+	defer chain.Close()
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+	to := util.BytesToAddress([]byte{0x01})
+
+	tx := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 100, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), chain.Config.SignerPrivateKey))
+
+	for i := 2; i <= 4; i++ {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), chain.Config.SignerPrivateKey))
+	}
+
+	var exported bytes.Buffer
+
+	assert.NoError(t, ExportChain(chain.BlockchainDb, &exported, 0, 4))
+
+	fresh := newTestBlockchain(t, newRPCTestConfig(t, ":1735", ":6085"))
+	defer fresh.Close()
+
+	// The fresh node already has the first 2 blocks.
+	var head bytes.Buffer
+
+	assert.NoError(t, ExportChain(chain.BlockchainDb, &head, 1, 2))
+
+	imported, skipped, err := fresh.ImportChain(&head)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, 0, skipped)
+
+	imported, skipped, err = fresh.ImportChain(bytes.NewReader(exported.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, 3, skipped)
+
+	assert.Equal(t, chain.LastBlock.DeriveHash(), fresh.LastBlock.DeriveHash())
+	assert.Equal(t, stateBalance(t, chain, to), stateBalance(t, fresh, to))
+
+	// A block with a broken seal aborts the import, reporting its number.
+	invalid := newTestBlockchain(t, newRPCTestConfig(t, ":1736", ":6086"))
+	defer invalid.Close()
+
+	var tampered bytes.Buffer
+
+	for i := 1; i <= 4; i++ {
+		block, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(int64(i)))
+		assert.NoError(t, err)
+
+		if i == 3 {
+			block.ExtraData = []byte("Tampered")
+		}
+
+		assert.NoError(t, WriteExportedBlock(&tampered, block))
+	}
+
+	imported, _, err = invalid.ImportChain(&tampered)
+	assert.ErrorIs(t, err, ErrInvalidSeal)
+	assert.ErrorContains(t, err, "block 3")
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, uint64(2), invalid.LastBlock.Number.Uint64())
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/golang/groupcache/lru"
+)
+
+// ErrKnownBlock is returned when importing a block the chain already has.
+var ErrKnownBlock = errors.New("block already known")
+
+// defaultKnownBlocksSize is the number of block hashes the import path remembers.
+var defaultKnownBlocksSize = 1024
+
+// knownBlocks remembers the hashes of the last blocks imported or mined, so that a block
+// received again, from another peer or the same one, is dropped before it is validated.
+type knownBlocks struct {
+	mu     sync.Mutex
+	hashes *lru.Cache
+}
+
+func newKnownBlocks(size int) *knownBlocks {
+	return &knownBlocks{hashes: lru.New(size)}
+}
+
+func (kb *knownBlocks) add(hash *util.Hash) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	kb.hashes.Add(hash.String(), struct{}{})
+}
+
+func (kb *knownBlocks) has(hash *util.Hash) bool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	_, ok := kb.hashes.Get(hash.String())
+
+	return ok
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// nolint : tparallel
+func TestImportKnownBlock(t *testing.T) {
+	config := newRPCTestConfig(t, ":1785", ":6136")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importerConfig := newRPCTestConfig(t, ":1786", ":6137")
+
+	importer := newTestBlockchain(t, importerConfig)
+	defer importer.Close()
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	block := chain.LastBlock
+
+	// A block mined locally is known right away.
+	assert.ErrorIs(t, chain.importBlock(block), ErrKnownBlock)
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ws, err := websocket.Dial("ws://localhost"+importerConfig.RPCPort+rpc.WebSocketPath, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	err = websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": []string{rpc.NewHeadsTopic}})
+	assert.NoError(t, err)
+
+	var res jsonrpcTestResponse
+	assert.NoError(t, websocket.JSON.Receive(ws, &res))
+	assert.Nil(t, res.Error)
+
+	assert.NoError(t, importer.importBlock(block))
+
+	var notification wsTestNotification
+
+	assert.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	assert.NoError(t, websocket.JSON.Receive(ws, &notification))
+	assert.Equal(t, block.DeriveHash().String(), notification.Params.Result.Hash)
+
+	// The second delivery returns without validating the block, which would need the
+	// chain lock.
+	importer.Mutex.Lock()
+
+	done := make(chan error, 1)
+	go func() { done <- importer.importBlock(block) }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrKnownBlock)
+	case <-time.After(time.Second):
+		t.Fatal("importing a known block waited for the chain lock")
+	}
+
+	importer.Mutex.Unlock()
+
+	// It isn't notified again.
+	assert.NoError(t, ws.SetReadDeadline(time.Now().Add(300*time.Millisecond)))
+	assert.Error(t, websocket.JSON.Receive(ws, &notification))
+	assert.Equal(t, block.DeriveHash(), importer.Current().DeriveHash())
+}
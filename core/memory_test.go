@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestMemoryDBBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	config := newRPCTestConfig(t, ":1759", ":6109")
+	config.DBBackend = dbstore.BackendMemory
+	config.DBDir = filepath.Join(dir, "db")
+	config.StateDBDir = filepath.Join(dir, "statedb")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	for i := 0; i < 3; i++ {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 100, 1000, int64(i))
+		tx.Sign(ua)
+
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i+1)), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	assert.Equal(t, uint64(3), chain.LastBlock.Number.Uint64())
+
+	to := util.BytesToAddress([]byte{0x01})
+
+	for number, expected := range []int64{0, 1000, 2000, 3000} {
+		balance, err := balanceAt(t, chain, to, uint64(number))
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(expected), balance)
+	}
+
+	block, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(2))
+	assert.NoError(t, err)
+	assert.Len(t, block.Transactions, 1)
+
+	// Nothing got written to disk.
+	for _, path := range []string{config.DBDir, config.StateDBDir} {
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	_, err = dbstore.OpenDBInstance("rocksdb", dir)
+	assert.ErrorIs(t, err, dbstore.ErrUnknownBackend)
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrNoSigner is returned when starting to mine on a node without a signer key.
+var ErrNoSigner = errors.New("no signer key to mine with")
+
+// Miner toggles the sealing of new blocks on a running node. Stopping interrupts the
+// block being mined, its transactions are left in the txpool.
+type Miner struct {
+	mining    atomic.Bool
+	canMine   bool
+	started   chan struct{}
+	interrupt chan bool
+}
+
+// newMiner returns a miner interrupting blocks through the given channel. It only
+// starts if canMine is set, as sealing needs a signer key.
+func newMiner(mining bool, canMine bool, interrupt chan bool) *Miner {
+	m := &Miner{
+		canMine:   canMine,
+		started:   make(chan struct{}, 1),
+		interrupt: interrupt,
+	}
+	m.mining.Store(mining && canMine)
+
+	return m
+}
+
+// Mining reports whether the node is sealing new blocks.
+func (m *Miner) Mining() bool {
+	return m.mining.Load()
+}
+
+// Start resumes sealing new blocks.
+func (m *Miner) Start() error {
+	if !m.canMine {
+		return ErrNoSigner
+	}
+
+	if m.mining.CompareAndSwap(false, true) {
+		select {
+		case m.started <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Stop stops sealing new blocks, interrupting the block being mined.
+func (m *Miner) Stop() {
+	if m.mining.CompareAndSwap(true, false) {
+		select {
+		case m.interrupt <- true:
+		default:
+		}
+	}
+}
+
+// wait blocks until mining is started, returning false if quit is closed first.
+// Interrupts sent while stopped are dropped, so they don't abort the next block.
+func (m *Miner) wait(quit <-chan struct{}) bool {
+	if m.Mining() {
+		return true
+	}
+
+	for !m.Mining() {
+		select {
+		case <-quit:
+			return false
+		case <-m.started:
+		}
+	}
+
+	for {
+		select {
+		case <-m.interrupt:
+		default:
+			return true
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinerStartStop(t *testing.T) {
+	t.Parallel()
+
+	interrupt := make(chan bool, 1)
+
+	m := newMiner(true, true, interrupt)
+	assert.True(t, m.Mining())
+
+	// Stopping interrupts the block being mined.
+	m.Stop()
+	assert.False(t, m.Mining())
+	assert.Len(t, interrupt, 1)
+
+	// A stopped miner waits until it is started, the stale interrupt doesn't abort
+	// the next block.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, m.Start())
+	}()
+
+	assert.True(t, m.wait(make(chan struct{})))
+	assert.True(t, m.Mining())
+	assert.Len(t, interrupt, 0)
+
+	m.Stop()
+
+	quit := make(chan struct{})
+	close(quit)
+	assert.False(t, m.wait(quit))
+
+	// Mining needs a signer key.
+	m = newMiner(true, false, interrupt)
+	assert.False(t, m.Mining())
+	assert.ErrorIs(t, m.Start(), ErrNoSigner)
+}
+
+// nolint : tparallel
+func TestRPCMinerStartStop(t *testing.T) {
+	config := newRPCTestConfig(t, ":1747", ":6097")
+	config.BlockTime = 2
+	config.RPCAuthToken = "s3cret"
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	chain.wg.Add(1)
+
+	go func() {
+		defer chain.wg.Done()
+		chain.mineLoop(config.SignerPrivateKey, config.BlockTime)
+	}()
+
+	assert.Eventually(t, func() bool { return chain.Current().Number.Int64() >= 1 }, 10*time.Second, 50*time.Millisecond)
+
+	res := sendAuthJSONRPCRequest(t, config.RPCPort, config.RPCAuthToken, "miner_stop")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "true", string(res.Result))
+
+	// Let the loop settle on the stopped miner.
+	time.Sleep(500 * time.Millisecond)
+
+	number := chain.Current().Number.Int64()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")) // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 1000, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.Txpool.AddTx(tx))
+
+	// No block is mined while stopped, the transaction stays pending.
+	time.Sleep(3 * time.Second)
+	assert.Equal(t, number, chain.Current().Number.Int64())
+
+	pending, _ := chain.Txpool.Stats()
+	assert.Equal(t, 1, pending)
+
+	res = sendAuthJSONRPCRequest(t, config.RPCPort, config.RPCAuthToken, "miner_start")
+	assert.Nil(t, res.Error)
+	assert.Equal(t, "true", string(res.Result))
+
+	assert.Eventually(t, func() bool {
+		pending, _ := chain.Txpool.Stats()
+
+		return pending == 0 && chain.Current().Number.Int64() > number
+	}, 10*time.Second, 50*time.Millisecond)
+
+	included := false
+
+	for _, minedTx := range chain.Current().Transactions {
+		if minedTx.Hash().String() == tx.Hash().String() {
+			included = true
+		}
+	}
+
+	assert.True(t, included)
+
+	res = sendAuthJSONRPCRequest(t, config.RPCPort, config.RPCAuthToken, "miner_start", 1)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+}
+
+// nolint : tparallel
+func TestImportBlockLoopFullInterrupt(t *testing.T) {
+	source := newTestBlockchain(t, newRPCTestConfig(t, ":1827", ":6179"))
+	defer source.Close()
+
+	config := newRPCTestConfig(t, ":1828", ":6180")
+	config.Mine = false
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, source.AddBlock([]byte(fmt.Sprintf("Block %d", i)), []*types.Transaction{}, make(chan bool), source.Config.SignerPrivateKey))
+	}
+
+	// Nothing mines on the chain to take the interrupts, the loop keeps importing anyway.
+	for len(chain.MineInterrupt) < cap(chain.MineInterrupt) {
+		chain.MineInterrupt <- true
+	}
+
+	go chain.ImportBlockLoop()
+
+	for i := int64(1); i <= 2; i++ {
+		block, err := source.BlockchainDb.GetBlockByNumber(big.NewInt(i))
+		assert.NoError(t, err)
+
+		chain.BlockCh <- block
+	}
+
+	assert.Eventually(t, func() bool { return chain.Current().Number.Uint64() == 2 }, 5*time.Second, 10*time.Millisecond)
+}
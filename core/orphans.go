@@ -0,0 +1,151 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// ErrUnknownParent is returned when importing a block whose parent isn't known.
+var ErrUnknownParent = errors.New("unknown parent block")
+
+// defaultOrphanPoolSize is the number of orphan blocks kept at a time, and defaultOrphanTTL
+// how long an orphan block waits for its parent.
+var (
+	defaultOrphanPoolSize = 32
+	defaultOrphanTTL      = 2 * time.Minute
+)
+
+type orphan struct {
+	block   *types.Block
+	expires time.Time
+}
+
+// orphanPool keeps the blocks received before their parent, so that they are imported
+// once the parent is. Orphans are dropped once they expire, and the one expiring first
+// makes room for a new orphan when the pool is full.
+type orphanPool struct {
+	mu     sync.Mutex
+	max    int
+	ttl    time.Duration
+	blocks map[string]*orphan // By hash
+}
+
+func newOrphanPool(max int, ttl time.Duration) *orphanPool {
+	return &orphanPool{max: max, ttl: ttl, blocks: make(map[string]*orphan)}
+}
+
+// add keeps the block until its parent is imported or it expires.
+func (op *orphanPool) add(block *types.Block) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	now := time.Now()
+	op.expire(now)
+
+	hash := block.DeriveHash().String()
+	if _, ok := op.blocks[hash]; ok {
+		return
+	}
+
+	if len(op.blocks) >= op.max {
+		var oldest string
+
+		for h, o := range op.blocks {
+			if oldest == "" || o.expires.Before(op.blocks[oldest].expires) {
+				oldest = h
+			}
+		}
+
+		delete(op.blocks, oldest)
+	}
+
+	op.blocks[hash] = &orphan{block: block, expires: now.Add(op.ttl)}
+}
+
+// children removes and returns the orphans which extend the block with the given hash.
+func (op *orphanPool) children(parent *util.Hash) []*types.Block {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	op.expire(time.Now())
+
+	var blocks []*types.Block
+
+	for hash, o := range op.blocks {
+		if o.block.ParentHash.String() == parent.String() {
+			blocks = append(blocks, o.block)
+			delete(op.blocks, hash)
+		}
+	}
+
+	return blocks
+}
+
+// len returns the number of orphans in the pool, expired or not.
+func (op *orphanPool) len() int {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	return len(op.blocks)
+}
+
+func (op *orphanPool) expire(now time.Time) {
+	for hash, o := range op.blocks {
+		if now.After(o.expires) {
+			delete(op.blocks, hash)
+		}
+	}
+}
+
+// importBlock adds a block received from a peer. Blocks imported or mined lately are
+// dropped with ErrKnownBlock right away. A block whose parent is unknown is kept in the
+// orphan pool, and the orphans waiting on a block are imported once it is.
+func (bc *Blockchain) importBlock(block *types.Block) error {
+	hash := block.DeriveHash()
+
+	if bc.known.has(hash) {
+		return ErrKnownBlock
+	}
+
+	err := bc.AddExternalBlock(block)
+	if errors.Is(err, ErrKnownBlock) {
+		bc.known.add(hash)
+		return err
+	}
+
+	if errors.Is(err, ErrUnknownParent) {
+		bc.orphans.add(block)
+		bc.Logger.Debug("Buffered orphan block", "number", block.Number, "hash", block.DeriveHash().String(), "parentHash", block.ParentHash.String())
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bc.known.add(hash)
+
+	queue := bc.orphans.children(hash)
+
+	for len(queue) > 0 {
+		orphan := queue[0]
+		queue = queue[1:]
+
+		if err := bc.AddExternalBlock(orphan); err != nil {
+			bc.Logger.Warn("Failed to import orphan block", "number", orphan.Number, "hash", orphan.DeriveHash().String(), "err", err)
+			continue
+		}
+
+		bc.known.add(orphan.DeriveHash())
+		bc.Logger.Info("Imported orphan block", "number", orphan.Number, "hash", orphan.DeriveHash().String())
+
+		queue = append(queue, bc.orphans.children(orphan.DeriveHash())...)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanPool(t *testing.T) {
+	t.Parallel()
+
+	parent := util.HashData([]byte("parent"))
+
+	newOrphan := func(number int64, parentHash *util.Hash) *types.Block {
+		return types.NewBlock(big.NewInt(number), parentHash, []byte{})
+	}
+
+	pool := newOrphanPool(2, time.Hour)
+
+	first := newOrphan(2, parent)
+	pool.add(first)
+	pool.add(first)
+	assert.Equal(t, 1, pool.len())
+
+	// The orphan expiring first makes room once the pool is full.
+	time.Sleep(time.Millisecond)
+	pool.add(newOrphan(3, first.DeriveHash()))
+	pool.add(newOrphan(4, util.HashData([]byte("other"))))
+	assert.Equal(t, 2, pool.len())
+	assert.Empty(t, pool.children(parent))
+
+	children := pool.children(first.DeriveHash())
+	assert.Len(t, children, 1)
+	assert.Equal(t, int64(3), children[0].Number.Int64())
+	assert.Equal(t, 1, pool.len())
+
+	// Expired orphans are dropped.
+	pool = newOrphanPool(2, time.Millisecond)
+	pool.add(first)
+	time.Sleep(5 * time.Millisecond)
+	assert.Empty(t, pool.children(parent))
+	assert.Equal(t, 0, pool.len())
+}
+
+// nolint : tparallel
+func TestImportOrphanBlock(t *testing.T) {
+	config := newRPCTestConfig(t, ":1782", ":6133")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	importer := newTestBlockchain(t, newRPCTestConfig(t, ":1783", ":6134"))
+	defer importer.Close()
+
+	go importer.ImportBlockLoop()
+
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, chain.AddBlock([]byte("Block"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	}
+
+	block1, err := chain.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block2 := chain.LastBlock
+
+	// Block 2 arrives first and waits for its parent.
+	importer.BlockCh <- block2
+
+	assert.Eventually(t, func() bool { return importer.orphans.len() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), importer.Current().Number.Uint64())
+
+	importer.BlockCh <- block1
+
+	assert.Eventually(t, func() bool {
+		return importer.Current().DeriveHash().String() == block2.DeriveHash().String()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, importer.orphans.len())
+
+	imported, err := importer.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, block1.DeriveHash(), imported.DeriveHash())
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/p2p"
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestRPCPeers(t *testing.T) {
+	peerConfig := newRPCTestConfig(t, ":1760", ":6110")
+	peer := newTestBlockchain(t, peerConfig)
+
+	defer peer.Close()
+
+	config := newRPCTestConfig(t, ":1759", ":6109")
+	config.Peers = []string{"localhost:6110"}
+	config.MaxPeerBackoff = 200 * time.Millisecond
+
+	chain := newTestBlockchain(t, config)
+
+	defer chain.Close()
+
+	peers := func(port string) []*rpc.Peer {
+		res := sendJSONRPCRequest(t, port, "chain_peers")
+		if res.Error != nil {
+			return nil
+		}
+
+		var out []*rpc.Peer
+		assert.NoError(t, json.Unmarshal(res.Result, &out))
+
+		return out
+	}
+
+	assert.NoError(t, peer.AddBlock([]byte("Block 1"), nil, make(chan bool), peerConfig.SignerPrivateKey))
+
+	// The node dialed the peer and sees its height.
+	assert.Eventually(t, func() bool {
+		out := peers(config.RPCPort)
+		return len(out) == 1 && out[0].Height == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, []*rpc.Peer{{Addr: "localhost:6110", Direction: rpc.PeerOutbound, ProtocolVersion: p2p.ProtocolVersion, Height: 1}}, peers(config.RPCPort))
+
+	// The peer lists the node as inbound.
+	out := peers(peerConfig.RPCPort)
+	assert.Len(t, out, 1)
+	assert.Equal(t, rpc.PeerInbound, out[0].Direction)
+	assert.Equal(t, p2p.ProtocolVersion, out[0].ProtocolVersion)
+	assert.NotEmpty(t, out[0].Addr)
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_peers", 1)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+
+	// The peer is forgotten once the node disconnects.
+	chain.P2PServer.Stop()
+
+	assert.Eventually(t, func() bool { return len(peers(peerConfig.RPCPort)) == 0 }, 5*time.Second, 50*time.Millisecond)
+}
+
+// nolint : tparallel
+func TestRPCSyncStatus(t *testing.T) {
+	config := newRPCTestConfig(t, ":1774", ":6125")
+	config.MaxPeerBackoff = 200 * time.Millisecond
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	go chain.ImportBlockLoop()
+
+	peerConfig := newRPCTestConfig(t, ":1775", ":6126")
+	peer := newTestBlockchain(t, peerConfig)
+
+	defer peer.Close()
+
+	syncStatus := func() *rpc.SyncStatus {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_syncStatus")
+		if res.Error != nil {
+			return nil
+		}
+
+		var out rpc.SyncStatus
+		assert.NoError(t, json.Unmarshal(res.Result, &out))
+
+		return &out
+	}
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, peer.AddBlock([]byte("Block"), nil, make(chan bool), peerConfig.SignerPrivateKey))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, &rpc.SyncStatus{Percentage: 100, Synced: true}, syncStatus())
+
+	// The peer dials the node and advertises its height in the handshake. The node doesn't
+	// download from inbound peers, so it stays behind.
+	peer.P2PServer.Downloader.SetPeers([]string{"localhost:6125"})
+
+	assert.Eventually(t, func() bool {
+		out := syncStatus()
+		return out != nil && out.HighestHeight == 4
+	}, 10*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, &rpc.SyncStatus{CurrentHeight: 0, HighestHeight: 4, Percentage: 0, Synced: false}, syncStatus())
+
+	res := sendJSONRPCRequest(t, config.RPCPort, "chain_syncStatus", 1)
+	assert.Equal(t, rpc.ErrCodeInvalidParams, res.Error.Code)
+
+	// Once the node dials the peer it catches up.
+	chain.P2PServer.Downloader.SetPeers([]string{"localhost:6126"})
+
+	assert.Eventually(t, func() bool {
+		out := syncStatus()
+		return out != nil && out.Synced
+	}, 20*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, &rpc.SyncStatus{CurrentHeight: 4, HighestHeight: 4, Percentage: 100, Synced: true}, syncStatus())
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/executer"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+var (
+	// ErrReadOnly is returned when writing to a blockchain opened read-only.
+	ErrReadOnly = errors.New("blockchain is read-only")
+
+	// ErrNoChain is returned when opening a blockchain DB without a chain read-only.
+	ErrNoChain = errors.New("no chain in the blockchain db")
+)
+
+// NewBlockchainReadOnly opens the chain of the given config for reading, for tools such
+// as explorers. The DBs are opened read-only, so they must exist and not be open in a
+// running node, and only the leveldb backend can be. No P2P or RPC server is started,
+// there is no txpool and mining is off. Adding blocks and reloading the config fail with
+// ErrReadOnly.
+func NewBlockchainReadOnly(c *config.Config) (*Blockchain, error) {
+	level, err := logger.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+
+	if c.DBBackend != "" && c.DBBackend != dbstore.BackendLevelDB {
+		return nil, fmt.Errorf("%w : the %s backend can't be opened read-only", ErrReadOnly, c.DBBackend)
+	}
+
+	dbInstance, err := dbstore.OpenReadOnlyDBInstance(c.DBDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stateDBInstance, err := dbstore.OpenReadOnlyDBInstance(c.StateDBDir)
+	if err != nil {
+		// nolint : errcheck
+		dbInstance.Close()
+
+		return nil, err
+	}
+
+	bc, err := newReadOnlyBlockchain(c, dbInstance, stateDBInstance)
+	if err != nil {
+		// nolint : errcheck
+		dbInstance.Close()
+		// nolint : errcheck
+		stateDBInstance.Close()
+
+		return nil, err
+	}
+
+	bc.Logger = logger.NewWithLevel(c.LogOutput, logLevel)
+	bc.logLevel = logLevel
+
+	return bc, nil
+}
+
+// newReadOnlyBlockchain returns the read-only blockchain of the opened DBs, from their
+// latest block.
+func newReadOnlyBlockchain(c *config.Config, dbInstance *dbstore.DB, stateDBInstance *dbstore.DB) (*Blockchain, error) {
+	blockchainDB := dbstore.NewBlockchainDB(dbInstance)
+
+	// Blocks are decoded whatever their compression, the setting only matters to writes.
+	if err := blockchainDB.SetCompression(c.DBCompression); err != nil {
+		return nil, err
+	}
+
+	stateDB := dbstore.NewStateDB(stateDBInstance)
+
+	lastHash, err := blockchainDB.DB.Get(dbstore.LastHashKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrNoChain, err)
+	}
+
+	lastBlock, err := blockchainDB.GetBlockByHash(util.ByteToHash(lastHash))
+	if err != nil {
+		return nil, err
+	}
+
+	txProcessor := executer.NewTxProcessor(stateDB.DB, c.MinFee, c.BlockReward, nil)
+
+	// Read-only blockchains don't mine, so there is no progress to log.
+	consensus, err := newConsensus(c, blockchainDB, txProcessor, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mineInterrupt := make(chan bool, defaultMineInterruptSize)
+
+	return &Blockchain{
+		LastBlock:     lastBlock,
+		Config:        c,
+		Consensus:     consensus,
+		Mutex:         new(sync.RWMutex),
+		BlockchainDb:  blockchainDB,
+		LastHash:      lastBlock.DeriveHash(),
+		StateDB:       stateDB,
+		TxProcessor:   txProcessor,
+		Miner:         newMiner(false, false, mineInterrupt),
+		BlockCh:       make(chan *types.Block),
+		MineInterrupt: mineInterrupt,
+		orphans:       newOrphanPool(defaultOrphanPoolSize, defaultOrphanTTL),
+		known:         newKnownBlocks(defaultKnownBlocksSize),
+		valid:         newValidBlocks(defaultValidBlocksSize),
+		readOnly:      true,
+		quit:          make(chan struct{}),
+		closeDone:     make(chan struct{}),
+	}, nil
+}
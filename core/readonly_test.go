@@ -0,0 +1,71 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestNewBlockchainReadOnly(t *testing.T) {
+	config := newRPCTestConfig(t, ":1801", ":6153")
+	config.Mine = false
+
+	chain := newTestBlockchain(t, config)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	to := util.BytesToAddress([]byte{0x01})
+
+	tx := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 100, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+
+	head := chain.LastBlock
+	chain.Close()
+
+	readOnly, err := NewBlockchainReadOnly(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer readOnly.Close()
+
+	// Blocks and balances read as they were written.
+	assert.Equal(t, head.DeriveHash(), readOnly.Current().DeriveHash())
+
+	block, err := readOnly.BlockchainDb.GetBlockByNumber(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, head.DeriveHash(), block.DeriveHash())
+	assert.Len(t, block.Transactions, 1)
+
+	assert.Equal(t, big.NewInt(1000), stateBalance(t, readOnly, to))
+	assert.Nil(t, readOnly.Txpool)
+	assert.False(t, readOnly.Miner.Mining())
+
+	// Nothing can be written.
+	assert.ErrorIs(t, readOnly.AddBlock([]byte("Block 2"), nil, make(chan bool), config.SignerPrivateKey), ErrReadOnly)
+	assert.ErrorIs(t, readOnly.AddExternalBlock(head), ErrReadOnly)
+	assert.ErrorIs(t, readOnly.Reload(config), ErrReadOnly)
+	assert.Error(t, readOnly.StateDB.DB.Put("key", []byte("value")))
+	assert.Equal(t, head.DeriveHash(), readOnly.Current().DeriveHash())
+}
+
+func TestNewBlockchainReadOnlyMissingDB(t *testing.T) {
+	t.Parallel()
+
+	config := newRPCTestConfig(t, "", "")
+
+	// An empty directory has no DB to open read-only.
+	_, err := NewBlockchainReadOnly(config)
+	assert.Error(t, err)
+
+	config.DBBackend = dbstore.BackendMemory
+
+	_, err = NewBlockchainReadOnly(config)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/rpc"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestRPCGetTransactionReceipt(t *testing.T) {
+	config := newRPCTestConfig(t, ":1765", ":6115")
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	fork := newTestBlockchain(t, newRPCTestConfig(t, ":1766", ":6116"))
+	defer fork.Close()
+
+	// Let the RPC server come up.
+	time.Sleep(100 * time.Millisecond)
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	getReceipt := func(hash string) *rpc.RPCReceipt {
+		res := sendJSONRPCRequest(t, config.RPCPort, "chain_getTransactionReceipt", hash)
+		assert.Nil(t, res.Error)
+
+		var receipt *rpc.RPCReceipt
+		if err := json.Unmarshal(res.Result, &receipt); err != nil {
+			t.Fatal(err)
+		}
+
+		return receipt
+	}
+
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 300, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.Txpool.AddTx(tx))
+
+	// Pending transactions have no receipt.
+	assert.Nil(t, getReceipt(tx.Hash().String()))
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), chain.Txpool.GetTxs(), make(chan bool), config.SignerPrivateKey))
+
+	assert.Equal(t, &rpc.RPCReceipt{
+		TransactionHash:  tx.Hash().String(),
+		BlockHash:        chain.LastBlock.DeriveHash().String(),
+		BlockNumber:      "0x1",
+		TransactionIndex: "0x0",
+		Status:           "0x1",
+		GasUsed:          "0x5208",
+		Fee:              "300",
+	}, getReceipt(tx.Hash().String()))
+
+	assert.Nil(t, getReceipt(util.HashData([]byte("unknown")).String()))
+
+	// A heavier branch without the transaction drops its receipt.
+	for _, data := range []string{"Block 1 B", "Block 2 B"} {
+		assert.NoError(t, fork.AddBlock([]byte(data), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+		assert.NoError(t, chain.AddExternalBlock(fork.LastBlock))
+	}
+
+	assert.Equal(t, fork.LastBlock.DeriveHash(), chain.LastBlock.DeriveHash())
+	assert.Nil(t, getReceipt(tx.Hash().String()))
+}
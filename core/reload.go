@@ -0,0 +1,106 @@
+package core
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/logger"
+)
+
+// Reload applies the peers, the peer bans, the log level and the mining switch of the given
+// config to the running node. New peers are dialed and removed ones disconnected. Changes
+// to the fields which can't change while the node runs, such as the genesis or the chain
+// id, are ignored with a warning.
+func (bc *Blockchain) Reload(c *config.Config) error {
+	if bc.readOnly {
+		return ErrReadOnly
+	}
+
+	level, err := logger.ParseLevel(c.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	if c.GenesisFile != "" {
+		genesis, err := LoadGenesis(c.GenesisFile)
+		if err != nil {
+			return err
+		}
+
+		c = genesis.Configure(c)
+	}
+
+	for _, field := range []struct {
+		name    string
+		current interface{}
+		updated interface{}
+	}{
+		{"GenesisFile", bc.Config.GenesisFile, c.GenesisFile},
+		{"NetworkID", bc.Config.NetworkID, c.NetworkID},
+		{"BalanceAlloc", allocHash(bc.Config.BalanceAlloc), allocHash(c.BalanceAlloc)},
+		{"ConsensusName", bc.Config.ConsensusName, c.ConsensusName},
+		{"DBDir", bc.Config.DBDir, c.DBDir},
+		{"StateDBDir", bc.Config.StateDBDir, c.StateDBDir},
+		{"DBCompression", bc.Config.DBCompression, c.DBCompression},
+		{"RPCPort", bc.Config.RPCPort, c.RPCPort},
+		{"P2PPort", bc.Config.P2PPort, c.P2PPort},
+	} {
+		if !reflect.DeepEqual(field.current, field.updated) {
+			bc.Logger.Warn("Ignoring change of config field, restart the node to apply it", "field", field.name)
+		}
+	}
+
+	bc.P2PServer.Downloader.Bans.Configure(c.PeerBanDuration, c.PeerDenylist)
+	bc.Config.PeerBanDuration = c.PeerBanDuration
+	bc.Config.PeerDenylist = c.PeerDenylist
+
+	bc.P2PServer.Downloader.SetPeers(c.Peers)
+	bc.Config.Peers = c.Peers
+
+	bc.logLevel.Set(level)
+	bc.Config.LogLevel = c.LogLevel
+
+	if !c.Mine {
+		bc.Miner.Stop()
+	} else if err := bc.Miner.Start(); err != nil {
+		bc.Logger.Warn("Not mining", "err", err)
+	}
+
+	bc.Config.Mine = c.Mine
+
+	bc.Logger.Info("Reloaded config", "peers", len(c.Peers), "logLevel", c.LogLevel, "mine", c.Mine)
+
+	return nil
+}
+
+// ReloadOnSignal reloads the node with the config returned by reload on every SIGHUP,
+// until the blockchain is closed. The signal is handled once ReloadOnSignal returns.
+func (bc *Blockchain) ReloadOnSignal(reload func() (*config.Config, error)) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigCh)
+
+		for {
+			select {
+			case <-sigCh:
+				bc.Logger.Info("Reloading config", "signal", syscall.SIGHUP.String())
+
+				c, err := reload()
+				if err == nil {
+					err = bc.Reload(c)
+				}
+
+				if err != nil {
+					bc.Logger.Error("Failed to reload config", "err", err)
+				}
+			case <-bc.quit:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// reorg switches the canonical chain to the branch ending at newHead. The state is rolled
+// back to the common ancestor of both branches and the new branch is replayed on top of
+// it. If a block of the new branch fails to validate, the old branch is restored and the
+// block is forgotten along with the rest of the branch. Branches forking below the final
+// blocks are refused, and so are branches dropping more than MaxReorgDepth blocks.
+// Transactions of the dropped blocks which are not part of the new branch are returned
+// to the txpool. The caller must hold the blockchain lock.
+func (bc *Blockchain) reorg(newHead *types.Block) error {
+	newBranch := []*types.Block{}
+	ancestor := newHead
+
+	// Walk the new branch back to the first block on the canonical chain.
+	for !bc.isCanonical(ancestor) {
+		newBranch = append([]*types.Block{ancestor}, newBranch...)
+
+		parent, err := bc.BlockchainDb.GetBlockByHash(ancestor.ParentHash)
+		if err != nil {
+			return fmt.Errorf("Missing ancestor of block %s : %w", ancestor.DeriveHash().String(), err)
+		}
+
+		ancestor = parent
+	}
+
+	// Final blocks are never rewritten, however heavy the new branch.
+	if finalized := finalizedNumber(bc.LastBlock.Number, finalityDepth(bc.Config)); finalized != nil && ancestor.Number.Cmp(finalized) < 0 {
+		bc.Logger.Warn("Refusing reorg below a finalized block", "number", newHead.Number, "hash", newHead.DeriveHash().String(), "ancestor", ancestor.Number, "finalized", finalized, "maxReorgDepth", bc.Config.MaxReorgDepth)
+		return finalityError(bc.Config)
+	}
+
+	// The old branch is ordered from the current head down to the ancestor.
+	oldBranch := []*types.Block{}
+
+	for block := bc.LastBlock; block.DeriveHash().String() != ancestor.DeriveHash().String(); {
+		oldBranch = append(oldBranch, block)
+
+		parent, err := bc.BlockchainDb.GetBlockByHash(block.ParentHash)
+		if err != nil {
+			return fmt.Errorf("Missing ancestor of block %s : %w", block.DeriveHash().String(), err)
+		}
+
+		block = parent
+	}
+
+	bc.Logger.Warn("Switching to heavier branch", "number", newHead.Number, "hash", newHead.DeriveHash().String(), "ancestor", ancestor.Number, "dropped", len(oldBranch), "added", len(newBranch))
+
+	for _, block := range oldBranch {
+		bc.TxProcessor.RollbackTxs(block.Transactions, block.Coinbase(), block.BaseFee)
+		bc.deleteStateHistory(block)
+	}
+
+	for i, block := range newBranch {
+		err := bc.applyBlock(block)
+		if err == nil {
+			bc.recordStateHistory(block)
+			continue
+		}
+
+		bc.Logger.Warn("Invalid block in new branch", "number", block.Number, "hash", block.DeriveHash().String(), "err", err)
+
+		for j := i - 1; j >= 0; j-- {
+			bc.TxProcessor.RollbackTxs(newBranch[j].Transactions, newBranch[j].Coinbase(), newBranch[j].BaseFee)
+			bc.deleteStateHistory(newBranch[j])
+		}
+
+		for j := len(oldBranch) - 1; j >= 0; j-- {
+			if !bc.validateBlock(oldBranch[j]) {
+				panic("Failed to restore the canonical chain after an invalid reorg")
+			}
+
+			bc.recordStateHistory(oldBranch[j])
+		}
+
+		bc.forgetBlocks(newBranch[i:])
+
+		return err
+	}
+
+	dbBatch := bc.BlockchainDb.DB.NewBatch()
+
+	// Batch write to db
+	for _, block := range oldBranch {
+		dbBatch.Delete([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())))
+		dbstore.DeleteTxLookups(dbBatch, block)
+		dbstore.DeleteReceipts(dbBatch, block)
+	}
+
+	for _, block := range newBranch {
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())), block.DeriveHash().Bytes())
+		dbstore.WriteTxLookups(dbBatch, block)
+		dbstore.WriteReceipts(dbBatch, block)
+	}
+
+	dbBatch.Put([]byte(dbstore.LastHashKey), newHead.DeriveHash().Bytes())
+
+	// Commit batch to db
+	err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+	if err != nil {
+		panic(err)
+	}
+
+	// The transactions of the head are notified by setHead.
+	for _, block := range newBranch[:len(newBranch)-1] {
+		bc.RPCServer.NotifyTxsIncluded(block)
+	}
+
+	bc.setHead(newHead)
+
+	included := make(map[string]bool)
+
+	for _, block := range newBranch {
+		for _, tx := range block.Transactions {
+			included[tx.Hash().String()] = true
+
+			// nolint : errcheck
+			bc.Txpool.RemoveTx(tx)
+		}
+	}
+
+	dropped := []*types.Transaction{}
+
+	for i := len(oldBranch) - 1; i >= 0; i-- {
+		for _, tx := range oldBranch[i].Transactions {
+			if !included[tx.Hash().String()] {
+				dropped = append(dropped, tx)
+			}
+		}
+	}
+
+	bc.Txpool.Reinject(dropped)
+
+	return nil
+}
+
+// isCanonical reports whether the block is part of the canonical chain.
+func (bc *Blockchain) isCanonical(block *types.Block) bool {
+	if block.Number.Cmp(bc.LastBlock.Number) > 0 {
+		return false
+	}
+
+	canonical, err := bc.BlockchainDb.GetBlockByNumber(block.Number)
+	if err != nil {
+		return false
+	}
+
+	return canonical.DeriveHash().String() == block.DeriveHash().String()
+}
+
+// forgetBlocks deletes the side blocks, an invalid block and its descendants, from the
+// blockchain DB so that no branch extends them anymore. They are remembered as known, so
+// that receiving them again doesn't validate them again. The caller must hold the
+// blockchain lock.
+func (bc *Blockchain) forgetBlocks(blocks []*types.Block) {
+	dbBatch := bc.BlockchainDb.DB.NewBatch()
+
+	for _, block := range blocks {
+		hash := block.DeriveHash()
+
+		dbBatch.Delete([]byte(dbstore.PrefixKey(dbstore.HashesKey, hash.String())))
+		dbBatch.Delete([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, hash.String())))
+
+		bc.valid.remove(block)
+		bc.known.add(hash)
+	}
+
+	// Commit batch to db
+	err := bc.BlockchainDb.DB.WriteBatch(dbBatch)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// snapshotAncestors is the number of blocks below the snapshot block a snapshot carries,
+// so that the difficulty retargeting and the fork searches of the importing node find
+// the blocks they look back at.
+var snapshotAncestors uint64 = 64
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	ErrChainNotEmpty   = errors.New("chain is not empty")
+)
+
+// Snapshot is the whole account state of the chain at a block, along with the genesis
+// block and the last blocks up to it.
+type Snapshot struct {
+	Number          uint64
+	BlockHash       *util.Hash // Hash of the block the state is at
+	TotalDifficulty *big.Int   // Total difficulty of the chain ending at the block
+	Blocks          []*types.Block
+	State           []SnapshotEntry // Sorted by key
+	StateHash       *util.Hash
+}
+
+// SnapshotEntry is a key of the state with its value.
+type SnapshotEntry struct {
+	Key   string
+	Value []byte
+}
+
+// hashSnapshotState returns the hash of the state entries, each key and value being
+// prefixed with its length.
+func hashSnapshotState(entries []SnapshotEntry) *util.Hash {
+	var buf bytes.Buffer
+
+	for _, entry := range entries {
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(entry.Key))))
+		buf.WriteString(entry.Key)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(entry.Value))))
+		buf.Write(entry.Value)
+	}
+
+	return util.HashData(buf.Bytes())
+}
+
+// ExportSnapshot writes the snapshot of the state after the canonical block number to w.
+// The state history must still be available at that block.
+func ExportSnapshot(blockchainDB *dbstore.BlockchainDB, stateDB *dbstore.StateDB, w io.Writer, number uint64) error {
+	latest, err := blockchainDB.GetLatestBlock()
+	if err != nil {
+		return fmt.Errorf("failed to read the latest block : %w", err)
+	}
+
+	if number > latest.Number.Uint64() {
+		return fmt.Errorf("%w : block %d, latest block is %d", ErrInvalidExportRange, number, latest.Number.Uint64())
+	}
+
+	snap := &Snapshot{Number: number}
+
+	first := uint64(1)
+	if number > snapshotAncestors {
+		first = number - snapshotAncestors
+	}
+
+	numbers := []uint64{0}
+	for n := first; n <= number; n++ {
+		numbers = append(numbers, n)
+	}
+
+	for _, n := range numbers {
+		block, err := blockchainDB.GetBlockByNumber(new(big.Int).SetUint64(n))
+		if err != nil {
+			return fmt.Errorf("failed to read block %d : %w", n, err)
+		}
+
+		snap.Blocks = append(snap.Blocks, block)
+	}
+
+	block := snap.Blocks[len(snap.Blocks)-1]
+	snap.BlockHash = block.DeriveHash()
+
+	snap.TotalDifficulty, err = blockchainDB.GetTotalDifficulty(snap.BlockHash)
+	if err != nil {
+		return fmt.Errorf("failed to read the total difficulty of block %d : %w", number, err)
+	}
+
+	state, err := stateDB.StateAt(number)
+	if err != nil {
+		return fmt.Errorf("failed to read the state at block %d : %w", number, err)
+	}
+
+	for key, value := range state {
+		snap.State = append(snap.State, SnapshotEntry{Key: key, Value: value})
+	}
+
+	sort.Slice(snap.State, func(i, j int) bool {
+		return snap.State[i].Key < snap.State[j].Key
+	})
+
+	snap.StateHash = hashSnapshotState(snap.State)
+
+	return gob.NewEncoder(w).Encode(snap)
+}
+
+// ReadSnapshot reads a snapshot written by ExportSnapshot, checking that its blocks link
+// up to the block hash it is at and that its state matches the state hash.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snap Snapshot
+
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidSnapshot, err)
+	}
+
+	if len(snap.Blocks) == 0 || snap.BlockHash == nil || snap.TotalDifficulty == nil || snap.StateHash == nil {
+		return nil, fmt.Errorf("%w : missing fields", ErrInvalidSnapshot)
+	}
+
+	for i, block := range snap.Blocks {
+		if block.Number == nil || block.ParentHash == nil || block.Nonce == nil {
+			return nil, fmt.Errorf("%w : missing header fields", ErrInvalidSnapshot)
+		}
+
+		if i == 0 {
+			if block.Number.Sign() != 0 {
+				return nil, fmt.Errorf("%w : missing genesis block", ErrInvalidSnapshot)
+			}
+
+			continue
+		}
+
+		parent := snap.Blocks[i-1]
+
+		// The ancestors don't go down to genesis on long chains.
+		if i == 1 && block.Number.Uint64() > 1 {
+			continue
+		}
+
+		if block.Number.Uint64() != parent.Number.Uint64()+1 || block.ParentHash.String() != parent.DeriveHash().String() {
+			return nil, fmt.Errorf("%w : block %d doesn't extend block %d", ErrInvalidSnapshot, block.Number, parent.Number)
+		}
+	}
+
+	block := snap.Blocks[len(snap.Blocks)-1]
+
+	if block.Number.Uint64() != snap.Number || block.DeriveHash().String() != snap.BlockHash.String() {
+		return nil, fmt.Errorf("%w : blocks end at %s, expected block %d with hash %s", ErrInvalidSnapshot, block.DeriveHash().String(), snap.Number, snap.BlockHash.String())
+	}
+
+	if hashSnapshotState(snap.State).String() != snap.StateHash.String() {
+		return nil, fmt.Errorf("%w : state doesn't match its hash", ErrInvalidSnapshot)
+	}
+
+	return &snap, nil
+}
+
+// ImportSnapshot writes the snapshot to empty databases, making the snapshot block the
+// head of the chain with the state of the snapshot. The state is trusted, it isn't
+// replayed from genesis. The state history starts at the snapshot block.
+func ImportSnapshot(blockchainDB *dbstore.BlockchainDB, stateDB *dbstore.StateDB, snap *Snapshot) error {
+	for _, has := range []func() (bool, error){
+		func() (bool, error) { return blockchainDB.DB.Has(dbstore.LastHashKey) },
+		func() (bool, error) { return stateDB.DB.Has(dbstore.StatePrunedKey) },
+	} {
+		found, err := has()
+		if err != nil {
+			return err
+		}
+
+		if found {
+			return ErrChainNotEmpty
+		}
+	}
+
+	stateBatch := stateDB.DB.NewBatch()
+
+	for _, entry := range snap.State {
+		stateBatch.Put([]byte(entry.Key), entry.Value)
+	}
+
+	if err := stateDB.DB.WriteBatch(stateBatch); err != nil {
+		return err
+	}
+
+	if err := stateDB.InitStateHistory(snap.Number); err != nil {
+		return err
+	}
+
+	dbBatch := blockchainDB.DB.NewBatch()
+
+	// The total difficulty of an ancestor is the one of the snapshot block minus the work
+	// of the blocks above it.
+	td := new(big.Int).Set(snap.TotalDifficulty)
+
+	for i := len(snap.Blocks) - 1; i >= 0; i-- {
+		block := snap.Blocks[i]
+		hash := block.DeriveHash()
+
+		if i == 0 {
+			td = blockWork(block)
+		}
+
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, hash.String())), blockchainDB.EncodeBlock(block))
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, block.Number.String())), hash.Bytes())
+		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.TotalDiffKey, hash.String())), td.Bytes())
+		dbstore.WriteTxLookups(dbBatch, block)
+		dbstore.WriteReceipts(dbBatch, block)
+
+		td = new(big.Int).Sub(td, blockWork(block))
+	}
+
+	dbBatch.Put([]byte(dbstore.LastHashKey), snap.BlockHash.Bytes())
+
+	return blockchainDB.DB.WriteBatch(dbBatch)
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestSnapshotRoundTrip(t *testing.T) {
+	source := newTestBlockchain(t, newRPCTestConfig(t, ":1757", ":6107"))
+	defer source.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	signer := util.NewUnlockedAccount(source.Config.SignerPrivateKey).Address()
+	accounts := []string{ua.Address().String(), "0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002", signer.String()}
+
+	balances := func(stateDB *dbstore.StateDB) map[string]string {
+		out := make(map[string]string)
+
+		for _, address := range accounts {
+			balance, err := stateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, address))
+			if err == nil {
+				out[address] = new(big.Int).SetBytes(balance).String()
+			}
+		}
+
+		return out
+	}
+
+	var expected map[string]string
+
+	for i, to := range []byte{0x01, 0x02, 0x01} {
+		tx := newTransaction(t, ua.Address().Bytes(), []byte{to}, "hello", 100, int64(1000*(i+1)), int64(i))
+		tx.Sign(ua)
+
+		assert.NoError(t, source.AddBlock([]byte(fmt.Sprintf("Block %d", i+1)), []*types.Transaction{tx}, make(chan bool), source.Config.SignerPrivateKey))
+		assert.Len(t, source.LastBlock.Transactions, 1)
+
+		if i == 1 {
+			expected = balances(source.StateDB)
+		}
+	}
+
+	block2, err := source.BlockchainDb.GetBlockByNumber(big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	assert.NoError(t, ExportSnapshot(source.BlockchainDb, source.StateDB, &buf, 2))
+
+	snap, err := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(2), snap.Number)
+	assert.Equal(t, block2.DeriveHash().String(), snap.BlockHash.String())
+
+	config := newRPCTestConfig(t, ":1758", ":6108")
+
+	blockchainDB, stateDB, closeDBs := openTestDBs(t, config.DBDir, config.StateDBDir)
+	assert.NoError(t, ImportSnapshot(blockchainDB, stateDB, snap))
+
+	// The databases already hold a chain.
+	assert.ErrorIs(t, ImportSnapshot(blockchainDB, stateDB, snap), ErrChainNotEmpty)
+	closeDBs()
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	assert.Equal(t, block2.DeriveHash().String(), chain.LastBlock.DeriveHash().String())
+	assert.Equal(t, expected, balances(chain.StateDB))
+	assert.Equal(t, source.TotalDifficulty(block2), chain.TotalDifficulty(chain.LastBlock))
+
+	// The imported chain goes on from the snapshot block.
+	tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "hello", 100, 3000, 2)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 3"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 1)
+
+	// Tampered snapshots are refused.
+	snap.State[0].Value = big.NewInt(1).Bytes()
+
+	var tampered bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&tampered).Encode(snap))
+
+	_, err = ReadSnapshot(&tampered)
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+}
+
+func openTestDBs(t *testing.T, dbDir string, stateDBDir string) (*dbstore.BlockchainDB, *dbstore.StateDB, func()) {
+	t.Helper()
+
+	db, err := dbstore.NewDBInstance(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateDB, err := dbstore.NewDBInstance(stateDBDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dbstore.NewBlockchainDB(db), dbstore.NewStateDB(stateDB), func() {
+		assert.NoError(t, db.Close())
+		assert.NoError(t, stateDB.Close())
+	}
+}
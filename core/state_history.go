@@ -0,0 +1,119 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// defaultStateRetentionBlocks is the default number of blocks below the head the state
+// history is kept for.
+var defaultStateRetentionBlocks uint64 = 128
+
+// stateKeys returns the state keys the block touches, those of its transactions and the
+// balance of its coinbase.
+func (bc *Blockchain) stateKeys(block *types.Block) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		add(dbstore.PrefixKey(dbstore.BalanceKey, tx.From.String()))
+		add(dbstore.PrefixKey(dbstore.BalanceKey, tx.To.String()))
+		add(dbstore.PrefixKey(dbstore.NonceKey, tx.From.String()))
+	}
+
+	if coinbase := block.Coinbase(); coinbase != nil {
+		add(dbstore.PrefixKey(dbstore.BalanceKey, coinbase.String()))
+	}
+
+	return keys
+}
+
+// recordStateHistory records the values of the state keys touched by the block. It must
+// be called right after the block got applied to the state.
+func (bc *Blockchain) recordStateHistory(block *types.Block) {
+	dbBatch := bc.StateDB.DB.NewBatch()
+
+	for _, key := range bc.stateKeys(block) {
+		value, err := bc.StateDB.DB.Get(key)
+		dbstore.WriteStateHistory(dbBatch, key, block.Number.Uint64(), value, err == nil)
+	}
+
+	// Commit batch to db
+	err := bc.StateDB.DB.WriteBatch(dbBatch)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// deleteStateHistory deletes the state history records of a block rolled back from the state.
+func (bc *Blockchain) deleteStateHistory(block *types.Block) {
+	dbBatch := bc.StateDB.DB.NewBatch()
+
+	for _, key := range bc.stateKeys(block) {
+		dbstore.DeleteStateHistory(dbBatch, key, block.Number.Uint64())
+	}
+
+	// Commit batch to db
+	err := bc.StateDB.DB.WriteBatch(dbBatch)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// stateRetention returns the number of blocks below the head the state history is kept
+// for, StateRetentionBlocks or else the default.
+func (bc *Blockchain) stateRetention() uint64 {
+	if bc.Config.StateRetentionBlocks > 0 {
+		return bc.Config.StateRetentionBlocks
+	}
+
+	return defaultStateRetentionBlocks
+}
+
+// pruneStateHistory drops the state history of the blocks more than the retention below
+// the head. The head state itself is never pruned. The caller must hold the blockchain
+// lock.
+func (bc *Blockchain) pruneStateHistory() {
+	retention := bc.stateRetention()
+	head := bc.LastBlock.Number.Uint64()
+
+	if head <= retention {
+		return
+	}
+
+	target := head - retention
+
+	pruned, err := bc.StateDB.PrunedBefore()
+	if err != nil {
+		bc.Logger.Error("Failed to read the pruned state height", "err", err)
+		return
+	}
+
+	if pruned >= target {
+		return
+	}
+
+	for number := pruned + 1; number <= target; number++ {
+		block, err := bc.BlockchainDb.GetBlockByNumber(new(big.Int).SetUint64(number))
+		if err != nil {
+			bc.Logger.Error("Failed to read block to prune", "number", number, "err", err)
+			return
+		}
+
+		if err := bc.StateDB.PruneStateHistory(number, bc.stateKeys(block)); err != nil {
+			bc.Logger.Error("Failed to prune state", "number", number, "err", err)
+			return
+		}
+	}
+
+	bc.Logger.Debug("Pruned state history", "before", target)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+	lutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func balanceAt(t *testing.T, chain *Blockchain, address *util.Address, number uint64) (*big.Int, error) {
+	t.Helper()
+
+	value, _, err := chain.StateDB.GetStateAt(dbstore.PrefixKey(dbstore.BalanceKey, address.String()), number)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(value), nil
+}
+
+func TestStateRetention(t *testing.T) {
+	t.Parallel()
+
+	// Configs left at zero prune with the default retention rather than growing for ever.
+	assert.Equal(t, defaultStateRetentionBlocks, (&Blockchain{Config: &config.Config{}}).stateRetention())
+	assert.Equal(t, uint64(2), (&Blockchain{Config: &config.Config{StateRetentionBlocks: 2}}).stateRetention())
+	assert.Equal(t, uint64(math.MaxUint64), (&Blockchain{Config: &config.Config{StateRetentionBlocks: math.MaxUint64}}).stateRetention())
+}
+
+// nolint : tparallel
+func TestStatePruning(t *testing.T) {
+	config := newRPCTestConfig(t, ":1739", ":6089")
+	config.StateRetentionBlocks = 2
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6") // Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(pkey)
+	to := util.BytesToAddress([]byte{0x01})
+
+	txs := [][]*types.Transaction{}
+
+	for i, value := range []int64{1000, 2000} {
+		tx := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "hello", 100, value, int64(i))
+		tx.Sign(ua)
+
+		txs = append(txs, []*types.Transaction{tx})
+	}
+
+	// Block 1 and 2 pay the receiver, block 3 doesn't touch its balance.
+	for i, blockTxs := range append(txs, []*types.Transaction{}) {
+		assert.NoError(t, chain.AddBlock([]byte(fmt.Sprintf("Block %d", i+1)), blockTxs, make(chan bool), config.SignerPrivateKey))
+	}
+
+	// Blocks 1 to 3 are within the retention window.
+	balance, err := balanceAt(t, chain, to, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), balance)
+
+	_, err = balanceAt(t, chain, ua.Address(), 0)
+	assert.ErrorIs(t, err, dbstore.ErrStatePruned)
+
+	// Mining past the retention depth prunes the history below block 2.
+	assert.NoError(t, chain.AddBlock([]byte("Block 4"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	_, err = balanceAt(t, chain, to, 1)
+	assert.ErrorIs(t, err, dbstore.ErrStatePruned)
+
+	// Only the record of block 2 is left for the receiver, as it holds its balance at
+	// blocks 2 and above.
+	prefix := dbstore.PrefixKey(dbstore.StateHistoryKey, dbstore.PrefixKey(dbstore.BalanceKey, to.String()))
+	iter := chain.StateDB.DB.LevelDb.NewIterator(lutil.BytesPrefix([]byte(prefix)), nil)
+
+	records := 0
+	for iter.Next() {
+		records++
+	}
+
+	iter.Release()
+	assert.Equal(t, 1, records)
+
+	// The states within the window and the head state still resolve.
+	for number := uint64(2); number <= 4; number++ {
+		balance, err = balanceAt(t, chain, to, number)
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(3000), balance)
+	}
+
+	assert.Equal(t, big.NewInt(3000), stateBalance(t, chain, to))
+	assert.Equal(t, big.NewInt(1000000000000000000-3000-200), stateBalance(t, chain, ua.Address()))
+}
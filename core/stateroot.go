@@ -0,0 +1,51 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// ErrInvalidStateRoot is returned when the state after applying a block doesn't match its root.
+var ErrInvalidStateRoot = errors.New("invalid block state root")
+
+// allowLegacyStateRoot reports whether the block with the given number may leave out its
+// state root. Blocks of chains started before the state commitment committed to another
+// root, so the roots of these blocks aren't checked either.
+func (bc *Blockchain) allowLegacyStateRoot(number *big.Int) bool {
+	return number.Cmp(new(big.Int).SetUint64(bc.Config.LegacyStateRootBlock)) <= 0
+}
+
+// applyBlock validates the block and applies its transactions to the state, then checks
+// the resulting state against the state root of the block, which blocks after
+// LegacyStateRootBlock must carry. A block whose state doesn't match is rolled back and
+// forgotten as valid. The caller must hold the blockchain lock.
+func (bc *Blockchain) applyBlock(block *types.Block) error {
+	if !bc.validateBlock(block) {
+		bc.Logger.Warn("Invalid block", "number", block.Number, "hash", block.DeriveHash().String())
+		return ErrInvalidBlockTxs
+	}
+
+	if bc.allowLegacyStateRoot(block.Number) {
+		return nil
+	}
+
+	if root := bc.TxProcessor.StateRoot(); !block.VerifyStateRoot(root) {
+		bc.TxProcessor.RollbackTxs(block.Transactions, block.Coinbase(), block.BaseFee)
+		bc.valid.remove(block)
+
+		if block.StateRoot == nil {
+			bc.Logger.Warn("Missing block state root", "number", block.Number, "hash", block.DeriveHash().String(), "expected", root.String())
+
+			return fmt.Errorf("%w : missing, state %s", ErrInvalidStateRoot, root.String())
+		}
+
+		bc.Logger.Warn("Invalid block state root", "number", block.Number, "hash", block.DeriveHash().String(), "stateRoot", block.StateRoot.String(), "expected", root.String())
+
+		return fmt.Errorf("%w : header %s, state %s", ErrInvalidStateRoot, block.StateRoot.String(), root.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// defaultMaxClockDrift is the maximum drift used when MaxClockDrift isn't configured.
+const defaultMaxClockDrift = 15 * time.Second
+
+var (
+	ErrOldTimestamp    = errors.New("block timestamp not after parent timestamp")
+	ErrFutureTimestamp = errors.New("block timestamp too far in the future")
+)
+
+// nextTimestamp returns the timestamp of a block mined now on top of the given parent :
+// the current time, or one second after the parent if the parent isn't older.
+func nextTimestamp(parent *types.Block) uint64 {
+	now := uint64(time.Now().Unix())
+	if now <= parent.Timestamp {
+		return parent.Timestamp + 1
+	}
+
+	return now
+}
+
+// waitTimestamp waits until the timestamp is no more than MaxClockDrift ahead of the local
+// clock, so that blocks mined faster than one per second hold back instead of moving the
+// chain time ever further ahead of the clock. It returns early with an error if mining is
+// interrupted or the blockchain closed.
+func (bc *Blockchain) waitTimestamp(timestamp uint64, mineInterrupt chan bool) error {
+	delay := time.Until(time.Unix(int64(timestamp), 0).Add(-bc.maxClockDrift()))
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-bc.quit:
+		return ErrBlockchainClosed
+	case <-mineInterrupt:
+		return errors.New("Mining interrupted")
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// maxClockDrift returns the configured MaxClockDrift, or the default one.
+func (bc *Blockchain) maxClockDrift() time.Duration {
+	if bc.Config.MaxClockDrift > 0 {
+		return bc.Config.MaxClockDrift
+	}
+
+	return defaultMaxClockDrift
+}
+
+// maxTimestamp returns the latest timestamp a block may have, MaxClockDrift ahead of the
+// local clock.
+func (bc *Blockchain) maxTimestamp() uint64 {
+	return uint64(time.Now().Add(bc.maxClockDrift()).Unix())
+}
+
+// verifyTimestamp checks that the block is more recent than its parent and not more
+// than MaxClockDrift ahead of the local clock.
+func (bc *Blockchain) verifyTimestamp(block *types.Block, parent *types.Block) error {
+	if block.Timestamp <= parent.Timestamp {
+		return ErrOldTimestamp
+	}
+
+	if block.Timestamp > bc.maxTimestamp() {
+		return ErrFutureTimestamp
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyTimestamp(t *testing.T) {
+	t.Parallel()
+
+	bc := &Blockchain{Config: &config.Config{MaxClockDrift: 10 * time.Second}}
+
+	now := uint64(time.Now().Unix())
+
+	parent := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{})
+	parent.Timestamp = now - 100
+
+	block := types.NewBlock(big.NewInt(2), parent.DeriveHash(), []byte{})
+
+	block.Timestamp = now
+	assert.NoError(t, bc.verifyTimestamp(block, parent))
+
+	block.Timestamp = parent.Timestamp
+	assert.ErrorIs(t, bc.verifyTimestamp(block, parent), ErrOldTimestamp)
+
+	block.Timestamp = parent.Timestamp - 1
+	assert.ErrorIs(t, bc.verifyTimestamp(block, parent), ErrOldTimestamp)
+
+	block.Timestamp = now + 5
+	assert.NoError(t, bc.verifyTimestamp(block, parent))
+
+	block.Timestamp = now + 60
+	assert.ErrorIs(t, bc.verifyTimestamp(block, parent), ErrFutureTimestamp)
+
+	// Without a configured drift, the default one applies.
+	bc.Config.MaxClockDrift = 0
+
+	block.Timestamp = now + 5
+	assert.NoError(t, bc.verifyTimestamp(block, parent))
+
+	block.Timestamp = now + 3600
+	assert.ErrorIs(t, bc.verifyTimestamp(block, parent), ErrFutureTimestamp)
+}
+
+func TestNextTimestamp(t *testing.T) {
+	t.Parallel()
+
+	now := uint64(time.Now().Unix())
+
+	parent := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{})
+	parent.Timestamp = now - 100
+	assert.GreaterOrEqual(t, nextTimestamp(parent), now)
+
+	// A parent from the same second, or ahead of the clock, is followed by the next second.
+	parent.Timestamp = now + 5
+	assert.Equal(t, now+6, nextTimestamp(parent))
+}
+
+// nolint : tparallel
+func TestRejectBlockTimestamps(t *testing.T) {
+	config := newRPCTestConfig(t, ":1742", ":6092")
+	config.MaxClockDrift = 10 * time.Second
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+
+	parent := chain.LastBlock
+	assert.Greater(t, parent.Timestamp, uint64(0))
+
+	newBlock := func(timestamp uint64) *types.Block {
+		block := types.NewBlock(big.NewInt(2), parent.DeriveHash(), []byte("Block 2"))
+		block.Timestamp = timestamp
+		block.Difficulty = chain.CalcNextDifficulty(parent)
+		block.BaseFee = chain.CalcBaseFee(parent)
+
+		block = chain.Consensus.Mine(block, make(chan bool))
+		block.Sign(util.NewUnlockedAccount(config.SignerPrivateKey))
+
+		return block
+	}
+
+	// A block dated before its parent.
+	assert.ErrorIs(t, chain.AddExternalBlock(newBlock(parent.Timestamp-1)), ErrOldTimestamp)
+
+	// A block dated far in the future.
+	future := uint64(time.Now().Add(time.Hour).Unix())
+	assert.ErrorIs(t, chain.AddExternalBlock(newBlock(future)), ErrFutureTimestamp)
+
+	assert.Equal(t, parent.DeriveHash(), chain.LastBlock.DeriveHash())
+
+	// Blocks mined in the same second as their parent still get a later timestamp.
+	assert.NoError(t, chain.AddBlock([]byte("Block 2"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	assert.Greater(t, chain.LastBlock.Timestamp, parent.Timestamp)
+}
+
+func TestWaitTimestamp(t *testing.T) {
+	t.Parallel()
+
+	bc := &Blockchain{Config: &config.Config{MaxClockDrift: time.Second}, quit: make(chan struct{})}
+
+	now := uint64(time.Now().Unix())
+	assert.NoError(t, bc.waitTimestamp(now+1, make(chan bool)))
+
+	// A timestamp beyond the drift waits for the clock to catch up.
+	assert.NoError(t, bc.waitTimestamp(now+2, make(chan bool)))
+	assert.LessOrEqual(t, now+2, bc.maxTimestamp())
+
+	// Unless mining is interrupted, or the blockchain closed.
+	interrupt := make(chan bool, 1)
+	interrupt <- true
+	assert.Error(t, bc.waitTimestamp(now+3600, interrupt))
+
+	close(bc.quit)
+	assert.ErrorIs(t, bc.waitTimestamp(now+3600, make(chan bool)), ErrBlockchainClosed)
+}
+
+// nolint : tparallel
+func TestMiningKeepsPaceWithClock(t *testing.T) {
+	config := newRPCTestConfig(t, ":1821", ":6173")
+	config.MaxClockDrift = time.Second
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	// Blocks mined faster than one per second don't get ahead of the clock by more than the drift.
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, chain.AddBlock([]byte("Block"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+		assert.LessOrEqual(t, chain.LastBlock.Timestamp, uint64(time.Now().Add(config.MaxClockDrift).Unix()))
+	}
+
+	assert.Equal(t, uint64(5), chain.LastBlock.Number.Uint64())
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// ErrInvalidTxOrder is returned when the transactions of a sender aren't in nonce order.
+var ErrInvalidTxOrder = errors.New("block transactions out of nonce order")
+
+// verifyTxOrder checks that the transactions of each sender have consecutive nonces in
+// the block, the only part of the order which changes the state. Blocks of other miners
+// don't need to follow the canonical order otherwise. The first nonce of each sender is
+// checked against the state once the block is executed.
+func verifyTxOrder(block *types.Block) error {
+	next := make(map[util.Address]*big.Int)
+
+	for _, tx := range block.Transactions {
+		if expected, ok := next[tx.From]; ok && tx.Nonce.Cmp(expected) != 0 {
+			return ErrInvalidTxOrder
+		}
+
+		next[tx.From] = new(big.Int).Add(tx.Nonce, big.NewInt(1))
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/logger"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyTxOrder(t *testing.T) {
+	t.Parallel()
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{})
+	block.Transactions = []*types.Transaction{
+		newTransaction(t, []byte{0x01}, []byte{0x02}, "", 100, 1, 4),
+		newTransaction(t, []byte{0x02}, []byte{0x01}, "", 500, 1, 0),
+		newTransaction(t, []byte{0x01}, []byte{0x02}, "", 900, 1, 5),
+	}
+
+	// Senders may interleave, whatever their fees.
+	assert.NoError(t, verifyTxOrder(block))
+
+	block.Transactions[0], block.Transactions[2] = block.Transactions[2], block.Transactions[0]
+	assert.ErrorIs(t, verifyTxOrder(block), ErrInvalidTxOrder)
+
+	// Nonce gaps are refused as well.
+	block.Transactions[0], block.Transactions[2] = block.Transactions[2], block.Transactions[0]
+	block.Transactions[2].Nonce = big.NewInt(6)
+	assert.ErrorIs(t, verifyTxOrder(block), ErrInvalidTxOrder)
+}
+
+// nolint : tparallel
+func TestImportReplayedTx(t *testing.T) {
+	config := newRPCTestConfig(t, ":1831", ":6183")
+	config.Mine = false
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	to := util.BytesToAddress([]byte{0x01})
+
+	tx := newTransaction(t, ua.Address().Bytes(), to.Bytes(), "", 100000, 1000, 0)
+	tx.Sign(ua)
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{tx}, make(chan bool), config.SignerPrivateKey))
+	assert.Len(t, chain.LastBlock.Transactions, 1)
+
+	balance := func() *big.Int {
+		value, err := chain.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, to.String()))
+		assert.NoError(t, err)
+
+		return new(big.Int).SetBytes(value)
+	}
+
+	assert.Equal(t, big.NewInt(1000), balance())
+
+	// The next block includes the mined transaction again, in nonce order within the block.
+	parent := chain.LastBlock
+	block := types.NewBlock(big.NewInt(2), parent.DeriveHash(), []byte("Block 2"))
+	block.Version = chain.blockVersion(block.Number)
+	block.Timestamp = nextTimestamp(parent)
+	block.Difficulty = chain.CalcNextDifficulty(parent)
+	block.BaseFee = chain.CalcBaseFee(parent)
+	block.Transactions = []*types.Transaction{tx}
+	block.StateRoot = parent.StateRoot
+	block.SetTxRoot()
+
+	signer := util.NewUnlockedAccount(config.SignerPrivateKey)
+
+	for nonce := int64(0); ; nonce++ {
+		block.SetNonce(big.NewInt(nonce))
+		block.Sign(signer)
+
+		if chain.Consensus.VerifySeal(block) {
+			break
+		}
+	}
+
+	assert.NoError(t, verifyTxOrder(block))
+	assert.ErrorIs(t, chain.AddExternalBlock(block), ErrInvalidBlockTxs)
+	assert.Equal(t, parent.DeriveHash(), chain.Current().DeriveHash())
+	assert.Equal(t, big.NewInt(1000), balance())
+}
+
+func TestPackTxsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	log, err := logger.New(io.Discard, "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc := &Blockchain{Config: &config.Config{BlockGasLimit: 4 * types.TxGas}, Logger: log}
+
+	txs := []*types.Transaction{}
+
+	for i, key := range []string{"c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6", "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"} {
+		ua := util.NewUnlockedAccount(util.HexToPrivateKey(key))
+
+		for nonce := int64(0); nonce < 3; nonce++ {
+			tx := newTransaction(t, ua.Address().Bytes(), []byte{0x01}, "", 100+int64(i)*50, 1, nonce)
+			tx.Sign(ua)
+
+			txs = append(txs, tx)
+		}
+	}
+
+	newBlock := func(txs []*types.Transaction) *types.Block {
+		block := types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte("Block 1"))
+		block.Timestamp = 1700000000
+		block.BaseFee = big.NewInt(100)
+		block.Transactions = bc.packTxs(txs, block)
+		block.SetTxRoot()
+
+		return block
+	}
+
+	reversed := make([]*types.Transaction, len(txs))
+	for i, tx := range txs {
+		reversed[len(txs)-1-i] = tx
+	}
+
+	first, second := newBlock(txs), newBlock(reversed)
+
+	assert.Len(t, first.Transactions, 4)
+	assert.NoError(t, verifyTxOrder(first))
+	assert.Equal(t, first.DeriveHash().String(), second.DeriveHash().String())
+	assert.Equal(t, first.TxRoot.String(), second.TxRoot.String())
+}
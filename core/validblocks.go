@@ -0,0 +1,76 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/golang/groupcache/lru"
+)
+
+// defaultValidBlocksSize is the number of blocks whose seal the chain remembers as
+// verified.
+var defaultValidBlocksSize = 1024
+
+// validBlocks remembers the blocks whose seal was verified, by signed hash, so that the
+// blocks applied again on a reorg, or restored after a failed one, don't have their
+// proof of work and signature verified again. A block is forgotten once it turns out
+// invalid in the context of its branch.
+type validBlocks struct {
+	mu     sync.Mutex
+	hashes *lru.Cache
+}
+
+func newValidBlocks(size int) *validBlocks {
+	return &validBlocks{hashes: lru.New(size)}
+}
+
+func (vb *validBlocks) add(block *types.Block) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vb.hashes.Add(block.SignedHash().String(), struct{}{})
+}
+
+func (vb *validBlocks) has(block *types.Block) bool {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	_, ok := vb.hashes.Get(block.SignedHash().String())
+
+	return ok
+}
+
+func (vb *validBlocks) remove(block *types.Block) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vb.hashes.Remove(block.SignedHash().String())
+}
+
+// verifySeal verifies the seal of the block, unless it was verified before, and
+// remembers the blocks whose seal is valid.
+func (bc *Blockchain) verifySeal(block *types.Block) bool {
+	if bc.valid.has(block) {
+		return true
+	}
+
+	if !bc.Consensus.VerifySeal(block) {
+		return false
+	}
+
+	bc.valid.add(block)
+
+	return true
+}
+
+// validateBlock verifies the seal of the block and applies its transactions to the state.
+// A block which fails to apply is forgotten as valid.
+func (bc *Blockchain) validateBlock(block *types.Block) bool {
+	if bc.verifySeal(block) && bc.TxProcessor.ProcessImportTxs(block.Transactions, block.Coinbase(), block.BaseFee) {
+		return true
+	}
+
+	bc.valid.remove(block)
+
+	return false
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/consensus/pow"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nolint : tparallel
+func TestValidBlocks(t *testing.T) {
+	config := newRPCTestConfig(t, ":1789", ":6140")
+	config.BlockReward = big.NewInt(1000)
+
+	chain := newTestBlockchain(t, config)
+	defer chain.Close()
+
+	assert.NoError(t, chain.AddBlock([]byte("Block 1"), []*types.Transaction{}, make(chan bool), config.SignerPrivateKey))
+	block := chain.LastBlock
+
+	// The seal of a mined block is remembered.
+	assert.True(t, chain.valid.has(block))
+
+	// A copy carrying another signature isn't.
+	forged := types.DeserializeBlock(block.Serialize())
+	forged.R = new(big.Int).Add(forged.R, big.NewInt(1))
+
+	assert.False(t, chain.valid.has(forged))
+	assert.False(t, chain.verifySeal(forged))
+	assert.False(t, chain.valid.has(forged))
+
+	// Applied on top of itself the block has a valid seal but credits its reward twice,
+	// which doesn't match its state root. It is forgotten.
+	chain.Mutex.Lock()
+	root := chain.TxProcessor.StateRoot()
+
+	assert.ErrorIs(t, chain.applyBlock(block), ErrInvalidStateRoot)
+	assert.Equal(t, root, chain.TxProcessor.StateRoot())
+	chain.Mutex.Unlock()
+
+	assert.False(t, chain.valid.has(block))
+
+	// Its seal is verified again, and remembered, the next time.
+	assert.True(t, chain.verifySeal(block))
+	assert.True(t, chain.valid.has(block))
+}
+
+// newSealedBlock returns a block meeting its proof of work target, signed by the key
+// of the RPC test config.
+func newSealedBlock(tb testing.TB, consensus *pow.POW) *types.Block {
+	tb.Helper()
+
+	block := types.NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte("Block 1"))
+	block.Difficulty = 8
+
+	for nonce := int64(0); ; nonce++ {
+		block.SetNonce(big.NewInt(nonce))
+		block.Sign(util.NewUnlockedAccount(util.HexToPrivateKey("e3ddd0f483e2ef1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")))
+
+		if consensus.VerifySeal(block) {
+			return block
+		}
+	}
+}
+
+func BenchmarkVerifySeal(b *testing.B) {
+	consensus := pow.NewPOW(8, nil)
+	block := newSealedBlock(b, consensus)
+
+	bc := &Blockchain{Consensus: consensus, valid: newValidBlocks(defaultValidBlocksSize)}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		bc.valid.remove(block)
+
+		if !bc.verifySeal(block) {
+			b.Fatal("invalid seal")
+		}
+	}
+}
+
+func BenchmarkVerifySealCached(b *testing.B) {
+	consensus := pow.NewPOW(8, nil)
+	block := newSealedBlock(b, consensus)
+
+	bc := &Blockchain{Consensus: consensus, valid: newValidBlocks(defaultValidBlocksSize)}
+	bc.verifySeal(block)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if !bc.verifySeal(block) {
+			b.Fatal("invalid seal")
+		}
+	}
+}
@@ -1,14 +1,18 @@
 package dbstore
 
 import (
+	"errors"
 	"math/big"
 
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 type BlockchainDB struct {
 	DB *DB
+
+	compression string // Compression of the blocks written, see SetCompression
 }
 
 func NewBlockchainDB(db *DB) *BlockchainDB {
@@ -21,9 +25,14 @@ func (bdb *BlockchainDB) GetBlockByHash(hash *util.Hash) (*types.Block, error) {
 		return nil, err
 	}
 
-	block := types.DeserializeBlock(blockBytes)
+	return DecodeBlock(blockBytes)
+}
 
-	return block, nil
+// HasBlock reports whether the block of the hash is stored, on the canonical chain or not.
+func (bdb *BlockchainDB) HasBlock(hash *util.Hash) bool {
+	has, err := bdb.DB.Has(PrefixKey(HashesKey, hash.String()))
+
+	return err == nil && has
 }
 
 func (bdb *BlockchainDB) GetBlockByNumber(number *big.Int) (*types.Block, error) {
@@ -42,6 +51,16 @@ func (bdb *BlockchainDB) GetBlockByNumber(number *big.Int) (*types.Block, error)
 	return block, nil
 }
 
+// GetTotalDifficulty returns the cumulative difficulty of the chain ending at the block with the given hash.
+func (bdb *BlockchainDB) GetTotalDifficulty(hash *util.Hash) (*big.Int, error) {
+	td, err := bdb.DB.Get(PrefixKey(TotalDiffKey, hash.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(td), nil
+}
+
 func (bdb *BlockchainDB) GetLatestBlock() (*types.Block, error) {
 	lastBlockHashBytes, err := bdb.DB.Get(LastHashKey)
 	if err != nil {
@@ -77,3 +96,78 @@ func (bdb *BlockchainDB) GetBlocksInRange(start uint, end uint) ([]*types.Block,
 
 	return blocks, nil
 }
+
+// TxLookup locates a transaction in the canonical chain.
+type TxLookup struct {
+	BlockNumber *big.Int
+	Index       uint64
+}
+
+// GetTxLookup returns the location of the transaction with the given hash.
+func (bdb *BlockchainDB) GetTxLookup(hash *util.Hash) (*TxLookup, error) {
+	lookupBytes, err := bdb.DB.Get(PrefixKey(TxLookupKey, hash.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	return util.DecodeFromBytes[TxLookup](lookupBytes)
+}
+
+// GetTransactionByHash returns the transaction with the given hash along with the block including it.
+func (bdb *BlockchainDB) GetTransactionByHash(hash *util.Hash) (*types.Transaction, *types.Block, *TxLookup, error) {
+	lookup, err := bdb.GetTxLookup(hash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	block, err := bdb.GetBlockByNumber(lookup.BlockNumber)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if lookup.Index >= uint64(len(block.Transactions)) || block.Transactions[lookup.Index].Hash().String() != hash.String() {
+		return nil, nil, nil, errors.New("transaction index is out of date")
+	}
+
+	return block.Transactions[lookup.Index], block, lookup, nil
+}
+
+// WriteTxLookups adds the transactions of the block to the tx index.
+func WriteTxLookups(batch *leveldb.Batch, block *types.Block) {
+	for i, tx := range block.Transactions {
+		lookup := &TxLookup{BlockNumber: block.Number, Index: uint64(i)}
+		batch.Put([]byte(PrefixKey(TxLookupKey, tx.Hash().String())), util.EncodeToBytes(lookup))
+	}
+}
+
+// DeleteTxLookups removes the transactions of the block from the tx index.
+func DeleteTxLookups(batch *leveldb.Batch, block *types.Block) {
+	for _, tx := range block.Transactions {
+		batch.Delete([]byte(PrefixKey(TxLookupKey, tx.Hash().String())))
+	}
+}
+
+// GetReceipt returns the receipt of the transaction with the given hash, if it is part
+// of the canonical chain.
+func (bdb *BlockchainDB) GetReceipt(hash *util.Hash) (*types.Receipt, error) {
+	receiptBytes, err := bdb.DB.Get(PrefixKey(ReceiptKey, hash.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	return util.DecodeFromBytes[types.Receipt](receiptBytes)
+}
+
+// WriteReceipts stores the receipts of the transactions of the block.
+func WriteReceipts(batch *leveldb.Batch, block *types.Block) {
+	for _, receipt := range block.Receipts() {
+		batch.Put([]byte(PrefixKey(ReceiptKey, receipt.TxHash.String())), util.EncodeToBytes(receipt))
+	}
+}
+
+// DeleteReceipts removes the receipts of the transactions of the block.
+func DeleteReceipts(batch *leveldb.Batch, block *types.Block) {
+	for _, tx := range block.Transactions {
+		batch.Delete([]byte(PrefixKey(ReceiptKey, tx.Hash().String())))
+	}
+}
@@ -0,0 +1,167 @@
+package dbstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/syndtr/goleveldb/leveldb"
+	lutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The state commitment is a homomorphic multiset hash of the balances and nonces of the
+// state (LtHash) : every key and value is expanded into stateCommitmentLanes lanes of 16
+// bits, which are summed lane by lane modulo 2^16 over the whole state. Writing a key
+// subtracts the lanes of its previous value and adds the ones of its new value, so that
+// the commitment follows the state at the cost of the keys written rather than of the
+// whole state. Zero balances and nonces are left out, as rolled back transactions leave
+// them behind for accounts which had none.
+const stateCommitmentLanes = 1024
+
+type stateCommitment [stateCommitmentLanes]uint16
+
+// isStateKey reports whether the key is a balance or a nonce, committed to by the state
+// commitment.
+func isStateKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(BalanceKey)) || bytes.HasPrefix(key, []byte(NonceKey))
+}
+
+// update adds the lanes of the key and value to the commitment, or subtracts them if
+// remove is set. Empty values aren't committed to.
+func (c *stateCommitment) update(key, value []byte, remove bool) {
+	if len(value) == 0 {
+		return
+	}
+
+	preimage := binary.BigEndian.AppendUint32(nil, uint32(len(key)))
+	preimage = append(preimage, key...)
+	preimage = binary.BigEndian.AppendUint32(preimage, uint32(len(value)))
+	preimage = append(preimage, value...)
+
+	// Each block of the expansion, hashed with its counter, fills sha256.Size/2 lanes.
+	for block := 0; block < stateCommitmentLanes*2/sha256.Size; block++ {
+		sum := sha256.Sum256(append(binary.BigEndian.AppendUint16(nil, uint16(block)), preimage...))
+
+		for i := 0; i < sha256.Size/2; i++ {
+			lane := binary.BigEndian.Uint16(sum[2*i:])
+			if remove {
+				c[block*sha256.Size/2+i] -= lane
+			} else {
+				c[block*sha256.Size/2+i] += lane
+			}
+		}
+	}
+}
+
+func (c *stateCommitment) bytes() []byte {
+	b := make([]byte, 0, 2*stateCommitmentLanes)
+	for _, lane := range c {
+		b = binary.BigEndian.AppendUint16(b, lane)
+	}
+
+	return b
+}
+
+// root returns the hash of the commitment, the root the block headers commit to.
+func (c *stateCommitment) root() *util.Hash {
+	return util.HashData(c.bytes())
+}
+
+// loadStateCommitment reads the commitment of the state. The state of a database written
+// before the commitment is committed to from a scan of all its balances and nonces.
+func (db *DB) loadStateCommitment() (*stateCommitment, error) {
+	c := &stateCommitment{}
+
+	value, err := db.Get(StateCommitmentKey)
+	if err == nil {
+		if len(value) != 2*stateCommitmentLanes {
+			return nil, errors.New("invalid state commitment")
+		}
+
+		for i := range c {
+			c[i] = binary.BigEndian.Uint16(value[2*i:])
+		}
+
+		return c, nil
+	}
+
+	if !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, err
+	}
+
+	for _, prefix := range []string{BalanceKey, NonceKey} {
+		iter := db.LevelDb.NewIterator(lutil.BytesPrefix([]byte(prefix)), nil)
+
+		for iter.Next() {
+			c.update(iter.Key(), iter.Value(), false)
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// stateWrites records the writes of state keys replayed from a batch.
+type stateWrites struct {
+	keys   [][]byte
+	values [][]byte
+}
+
+func (w *stateWrites) Put(key, value []byte) {
+	if isStateKey(key) {
+		w.keys = append(w.keys, append([]byte{}, key...))
+		w.values = append(w.values, append([]byte{}, value...))
+	}
+}
+
+func (w *stateWrites) Delete(key []byte) {
+	w.Put(key, nil)
+}
+
+// writeBatch writes the batch, updating the state commitment along in the same batch if
+// it writes state keys.
+func (db *DB) writeBatch(batch *leveldb.Batch) error {
+	writes := &stateWrites{}
+	if err := batch.Replay(writes); err != nil {
+		return err
+	}
+
+	if len(writes.keys) == 0 {
+		return db.LevelDb.Write(batch, nil)
+	}
+
+	db.commitmentMu.Lock()
+	defer db.commitmentMu.Unlock()
+
+	c, err := db.loadStateCommitment()
+	if err != nil {
+		return err
+	}
+
+	// Later writes of a key in the batch replace the earlier ones.
+	written := make(map[string][]byte)
+
+	for i, key := range writes.keys {
+		previous, ok := written[string(key)]
+		if !ok {
+			if previous, err = db.LevelDb.Get(key, nil); err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+				return err
+			}
+		}
+
+		c.update(key, previous, true)
+		c.update(key, writes.values[i], false)
+		written[string(key)] = writes.values[i]
+	}
+
+	batch.Put([]byte(StateCommitmentKey), c.bytes())
+
+	return db.LevelDb.Write(batch, nil)
+}
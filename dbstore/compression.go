@@ -0,0 +1,68 @@
+package dbstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/golang/snappy"
+)
+
+// Compressions of the block values of the blockchain DB.
+const (
+	CompressionNone   = "none"   // Blocks stored as serialized
+	CompressionSnappy = "snappy" // Serialized blocks compressed with snappy
+)
+
+var (
+	// ErrUnknownCompression is returned for an unknown block compression.
+	ErrUnknownCompression = errors.New("unknown db compression")
+
+	// ErrInvalidBlockValue is returned for a stored block value which can't be decompressed.
+	ErrInvalidBlockValue = errors.New("invalid stored block")
+)
+
+// snappyBlockHeader prefixes the snappy compressed block values. Serialized blocks start
+// with the prefix of the canonical encoding, or are gob streams if stored before it, which
+// start with a length : a byte below 0x80, or a negated byte count of 0xf8 and above.
+// Blocks stored without compression never start with the header, so they stay readable
+// once compression is turned on.
+const snappyBlockHeader byte = 0x80
+
+// SetCompression sets the compression of the blocks written from now on. Blocks are read
+// whatever their compression. An empty name is no compression.
+func (bdb *BlockchainDB) SetCompression(name string) error {
+	switch name {
+	case "", CompressionNone, CompressionSnappy:
+		bdb.compression = name
+		return nil
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownCompression, name)
+	}
+}
+
+// EncodeBlock returns the value the block is stored as, compressed with the compression
+// of the DB.
+func (bdb *BlockchainDB) EncodeBlock(block *types.Block) []byte {
+	data := block.Serialize()
+
+	if bdb.compression != CompressionSnappy {
+		return data
+	}
+
+	return append([]byte{snappyBlockHeader}, snappy.Encode(nil, data)...)
+}
+
+// DecodeBlock decodes a stored block value, compressed or not.
+func DecodeBlock(value []byte) (*types.Block, error) {
+	if len(value) == 0 || value[0] != snappyBlockHeader {
+		return types.DeserializeBlock(value), nil
+	}
+
+	data, err := snappy.Decode(nil, value[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidBlockValue, err)
+	}
+
+	return types.DeserializeBlock(data), nil
+}
@@ -1,15 +1,37 @@
 package dbstore
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// Backends storing the databases.
+const (
+	BackendLevelDB = "leveldb" // On disk, in the database directory
+	BackendMemory  = "memory"  // In memory, dropped once closed
 )
 
+// ErrUnknownBackend is returned when opening a database with an unknown backend.
+var ErrUnknownBackend = errors.New("unknown database backend")
+
 const (
-	LastHashKey    = "lh" // Last hash key ( lastHash -> hash)
-	HashesKey      = "hs" // Hashes key (hash->block)
-	BlockNumberKey = "bn" // Block number key (blockNumber -> hash)
-	BalanceKey     = "bl" // Balance key (address -> balance)
-	NonceKey       = "nc" // Nonce key (address -> nonce)
+	LastHashKey     = "lh" // Last hash key ( lastHash -> hash)
+	HashesKey       = "hs" // Hashes key (hash->block)
+	BlockNumberKey  = "bn" // Block number key (blockNumber -> hash)
+	BalanceKey      = "bl" // Balance key (address -> balance)
+	NonceKey        = "nc" // Nonce key (address -> nonce)
+	TotalDiffKey    = "td" // Total difficulty key (hash -> total difficulty)
+	TxLookupKey     = "tx" // Tx lookup key (tx hash -> block number, index)
+	ReceiptKey      = "rc" // Receipt key (tx hash -> receipt)
+	StateHistoryKey = "sh" // State history key (state key, block number -> value after the block)
+	StatePrunedKey  = "sp" // State pruned key (statePruned -> first block number with history)
+
+	StateCommitmentKey = "sc" // State commitment key (stateCommitment -> lanes of the balances and nonces)
 )
 
 // PrefixKey prefixes a string with another string.
@@ -17,10 +39,13 @@ func PrefixKey(prefix string, str string) string {
 	return prefix + str
 }
 
-// DB is a wrapper around leveldb.
+// DB is a wrapper around leveldb. Writes of balances and nonces through it keep the state
+// commitment up to date.
 type DB struct {
 	dbPath  string
 	LevelDb *leveldb.DB
+
+	commitmentMu sync.Mutex // Serializes the updates of the state commitment
 }
 
 // NewDB creates a new DB instance.
@@ -33,6 +58,42 @@ func NewDBInstance(dbPath string) (*DB, error) {
 	return &DB{dbPath: dbPath, LevelDb: db}, nil
 }
 
+// OpenReadOnlyDBInstance opens an existing on disk DB for reading only, writes to it
+// fail. It takes a shared lock on the DB, so it can be opened by several readers at
+// once but not while a node has it open.
+func OpenReadOnlyDBInstance(dbPath string) (*DB, error) {
+	db, err := leveldb.OpenFile(dbPath, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{dbPath: dbPath, LevelDb: db}, nil
+}
+
+// NewMemoryDBInstance creates a DB instance kept in memory. It supports the same
+// operations as an on disk one, its content is lost once closed.
+func NewMemoryDBInstance() (*DB, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{LevelDb: db}, nil
+}
+
+// OpenDBInstance creates a DB instance with the given backend. The path is ignored by
+// the memory backend, an empty backend is leveldb.
+func OpenDBInstance(backend string, dbPath string) (*DB, error) {
+	switch backend {
+	case "", BackendLevelDB:
+		return NewDBInstance(dbPath)
+	case BackendMemory:
+		return NewMemoryDBInstance()
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownBackend, backend)
+	}
+}
+
 // Get returns the value for the given key.
 func (db *DB) Get(key string) ([]byte, error) {
 	value, err := db.LevelDb.Get([]byte(key), nil)
@@ -45,22 +106,18 @@ func (db *DB) Get(key string) ([]byte, error) {
 
 // Put sets the value for the given key.
 func (db *DB) Put(key string, value []byte) error {
-	err := db.LevelDb.Put([]byte(key), value, nil)
-	if err != nil {
-		return err
-	}
+	batch := db.NewBatch()
+	batch.Put([]byte(key), value)
 
-	return nil
+	return db.WriteBatch(batch)
 }
 
 // Delete deletes the value for the given key.
 func (db *DB) Delete(key string) error {
-	err := db.LevelDb.Delete([]byte(key), nil)
-	if err != nil {
-		return err
-	}
+	batch := db.NewBatch()
+	batch.Delete([]byte(key))
 
-	return nil
+	return db.WriteBatch(batch)
 }
 
 // Has returns true if the given key exists.
@@ -78,9 +135,10 @@ func (db *DB) NewBatch() *leveldb.Batch {
 	return new(leveldb.Batch)
 }
 
-// WriteBatch writes a batch to the db.
+// WriteBatch writes a batch to the db. A batch writing balances or nonces gets the update
+// of the state commitment appended.
 func (db *DB) WriteBatch(batch *leveldb.Batch) error {
-	err := db.LevelDb.Write(batch, nil)
+	err := db.writeBatch(batch)
 	if err != nil {
 		return err
 	}
@@ -1,5 +1,18 @@
 package dbstore
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/syndtr/goleveldb/leveldb"
+	lutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrStatePruned is returned when reading the state at a block whose history was pruned.
+var ErrStatePruned = errors.New("state is pruned")
+
 type StateDB struct {
 	DB *DB
 }
@@ -7,3 +20,184 @@ type StateDB struct {
 func NewStateDB(db *DB) *StateDB {
 	return &StateDB{DB: db}
 }
+
+// The state history records, for every block, the value each state key it touched has
+// after the block. The value of a key at a block is the last record at or below it.
+// Records are prefixed with 1 for a set key and 0 for a deleted one.
+
+// stateHistoryPrefix returns the prefix of the history records of the state key.
+func stateHistoryPrefix(key string) []byte {
+	return []byte(PrefixKey(StateHistoryKey, key+"@"))
+}
+
+// stateHistoryKey returns the history record key of the state key at the block number,
+// which sorts the records of a state key by block number.
+func stateHistoryKey(key string, number uint64) []byte {
+	return binary.BigEndian.AppendUint64(stateHistoryPrefix(key), number)
+}
+
+// WriteStateHistory records the value the state key has after the block number.
+func WriteStateHistory(batch *leveldb.Batch, key string, number uint64, value []byte, exists bool) {
+	record := []byte{0}
+	if exists {
+		record = append([]byte{1}, value...)
+	}
+
+	batch.Put(stateHistoryKey(key, number), record)
+}
+
+// DeleteStateHistory deletes the record of the state key at the block number.
+func DeleteStateHistory(batch *leveldb.Batch, key string, number uint64) {
+	batch.Delete(stateHistoryKey(key, number))
+}
+
+// GetStateAt returns the value of the state key after the block number, and false if
+// the key was not set at that block.
+func (sdb *StateDB) GetStateAt(key string, number uint64) ([]byte, bool, error) {
+	pruned, err := sdb.PrunedBefore()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if number < pruned {
+		return nil, false, fmt.Errorf("%w : history starts at block %d", ErrStatePruned, pruned)
+	}
+
+	iter := sdb.DB.LevelDb.NewIterator(lutil.BytesPrefix(stateHistoryPrefix(key)), nil)
+	defer iter.Release()
+
+	// Seek to the first record above the block number, the one before holds the value.
+	if iter.Seek(stateHistoryKey(key, number+1)) {
+		if !iter.Prev() {
+			return nil, false, iter.Error()
+		}
+	} else if !iter.Last() {
+		return nil, false, iter.Error()
+	}
+
+	record := iter.Value()
+	if len(record) == 0 || record[0] == 0 {
+		return nil, false, nil
+	}
+
+	return append([]byte{}, record[1:]...), true, nil
+}
+
+// InitStateHistory starts the state history at the block number, recording the value of
+// every state key at that block. It does nothing if the history was already started.
+func (sdb *StateDB) InitStateHistory(number uint64) error {
+	if has, err := sdb.DB.Has(StatePrunedKey); err != nil || has {
+		return err
+	}
+
+	batch := sdb.DB.NewBatch()
+
+	for _, prefix := range []string{BalanceKey, NonceKey} {
+		iter := sdb.DB.LevelDb.NewIterator(lutil.BytesPrefix([]byte(prefix)), nil)
+
+		for iter.Next() {
+			WriteStateHistory(batch, string(iter.Key()), number, iter.Value(), true)
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	batch.Put([]byte(StatePrunedKey), binary.BigEndian.AppendUint64(nil, number))
+
+	return sdb.DB.WriteBatch(batch)
+}
+
+// StateRoot returns the root of the state, the hash of the state commitment kept up to
+// date by the writes of balances and nonces.
+func StateRoot(db *DB) (*util.Hash, error) {
+	c, err := db.loadStateCommitment()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.root(), nil
+}
+
+// PrunedBefore returns the first block number the state history is available from.
+func (sdb *StateDB) PrunedBefore() (uint64, error) {
+	value, err := sdb.DB.Get(StatePrunedKey)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// PruneStateHistory drops the history before the block number. Records of the given
+// keys, which the block touched, below the block number are only needed by the states
+// before it, records of other keys are kept as they may still hold the value at the
+// block number and above.
+func (sdb *StateDB) PruneStateHistory(number uint64, keys []string) error {
+	batch := sdb.DB.NewBatch()
+
+	for _, key := range keys {
+		iter := sdb.DB.LevelDb.NewIterator(&lutil.Range{Start: stateHistoryPrefix(key), Limit: stateHistoryKey(key, number)}, nil)
+
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	batch.Put([]byte(StatePrunedKey), binary.BigEndian.AppendUint64(nil, number))
+
+	return sdb.DB.WriteBatch(batch)
+}
+
+// StateAt returns the value of every state key set after the block number.
+func (sdb *StateDB) StateAt(number uint64) (map[string][]byte, error) {
+	pruned, err := sdb.PrunedBefore()
+	if err != nil {
+		return nil, err
+	}
+
+	if number < pruned {
+		return nil, fmt.Errorf("%w : history starts at block %d", ErrStatePruned, pruned)
+	}
+
+	state := make(map[string][]byte)
+
+	iter := sdb.DB.LevelDb.NewIterator(lutil.BytesPrefix([]byte(StateHistoryKey)), nil)
+	defer iter.Release()
+
+	// Records of a state key are sorted by block number, the last one at or below the
+	// block number holds the value.
+	for iter.Next() {
+		record := iter.Key()[len(StateHistoryKey):]
+		if len(record) < 9 || record[len(record)-9] != '@' {
+			continue
+		}
+
+		if binary.BigEndian.Uint64(record[len(record)-8:]) > number {
+			continue
+		}
+
+		key := string(record[:len(record)-9])
+
+		if value := iter.Value(); len(value) > 0 && value[0] == 1 {
+			state[key] = append([]byte{}, value[1:]...)
+		} else {
+			delete(state, key)
+		}
+	}
+
+	return state, iter.Error()
+}
@@ -0,0 +1,49 @@
+package executer
+
+import (
+	"sync"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/golang/groupcache/lru"
+)
+
+// defaultSenderCacheSize is the number of verified transaction signatures the processor
+// remembers.
+var defaultSenderCacheSize = 4096
+
+// senderCache remembers the senders of the transactions whose signature was verified, by
+// signed hash, so that transactions applied again on a reorg or re-import aren't verified
+// again. A signature verifies regardless of the state, so entries never go stale.
+type senderCache struct {
+	mu      sync.Mutex
+	senders *lru.Cache
+}
+
+func newSenderCache(size int) *senderCache {
+	return &senderCache{senders: lru.New(size)}
+}
+
+// verify checks the signature of the transaction as VerifySignature does, unless the
+// same signed transaction was verified before.
+func (sc *senderCache) verify(tx *types.Transaction) error {
+	key := tx.SignedHash().String()
+
+	sc.mu.Lock()
+	_, ok := sc.senders.Get(key)
+	sc.mu.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	if err := tx.VerifySignature(); err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.senders.Add(key, tx.From)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package executer
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// VerifySignatures verifies the signatures of the transactions of a block over up to
+// GOMAXPROCS goroutines, remembering their senders for the execution of the block. It
+// fails with the first transaction of the block whose signature is invalid.
+func (txp *TxProcessor) VerifySignatures(txs []*types.Transaction) error {
+	return verifySignatures(txs, runtime.GOMAXPROCS(0), txp.senders.verify)
+}
+
+// verifySignatures verifies the transactions over the given number of workers. The
+// transactions are handed out in order and none after an invalid one is verified, so
+// that all the transactions before it are and the error always names the lowest index.
+func verifySignatures(txs []*types.Transaction, workers int, verify func(*types.Transaction) error) error {
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+
+	var (
+		next    atomic.Int64
+		invalid atomic.Int64
+		wg      sync.WaitGroup
+	)
+
+	invalid.Store(int64(len(txs)))
+
+	errs := make([]error, len(txs))
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				i := next.Add(1) - 1
+				if i >= invalid.Load() {
+					return
+				}
+
+				if err := verify(txs[i]); err != nil {
+					errs[i] = err
+
+					// Lower the index of the first invalid transaction if it is the lowest so far.
+					for {
+						current := invalid.Load()
+						if i >= current || invalid.CompareAndSwap(current, i) {
+							break
+						}
+					}
+
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if i := invalid.Load(); i < int64(len(txs)) {
+		return fmt.Errorf("%w : transaction %d %s", errs[i], i, txs[i].Hash().String())
+	}
+
+	return nil
+}
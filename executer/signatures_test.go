@@ -0,0 +1,72 @@
+package executer
+
+import (
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedTxs(tb testing.TB, count int) []*types.Transaction {
+	tb.Helper()
+
+	// Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	txs := make([]*types.Transaction, count)
+
+	for i := range txs {
+		txs[i] = &types.Transaction{
+			From:  *ua.Address(),
+			To:    *util.BytesToAddress([]byte{0x01}),
+			Value: big.NewInt(1000),
+			Fee:   big.NewInt(100),
+			Nonce: big.NewInt(int64(i)),
+		}
+		txs[i].Sign(ua)
+	}
+
+	return txs
+}
+
+func TestVerifySignatures(t *testing.T) {
+	t.Parallel()
+
+	txs := newSignedTxs(t, 64)
+	assert.NoError(t, verifySignatures(txs, 8, (*types.Transaction).VerifySignature))
+
+	txs[40].R = new(big.Int).Add(txs[40].R, big.NewInt(1))
+	txs[10].R = new(big.Int).Add(txs[10].R, big.NewInt(1))
+
+	// The error always names the first invalid transaction.
+	for i := 0; i < 20; i++ {
+		err := verifySignatures(txs, 8, (*types.Transaction).VerifySignature)
+		assert.ErrorIs(t, err, types.ErrInvalidSignature)
+		assert.Contains(t, err.Error(), "transaction 10 "+txs[10].Hash().String())
+	}
+
+	assert.NoError(t, verifySignatures(nil, 8, (*types.Transaction).VerifySignature))
+}
+
+func benchmarkVerifySignatures(b *testing.B, workers int) {
+	txs := newSignedTxs(b, 1000)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := verifySignatures(txs, workers, (*types.Transaction).VerifySignature); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifySignaturesSerial(b *testing.B) {
+	benchmarkVerifySignatures(b, 1)
+}
+
+func BenchmarkVerifySignaturesParallel(b *testing.B) {
+	benchmarkVerifySignatures(b, runtime.GOMAXPROCS(0))
+}
@@ -2,6 +2,7 @@ package executer
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
 
@@ -15,19 +16,25 @@ var (
 )
 
 type TxProcessor struct {
-	MinFee *big.Int
-	State  *dbstore.DB
-	Signer *util.Address
+	MinFee      *big.Int
+	BlockReward *big.Int // Credited to the coinbase of every block, nil for none
+	State       *dbstore.DB
+	Coinbase    *util.Address // Coinbase of the blocks mined locally, nil if not mining
+	BaseFeeSink *util.Address // Credited with the base fee part of dynamic fees, nil burns it
 
 	StateMu *sync.Mutex
+
+	senders *senderCache
 }
 
-func NewTxProcessor(state *dbstore.DB, minFee *big.Int, signer *util.Address) *TxProcessor {
+func NewTxProcessor(state *dbstore.DB, minFee *big.Int, blockReward *big.Int, coinbase *util.Address) *TxProcessor {
 	return &TxProcessor{
-		MinFee:  minFee,
-		State:   state,
-		Signer:  signer,
-		StateMu: new(sync.Mutex),
+		MinFee:      minFee,
+		BlockReward: blockReward,
+		State:       state,
+		Coinbase:    coinbase,
+		StateMu:     new(sync.Mutex),
+		senders:     newSenderCache(defaultSenderCacheSize),
 	}
 }
 
@@ -35,8 +42,7 @@ func (txp *TxProcessor) IsValid(tx *types.Transaction) bool {
 	txp.StateMu.Lock()
 	defer txp.StateMu.Unlock()
 
-	signOk := tx.Verify()
-	if !signOk {
+	if err := txp.senders.verify(tx); err != nil {
 		return false
 	}
 
@@ -49,8 +55,8 @@ func (txp *TxProcessor) IsValid(tx *types.Transaction) bool {
 
 	balanceBig := new(big.Int).SetBytes(balance)
 
-	// Add Fee to Value
-	totalValue := big.NewInt(0).Add(tx.Value, tx.Fee)
+	// Add the highest fee the transaction may pay to Value
+	totalValue := big.NewInt(0).Add(tx.Value, tx.FeeCap())
 
 	if balanceBig.Cmp(totalValue) < 0 {
 		return false
@@ -72,12 +78,15 @@ func (txp *TxProcessor) IsValid(tx *types.Transaction) bool {
 	return true
 }
 
+// IsValidImport reports whether the transaction of an imported block can be executed on
+// top of the state : signed by its sender, paying for its value and fee, and with the
+// nonce following the one of the sender, so that a transaction mined before can't be
+// applied again.
 func (txp *TxProcessor) IsValidImport(tx *types.Transaction) bool {
 	txp.StateMu.Lock()
 	defer txp.StateMu.Unlock()
 
-	signOk := tx.Verify()
-	if !signOk {
+	if err := txp.senders.verify(tx); err != nil {
 		return false
 	}
 
@@ -90,20 +99,27 @@ func (txp *TxProcessor) IsValidImport(tx *types.Transaction) bool {
 
 	balanceBig := new(big.Int).SetBytes(balance)
 
-	// Add Fee to Value
-	totalValue := big.NewInt(0).Add(tx.Value, tx.Fee)
+	// Add the highest fee the transaction may pay to Value
+	totalValue := big.NewInt(0).Add(tx.Value, tx.FeeCap())
+
+	if balanceBig.Cmp(totalValue) < 0 {
+		return false
+	}
 
-	return balanceBig.Cmp(totalValue) >= 0
+	return new(big.Int).Sub(tx.Nonce, txp.nonce(&from)).Cmp(big.NewInt(1)) == 0
 }
 
-// ProcessTx processes a transaction and returns the transaction fee.
-func (txp *TxProcessor) ProcessTx(tx *types.Transaction) error {
+// ProcessTx processes a transaction in a block of the given base fee, crediting its tip
+// to the coinbase and the rest of its fee to the base fee sink.
+func (txp *TxProcessor) ProcessTx(tx *types.Transaction, coinbase *util.Address, baseFee *big.Int) error {
 	txp.StateMu.Lock()
 	defer txp.StateMu.Unlock()
 
 	from := tx.From
 	to := tx.To
 	value := tx.Value
+	fee := tx.EffectiveFee(baseFee)
+	tip := tx.Tip(baseFee)
 
 	dbBatch := txp.State.NewBatch()
 
@@ -115,19 +131,15 @@ func (txp *TxProcessor) ProcessTx(tx *types.Transaction) error {
 
 	sendBalanceBig := new(big.Int).SetBytes(senderBalance)
 
-	// Get receiver balance.
-	var receiverBalanceBig *big.Int
-
-	receiverBalance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, to.String()))
-	if err != nil {
-		receiverBalanceBig = big.NewInt(0)
-	} else {
-		receiverBalanceBig = new(big.Int).SetBytes(receiverBalance)
-
+	// Get receiver balance, the one of the sender for self-transfers, which only pay the fee.
+	receiverBalanceBig := sendBalanceBig
+	if to != from {
+		receiverBalanceBig = txp.balance(&to)
 	}
 
-	// Update sender balance.
+	// Update sender balance, the sender pays the value and the fee.
 	sendBalanceBig.Sub(sendBalanceBig, value)
+	sendBalanceBig.Sub(sendBalanceBig, fee)
 	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, from.String())), sendBalanceBig.Bytes())
 
 	// Update receiver balance.
@@ -147,20 +159,21 @@ func (txp *TxProcessor) ProcessTx(tx *types.Transaction) error {
 	nonceBig.Add(nonceBig, big.NewInt(1))
 	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.NonceKey, from.String())), nonceBig.Bytes())
 
-	// Get Miner balance.
+	// Get Miner balance, the miner may be the sender or the receiver.
 	var minerBalanceBig *big.Int
 
-	minerBalance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, txp.Signer.String()))
-	if err != nil {
-		minerBalanceBig = big.NewInt(0)
-	} else {
-		minerBalanceBig = new(big.Int).SetBytes(minerBalance)
-
+	switch *coinbase {
+	case from:
+		minerBalanceBig = sendBalanceBig
+	case to:
+		minerBalanceBig = receiverBalanceBig
+	default:
+		minerBalanceBig = txp.balance(coinbase)
 	}
 
 	// Update Miner Fee.
-	minerBalanceBig.Add(minerBalanceBig, tx.Fee)
-	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, txp.Signer.String())), minerBalanceBig.Bytes())
+	minerBalanceBig.Add(minerBalanceBig, tip)
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, coinbase.String())), minerBalanceBig.Bytes())
 
 	// Commit batch to db
 	err = txp.State.WriteBatch(dbBatch)
@@ -168,17 +181,22 @@ func (txp *TxProcessor) ProcessTx(tx *types.Transaction) error {
 		panic(err)
 	}
 
+	txp.creditBaseFee(new(big.Int).Sub(fee, tip), false)
+
 	return nil
 }
 
-// RevertTx undoes a transaction
-func (txp *TxProcessor) RollbackTx(tx *types.Transaction) error {
+// RollbackTx undoes a transaction of a block of the given base fee, taking its tip back
+// from the coinbase and the rest of its fee from the base fee sink.
+func (txp *TxProcessor) RollbackTx(tx *types.Transaction, coinbase *util.Address, baseFee *big.Int) error {
 	txp.StateMu.Lock()
 	defer txp.StateMu.Unlock()
 
 	from := tx.From
 	to := tx.To
 	value := tx.Value
+	fee := tx.EffectiveFee(baseFee)
+	tip := tx.Tip(baseFee)
 
 	dbBatch := txp.State.NewBatch()
 
@@ -190,19 +208,15 @@ func (txp *TxProcessor) RollbackTx(tx *types.Transaction) error {
 
 	sendBalanceBig := new(big.Int).SetBytes(senderBalance)
 
-	// Get receiver balance.
-	var receiverBalanceBig *big.Int
-
-	receiverBalance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, to.String()))
-	if err != nil {
-		receiverBalanceBig = big.NewInt(0)
-	} else {
-		receiverBalanceBig = new(big.Int).SetBytes(receiverBalance)
-
+	// Get receiver balance, the one of the sender for self-transfers, which only refund the fee.
+	receiverBalanceBig := sendBalanceBig
+	if to != from {
+		receiverBalanceBig = txp.balance(&to)
 	}
 
-	// Update sender balance.
+	// Update sender balance, refunding the value and the fee.
 	sendBalanceBig.Add(sendBalanceBig, value)
+	sendBalanceBig.Add(sendBalanceBig, fee)
 	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, from.String())), sendBalanceBig.Bytes())
 
 	// Update receiver balance.
@@ -227,20 +241,21 @@ func (txp *TxProcessor) RollbackTx(tx *types.Transaction) error {
 		dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.NonceKey, from.String())), nonceBig.Bytes())
 	}
 
-	// Get Miner balance.
+	// Get Miner balance, the miner may be the sender or the receiver.
 	var minerBalanceBig *big.Int
 
-	minerBalance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, txp.Signer.String()))
-	if err != nil {
-		minerBalanceBig = big.NewInt(0)
-	} else {
-		minerBalanceBig = new(big.Int).SetBytes(minerBalance)
-
+	switch *coinbase {
+	case from:
+		minerBalanceBig = sendBalanceBig
+	case to:
+		minerBalanceBig = receiverBalanceBig
+	default:
+		minerBalanceBig = txp.balance(coinbase)
 	}
 
 	// Update Miner Fee.
-	minerBalanceBig.Sub(minerBalanceBig, tx.Fee)
-	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, txp.Signer.String())), minerBalanceBig.Bytes())
+	minerBalanceBig.Sub(minerBalanceBig, tip)
+	dbBatch.Put([]byte(dbstore.PrefixKey(dbstore.BalanceKey, coinbase.String())), minerBalanceBig.Bytes())
 
 	// Commit batch to db
 	err = txp.State.WriteBatch(dbBatch)
@@ -248,5 +263,158 @@ func (txp *TxProcessor) RollbackTx(tx *types.Transaction) error {
 		panic(err)
 	}
 
+	txp.creditBaseFee(new(big.Int).Sub(fee, tip), true)
+
 	return nil
 }
+
+// balance returns the balance of the address, zero for unknown addresses. The caller
+// must hold the state lock.
+func (txp *TxProcessor) balance(address *util.Address) *big.Int {
+	balance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, address.String()))
+	if err != nil {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).SetBytes(balance)
+}
+
+// nonce returns the nonce of the last transaction of the address, -1 for addresses which
+// never sent one. The caller must hold the state lock.
+func (txp *TxProcessor) nonce(address *util.Address) *big.Int {
+	nonce, err := txp.State.Get(dbstore.PrefixKey(dbstore.NonceKey, address.String()))
+	if err != nil {
+		return big.NewInt(-1)
+	}
+
+	return new(big.Int).SetBytes(nonce)
+}
+
+// creditBaseFee adds the base fee part of a dynamic fee to the balance of the base fee
+// sink, or takes it back when rolling a transaction back. It is burned without a sink.
+// The caller must hold the state lock.
+func (txp *TxProcessor) creditBaseFee(amount *big.Int, rollback bool) {
+	if txp.BaseFeeSink == nil || amount.Sign() == 0 {
+		return
+	}
+
+	balance := txp.balance(txp.BaseFeeSink)
+
+	if rollback {
+		balance.Sub(balance, amount)
+	} else {
+		balance.Add(balance, amount)
+	}
+
+	err := txp.State.Put(dbstore.PrefixKey(dbstore.BalanceKey, txp.BaseFeeSink.String()), balance.Bytes())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// creditReward adds the block reward to the balance of the coinbase, or takes it back
+// when rolling a block back.
+func (txp *TxProcessor) creditReward(coinbase *util.Address, rollback bool) {
+	if txp.BlockReward == nil || txp.BlockReward.Sign() == 0 {
+		return
+	}
+
+	txp.StateMu.Lock()
+	defer txp.StateMu.Unlock()
+
+	balance := txp.balance(coinbase)
+
+	if rollback {
+		balance.Sub(balance, txp.BlockReward)
+	} else {
+		balance.Add(balance, txp.BlockReward)
+	}
+
+	err := txp.State.Put(dbstore.PrefixKey(dbstore.BalanceKey, coinbase.String()), balance.Bytes())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// StateRoot returns the root of the current state.
+func (txp *TxProcessor) StateRoot() *util.Hash {
+	txp.StateMu.Lock()
+	defer txp.StateMu.Unlock()
+
+	root, err := dbstore.StateRoot(txp.State)
+	if err != nil {
+		panic(err)
+	}
+
+	return root
+}
+
+// ProcessTxs executes the valid transactions out of the given set in a block of the given
+// base fee and returns the transactions which were applied to the state. The tips and the
+// block reward are credited to the coinbase.
+func (txp *TxProcessor) ProcessTxs(txs []*types.Transaction, coinbase *util.Address, baseFee *big.Int) []*types.Transaction {
+	validTxs := []*types.Transaction{}
+
+	for _, tx := range txs {
+		if txp.IsValid(tx) {
+			err := txp.ProcessTx(tx, coinbase, baseFee)
+			if err == nil {
+				validTxs = append(validTxs, tx)
+			} else {
+				fmt.Println("Failed to execute Tx :", "tx :", tx, "error", err)
+			}
+		} else {
+			fmt.Println("Invalid Tx :", "tx :", tx)
+		}
+	}
+
+	txp.creditReward(coinbase, false)
+
+	return validTxs
+}
+
+// ProcessImportTxs executes the transactions of an imported block of the given base fee,
+// crediting the tips and the block reward to its coinbase. It fails on the first
+// transaction which is invalid or can't be executed, undoing the transactions applied
+// before it.
+func (txp *TxProcessor) ProcessImportTxs(txs []*types.Transaction, coinbase *util.Address, baseFee *big.Int) bool {
+	for i, tx := range txs {
+		if !txp.IsValidImport(tx) {
+			fmt.Println("Invalid Tx :", "tx :", tx)
+			txp.rollbackTxs(txs[:i], coinbase, baseFee)
+
+			return false
+		}
+
+		err := txp.ProcessTx(tx, coinbase, baseFee)
+		if err != nil {
+			fmt.Println("Failed to execute Tx :", "tx :", tx, "error", err)
+			txp.rollbackTxs(txs[:i], coinbase, baseFee)
+
+			return false
+		}
+	}
+
+	txp.creditReward(coinbase, false)
+
+	return true
+}
+
+// RollbackTxs undoes the transactions of a block of the given base fee, last one first,
+// and takes the block reward back from the coinbase.
+func (txp *TxProcessor) RollbackTxs(txs []*types.Transaction, coinbase *util.Address, baseFee *big.Int) {
+	txp.creditReward(coinbase, true)
+	txp.rollbackTxs(txs, coinbase, baseFee)
+}
+
+// rollbackTxs undoes the given transactions, last one first.
+func (txp *TxProcessor) rollbackTxs(txs []*types.Transaction, coinbase *util.Address, baseFee *big.Int) {
+	for i := len(txs) - 1; i >= 0; i-- {
+		tx := txs[i]
+
+		err := txp.RollbackTx(tx, coinbase, baseFee)
+		if err != nil {
+			fmt.Println("Failed to rollback Tx :", "tx :", tx, "error", err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package explorer serves a minimal block explorer, HTML pages listing the latest blocks
+// and detailing blocks and transactions, read from the blockchain DB.
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// Paths of the block and transaction pages, followed by a block number or hash and by
+// a transaction hash.
+const (
+	BlockPath = "/block/"
+	TxPath    = "/tx/"
+)
+
+// latestBlocks is the number of blocks listed on the home page.
+var latestBlocks uint64 = 20
+
+// shutdownTimeout is how long Stop waits for in-flight requests to finish.
+var shutdownTimeout = 5 * time.Second
+
+// Explorer serves the pages of the chain of the blockchain DB.
+type Explorer struct {
+	BlockchainDB *dbstore.BlockchainDB
+
+	server *http.Server
+}
+
+// New returns the explorer of the chain of the blockchain DB.
+func New(blockchainDB *dbstore.BlockchainDB) *Explorer {
+	return &Explorer{BlockchainDB: blockchainDB}
+}
+
+// Handler returns the handler serving the pages.
+func (e *Explorer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", e.serveHome)
+	mux.HandleFunc(BlockPath, e.serveBlock)
+	mux.HandleFunc(TxPath, e.serveTx)
+
+	return mux
+}
+
+// Start serves the pages on the given address.
+func (e *Explorer) Start(addr string) {
+	srv := &http.Server{Addr: addr, Handler: e.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	log.Println("Serving explorer on", addr)
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			log.Fatalf("Error serving explorer: %s", err)
+		}
+	}()
+
+	e.server = srv
+}
+
+// Stop shuts down the explorer server, if started.
+func (e *Explorer) Stop() error {
+	if e.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return e.server.Shutdown(ctx)
+}
+
+// blockView and txView are the fields of the blocks and transactions shown on the pages.
+type blockView struct {
+	Number       string
+	Hash         string
+	ParentHash   string
+	Timestamp    string
+	Difficulty   uint64
+	BaseFee      string
+	GasUsed      uint64
+	Coinbase     string
+	ExtraData    string
+	Transactions []*txView
+}
+
+type txView struct {
+	Hash        string
+	BlockNumber string
+	BlockHash   string
+	Index       int
+	From        string
+	To          string
+	Value       string
+	Fee         string
+	Nonce       string
+	GasLimit    uint64
+	Data        string
+}
+
+func newBlockView(block *types.Block) *blockView {
+	view := &blockView{
+		Number:     block.Number.String(),
+		Hash:       block.DeriveHash().String(),
+		ParentHash: block.ParentHash.String(),
+		Timestamp:  time.Unix(int64(block.Timestamp), 0).UTC().Format(time.RFC3339),
+		Difficulty: block.Difficulty,
+		BaseFee:    "-",
+		GasUsed:    block.GasUsed(),
+		Coinbase:   "-",
+		ExtraData:  fmt.Sprintf("%q", block.ExtraData),
+	}
+
+	if block.BaseFee != nil {
+		view.BaseFee = block.BaseFee.String()
+	}
+
+	if coinbase := block.Coinbase(); coinbase != nil {
+		view.Coinbase = coinbase.String()
+	}
+
+	for i, tx := range block.Transactions {
+		view.Transactions = append(view.Transactions, newTxView(tx, block, i))
+	}
+
+	return view
+}
+
+func newTxView(tx *types.Transaction, block *types.Block, index int) *txView {
+	return &txView{
+		Hash:        tx.Hash().String(),
+		BlockNumber: block.Number.String(),
+		BlockHash:   block.DeriveHash().String(),
+		Index:       index,
+		From:        tx.From.String(),
+		To:          tx.To.String(),
+		Value:       tx.Value.String(),
+		Fee:         tx.EffectiveFee(block.BaseFee).String(),
+		Nonce:       tx.Nonce.String(),
+		GasLimit:    tx.GasLimit,
+		Data:        fmt.Sprintf("0x%x", tx.Data),
+	}
+}
+
+// serveHome lists the latest blocks, from the head down.
+func (e *Explorer) serveHome(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	latest, err := e.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	blocks := []*blockView{}
+
+	for n, i := latest.Number.Uint64(), uint64(0); i < latestBlocks; n, i = n-1, i+1 {
+		block, err := e.BlockchainDB.GetBlockByNumber(new(big.Int).SetUint64(n))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		blocks = append(blocks, newBlockView(block))
+
+		if n == 0 {
+			break
+		}
+	}
+
+	render(w, "home", blocks)
+}
+
+// serveBlock details the canonical block of the number, or the block of the 0x-prefixed
+// hash.
+func (e *Explorer) serveBlock(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, BlockPath)
+
+	var (
+		block *types.Block
+		err   error
+	)
+
+	if strings.HasPrefix(id, "0x") {
+		hash, herr := util.HexToHash(id)
+		if herr != nil {
+			http.Error(w, herr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		block, err = e.BlockchainDB.GetBlockByHash(hash)
+	} else {
+		number, perr := strconv.ParseUint(id, 10, 64)
+		if perr != nil {
+			http.Error(w, "invalid block number or hash", http.StatusBadRequest)
+			return
+		}
+
+		block, err = e.BlockchainDB.GetBlockByNumber(new(big.Int).SetUint64(number))
+	}
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	render(w, "block", newBlockView(block))
+}
+
+// serveTx details the transaction of the 0x-prefixed hash, if mined on the canonical chain.
+func (e *Explorer) serveTx(w http.ResponseWriter, r *http.Request) {
+	hash, err := util.HexToHash(strings.TrimPrefix(r.URL.Path, TxPath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, block, lookup, err := e.BlockchainDB.GetTransactionByHash(hash)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	render(w, "tx", newTxView(tx, block, int(lookup.Index)))
+}
+
+func render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		log.Println("Failed to render explorer page", name, err)
+	}
+}
+
+var pages = template.Must(template.New("pages").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Compact-Chain explorer</title>
+<style>body{font-family:monospace;margin:2em}table{border-collapse:collapse}td,th{padding:2px 12px;text-align:left}</style>
+</head>
+<body>
+<h1><a href="/">Compact-Chain explorer</a></h1>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "home"}}{{template "header"}}
+<h2>Latest blocks</h2>
+<table>
+<tr><th>Number</th><th>Hash</th><th>Timestamp</th><th>Transactions</th></tr>
+{{range .}}<tr><td><a href="/block/{{.Number}}">{{.Number}}</a></td><td><a href="/block/{{.Hash}}">{{.Hash}}</a></td><td>{{.Timestamp}}</td><td>{{len .Transactions}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "block"}}{{template "header"}}
+<h2>Block {{.Number}}</h2>
+<table>
+<tr><th>Hash</th><td>{{.Hash}}</td></tr>
+<tr><th>Parent hash</th><td><a href="/block/{{.ParentHash}}">{{.ParentHash}}</a></td></tr>
+<tr><th>Timestamp</th><td>{{.Timestamp}}</td></tr>
+<tr><th>Difficulty</th><td>{{.Difficulty}}</td></tr>
+<tr><th>Base fee</th><td>{{.BaseFee}}</td></tr>
+<tr><th>Gas used</th><td>{{.GasUsed}}</td></tr>
+<tr><th>Coinbase</th><td>{{.Coinbase}}</td></tr>
+<tr><th>Extra data</th><td>{{.ExtraData}}</td></tr>
+</table>
+<h3>Transactions</h3>
+<table>
+<tr><th>Index</th><th>Hash</th><th>From</th><th>To</th><th>Value</th></tr>
+{{range .Transactions}}<tr><td>{{.Index}}</td><td><a href="/tx/{{.Hash}}">{{.Hash}}</a></td><td>{{.From}}</td><td>{{.To}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "tx"}}{{template "header"}}
+<h2>Transaction</h2>
+<table>
+<tr><th>Hash</th><td>{{.Hash}}</td></tr>
+<tr><th>Block</th><td><a href="/block/{{.BlockHash}}">{{.BlockNumber}}</a></td></tr>
+<tr><th>Index</th><td>{{.Index}}</td></tr>
+<tr><th>From</th><td>{{.From}}</td></tr>
+<tr><th>To</th><td>{{.To}}</td></tr>
+<tr><th>Value</th><td>{{.Value}}</td></tr>
+<tr><th>Fee</th><td>{{.Fee}}</td></tr>
+<tr><th>Nonce</th><td>{{.Nonce}}</td></tr>
+<tr><th>Gas limit</th><td>{{.GasLimit}}</td></tr>
+<tr><th>Data</th><td>{{.Data}}</td></tr>
+</table>
+{{template "footer"}}{{end}}
+`))
@@ -0,0 +1,116 @@
+package explorer
+
+import (
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestExplorer returns an explorer over a chain of a genesis block and a block with a
+// transaction.
+func newTestExplorer(t *testing.T) (*Explorer, *types.Block) {
+	t.Helper()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// nolint : errcheck
+		db.Close()
+	})
+
+	blockchainDB := dbstore.NewBlockchainDB(db)
+
+	genesis := types.NewBlock(big.NewInt(0), util.HashData([]byte("genesis")), []byte("genesis"))
+	block := types.NewBlock(big.NewInt(1), genesis.DeriveHash(), []byte("Block 1"))
+	block.Transactions = []*types.Transaction{{
+		From:  *util.StringToAddress("0xa52c981eee8687b5e4afd69aa5006548c24d7685"),
+		To:    *util.StringToAddress("0x93a63fc45341fc02ac9cce62cc5aeb5c5799403e"),
+		Value: big.NewInt(10),
+		Fee:   big.NewInt(100),
+		Nonce: big.NewInt(0),
+	}}
+
+	batch := db.NewBatch()
+
+	for _, b := range []*types.Block{genesis, block} {
+		batch.Put([]byte(dbstore.PrefixKey(dbstore.HashesKey, b.DeriveHash().String())), blockchainDB.EncodeBlock(b))
+		batch.Put([]byte(dbstore.PrefixKey(dbstore.BlockNumberKey, b.Number.String())), b.DeriveHash().Bytes())
+	}
+
+	batch.Put([]byte(dbstore.LastHashKey), block.DeriveHash().Bytes())
+	dbstore.WriteTxLookups(batch, block)
+
+	if err := db.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	return New(blockchainDB), block
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rec.Code, string(body)
+}
+
+func TestExplorerPages(t *testing.T) {
+	t.Parallel()
+
+	explorer, block := newTestExplorer(t)
+	handler := explorer.Handler()
+	hash := block.DeriveHash().String()
+	txHash := block.Transactions[0].Hash().String()
+
+	// The block detail page, by number and by hash.
+	code, body := get(t, handler, "/block/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, hash)
+	assert.Contains(t, body, txHash)
+
+	code, body = get(t, handler, "/block/"+hash)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, hash)
+
+	// The latest blocks, from the head down to genesis.
+	code, body = get(t, handler, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, hash)
+	assert.Contains(t, body, block.ParentHash.String())
+
+	// The transaction detail page.
+	code, body = get(t, handler, "/tx/"+txHash)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, txHash)
+	assert.Contains(t, body, hash)
+
+	// Unknown blocks, transactions and routes aren't found, malformed ids are rejected.
+	code, _ = get(t, handler, "/block/2")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get(t, handler, "/tx/"+util.HashData([]byte("unknown")).String())
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get(t, handler, "/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get(t, handler, "/block/latest")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,366 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0xsharma/compact-chain/util"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrDecrypt         = errors.New("could not decrypt key with given password")
+	ErrNoMatch         = errors.New("no key for given address")
+	ErrAlreadyExists   = errors.New("account already exists")
+	ErrInvalidKeyFile  = errors.New("invalid key file")
+	ErrUnsupportedKDF  = errors.New("unsupported key derivation function")
+	ErrUnsupportedAlgo = errors.New("unsupported cipher")
+)
+
+const (
+	// StandardScryptN is the scrypt N parameter used for new keys.
+	StandardScryptN = 1 << 18
+
+	// StandardScryptP is the scrypt P parameter used for new keys.
+	StandardScryptP = 1
+
+	// LightScryptN is a cheaper scrypt N parameter, meant for tests.
+	LightScryptN = 1 << 12
+
+	// LightScryptP is a cheaper scrypt P parameter, meant for tests.
+	LightScryptP = 6
+
+	keyFileVersion = 1
+	scryptR        = 8
+	scryptDKLen    = 32
+	cipherName     = "aes-128-ctr"
+	kdfName        = "scrypt"
+)
+
+// KeyStore stores accounts as password encrypted JSON key files in a directory.
+type KeyStore struct {
+	Dir     string
+	ScryptN int
+	ScryptP int
+}
+
+// NewKeyStore creates a keystore in the given directory using the standard scrypt parameters.
+func NewKeyStore(dir string) *KeyStore {
+	return &KeyStore{Dir: dir, ScryptN: StandardScryptN, ScryptP: StandardScryptP}
+}
+
+type keyFile struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams cipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    kdfParams    `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type kdfParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// NewAccount generates a new key, stores it encrypted with the password and returns its address.
+func (ks *KeyStore) NewAccount(password string) (*util.Address, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.storeKey(key, password)
+}
+
+// Import stores the hex encoded private key encrypted with the password and returns its address.
+func (ks *KeyStore) Import(hexKey string, password string) (*util.Address, error) {
+	hexKey = strings.TrimPrefix(hexKey, "0x")
+
+	if _, err := hex.DecodeString(hexKey); err != nil || hexKey == "" {
+		return nil, fmt.Errorf("invalid private key : %s", hexKey)
+	}
+
+	key := util.HexToPrivateKey(hexKey)
+
+	if _, err := ks.find(util.PublicKeyToAddress(&key.PublicKey)); err == nil {
+		return nil, ErrAlreadyExists
+	}
+
+	return ks.storeKey(key, password)
+}
+
+// Accounts returns the addresses of the accounts in the keystore.
+func (ks *KeyStore) Accounts() ([]*util.Address, error) {
+	files, err := ks.keyFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []*util.Address{}
+
+	for _, path := range files {
+		kf, err := readKeyFile(path)
+		if err != nil {
+			fmt.Println("Skipping key file", path, err)
+			continue
+		}
+
+		address, err := util.HexToAddress(kf.Address)
+		if err != nil {
+			fmt.Println("Skipping key file", path, err)
+			continue
+		}
+
+		accounts = append(accounts, address)
+	}
+
+	return accounts, nil
+}
+
+// Unlock decrypts the key of the account with the password.
+func (ks *KeyStore) Unlock(address *util.Address, password string) (*ecdsa.PrivateKey, error) {
+	path, err := ks.find(address)
+	if err != nil {
+		return nil, err
+	}
+
+	kf, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decryptKey(kf, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if util.PublicKeyToAddress(&key.PublicKey).String() != address.String() {
+		return nil, ErrInvalidKeyFile
+	}
+
+	return key, nil
+}
+
+func (ks *KeyStore) storeKey(key *ecdsa.PrivateKey, password string) (*util.Address, error) {
+	address := util.PublicKeyToAddress(&key.PublicKey)
+
+	kf, err := encryptKey(key, password, ks.ScryptN, ks.ScryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(ks.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("UTC--%s--%x", time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z"), address.Bytes())
+	if err := os.WriteFile(filepath.Join(ks.Dir, name), content, 0o600); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// find returns the path of the key file of the account.
+func (ks *KeyStore) find(address *util.Address) (string, error) {
+	files, err := ks.keyFiles()
+	if err != nil {
+		return "", err
+	}
+
+	suffix := fmt.Sprintf("--%x", address.Bytes())
+
+	for _, path := range files {
+		if strings.HasSuffix(path, suffix) {
+			return path, nil
+		}
+	}
+
+	return "", ErrNoMatch
+}
+
+func (ks *KeyStore) keyFiles() ([]string, error) {
+	entries, err := os.ReadDir(ks.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		files = append(files, filepath.Join(ks.Dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+func readKeyFile(path string) (*keyFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(content, &kf); err != nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	return &kf, nil
+}
+
+// encryptKey encrypts the key with AES-128-CTR under a scrypt derived key. The MAC
+// over the second half of the derived key and the ciphertext detects wrong passwords.
+func encryptKey(key *ecdsa.PrivateKey, password string, scryptN, scryptP int) (*keyFile, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 32)
+	key.D.FillBytes(keyBytes)
+
+	cipherText, err := aesCTR(derivedKey[:16], iv, keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	address := util.PublicKeyToAddress(&key.PublicKey)
+
+	return &keyFile{
+		Address: address.String(),
+		Crypto: cryptoJSON{
+			Cipher:       cipherName,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          kdfName,
+			KDFParams: kdfParams{
+				N:     scryptN,
+				R:     scryptR,
+				P:     scryptP,
+				DKLen: scryptDKLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(keyMAC(derivedKey, cipherText)),
+		},
+		Version: keyFileVersion,
+	}, nil
+}
+
+func decryptKey(kf *keyFile, password string) (*ecdsa.PrivateKey, error) {
+	if kf.Crypto.KDF != kdfName {
+		return nil, ErrUnsupportedKDF
+	}
+
+	if kf.Crypto.Cipher != cipherName {
+		return nil, ErrUnsupportedAlgo
+	}
+
+	salt, err := hex.DecodeString(kf.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	iv, err := hex.DecodeString(kf.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	cipherText, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	mac, err := hex.DecodeString(kf.Crypto.MAC)
+	if err != nil {
+		return nil, ErrInvalidKeyFile
+	}
+
+	params := kf.Crypto.KDFParams
+
+	derivedKey, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(derivedKey) < 32 || subtle.ConstantTimeCompare(keyMAC(derivedKey, cipherText), mac) != 1 {
+		return nil, ErrDecrypt
+	}
+
+	keyBytes, err := aesCTR(derivedKey[:16], iv, cipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.HexToPrivateKey(hex.EncodeToString(keyBytes)), nil
+}
+
+func keyMAC(derivedKey []byte, cipherText []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, derivedKey[16:32]...), cipherText...))
+	return sum[:]
+}
+
+func aesCTR(key []byte, iv []byte, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != aes.BlockSize {
+		return nil, ErrInvalidKeyFile
+	}
+
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyStore(t *testing.T) *KeyStore {
+	t.Helper()
+
+	return &KeyStore{Dir: t.TempDir(), ScryptN: LightScryptN, ScryptP: LightScryptP}
+}
+
+func TestKeyStoreNewAccount(t *testing.T) {
+	t.Parallel()
+
+	ks := newTestKeyStore(t)
+
+	address, err := ks.NewAccount("secret")
+	assert.NoError(t, err)
+
+	accounts, err := ks.Accounts()
+	assert.NoError(t, err)
+	assert.Equal(t, []*util.Address{address}, accounts)
+
+	// Reloading the keystore unlocks the key with the right password.
+	key, err := (&KeyStore{Dir: ks.Dir}).Unlock(address, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, address, util.PublicKeyToAddress(&key.PublicKey))
+
+	// A wrong password fails cleanly.
+	key, err = ks.Unlock(address, "wrong")
+	assert.ErrorIs(t, err, ErrDecrypt)
+	assert.Nil(t, key)
+
+	// Unknown accounts are not found.
+	_, err = ks.Unlock(util.BytesToAddress([]byte{0x01}), "secret")
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestKeyStoreImport(t *testing.T) {
+	t.Parallel()
+
+	ks := newTestKeyStore(t)
+	hexKey := "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"
+
+	address, err := ks.Import(hexKey, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", address.String())
+
+	key, err := ks.Unlock(address, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, util.HexToPrivateKey(hexKey).D, key.D)
+
+	_, err = ks.Import(hexKey, "other")
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	_, err = ks.Import("not hex", "secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+)
+
+// DefaultLevel is the level used when none is configured.
+const DefaultLevel = "info"
+
+// Levels beyond the slog ones. Trace is below debug, for the most detailed records, and
+// nothing is logged at the silent level.
+const (
+	LevelTrace  = slog.LevelDebug - 4
+	LevelSilent = slog.Level(math.MaxInt)
+)
+
+// verbosityLevels are the level names of the verbosities, by increasing verbosity.
+var verbosityLevels = []string{"silent", "error", "warn", "info", "debug", "trace"}
+
+// ParseLevel returns the slog level named trace, debug, info, warn, error or silent.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "silent":
+		return LevelSilent, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q : must be trace, debug, info, warn, error or silent", name)
+	}
+}
+
+// VerbosityLevel returns the name of the level of the verbosity, from 0 logging nothing
+// then errors, warnings, info, debug, up to 5 logging trace records too.
+func VerbosityLevel(verbosity int) (string, error) {
+	if verbosity < 0 || verbosity >= len(verbosityLevels) {
+		return "", fmt.Errorf("invalid verbosity %d : must be from 0 to %d", verbosity, len(verbosityLevels)-1)
+	}
+
+	return verbosityLevels[verbosity], nil
+}
+
+// New returns a logger writing text records of at least the given level to w.
+// A nil writer logs to stdout.
+func New(w io.Writer, level string) (*slog.Logger, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(l)
+
+	return NewWithLevel(w, levelVar), nil
+}
+
+// NewWithLevel returns a logger writing text records of at least the level of levelVar
+// to w, so that the level can be changed while logging. A nil writer logs to stdout.
+func NewWithLevel(w io.Writer, levelVar *slog.LevelVar) *slog.Logger {
+	if w == nil {
+		w = os.Stdout
+	}
+
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: levelVar, ReplaceAttr: replaceLevel}))
+}
+
+// replaceLevel names the trace level of the records, which slog would print as DEBUG-4.
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == LevelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]slog.Level{
+		"debug":  slog.LevelDebug,
+		"":       slog.LevelInfo,
+		"INFO":   slog.LevelInfo,
+		"warn":   slog.LevelWarn,
+		"error":  slog.LevelError,
+		"trace":  LevelTrace,
+		"silent": LevelSilent,
+	} {
+		level, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, level)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	log, err := New(&buf, "warn")
+	assert.NoError(t, err)
+
+	log.Info("Mined block", "number", 1)
+	log.Warn("Invalid block", "number", 2)
+
+	assert.NotContains(t, buf.String(), "Mined block")
+	assert.Contains(t, buf.String(), `level=WARN msg="Invalid block" number=2`)
+}
+
+func TestVerbosityLevel(t *testing.T) {
+	t.Parallel()
+
+	for verbosity, expected := range []string{"silent", "error", "warn", "info", "debug", "trace"} {
+		name, err := VerbosityLevel(verbosity)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, name)
+	}
+
+	for _, verbosity := range []int{-1, 6} {
+		_, err := VerbosityLevel(verbosity)
+		assert.Error(t, err)
+	}
+
+	// Trace records are named, and nothing is logged when silent.
+	var buf bytes.Buffer
+
+	log, err := New(&buf, "trace")
+	assert.NoError(t, err)
+
+	log.Log(context.Background(), LevelTrace, "Peer message", "peer", 1)
+	assert.Contains(t, buf.String(), `level=TRACE msg="Peer message" peer=1`)
+
+	buf.Reset()
+
+	log, err = New(&buf, "silent")
+	assert.NoError(t, err)
+
+	log.Error("Failed to close blockchain db")
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is the HTTP path the metrics are served on.
+const Path = "/metrics"
+
+const namespace = "compactchain"
+
+// shutdownTimeout is how long Stop waits for in-flight scrapes to finish.
+var shutdownTimeout = 5 * time.Second
+
+// Metrics are the Prometheus metrics of a node.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	BlockHeight   prometheus.Gauge
+	BlocksMined   prometheus.Counter
+	BlockInterval prometheus.Summary
+
+	server *http.Server
+}
+
+// Sources are sampled on every scrape.
+type Sources struct {
+	PeerCount func() int
+	TxPool    func() (pending int, queued int)
+}
+
+// New creates the metrics of a node in their own registry.
+func New(sources *Sources) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		BlockHeight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "block_height",
+			Help:      "Number of the head block of the chain.",
+		}),
+		BlocksMined: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_mined_total",
+			Help:      "Number of blocks mined by the node.",
+		}),
+		BlockInterval: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace: namespace,
+			Name:      "block_interval_seconds",
+			Help:      "Time between the timestamps of consecutive head blocks, the average block time is sum / count.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		m.BlockHeight,
+		m.BlocksMined,
+		m.BlockInterval,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "peers",
+			Help:      "Number of connected peers.",
+		}, func() float64 {
+			return float64(sources.PeerCount())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "txpool_pending",
+			Help:      "Number of txpool transactions ready to be mined.",
+		}, func() float64 {
+			pending, _ := sources.TxPool()
+			return float64(pending)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "txpool_queued",
+			Help:      "Number of txpool transactions waiting for a nonce gap to fill.",
+		}, func() float64 {
+			_, queued := sources.TxPool()
+			return float64(queued)
+		}),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Start serves the metrics on the given address.
+func (m *Metrics) Start(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{}))
+
+	// nolint : gosec
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	log.Println("Serving metrics on", addr+Path)
+
+	go func() {
+		// nolint : gosec
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			log.Fatalf("Error serving metrics: %s", err)
+		}
+	}()
+
+	m.server = srv
+}
+
+// Stop shuts down the metrics server, if started.
+func (m *Metrics) Stop() error {
+	if m.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return m.server.Shutdown(ctx)
+}
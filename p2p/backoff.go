@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"math/rand"
+	"time"
+)
+
+// minPeerBackoff is the delay before dialing an unreachable peer again for the first time.
+var minPeerBackoff = 500 * time.Millisecond
+
+// defaultMaxPeerBackoff is the maximum delay between two dials of an unreachable peer.
+var defaultMaxPeerBackoff = 30 * time.Second
+
+// backoff computes exponentially growing delays between attempts, capped at max.
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func newBackoff(min time.Duration, max time.Duration) *backoff {
+	if max <= 0 {
+		max = defaultMaxPeerBackoff
+	}
+
+	if min > max {
+		min = max
+	}
+
+	return &backoff{min: min, max: max}
+}
+
+// next returns the delay before the next attempt : min * 2^attempt capped at max, of
+// which the second half is random so that peers dialing each other don't stay in step.
+func (b *backoff) next() time.Duration {
+	delay := b.min
+	for i := 0; i < b.attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+
+	if delay > b.max {
+		delay = b.max
+	}
+
+	b.attempt++
+
+	half := delay / 2
+
+	// nolint : gosec
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// reset starts over from the minimum delay.
+func (b *backoff) reset() {
+	b.attempt = 0
+}
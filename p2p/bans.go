@@ -0,0 +1,148 @@
+package p2p
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultBanDuration is how long a misbehaving peer is banned when no duration is configured.
+const DefaultBanDuration = time.Hour
+
+var (
+	ErrMalformedBlock  = errors.New("malformed block")
+	ErrMalformedTx     = errors.New("malformed transaction")
+	ErrUnexpectedBlock = errors.New("block does not match the requested hash")
+)
+
+// Bans refuses the peers of the denylist, and the peers which sent invalid blocks or
+// malformed messages until their ban expires. The denylist accepts a host:port address
+// or a host for any port. Bans apply to the IP addresses of the peer, so that a peer
+// dialed by name is refused when it connects from its IP and an ephemeral port.
+type Bans struct {
+	mu       sync.Mutex
+	duration time.Duration
+	denylist map[string]bool
+	until    map[string]time.Time // Ban expiry by peer IP address
+	now      func() time.Time
+	lookupIP func(host string) ([]net.IP, error)
+
+	Logger *slog.Logger
+}
+
+// NewBans returns the bans of the peers for the given duration, DefaultBanDuration if
+// zero, along with the given denylist.
+func NewBans(duration time.Duration, denylist []string) *Bans {
+	b := &Bans{until: make(map[string]time.Time), now: time.Now, lookupIP: net.LookupIP, Logger: slog.Default()}
+	b.Configure(duration, denylist)
+
+	return b
+}
+
+// Configure replaces the ban duration and the denylist. Bans already in place keep
+// their expiry.
+func (b *Bans) Configure(duration time.Duration, denylist []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if duration <= 0 {
+		duration = DefaultBanDuration
+	}
+
+	b.duration = duration
+	b.denylist = make(map[string]bool, len(denylist))
+
+	for _, addr := range denylist {
+		b.denylist[addr] = true
+	}
+}
+
+// Ban refuses the peer at the address for the ban duration, on any port of its IP addresses.
+func (b *Bans) Ban(addr string, reason error) {
+	ips := b.ips(addr)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Logger.Warn("Banning peer", "addr", addr, "duration", b.duration, "reason", reason)
+
+	for _, ip := range ips {
+		b.until[ip] = b.now().Add(b.duration)
+	}
+}
+
+// ips returns the IP addresses of the host of the address, resolving host names. A host
+// which can't be resolved is returned as is.
+func (b *Bans) ips(addr string) []string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}
+	}
+
+	resolved, err := b.lookupIP(host)
+	if err != nil || len(resolved) == 0 {
+		return []string{host}
+	}
+
+	ips := make([]string, 0, len(resolved))
+	for _, ip := range resolved {
+		ips = append(ips, ip.String())
+	}
+
+	return ips
+}
+
+// Denylisted reports whether the address, or its host, is on the denylist.
+func (b *Bans) Denylisted(addr string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.denylist[addr] {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+
+	return err == nil && b.denylist[host]
+}
+
+// BannedFor returns how long the peer at the address stays banned, zero if it isn't. It
+// is the longest ban of its IP addresses.
+func (b *Bans) BannedFor(addr string) time.Duration {
+	ips := b.ips(addr)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var longest time.Duration
+
+	for _, ip := range ips {
+		until, ok := b.until[ip]
+		if !ok {
+			continue
+		}
+
+		remaining := until.Sub(b.now())
+		if remaining <= 0 {
+			delete(b.until, ip)
+			continue
+		}
+
+		if remaining > longest {
+			longest = remaining
+		}
+	}
+
+	return longest
+}
+
+// Banned reports whether the peer at the address is refused, being denylisted or banned.
+func (b *Bans) Banned(addr string) bool {
+	return b.Denylisted(addr) || b.BannedFor(addr) > 0
+}
@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/protos"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBans(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	b := NewBans(time.Minute, []string{"10.0.0.1", "localhost:6061"})
+	b.now = func() time.Time { return now }
+
+	// Denylisted hosts are refused on any port, denylisted addresses on their port only.
+	assert.True(t, b.Banned("10.0.0.1:6060"))
+	assert.True(t, b.Banned("localhost:6061"))
+	assert.False(t, b.Banned("localhost:6062"))
+
+	b.Ban("localhost:6062", errors.New("invalid block"))
+	assert.True(t, b.Banned("localhost:6062"))
+	assert.Equal(t, time.Minute, b.BannedFor("localhost:6062"))
+	assert.False(t, b.Denylisted("localhost:6062"))
+
+	now = now.Add(59 * time.Second)
+	assert.Equal(t, time.Second, b.BannedFor("localhost:6062"))
+
+	// The ban expires after its duration.
+	now = now.Add(time.Second)
+	assert.False(t, b.Banned("localhost:6062"))
+
+	// Zero bans for the default duration, the new denylist replaces the old one.
+	b.Configure(0, nil)
+	b.Ban("localhost:6062", errors.New("invalid block"))
+	assert.Equal(t, DefaultBanDuration, b.BannedFor("localhost:6062"))
+	assert.False(t, b.Banned("10.0.0.1:6060"))
+}
+
+func TestBansByIP(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	b := NewBans(time.Minute, nil)
+	b.now = func() time.Time { return now }
+	b.lookupIP = func(host string) ([]net.IP, error) {
+		if host == "node.example" {
+			return []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2")}, nil
+		}
+
+		return nil, errors.New("no such host")
+	}
+
+	// A peer banned by the name it is dialed with is refused when it connects from its IP
+	// and an ephemeral port, whichever of its addresses it connects from.
+	b.Ban("node.example:6060", errors.New("invalid block"))
+	assert.True(t, b.Banned("10.0.0.2:51234"))
+	assert.True(t, b.Banned("[fd00::2]:51234"))
+	assert.False(t, b.Banned("10.0.0.3:6060"))
+
+	// A peer banned from the address of its connection isn't dialed either.
+	b.Ban("10.0.0.3:51234", errors.New("malformed message"))
+	assert.Equal(t, time.Minute, b.BannedFor("10.0.0.3:6060"))
+
+	// Hosts which don't resolve are banned by name.
+	b.Ban("unknown.example:6060", errors.New("invalid block"))
+	assert.True(t, b.Banned("unknown.example:6061"))
+}
+
+func TestDenylistedPeerRefused(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := fmt.Sprintf(":%d", lis.Addr().(*net.TCPAddr).Port)
+	// nolint : errcheck
+	lis.Close()
+
+	status := NewStatus(1, util.HashData([]byte("genesis")))
+
+	srv := startTestServer(t, port, status, 0)
+	defer srv.Stop()
+
+	srv.Downloader.Bans.Configure(0, []string{"127.0.0.1"})
+
+	handshake := func(client protos.P2PClient) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := client.Handshake(ctx, &protos.HandshakeRequest{
+			ProtocolVersion: status.ProtocolVersion,
+			NetworkId:       status.NetworkID,
+			GenesisHash:     status.GenesisHash.Bytes(),
+		})
+
+		return err
+	}
+
+	conn, client := ConnectToGRPCServer("127.0.0.1" + port)
+	// nolint : errcheck
+	defer conn.Close()
+
+	err = handshake(client)
+	assert.True(t, isUnreachable(err), err)
+	assert.Empty(t, srv.PeerInfos())
+
+	// The connection is accepted once the host leaves the denylist.
+	srv.Downloader.Bans.Configure(0, nil)
+
+	assert.Eventually(t, func() bool { return handshake(client) == nil }, 5*time.Second, 50*time.Millisecond)
+}
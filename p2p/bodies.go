@@ -0,0 +1,84 @@
+package p2p
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// bodyRequestTimeout is how long a requested block body is waited for, before the block
+// can be requested from another peer.
+var bodyRequestTimeout = 5 * time.Second
+
+// blockSourcesSize is the number of received blocks whose sending peer is remembered.
+var blockSourcesSize = 1024
+
+// bodyRequests tracks the block bodies requested from the peers. Peers only announce the
+// hash of their head block, and a block announced by several peers is only downloaded
+// from the first one, unless it doesn't get imported within bodyRequestTimeout.
+type bodyRequests struct {
+	mu       sync.Mutex
+	pending  map[string]time.Time // Request time by block hash
+	received atomic.Uint64        // Block bodies received from peers
+	sources  *lru.Cache           // Address of the sending peer by block hash
+	now      func() time.Time
+}
+
+func newBodyRequests() *bodyRequests {
+	return &bodyRequests{pending: make(map[string]time.Time), sources: lru.New(blockSourcesSize), now: time.Now}
+}
+
+// claim reports whether the block body of the hash should be requested, which is the case
+// unless another request for it is pending.
+func (br *bodyRequests) claim(hash string) bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	now := br.now()
+
+	for h, requested := range br.pending {
+		if now.Sub(requested) >= bodyRequestTimeout {
+			delete(br.pending, h)
+		}
+	}
+
+	if _, ok := br.pending[hash]; ok {
+		return false
+	}
+
+	br.pending[hash] = now
+
+	return true
+}
+
+// release lets the block body of the hash be requested again, after a failed request.
+func (br *bodyRequests) release(hash string) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	delete(br.pending, hash)
+}
+
+// receive counts the block body of the hash as received from the peer at the address.
+func (br *bodyRequests) receive(hash string, addr string) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	br.received.Add(1)
+	br.sources.Add(hash, addr)
+}
+
+// source returns the address of the peer the block body of the hash was received from.
+func (br *bodyRequests) source(hash string) (string, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	addr, ok := br.sources.Get(hash)
+	if !ok {
+		return "", false
+	}
+
+	return addr.(string), true
+}
@@ -0,0 +1,30 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyRequests(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	br := newBodyRequests()
+	br.now = func() time.Time { return now }
+
+	assert.True(t, br.claim("0x01"))
+	assert.False(t, br.claim("0x01"))
+	assert.True(t, br.claim("0x02"))
+
+	// A failed request lets another peer be asked.
+	br.release("0x02")
+	assert.True(t, br.claim("0x02"))
+
+	// So does a body which wasn't imported in time.
+	now = now.Add(bodyRequestTimeout)
+	assert.True(t, br.claim("0x01"))
+	assert.Len(t, br.pending, 1)
+}
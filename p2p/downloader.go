@@ -1,141 +1,657 @@
 package p2p
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"math/big"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/protos"
 	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+// maxBlocksPerRequest is the maximum number of blocks requested from a peer at once.
+var maxBlocksPerRequest uint64 = 50
+
+// maxForkSearchDepth is the maximum number of blocks searched for a common ancestor with a peer.
+var maxForkSearchDepth uint64 = 50
+
 type Downloader struct {
-	Peers []*Peer
-	Self  string
+	Peers      []*Peer
+	Self       string
+	Status     *Status
+	MaxBackoff time.Duration // Maximum delay between two dials of an unreachable peer
+
+	// Limits of the blocks received from the peers, the types defaults if zero.
+	MaxBlockBytes int
+	MaxBlockTxs   int
+
+	// Bans refuses the denylisted peers and the misbehaving ones.
+	Bans *Bans
 
 	TxpoolCh     chan *types.Transaction
 	BlockCh      chan *types.Block
 	BlockchainDB *dbstore.BlockchainDB
+	Logger       *slog.Logger
+
+	mu       sync.RWMutex
+	started  bool
+	bodies   *bodyRequests
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
 type Peer struct {
-	Addr        string
-	ClientConn  *grpc.ClientConn
-	P2PClient   protos.P2PClient
-	LatestBlock *types.Block
+	Addr       string
+	ClientConn *grpc.ClientConn
+	P2PClient  protos.P2PClient
+
+	attempts  atomic.Uint64 // Failed dials since the peer was last connected
+	connected atomic.Bool
+	height    atomic.Uint64 // Number of the latest block of the peer
+	version   atomic.Uint64 // Protocol version of the peer
+
+	misbehaved chan error // Reason to ban the peer, once it sent an invalid block or a malformed message
+	logger     *slog.Logger
+
+	quit     chan struct{} // Closed once the peer is removed or the downloader stopped
+	stopOnce sync.Once
 }
 
-func NewDownloader(self string, initPeers []string, txpoolCh chan *types.Transaction, blockCh chan *types.Block, blockchainDB *dbstore.BlockchainDB) *Downloader {
+// newPeer returns a peer with a connection to the given address, logging to logger.
+func newPeer(addr string, logger *slog.Logger) *Peer {
+	conn, c := ConnectToGRPCServer(addr)
+
+	return &Peer{
+		Addr:       addr,
+		ClientConn: conn,
+		P2PClient:  c,
+		misbehaved: make(chan error, 1),
+		logger:     logger,
+		quit:       make(chan struct{}),
+	}
+}
+
+// stop ends the loops of the peer and closes its connection.
+func (p *Peer) stop() {
+	p.stopOnce.Do(func() {
+		close(p.quit)
+
+		// nolint : errcheck
+		p.ClientConn.Close()
+	})
+}
+
+// misbehave ends the sync of the peer and bans it for the reason. Only the first reason
+// is kept until the downloader handles it.
+func (p *Peer) misbehave(reason error) {
+	select {
+	case p.misbehaved <- reason:
+	default:
+	}
+}
+
+// stopped reports whether the peer got removed or the downloader stopped.
+func (p *Peer) stopped() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewDownloader returns the downloader of the initial peers, logging to logger or else
+// to the default logger.
+func NewDownloader(self string, initPeers []string, status *Status, maxBackoff time.Duration, txpoolCh chan *types.Transaction, blockCh chan *types.Block, blockchainDB *dbstore.BlockchainDB, logger *slog.Logger) *Downloader {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	downloader := &Downloader{
 		TxpoolCh:     txpoolCh,
 		BlockCh:      blockCh,
 		Self:         self,
+		Status:       status,
+		MaxBackoff:   maxBackoff,
+		Bans:         NewBans(0, nil),
 		BlockchainDB: blockchainDB,
+		Logger:       logger,
+		bodies:       newBodyRequests(),
+		quit:         make(chan struct{}),
 	}
 
+	downloader.Bans.Logger = logger
+
 	for _, peer := range initPeers {
 		if peer == downloader.Self {
 			continue
 		}
 
-		conn, c := ConnectToGRPCServer(peer)
-		downloader.Peers = append(downloader.Peers, &Peer{
-			Addr:       peer,
-			ClientConn: conn,
-			P2PClient:  c,
-		})
+		downloader.Peers = append(downloader.Peers, newPeer(peer, logger))
 	}
 
 	return downloader
 }
 
 func (d *Downloader) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.started = true
+
 	for _, peer := range d.Peers {
-		go peer.PeerBlocksLoop(d.BlockCh, *d.BlockchainDB)
-		go peer.PeerTxpoolLoop(d.TxpoolCh)
+		go d.runPeer(peer)
+	}
+}
+
+// SetPeers replaces the peers with the given addresses. New peers are dialed and removed
+// ones disconnected, while the peers in both lists are left untouched.
+func (d *Downloader) SetPeers(addrs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.quit:
+		return
+	default:
+	}
+
+	wanted := make(map[string]bool)
+
+	for _, addr := range addrs {
+		if addr != d.Self {
+			wanted[addr] = true
+		}
+	}
+
+	peers := []*Peer{}
+
+	for _, peer := range d.Peers {
+		if !wanted[peer.Addr] {
+			fmt.Println("Removing peer", peer.Addr)
+			peer.stop()
+
+			continue
+		}
+
+		peers = append(peers, peer)
+		delete(wanted, peer.Addr)
+	}
+
+	for _, addr := range addrs {
+		if !wanted[addr] {
+			continue
+		}
+
+		fmt.Println("Adding peer", addr)
+
+		peer := newPeer(addr, d.Logger)
+		peers = append(peers, peer)
+		delete(wanted, addr)
+
+		if d.started {
+			go d.runPeer(peer)
+		}
+	}
+
+	d.Peers = peers
+}
+
+// runPeer dials the peer until the handshake succeeds, waiting an exponentially growing
+// delay capped at MaxBackoff between attempts, and syncs from it. A peer which becomes
+// unreachable is dialed again the same way, while a peer on a different network or on
+// the denylist is dropped. A banned peer is only dialed again once its ban expires.
+func (d *Downloader) runPeer(peer *Peer) {
+	b := newBackoff(minPeerBackoff, d.MaxBackoff)
+
+	for {
+		if d.Bans.Denylisted(peer.Addr) {
+			d.Logger.Info("Dropping peer", "addr", peer.Addr, "reason", "denylisted")
+			d.dropPeer(peer)
+
+			return
+		}
+
+		if remaining := d.Bans.BannedFor(peer.Addr); remaining > 0 {
+			d.Logger.Info("Peer banned", "addr", peer.Addr, "retryIn", remaining)
+
+			if !sleep(peer.quit, remaining) {
+				return
+			}
+
+			continue
+		}
+
+		err := peer.handshake(d.Status, localHeight(d.BlockchainDB))
+
+		switch {
+		case peer.stopped():
+			return
+		case isUnreachable(err):
+			attempt := peer.attempts.Add(1)
+			delay := b.next()
+
+			d.Logger.Info("Peer unreachable", "addr", peer.Addr, "attempt", attempt, "retryIn", delay)
+
+			if !sleep(peer.quit, delay) {
+				return
+			}
+
+			// Dial right away instead of waiting for the backoff of the connection.
+			peer.ClientConn.ResetConnectBackoff()
+
+			continue
+		case err != nil:
+			d.Logger.Warn("Dropping peer", "addr", peer.Addr, "reason", status.Convert(err).Message())
+			d.dropPeer(peer)
+
+			return
+		}
+
+		d.Logger.Info("Connected to peer", "addr", peer.Addr)
+
+		b.reset()
+		peer.attempts.Store(0)
+		peer.connected.Store(true)
+
+		stopped := !d.syncPeer(peer)
+
+		peer.connected.Store(false)
+
+		if stopped {
+			return
+		}
+
+		if d.Bans.BannedFor(peer.Addr) > 0 {
+			d.Logger.Info("Disconnected from banned peer", "addr", peer.Addr)
+			continue
+		}
+
+		d.Logger.Info("Lost connection to peer", "addr", peer.Addr)
+	}
+}
+
+// syncPeer runs the sync loops of the peer until one of them finds the peer unreachable,
+// or the peer misbehaves and gets banned. It returns false if the peer got removed or
+// the downloader stopped meanwhile.
+func (d *Downloader) syncPeer(peer *Peer) bool {
+	stop := make(chan struct{})
+	lost := make(chan struct{}, 2)
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		peer.PeerBlocksLoop(d.BlockCh, *d.BlockchainDB, d.bodies, d.decodeBlock, stop)
+		lost <- struct{}{}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		peer.PeerTxpoolLoop(d.TxpoolCh, stop)
+		lost <- struct{}{}
+	}()
+
+	running := true
+
+	select {
+	case <-peer.quit:
+		running = false
+	case <-lost:
+	case reason := <-peer.misbehaved:
+		d.Bans.Ban(peer.Addr, reason)
+	}
+
+	close(stop)
+	wg.Wait()
+
+	return running
+}
+
+// dropPeer closes the connection to the peer and removes it from the peer list.
+func (d *Downloader) dropPeer(peer *Peer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, p := range d.Peers {
+		if p == peer {
+			d.Peers = append(d.Peers[:i:i], d.Peers[i+1:]...)
+			peer.stop()
+
+			return
+		}
+	}
+}
+
+// Stop stops the peer loops and closes the peer connections. It is safe to call Stop more than once.
+func (d *Downloader) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.quit)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		for _, peer := range d.Peers {
+			peer.stop()
+		}
+	})
+}
+
+// sleep waits for the given duration and returns false if the quit channel got closed meanwhile.
+func sleep(quit chan struct{}, d time.Duration) bool {
+	select {
+	case <-quit:
+		return false
+	case <-time.After(d):
+		return true
 	}
 }
 
 func (d *Downloader) GetPeers() []*Peer {
-	return d.Peers
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]*Peer{}, d.Peers...)
+}
+
+// ConnectedPeers returns the number of peers the handshake succeeded with and which are
+// still reachable.
+func (d *Downloader) ConnectedPeers() int {
+	count := 0
+
+	for _, peer := range d.GetPeers() {
+		if peer.connected.Load() {
+			count++
+		}
+	}
+
+	return count
+}
+
+// BlockBodies returns the number of block bodies received from the peers.
+func (d *Downloader) BlockBodies() uint64 {
+	return d.bodies.received.Load()
+}
+
+// ReportInvalidBlock bans the peer the block of the hash was received from, for the
+// reason the block is invalid. Blocks of unknown origin are ignored.
+func (d *Downloader) ReportInvalidBlock(hash *util.Hash, reason error) {
+	addr, ok := d.bodies.source(hash.String())
+	if !ok {
+		return
+	}
+
+	for _, peer := range d.GetPeers() {
+		if peer.Addr == addr {
+			peer.misbehave(fmt.Errorf("invalid block %s : %w", hash.String(), reason))
+			return
+		}
+	}
+
+	// The peer got removed meanwhile, refuse it if it gets added back.
+	d.Bans.Ban(addr, fmt.Errorf("invalid block %s : %w", hash.String(), reason))
+}
+
+// decodeBlock decodes a block received from a peer within the block limits.
+func (d *Downloader) decodeBlock(data []byte) (*types.Block, error) {
+	maxBytes, maxTxs := d.MaxBlockBytes, d.MaxBlockTxs
+
+	if maxBytes <= 0 {
+		maxBytes = types.DefaultMaxBlockBytes
+	}
+
+	if maxTxs <= 0 {
+		maxTxs = types.DefaultMaxBlockTxs
+	}
+
+	return types.DecodeBlock(data, maxBytes, maxTxs)
 }
 
-func (p *Peer) PeerBlocksLoop(blockCh chan *types.Block, blockchainDB dbstore.BlockchainDB) {
+// PeerBlocksLoop downloads the blocks of the peer which the local chain lacks. The peer
+// only announces the hash of its head block, the bodies are requested for the blocks the
+// node doesn't have and no other peer is being asked for. It returns once the quit
+// channel is closed, the peer becomes unreachable or requires a new handshake, or it
+// misbehaves by sending a block which is malformed or not the requested one.
+func (p *Peer) PeerBlocksLoop(blockCh chan *types.Block, blockchainDB dbstore.BlockchainDB, bodies *bodyRequests, decode func([]byte) (*types.Block, error), quit chan struct{}) {
+	// sendBlock hands a block to core.Blockchain unless the downloader is stopped.
+	sendBlock := func(block *types.Block) bool {
+		select {
+		case blockCh <- block:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+
 	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
 		localLatest, err := blockchainDB.GetLatestBlock()
 		if err != nil {
 			fmt.Println("Error Fetching Latest Block in Downloader", err)
-			time.Sleep(500 * time.Millisecond)
+
+			if !sleep(quit, 500*time.Millisecond) {
+				return
+			}
+
+			continue
+		}
+
+		r, err := p.P2PClient.LatestBlock(context.Background(), &protos.LatestBlockRequest{HashOnly: true})
+		if isDisconnected(err) {
+			return
 		}
 
-		r, err := p.P2PClient.LatestBlock(context.Background(), &protos.LatestBlockRequest{})
 		if err != nil {
-			time.Sleep(5000 * time.Millisecond)
+			if !sleep(quit, 5000*time.Millisecond) {
+				return
+			}
+
 			continue
 		}
 
-		rBlock := types.DeserializeBlock(r.EncodedBlock)
+		p.height.Store(r.Height)
 
-		// nolint : nestif
-		if localLatest.Number.Int64() >= rBlock.Number.Int64() {
-			if localLatest.Number.Int64() == rBlock.Number.Int64() && localLatest.DeriveHash().String() != rBlock.DeriveHash().String() {
-				// send block to core.Blockchain
-				blockCh <- rBlock
-			} else {
-				time.Sleep(500 * time.Millisecond)
-				continue
+		// Nothing to download if the peer is behind or announces a known block.
+		if localLatest.Number.Uint64() > r.Height || blockchainDB.HasBlock(util.ByteToHash(r.Hash)) {
+			if !sleep(quit, 500*time.Millisecond) {
+				return
 			}
-		} else if rBlock.Number.Int64()-localLatest.Number.Int64() > 1 {
-			endHeight := uint64(rBlock.Number.Int64())
-			if rBlock.Number.Int64()-localLatest.Number.Int64() > 50 {
-				endHeight = uint64(localLatest.Number.Int64() + 50)
+
+			continue
+		}
+
+		// Download from the last block both chains share, so that blocks of a competing
+		// branch reach core.Blockchain along with their ancestors.
+		ancestor, err := p.commonAncestor(blockchainDB, localLatest)
+		if err != nil {
+			if !sleep(quit, 500*time.Millisecond) {
+				return
 			}
 
-			time.Sleep(1 * time.Second)
-			rBlocks, err := p.P2PClient.BlocksInRange(context.Background(), &protos.BlocksInRangeRequest{
-				StartHeight: uint64(localLatest.Number.Int64() + 1),
-				EndHeight:   endHeight,
-			})
-			if err != nil {
-				time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		endHeight := r.Height
+		if endHeight-ancestor > maxBlocksPerRequest {
+			endHeight = ancestor + maxBlocksPerRequest
+		}
+
+		rHashes, err := p.P2PClient.BlocksInRange(context.Background(), &protos.BlocksInRangeRequest{
+			StartHeight: ancestor + 1,
+			EndHeight:   endHeight,
+			HashesOnly:  true,
+		})
+		if err != nil {
+			if !sleep(quit, 500*time.Millisecond) {
+				return
+			}
+
+			continue
+		}
+
+		// Request the unknown blocks in order, up to the first one another peer is asked for.
+		var wanted [][]byte
+
+		for _, hash := range rHashes.Hashes {
+			if blockchainDB.HasBlock(util.ByteToHash(hash)) {
 				continue
 			}
 
-			for _, block := range rBlocks.EncodedBlocks {
-				blockCh <- types.DeserializeBlock(block)
+			if !bodies.claim(util.ByteToHash(hash).String()) {
+				break
+			}
+
+			wanted = append(wanted, hash)
+		}
+
+		if len(wanted) == 0 {
+			if !sleep(quit, 100*time.Millisecond) {
+				return
 			}
 
-			time.Sleep(100 * time.Millisecond)
 			continue
-		} else {
-			// send block to core.Blockchain
-			blockCh <- rBlock
 		}
 
-		p.LatestBlock = rBlock
+		rBlocks, err := p.P2PClient.BlocksByHash(context.Background(), &protos.BlocksByHashRequest{Hashes: wanted})
+		if err != nil {
+			rBlocks = &protos.BlocksByHashResponse{}
+		}
+
+		for i, hash := range wanted {
+			if i >= len(rBlocks.EncodedBlocks) {
+				bodies.release(util.ByteToHash(hash).String())
+				continue
+			}
+
+			block, decodeErr := decode(rBlocks.EncodedBlocks[i])
+
+			switch {
+			case decodeErr != nil:
+				decodeErr = fmt.Errorf("%w : %s", ErrMalformedBlock, decodeErr)
+			case !bytes.Equal(block.DeriveHash().Bytes(), hash):
+				decodeErr = fmt.Errorf("%w : %s", ErrUnexpectedBlock, util.ByteToHash(hash).String())
+			}
+
+			if decodeErr != nil {
+				fmt.Println("Refusing block from peer", p.Addr, decodeErr)
+
+				// Let other peers be asked for the rest.
+				for _, hash := range wanted[i:] {
+					bodies.release(util.ByteToHash(hash).String())
+				}
+
+				p.misbehave(decodeErr)
+
+				return
+			}
+
+			bodies.receive(util.ByteToHash(hash).String(), p.Addr)
+
+			if !sendBlock(block) {
+				return
+			}
+		}
+
+		if isDisconnected(err) {
+			return
+		}
 
-		time.Sleep(100 * time.Millisecond)
+		if !sleep(quit, 100*time.Millisecond) {
+			return
+		}
 	}
 }
 
-func (p *Peer) PeerTxpoolLoop(txpoolCh chan *types.Transaction) {
+// commonAncestor returns the height of the highest local block the peer has on its
+// chain as well, searching at most maxForkSearchDepth blocks below the local head.
+// Only the hashes of the blocks of the peer are downloaded.
+func (p *Peer) commonAncestor(blockchainDB dbstore.BlockchainDB, localLatest *types.Block) (uint64, error) {
+	height := localLatest.Number.Uint64()
+
+	for depth := uint64(0); depth < maxForkSearchDepth && height > 0; depth++ {
+		local, err := blockchainDB.GetBlockByNumber(new(big.Int).SetUint64(height))
+		if err != nil {
+			return 0, err
+		}
+
+		r, err := p.P2PClient.BlocksInRange(context.Background(), &protos.BlocksInRangeRequest{
+			StartHeight: height,
+			EndHeight:   height,
+			HashesOnly:  true,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if len(r.Hashes) == 1 && bytes.Equal(r.Hashes[0], local.DeriveHash().Bytes()) {
+			return height, nil
+		}
+
+		height--
+	}
+
+	return height, nil
+}
+
+// PeerTxpoolLoop forwards the pending transactions of the peer to the txpool. It returns
+// once the quit channel is closed or the peer becomes unreachable or requires a new handshake,
+// and bans the peer if it sends a malformed transaction.
+func (p *Peer) PeerTxpoolLoop(txpoolCh chan *types.Transaction, quit chan struct{}) {
 	for {
 		rTxpool, err := p.P2PClient.TxPoolPending(context.Background(), &protos.TxpoolPendingRequest{})
+		if isDisconnected(err) {
+			return
+		}
+
 		if err != nil {
-			time.Sleep(5000 * time.Millisecond)
+			if !sleep(quit, 5000*time.Millisecond) {
+				return
+			}
+
 			continue
 		}
 
-		for _, tx := range rTxpool.EncodedTxs {
+		for _, encodedTx := range rTxpool.EncodedTxs {
+			tx, err := types.DecodeTransaction(encodedTx)
+			if err != nil {
+				err = fmt.Errorf("%w : %s", ErrMalformedTx, err)
+				p.logger.Warn("Refusing transaction from peer", "addr", p.Addr, "reason", err)
+				p.misbehave(err)
+
+				return
+			}
+
 			// send tx to txpool.Txpool
-			txpoolCh <- types.DeserializeTransaction(tx)
+			select {
+			case txpoolCh <- tx:
+			case <-quit:
+				return
+			}
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		if !sleep(quit, 100*time.Millisecond) {
+			return
+		}
 	}
 }
 
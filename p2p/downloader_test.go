@@ -0,0 +1,214 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/protos"
+	"github.com/0xsharma/compact-chain/txpool"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(100*time.Millisecond, time.Second)
+
+	// 100ms, 200ms, 400ms, 800ms, then capped at 1s, each with a random second half.
+	for _, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		expected *= time.Millisecond
+		delay := b.next()
+
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+
+	b.reset()
+	assert.LessOrEqual(t, b.next(), 100*time.Millisecond)
+
+	// The minimum never exceeds the maximum.
+	b = newBackoff(time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, b.next(), 10*time.Millisecond)
+}
+
+func newTestBlockchainDB(t *testing.T) *dbstore.BlockchainDB {
+	t.Helper()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// nolint : errcheck
+		db.Close()
+	})
+
+	return dbstore.NewBlockchainDB(db)
+}
+
+func startTestServer(t *testing.T, port string, status *Status, maxPeers int) *P2PServer {
+	t.Helper()
+
+	pool := txpool.NewTxPool(&config.Config{}, nil, make(chan *types.Transaction))
+	srv := NewServer(port, nil, status, 0, maxPeers, nil, nil, newTestBlockchainDB(t), pool, make(chan *types.Transaction), make(chan *types.Block), nil)
+
+	go srv.StartServer()
+
+	return srv
+}
+
+func TestPeerReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	// Reserve a free port, the peer starts listening on it later.
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := fmt.Sprintf(":%d", lis.Addr().(*net.TCPAddr).Port)
+	// nolint : errcheck
+	lis.Close()
+
+	status := NewStatus(1, util.HashData([]byte("genesis")))
+
+	d := NewDownloader("localhost:0", []string{"localhost" + port}, status, 100*time.Millisecond, make(chan *types.Transaction, 100), make(chan *types.Block, 100), newTestBlockchainDB(t), nil)
+	d.Start()
+
+	defer d.Stop()
+
+	peer := d.GetPeers()[0]
+
+	// The peer is dialed again while it is down.
+	assert.Eventually(t, func() bool { return peer.attempts.Load() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, peer.connected.Load())
+
+	srv := startTestServer(t, port, status, 0)
+
+	assert.Eventually(t, func() bool { return peer.connected.Load() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), peer.attempts.Load())
+
+	// A lost peer enters the backoff loop again and reconnects once it is back.
+	srv.Stop()
+
+	assert.Eventually(t, func() bool { return !peer.connected.Load() && peer.attempts.Load() >= 1 }, 5*time.Second, 10*time.Millisecond)
+
+	srv = startTestServer(t, port, status, 0)
+	defer srv.Stop()
+
+	assert.Eventually(t, func() bool { return peer.connected.Load() }, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, d.GetPeers(), 1)
+}
+
+// garbageTxPeer is a peer on the network of status serving malformed pending transactions.
+type garbageTxPeer struct {
+	status *Status
+
+	protos.UnimplementedP2PServer
+}
+
+func (p *garbageTxPeer) Handshake(ctx context.Context, in *protos.HandshakeRequest) (*protos.HandshakeResponse, error) {
+	return &protos.HandshakeResponse{
+		ProtocolVersion: p.status.ProtocolVersion,
+		NetworkId:       p.status.NetworkID,
+		GenesisHash:     p.status.GenesisHash.Bytes(),
+	}, nil
+}
+
+func (p *garbageTxPeer) TxPoolPending(ctx context.Context, in *protos.TxpoolPendingRequest) (*protos.TxpoolPendingResponse, error) {
+	return &protos.TxpoolPendingResponse{EncodedTxs: [][]byte{[]byte("garbage")}}, nil
+}
+
+func TestBanPeerSendingMalformedTx(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := NewStatus(1, util.HashData([]byte("genesis")))
+
+	srv := grpc.NewServer()
+	protos.RegisterP2PServer(srv, &garbageTxPeer{status: status})
+
+	go srv.Serve(lis) // nolint : errcheck
+
+	defer srv.Stop()
+
+	addr := lis.Addr().String()
+	txpoolCh := make(chan *types.Transaction, 100)
+
+	d := NewDownloader("localhost:0", []string{addr}, status, 100*time.Millisecond, txpoolCh, make(chan *types.Block, 100), newTestBlockchainDB(t), nil)
+	d.Start()
+
+	defer d.Stop()
+
+	// The peer gets banned rather than crashing the node, and nothing reaches the txpool.
+	assert.Eventually(t, func() bool { return d.Bans.Banned(addr) }, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, txpoolCh)
+}
+
+func TestHandshakeRequired(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := fmt.Sprintf(":%d", lis.Addr().(*net.TCPAddr).Port)
+	// nolint : errcheck
+	lis.Close()
+
+	status := NewStatus(1, util.HashData([]byte("genesis")))
+
+	srv := startTestServer(t, port, status, 0)
+	defer srv.Stop()
+
+	conn, client := ConnectToGRPCServer("localhost" + port)
+	// nolint : errcheck
+	defer conn.Close()
+
+	handshake := func(genesis *util.Hash) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := client.Handshake(ctx, &protos.HandshakeRequest{
+			ProtocolVersion: status.ProtocolVersion,
+			NetworkId:       status.NetworkID,
+			GenesisHash:     genesis.Bytes(),
+		})
+
+		return err
+	}
+
+	pending := func() error {
+		_, err := client.TxPoolPending(context.Background(), &protos.TxpoolPendingRequest{})
+		return err
+	}
+
+	// A peer with another genesis dialing in is refused, and can't sync from the node.
+	err = handshake(util.HashData([]byte("other genesis")))
+	assert.Equal(t, codes.FailedPrecondition, grpcstatus.Code(err), err)
+	assert.Empty(t, srv.PeerInfos())
+
+	err = pending()
+	assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(err), err)
+	assert.True(t, isDisconnected(err))
+
+	// It can once it handshakes with the genesis of the node over its connection.
+	assert.NoError(t, handshake(status.GenesisHash))
+	assert.NoError(t, pending())
+}
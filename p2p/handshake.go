@@ -0,0 +1,170 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/protos"
+	"github.com/0xsharma/compact-chain/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion is the version of the p2p protocol spoken by this node. Version 2 only
+// announces block hashes, with the bodies requested by hash. Version 3 serializes blocks
+// and transactions with the canonical encoding. Version 4 hashes the transactions signed
+// for a chain id, and the blocks after LegacyHashBlock, from their canonical encoding.
+const ProtocolVersion uint64 = 4
+
+// handshakeTimeout bounds a single handshake attempt, including dialing the peer.
+var handshakeTimeout = 10 * time.Second
+
+var (
+	ErrProtocolVersionMismatch = errors.New("protocol version mismatch")
+	ErrNetworkIDMismatch       = errors.New("network id mismatch")
+	ErrGenesisMismatch         = errors.New("genesis hash mismatch")
+
+	// ErrHandshakeRequired is returned to peers calling the node over a connection they
+	// didn't handshake on.
+	ErrHandshakeRequired = errors.New("handshake required")
+)
+
+// Status identifies the network of a node. Peers are only synced with if their status matches.
+type Status struct {
+	ProtocolVersion uint64
+	NetworkID       uint64
+	GenesisHash     *util.Hash
+}
+
+// NewStatus returns the status of a node on the given network and genesis block.
+func NewStatus(networkID uint64, genesisHash *util.Hash) *Status {
+	return &Status{
+		ProtocolVersion: ProtocolVersion,
+		NetworkID:       networkID,
+		GenesisHash:     genesisHash,
+	}
+}
+
+// check returns the reason a peer with the given status can't be peered with, if any.
+func (s *Status) check(protocolVersion uint64, networkID uint64, genesisHash []byte) error {
+	if protocolVersion != s.ProtocolVersion {
+		return fmt.Errorf("%w : local %d, remote %d", ErrProtocolVersionMismatch, s.ProtocolVersion, protocolVersion)
+	}
+
+	if networkID != s.NetworkID {
+		return fmt.Errorf("%w : local %d, remote %d", ErrNetworkIDMismatch, s.NetworkID, networkID)
+	}
+
+	if !bytes.Equal(genesisHash, s.GenesisHash.Bytes()) {
+		return fmt.Errorf("%w : local %s, remote %s", ErrGenesisMismatch, s.GenesisHash.String(), util.ByteToHash(genesisHash).String())
+	}
+
+	return nil
+}
+
+// Handshake answers with the local status and height, refusing peers on a different
+// network. The peers it accepts are listed as inbound peers, with the height they
+// advertised.
+func (p2psrv *P2PServer) Handshake(ctx context.Context, in *protos.HandshakeRequest) (*protos.HandshakeResponse, error) {
+	p, ok := peer.FromContext(ctx)
+
+	if err := p2psrv.Status.check(in.ProtocolVersion, in.NetworkId, in.GenesisHash); err != nil {
+		addr := "unknown"
+		if ok {
+			addr = p.Addr.String()
+		}
+
+		fmt.Println("Refusing peer", addr, err)
+
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if ok {
+		p2psrv.inbound.add(p.Addr, in.ProtocolVersion, in.Height)
+	}
+
+	out := &protos.HandshakeResponse{
+		ProtocolVersion: p2psrv.Status.ProtocolVersion,
+		NetworkId:       p2psrv.Status.NetworkID,
+		GenesisHash:     p2psrv.Status.GenesisHash.Bytes(),
+		Height:          localHeight(p2psrv.BlockchainDB),
+	}
+
+	return out, nil
+}
+
+// requireHandshake refuses the calls of peers which didn't handshake over their connection,
+// so that peers on a different network, refused by Handshake, can't sync from the node
+// either. It is the unary interceptor of the gRPC server.
+func (ip *inboundPeers) requireHandshake(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod == protos.P2P_Handshake_FullMethodName {
+		return handler(ctx, req)
+	}
+
+	if p, ok := peer.FromContext(ctx); !ok || !ip.has(p.Addr) {
+		return nil, status.Error(codes.Unauthenticated, ErrHandshakeRequired.Error())
+	}
+
+	return handler(ctx, req)
+}
+
+// handshake exchanges the status and the height with the peer. It fails with an error
+// for which isUnreachable holds if the peer couldn't be reached.
+func (p *Peer) handshake(local *Status, height uint64) error {
+	req := &protos.HandshakeRequest{
+		ProtocolVersion: local.ProtocolVersion,
+		NetworkId:       local.NetworkID,
+		GenesisHash:     local.GenesisHash.Bytes(),
+		Height:          height,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+
+	r, err := p.P2PClient.Handshake(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := local.check(r.ProtocolVersion, r.NetworkId, r.GenesisHash); err != nil {
+		return err
+	}
+
+	p.version.Store(r.ProtocolVersion)
+	p.height.Store(r.Height)
+
+	return nil
+}
+
+// localHeight returns the number of the latest local block, zero if it can't be read.
+func localHeight(blockchainDB *dbstore.BlockchainDB) uint64 {
+	if blockchainDB == nil {
+		return 0
+	}
+
+	latest, err := blockchainDB.GetLatestBlock()
+	if err != nil {
+		return 0
+	}
+
+	return latest.Number.Uint64()
+}
+
+// isUnreachable reports whether a call to a peer failed because the peer can't be reached.
+func isUnreachable(err error) bool {
+	code := status.Code(err)
+
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
+
+// isDisconnected reports whether a call to a peer failed because the peer can't be
+// reached, or because the connection got replaced by one the handshake has to be redone on.
+func isDisconnected(err error) bool {
+	return isUnreachable(err) || status.Code(err) == codes.Unauthenticated
+}
@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// peerListener accepts up to maxPeers inbound connections at a time, closing the ones
+// over the limit, and the ones of banned peers, as soon as they are accepted. Zero accepts
+// any number of connections.
+type peerListener struct {
+	net.Listener
+	maxPeers int
+	bans     *Bans
+	logger   *slog.Logger
+
+	mu   sync.Mutex
+	open int
+}
+
+func (l *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.bans.Banned(conn.RemoteAddr().String()) {
+			l.logger.Info("Refusing peer", "addr", conn.RemoteAddr().String(), "reason", "banned")
+
+			// nolint : errcheck
+			conn.Close()
+
+			continue
+		}
+
+		if l.maxPeers <= 0 {
+			return conn, nil
+		}
+
+		l.mu.Lock()
+
+		if l.open >= l.maxPeers {
+			l.mu.Unlock()
+
+			fmt.Println("Refusing peer", conn.RemoteAddr().String(), "maximum number of peers reached", l.maxPeers)
+
+			// nolint : errcheck
+			conn.Close()
+
+			continue
+		}
+
+		l.open++
+		l.mu.Unlock()
+
+		return &peerConn{Conn: conn, release: l.release}, nil
+	}
+}
+
+func (l *peerListener) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.open--
+}
+
+// peerConn is an accepted connection which frees its slot once closed.
+type peerConn struct {
+	net.Conn
+
+	once    sync.Once
+	release func()
+}
+
+func (c *peerConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+
+	return err
+}
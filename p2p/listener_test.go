@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/protos"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxPeers(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := fmt.Sprintf(":%d", lis.Addr().(*net.TCPAddr).Port)
+	// nolint : errcheck
+	lis.Close()
+
+	status := NewStatus(1, util.HashData([]byte("genesis")))
+
+	srv := startTestServer(t, port, status, 1)
+	defer srv.Stop()
+
+	handshake := func(client protos.P2PClient) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := client.Handshake(ctx, &protos.HandshakeRequest{
+			ProtocolVersion: status.ProtocolVersion,
+			NetworkId:       status.NetworkID,
+			GenesisHash:     status.GenesisHash.Bytes(),
+		})
+
+		return err
+	}
+
+	conn1, client1 := ConnectToGRPCServer("localhost" + port)
+
+	assert.Eventually(t, func() bool { return handshake(client1) == nil }, 5*time.Second, 10*time.Millisecond)
+
+	// The second inbound connection is over the limit.
+	conn2, client2 := ConnectToGRPCServer("localhost" + port)
+	// nolint : errcheck
+	defer conn2.Close()
+
+	err = handshake(client2)
+	assert.True(t, isUnreachable(err), err)
+	assert.Len(t, srv.PeerInfos(), 1)
+
+	// Its slot is free once the first peer disconnects.
+	assert.NoError(t, conn1.Close())
+
+	assert.Eventually(t, func() bool { return handshake(client2) == nil }, 5*time.Second, 50*time.Millisecond)
+}
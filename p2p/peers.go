@@ -0,0 +1,127 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+)
+
+// PeerInfo describes a peer the node is connected to.
+type PeerInfo struct {
+	Addr            string
+	Inbound         bool   // Whether the peer dialed the node
+	ProtocolVersion uint64 // Protocol version the peer handshaked with
+	Height          uint64 // Number of the latest block of the peer, as of the handshake for inbound peers
+}
+
+// inboundPeers tracks the peers which dialed the node and handshaked with it, until their
+// connection closes. It is the stats handler of the gRPC server.
+type inboundPeers struct {
+	mu    sync.RWMutex
+	peers map[string]*PeerInfo // By remote address
+}
+
+type remoteAddrKey struct{}
+
+func newInboundPeers() *inboundPeers {
+	return &inboundPeers{peers: make(map[string]*PeerInfo)}
+}
+
+// add records the peer at the remote address after a successful handshake, with the
+// height it advertised.
+func (ip *inboundPeers) add(addr net.Addr, protocolVersion uint64, height uint64) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	ip.peers[addr.String()] = &PeerInfo{Addr: addr.String(), Inbound: true, ProtocolVersion: protocolVersion, Height: height}
+}
+
+// has reports whether the peer at the remote address handshaked over its connection.
+func (ip *inboundPeers) has(addr net.Addr) bool {
+	ip.mu.RLock()
+	defer ip.mu.RUnlock()
+
+	_, ok := ip.peers[addr.String()]
+
+	return ok
+}
+
+// list returns the inbound peers, sorted by address.
+func (ip *inboundPeers) list() []*PeerInfo {
+	ip.mu.RLock()
+	defer ip.mu.RUnlock()
+
+	infos := make([]*PeerInfo, 0, len(ip.peers))
+	for _, info := range ip.peers {
+		infos = append(infos, &PeerInfo{Addr: info.Addr, Inbound: true, ProtocolVersion: info.ProtocolVersion, Height: info.Height})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Addr < infos[j].Addr
+	})
+
+	return infos
+}
+
+func (ip *inboundPeers) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, info.RemoteAddr)
+}
+
+// HandleConn forgets the peer once its connection closes.
+func (ip *inboundPeers) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnEnd); !ok {
+		return
+	}
+
+	addr, ok := ctx.Value(remoteAddrKey{}).(net.Addr)
+	if !ok {
+		return
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	delete(ip.peers, addr.String())
+}
+
+func (ip *inboundPeers) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (ip *inboundPeers) HandleRPC(context.Context, stats.RPCStats) {}
+
+// PeerInfos returns the connected outbound peers followed by the inbound ones.
+func (p2psrv *P2PServer) PeerInfos() []*PeerInfo {
+	infos := []*PeerInfo{}
+
+	for _, peer := range p2psrv.Downloader.GetPeers() {
+		if !peer.connected.Load() {
+			continue
+		}
+
+		infos = append(infos, &PeerInfo{
+			Addr:            peer.Addr,
+			ProtocolVersion: peer.version.Load(),
+			Height:          peer.height.Load(),
+		})
+	}
+
+	return append(infos, p2psrv.inbound.list()...)
+}
+
+// HighestPeerHeight returns the highest height advertised by the connected peers, the
+// latest one for outbound peers and the one of the handshake for inbound peers.
+func (p2psrv *P2PServer) HighestPeerHeight() uint64 {
+	highest := uint64(0)
+
+	for _, info := range p2psrv.PeerInfos() {
+		if info.Height > highest {
+			highest = info.Height
+		}
+	}
+
+	return highest
+}
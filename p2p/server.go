@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"time"
 
 	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/protos"
 	"github.com/0xsharma/compact-chain/txpool"
 	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
 	"google.golang.org/grpc"
 )
 
@@ -22,11 +25,14 @@ type P2PServer struct {
 	Peers                 []string
 	P2PAddrBlockNumberMap map[string]int
 	Downloader            *Downloader
+	Status                *Status
 
 	BlockchainDB *dbstore.BlockchainDB
 	StateDB      *dbstore.StateDB
 	Txpool       *txpool.TxPool
 
+	inbound *inboundPeers
+
 	protos.UnimplementedP2PServer
 }
 
@@ -37,7 +43,10 @@ type P2PMessage struct {
 	Error   error
 }
 
-func NewServer(port string, initPeers []string, statedb *dbstore.StateDB, blockchainDb *dbstore.BlockchainDB, txpool *txpool.TxPool, txpoolCh chan *types.Transaction, blockCh chan *types.Block) *P2PServer {
+// NewServer listens on the port and starts the downloader of the initial peers. Nil bans
+// ban misbehaving peers for DefaultBanDuration, without a denylist, and a nil logger logs
+// to the default logger.
+func NewServer(port string, initPeers []string, status *Status, maxBackoff time.Duration, maxPeers int, bans *Bans, statedb *dbstore.StateDB, blockchainDb *dbstore.BlockchainDB, txpool *txpool.TxPool, txpoolCh chan *types.Transaction, blockCh chan *types.Block, logger *slog.Logger) *P2PServer {
 	// sanitize p2p port
 	if port == "" {
 		port = defaultP2pPort
@@ -48,8 +57,21 @@ func NewServer(port string, initPeers []string, statedb *dbstore.StateDB, blockc
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	grpcSrv := grpc.NewServer()
-	downloader := NewDownloader(fmt.Sprintf("localhost%s", port), initPeers, txpoolCh, blockCh, blockchainDb)
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if bans == nil {
+		bans = NewBans(0, nil)
+		bans.Logger = logger
+	}
+
+	lis = &peerListener{Listener: lis, maxPeers: maxPeers, bans: bans, logger: logger}
+
+	inbound := newInboundPeers()
+	grpcSrv := grpc.NewServer(grpc.StatsHandler(inbound), grpc.UnaryInterceptor(inbound.requireHandshake))
+	downloader := NewDownloader(fmt.Sprintf("localhost%s", port), initPeers, status, maxBackoff, txpoolCh, blockCh, blockchainDb, logger)
+	downloader.Bans = bans
 	downloader.Start()
 
 	p2psrv := &P2PServer{
@@ -62,11 +84,16 @@ func NewServer(port string, initPeers []string, statedb *dbstore.StateDB, blockc
 		BlockchainDB:          blockchainDb,
 		Txpool:                txpool,
 		Downloader:            downloader,
+		Status:                status,
+		inbound:               inbound,
 	}
 
 	return p2psrv
 }
 
+// LatestBlock announces the head block of the node with its height and hash. The block
+// itself is sent along unless the peer asks for the hash only, peers which lack the block
+// request it with BlocksByHash.
 func (p2psrv *P2PServer) LatestBlock(ctx context.Context, in *protos.LatestBlockRequest) (*protos.LatestBlockResponse, error) {
 	latestBlock, err := p2psrv.BlockchainDB.GetLatestBlock()
 	if err != nil {
@@ -74,35 +101,61 @@ func (p2psrv *P2PServer) LatestBlock(ctx context.Context, in *protos.LatestBlock
 	}
 
 	out := &protos.LatestBlockResponse{
-		Height:       latestBlock.Number.Uint64(),
-		EncodedBlock: latestBlock.Serialize(),
+		Height: latestBlock.Number.Uint64(),
+		Hash:   latestBlock.DeriveHash().Bytes(),
+	}
+
+	if !in.HashOnly {
+		out.EncodedBlock = latestBlock.Serialize()
 	}
 
 	return out, nil
 }
 
+// BlocksInRange returns the canonical blocks between the two heights, or only their
+// hashes if the peer asks for them.
 func (p2psrv *P2PServer) BlocksInRange(ctx context.Context, in *protos.BlocksInRangeRequest) (*protos.BlocksInRangeResponse, error) {
-	total := in.EndHeight - in.StartHeight + 1
-	enBlocks := make([][]byte, total)
-
 	blocks, err := p2psrv.BlockchainDB.GetBlocksInRange(uint(in.StartHeight), uint(in.EndHeight))
 	if err != nil {
 		return nil, err
 	}
 
-	for i, block := range blocks {
-		enBlocks[i] = block.Serialize()
+	out := &protos.BlocksInRangeResponse{}
+
+	for _, block := range blocks {
+		if in.HashesOnly {
+			out.Hashes = append(out.Hashes, block.DeriveHash().Bytes())
+		} else {
+			out.EncodedBlocks = append(out.EncodedBlocks, block.Serialize())
+		}
 	}
 
-	out := &protos.BlocksInRangeResponse{
-		EncodedBlocks: enBlocks,
+	return out, nil
+}
+
+// BlocksByHash returns the blocks of the given hashes, in the same order, skipping the
+// ones the node doesn't have. At most maxBlocksPerRequest blocks are returned.
+func (p2psrv *P2PServer) BlocksByHash(ctx context.Context, in *protos.BlocksByHashRequest) (*protos.BlocksByHashResponse, error) {
+	out := &protos.BlocksByHashResponse{}
+
+	for _, hash := range in.Hashes {
+		if uint64(len(out.EncodedBlocks)) >= maxBlocksPerRequest {
+			break
+		}
+
+		block, err := p2psrv.BlockchainDB.GetBlockByHash(util.ByteToHash(hash))
+		if err != nil {
+			continue
+		}
+
+		out.EncodedBlocks = append(out.EncodedBlocks, block.Serialize())
 	}
 
 	return out, nil
 }
 
 func (p2psrv *P2PServer) TxPoolPending(ctx context.Context, in *protos.TxpoolPendingRequest) (*protos.TxpoolPendingResponse, error) {
-	pending := p2psrv.Txpool.Transactions
+	pending := p2psrv.Txpool.Pending()
 	serialisedTxs := make([][]byte, len(pending))
 
 	for i, tx := range pending {
@@ -120,7 +173,20 @@ func (p2psrv *P2PServer) StartServer() {
 	protos.RegisterP2PServer(p2psrv.GRPCSrv, p2psrv)
 	fmt.Println("Serving P2P Server on port", p2psrv.Port)
 
-	if err := p2psrv.GRPCSrv.Serve(p2psrv.Lis); err != nil {
+	// The server stopped on shutdown before serving isn't a failure.
+	if err := p2psrv.GRPCSrv.Serve(p2psrv.Lis); err != nil && err != grpc.ErrServerStopped {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+// Stop stops the gRPC server and the downloader.
+func (p2psrv *P2PServer) Stop() {
+	p2psrv.GRPCSrv.Stop()
+
+	// The listener is only closed by the gRPC server once serving, close it in case
+	// StartServer didn't get to run yet.
+	// nolint : errcheck
+	p2psrv.Lis.Close()
+
+	p2psrv.Downloader.Stop()
+}
@@ -19,18 +19,22 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
+	P2P_Handshake_FullMethodName     = "/protos.P2P/Handshake"
 	P2P_LatestBlock_FullMethodName   = "/protos.P2P/LatestBlock"
 	P2P_TxPoolPending_FullMethodName = "/protos.P2P/TxPoolPending"
 	P2P_BlocksInRange_FullMethodName = "/protos.P2P/BlocksInRange"
+	P2P_BlocksByHash_FullMethodName  = "/protos.P2P/BlocksByHash"
 )
 
 // P2PClient is the client API for P2P service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type P2PClient interface {
+	Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error)
 	LatestBlock(ctx context.Context, in *LatestBlockRequest, opts ...grpc.CallOption) (*LatestBlockResponse, error)
 	TxPoolPending(ctx context.Context, in *TxpoolPendingRequest, opts ...grpc.CallOption) (*TxpoolPendingResponse, error)
 	BlocksInRange(ctx context.Context, in *BlocksInRangeRequest, opts ...grpc.CallOption) (*BlocksInRangeResponse, error)
+	BlocksByHash(ctx context.Context, in *BlocksByHashRequest, opts ...grpc.CallOption) (*BlocksByHashResponse, error)
 }
 
 type p2PClient struct {
@@ -41,6 +45,15 @@ func NewP2PClient(cc grpc.ClientConnInterface) P2PClient {
 	return &p2PClient{cc}
 }
 
+func (c *p2PClient) Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error) {
+	out := new(HandshakeResponse)
+	err := c.cc.Invoke(ctx, P2P_Handshake_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *p2PClient) LatestBlock(ctx context.Context, in *LatestBlockRequest, opts ...grpc.CallOption) (*LatestBlockResponse, error) {
 	out := new(LatestBlockResponse)
 	err := c.cc.Invoke(ctx, P2P_LatestBlock_FullMethodName, in, out, opts...)
@@ -68,13 +81,24 @@ func (c *p2PClient) BlocksInRange(ctx context.Context, in *BlocksInRangeRequest,
 	return out, nil
 }
 
+func (c *p2PClient) BlocksByHash(ctx context.Context, in *BlocksByHashRequest, opts ...grpc.CallOption) (*BlocksByHashResponse, error) {
+	out := new(BlocksByHashResponse)
+	err := c.cc.Invoke(ctx, P2P_BlocksByHash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // P2PServer is the server API for P2P service.
 // All implementations must embed UnimplementedP2PServer
 // for forward compatibility
 type P2PServer interface {
+	Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error)
 	LatestBlock(context.Context, *LatestBlockRequest) (*LatestBlockResponse, error)
 	TxPoolPending(context.Context, *TxpoolPendingRequest) (*TxpoolPendingResponse, error)
 	BlocksInRange(context.Context, *BlocksInRangeRequest) (*BlocksInRangeResponse, error)
+	BlocksByHash(context.Context, *BlocksByHashRequest) (*BlocksByHashResponse, error)
 	mustEmbedUnimplementedP2PServer()
 }
 
@@ -82,6 +106,9 @@ type P2PServer interface {
 type UnimplementedP2PServer struct {
 }
 
+func (UnimplementedP2PServer) Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Handshake not implemented")
+}
 func (UnimplementedP2PServer) LatestBlock(context.Context, *LatestBlockRequest) (*LatestBlockResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LatestBlock not implemented")
 }
@@ -91,6 +118,9 @@ func (UnimplementedP2PServer) TxPoolPending(context.Context, *TxpoolPendingReque
 func (UnimplementedP2PServer) BlocksInRange(context.Context, *BlocksInRangeRequest) (*BlocksInRangeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BlocksInRange not implemented")
 }
+func (UnimplementedP2PServer) BlocksByHash(context.Context, *BlocksByHashRequest) (*BlocksByHashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlocksByHash not implemented")
+}
 func (UnimplementedP2PServer) mustEmbedUnimplementedP2PServer() {}
 
 // UnsafeP2PServer may be embedded to opt out of forward compatibility for this service.
@@ -104,6 +134,24 @@ func RegisterP2PServer(s grpc.ServiceRegistrar, srv P2PServer) {
 	s.RegisterService(&P2P_ServiceDesc, srv)
 }
 
+func _P2P_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandshakeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(P2PServer).Handshake(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: P2P_Handshake_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(P2PServer).Handshake(ctx, req.(*HandshakeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _P2P_LatestBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(LatestBlockRequest)
 	if err := dec(in); err != nil {
@@ -158,6 +206,24 @@ func _P2P_BlocksInRange_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _P2P_BlocksByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlocksByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(P2PServer).BlocksByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: P2P_BlocksByHash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(P2PServer).BlocksByHash(ctx, req.(*BlocksByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // P2P_ServiceDesc is the grpc.ServiceDesc for P2P service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -165,6 +231,10 @@ var P2P_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.P2P",
 	HandlerType: (*P2PServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Handshake",
+			Handler:    _P2P_Handshake_Handler,
+		},
 		{
 			MethodName: "LatestBlock",
 			Handler:    _P2P_LatestBlock_Handler,
@@ -177,6 +247,10 @@ var P2P_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BlocksInRange",
 			Handler:    _P2P_BlocksInRange_Handler,
 		},
+		{
+			MethodName: "BlocksByHash",
+			Handler:    _P2P_BlocksByHash_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/p2p.proto",
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ErrCodeUnauthorized is the JSON-RPC error code of requests without the auth token.
+const ErrCodeUnauthorized = -32006
+
+// bearerToken returns the token of a bearer Authorization header, empty if the header
+// is missing or uses another scheme.
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// validToken reports whether the token matches the expected one. The hashes of both are
+// compared in constant time, so that neither the content nor the length of the expected
+// token leaks through the time taken.
+func validToken(expected string, token string) bool {
+	expectedHash := sha256.Sum256([]byte(expected))
+	tokenHash := sha256.Sum256([]byte(token))
+
+	return subtle.ConstantTimeCompare(expectedHash[:], tokenHash[:]) == 1
+}
+
+// authMiddleware refuses the requests without an "Authorization: Bearer <token>" header
+// carrying the token, with a 401 status and a JSON-RPC unauthorized error.
+func authMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if validToken(token, bearerToken(r.Header.Get("Authorization"))) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="compact-chain"`)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		// nolint : errchkjson
+		json.NewEncoder(w).Encode(errorResponse(nil, &Error{Code: ErrCodeUnauthorized, Message: "unauthorized"}))
+	})
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerToken(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "s3cret", bearerToken("Bearer s3cret"))
+	assert.Equal(t, "s3cret", bearerToken("bearer  s3cret"))
+	assert.Empty(t, bearerToken("Basic czNjcmV0"))
+	assert.Empty(t, bearerToken("s3cret"))
+	assert.Empty(t, bearerToken(""))
+
+	assert.True(t, validToken("s3cret", "s3cret"))
+	assert.False(t, validToken("s3cret", "s3cre"))
+	assert.False(t, validToken("s3cret", ""))
+}
+
+func TestAuthToken(t *testing.T) {
+	t.Parallel()
+
+	srv := NewRPCServer(":1715", &RPCDomains{}, &ServerOptions{AuthToken: "s3cret"})
+	defer srv.Stop()
+
+	srv.RegisterMethod("test_ping", func(params []json.RawMessage) (interface{}, error) { return "pong", nil })
+
+	post := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_ping"}`))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		rec := httptest.NewRecorder()
+		srv.HttpServer.Handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// Requests without the token, or with another one, are refused.
+	for _, authorization := range []string{"", "Bearer wrong", "Basic czNjcmV0", "s3cret"} {
+		rec := post(authorization)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+		assert.Equal(t, `Bearer realm="compact-chain"`, rec.Header().Get("WWW-Authenticate"))
+
+		var res jsonrpcResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		assert.Equal(t, ErrCodeUnauthorized, res.Error.Code)
+	}
+
+	// The token is accepted.
+	rec := post("Bearer s3cret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"pong"}`, rec.Body.String())
+}
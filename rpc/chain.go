@@ -0,0 +1,481 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// ChainAPI serves the chain_ namespace of the JSON-RPC server.
+type ChainAPI struct {
+	BlockchainDB  *dbstore.BlockchainDB
+	ChainID       uint64
+	FinalityDepth uint64
+}
+
+// RPCBlock is the JSON-RPC representation of a block.
+type RPCBlock struct {
+	Number       string   `json:"number"`
+	Hash         string   `json:"hash"`
+	ParentHash   string   `json:"parentHash"`
+	Timestamp    string   `json:"timestamp"`
+	BaseFee      string   `json:"baseFee,omitempty"`
+	GasUsed      string   `json:"gasUsed"`
+	TxRoot       string   `json:"transactionsRoot"`
+	StateRoot    string   `json:"stateRoot,omitempty"`
+	Transactions []string `json:"transactions"`
+}
+
+// NewRPCBlock converts a block into its JSON-RPC representation.
+func NewRPCBlock(b *types.Block) *RPCBlock {
+	txs := make([]string, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		txs[i] = tx.Hash().String()
+	}
+
+	block := &RPCBlock{
+		Number:       encodeBig(b.Number),
+		Hash:         b.DeriveHash().String(),
+		ParentHash:   b.ParentHash.String(),
+		Timestamp:    encodeBig(new(big.Int).SetUint64(b.Timestamp)),
+		GasUsed:      encodeBig(new(big.Int).SetUint64(b.GasUsed())),
+		TxRoot:       b.TxRootHash().String(),
+		Transactions: txs,
+	}
+
+	if b.BaseFee != nil {
+		block.BaseFee = encodeBig(b.BaseFee)
+	}
+
+	if b.StateRoot != nil {
+		block.StateRoot = b.StateRoot.String()
+	}
+
+	return block
+}
+
+// GetBlockNumber returns the number of the head block as hex.
+func (api *ChainAPI) GetBlockNumber(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeBig(latest.Number), nil
+}
+
+// GetChainID returns the chain id transactions are signed for as hex.
+func (api *ChainAPI) GetChainID(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	return encodeBig(new(big.Int).SetUint64(api.ChainID)), nil
+}
+
+// GetBlockByNumber returns the block at the given height, or null if the chain
+// hasn't reached it yet. Accepts a decimal or hex height, or "latest".
+func (api *ChainAPI) GetBlockByNumber(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := parseBlockNumber(params[0], latest.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	if number.Cmp(latest.Number) > 0 {
+		return nil, nil
+	}
+
+	block, err := api.BlockchainDB.GetBlockByNumber(number)
+	if err != nil {
+		// nolint : nilerr
+		return nil, nil
+	}
+
+	return NewRPCBlock(block), nil
+}
+
+// GetBlockByHash returns the block with the given hash, or null if the block is unknown.
+func (api *ChainAPI) GetBlockByHash(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := api.BlockchainDB.GetBlockByHash(hash)
+	if err != nil {
+		// nolint : nilerr
+		return nil, nil
+	}
+
+	return NewRPCBlock(block), nil
+}
+
+// IsFinalized returns whether the canonical block at the given height is buried under
+// the finality depth of the node, so that no reorg will rewrite it. Blocks are never
+// final without a finality depth. Accepts a decimal or hex height, or "latest".
+func (api *ChainAPI) IsFinalized(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := parseBlockNumber(params[0], latest.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.FinalityDepth == 0 || number.Cmp(latest.Number) > 0 {
+		return false, nil
+	}
+
+	return new(big.Int).Sub(latest.Number, number).Cmp(new(big.Int).SetUint64(api.FinalityDepth)) >= 0, nil
+}
+
+// RPCTransaction is the JSON-RPC representation of a mined transaction.
+type RPCTransaction struct {
+	Hash             string `json:"hash"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	Value            string `json:"value"`
+	Fee              string `json:"fee"` // Fee paid in the block, for dynamic fee transactions too
+	Nonce            string `json:"nonce"`
+	Gas              string `json:"gas"`
+	Msg              string `json:"msg"`
+	Data             string `json:"data"`
+	BlockHash        string `json:"blockHash"`
+	BlockNumber      string `json:"blockNumber"`
+	TransactionIndex string `json:"transactionIndex"`
+
+	MaxFee         string `json:"maxFee,omitempty"`
+	MaxPriorityFee string `json:"maxPriorityFee,omitempty"`
+}
+
+// NewRPCTransaction converts a transaction included at the given index of a block
+// into its JSON-RPC representation.
+func NewRPCTransaction(tx *types.Transaction, block *types.Block, index uint64) *RPCTransaction {
+	rpcTx := &RPCTransaction{
+		Hash:             tx.Hash().String(),
+		From:             tx.From.String(),
+		To:               tx.To.String(),
+		Value:            tx.Value.String(),
+		Fee:              tx.EffectiveFee(block.BaseFee).String(),
+		Nonce:            encodeBig(tx.Nonce),
+		Gas:              encodeBig(new(big.Int).SetUint64(tx.Gas())),
+		Msg:              fmt.Sprintf("0x%x", tx.Msg),
+		Data:             fmt.Sprintf("0x%x", tx.Data),
+		BlockHash:        block.DeriveHash().String(),
+		BlockNumber:      encodeBig(block.Number),
+		TransactionIndex: encodeBig(new(big.Int).SetUint64(index)),
+	}
+
+	if tx.IsDynamicFee() {
+		rpcTx.MaxFee = tx.MaxFee.String()
+		rpcTx.MaxPriorityFee = tx.MaxPriorityFee.String()
+	}
+
+	return rpcTx
+}
+
+// GetTransactionByHash returns the mined transaction with the given hash, or null
+// if the transaction is unknown or still pending.
+func (api *ChainAPI) GetTransactionByHash(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tx, block, lookup, err := api.BlockchainDB.GetTransactionByHash(hash)
+	if err != nil {
+		// nolint : nilerr
+		return nil, nil
+	}
+
+	return NewRPCTransaction(tx, block, lookup.Index), nil
+}
+
+// maxTransactionsBlockRange is the largest number of blocks a single
+// chain_getTransactions call scans.
+var maxTransactionsBlockRange uint64 = 1000
+
+// TransactionFilter selects the mined transactions returned by chain_getTransactions.
+// Every field is optional, the block range defaulting to the head block.
+type TransactionFilter struct {
+	From      string          `json:"from"`      // Hex address of the sender
+	To        string          `json:"to"`        // Hex address of the recipient
+	FromBlock json.RawMessage `json:"fromBlock"` // First block of the range, as accepted by chain_getBlockByNumber
+	ToBlock   json.RawMessage `json:"toBlock"`   // Last block of the range, included
+}
+
+// GetTransactions returns the canonical transactions matching the filter, in chain
+// order. The range spans at most maxTransactionsBlockRange blocks, those past the head
+// block being ignored.
+func (api *ChainAPI) GetTransactions(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	var filter TransactionFilter
+	if err := json.Unmarshal(params[0], &filter); err != nil {
+		return nil, NewInvalidParamsError("filter must be an object")
+	}
+
+	parseFilterAddress := func(str string) (*util.Address, error) {
+		if str == "" {
+			return nil, nil
+		}
+
+		address, err := util.HexToAddress(str)
+		if err != nil {
+			return nil, NewInvalidParamsError("%s", err)
+		}
+
+		return address, nil
+	}
+
+	from, err := parseFilterAddress(filter.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseFilterAddress(filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	parseFilterBlock := func(raw json.RawMessage) (*big.Int, error) {
+		if len(raw) == 0 || string(raw) == "null" {
+			return latest.Number, nil
+		}
+
+		return parseBlockNumber(raw, latest.Number)
+	}
+
+	fromBlock, err := parseFilterBlock(filter.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	toBlock, err := parseFilterBlock(filter.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromBlock.Cmp(toBlock) > 0 {
+		return nil, NewInvalidParamsError("fromBlock %s is after toBlock %s", fromBlock, toBlock)
+	}
+
+	if span := new(big.Int).Sub(toBlock, fromBlock); !span.IsUint64() || span.Uint64() >= maxTransactionsBlockRange {
+		return nil, NewInvalidParamsError("block range exceeds %d blocks", maxTransactionsBlockRange)
+	}
+
+	if toBlock.Cmp(latest.Number) > 0 {
+		toBlock = latest.Number
+	}
+
+	txs := []*RPCTransaction{}
+
+	for n := new(big.Int).Set(fromBlock); n.Cmp(toBlock) <= 0; n.Add(n, big.NewInt(1)) {
+		block, err := api.BlockchainDB.GetBlockByNumber(n)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, tx := range block.Transactions {
+			if from != nil && tx.From.String() != from.String() {
+				continue
+			}
+
+			if to != nil && tx.To.String() != to.String() {
+				continue
+			}
+
+			txs = append(txs, NewRPCTransaction(tx, block, uint64(i)))
+		}
+	}
+
+	return txs, nil
+}
+
+// RPCReceipt is the JSON-RPC representation of a transaction receipt.
+type RPCReceipt struct {
+	TransactionHash  string `json:"transactionHash"`
+	BlockHash        string `json:"blockHash"`
+	BlockNumber      string `json:"blockNumber"`
+	TransactionIndex string `json:"transactionIndex"`
+	Status           string `json:"status"`
+	GasUsed          string `json:"gasUsed"`
+	Fee              string `json:"fee"`
+}
+
+// NewRPCReceipt converts a receipt into its JSON-RPC representation.
+func NewRPCReceipt(r *types.Receipt) *RPCReceipt {
+	return &RPCReceipt{
+		TransactionHash:  r.TxHash.String(),
+		BlockHash:        r.BlockHash.String(),
+		BlockNumber:      encodeBig(r.BlockNumber),
+		TransactionIndex: encodeBig(new(big.Int).SetUint64(r.Index)),
+		Status:           encodeBig(new(big.Int).SetUint64(r.Status)),
+		GasUsed:          encodeBig(new(big.Int).SetUint64(r.GasUsed)),
+		Fee:              r.Fee.String(),
+	}
+}
+
+// GetTransactionReceipt returns the receipt of the mined transaction with the given
+// hash, or null if the transaction is unknown or still pending.
+func (api *ChainAPI) GetTransactionReceipt(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := api.BlockchainDB.GetReceipt(hash)
+	if err != nil {
+		// nolint : nilerr
+		return nil, nil
+	}
+
+	return NewRPCReceipt(receipt), nil
+}
+
+// RPCTransactionProof is the JSON-RPC representation of the Merkle branch proving a
+// transaction is part of a block.
+type RPCTransactionProof struct {
+	Hash        string   `json:"hash"`
+	BlockHash   string   `json:"blockHash"`
+	BlockNumber string   `json:"blockNumber"`
+	TxRoot      string   `json:"transactionsRoot"`
+	Branch      []string `json:"branch"`
+	Right       []bool   `json:"right"`
+}
+
+// GetTransactionProof returns the Merkle branch from the mined transaction with the
+// given hash to the transactions root of its block, or null if the transaction is
+// unknown or still pending. Right tells for each level whether the sibling hash is
+// hashed after the node.
+func (api *ChainAPI) GetTransactionProof(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	hash, err := parseHash(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	_, block, _, err := api.BlockchainDB.GetTransactionByHash(hash)
+	if err != nil {
+		// nolint : nilerr
+		return nil, nil
+	}
+
+	proof, err := block.TxProof(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := make([]string, len(proof.Branch))
+	for i, sibling := range proof.Branch {
+		branch[i] = sibling.String()
+	}
+
+	return &RPCTransactionProof{
+		Hash:        hash.String(),
+		BlockHash:   block.DeriveHash().String(),
+		BlockNumber: encodeBig(block.Number),
+		TxRoot:      block.TxRootHash().String(),
+		Branch:      branch,
+		Right:       proof.Right,
+	}, nil
+}
+
+// parseHash parses a hash param given as a 0x-prefixed hex string.
+func parseHash(raw json.RawMessage) (*util.Hash, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return nil, NewInvalidParamsError("hash must be a hex string")
+	}
+
+	hash, err := util.HexToHash(str)
+	if err != nil {
+		return nil, NewInvalidParamsError("%s", err)
+	}
+
+	return hash, nil
+}
+
+// parseBlockNumber parses a block number param given as a JSON number, a decimal
+// or 0x-prefixed hex string, or the "latest" tag.
+func parseBlockNumber(raw json.RawMessage, latest *big.Int) (*big.Int, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		str = string(raw)
+	}
+
+	if str == "latest" {
+		return latest, nil
+	}
+
+	number := new(big.Int)
+
+	var ok bool
+
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		number, ok = number.SetString(str[2:], 16)
+	} else {
+		number, ok = number.SetString(str, 10)
+	}
+
+	if !ok {
+		return nil, NewInvalidParamsError("invalid block number %s", str)
+	}
+
+	if number.Sign() < 0 {
+		return nil, NewInvalidParamsError("block number must not be negative")
+	}
+
+	return number, nil
+}
+
+// encodeBig encodes a big integer as a 0x-prefixed hex quantity.
+func encodeBig(n *big.Int) string {
+	return fmt.Sprintf("0x%x", n)
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsMaxAge is how long browsers may cache the answer to a preflight request.
+var corsMaxAge = 10 * time.Minute
+
+// corsAllowedMethods and corsAllowedHeaders are the methods and headers preflight
+// requests are answered with.
+var (
+	corsAllowedMethods = "GET, POST, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Authorization"
+)
+
+// allowedOrigin returns the value of the Access-Control-Allow-Origin header answering the
+// origin, empty if the origin isn't allowed. "*" allows any origin.
+func allowedOrigin(origins []string, origin string) string {
+	if origin == "" {
+		return ""
+	}
+
+	for _, allowed := range origins {
+		if allowed == "*" {
+			return "*"
+		}
+
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// corsMiddleware lets the pages of the allowed origins call the RPC server from a
+// browser, answering their preflight requests. Requests from other origins are served
+// without CORS headers, so that browsers keep the responses from the page, and their
+// preflight requests are refused with a 403 status.
+func corsMiddleware(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		allowed := allowedOrigin(origins, r.Header.Get("Origin"))
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	t.Parallel()
+
+	s := &RPCServer{methods: make(map[string]methodFunc)}
+	s.RegisterMethod("test_ping", func(params []json.RawMessage) (interface{}, error) { return "pong", nil })
+
+	preflight := func(handler http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	post := func(handler http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_ping"}`))
+		req.Header.Set("Origin", origin)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	handler := corsMiddleware([]string{"https://explorer.example.org/"}, s)
+
+	// The allowed origin is echoed.
+	rec := preflight(handler, "https://explorer.example.org")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://explorer.example.org", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST, OPTIONS", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+
+	rec = post(handler, "https://explorer.example.org")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://explorer.example.org", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// Other origins get no CORS headers.
+	rec = preflight(handler, "https://evil.example.org")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = post(handler, "https://evil.example.org")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// The wildcard allows any origin.
+	rec = preflight(corsMiddleware([]string{"*"}, s), "https://evil.example.org")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	srv := NewRPCServer(":1714", &RPCDomains{}, nil)
+	defer srv.Stop()
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://explorer.example.org")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	rec := httptest.NewRecorder()
+	srv.HttpServer.Handler.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/txpool"
+)
+
+// feeHistoryBlocks is the number of recent blocks the fee estimation looks at.
+var feeHistoryBlocks uint64 = 20
+
+// Percentiles of the sampled fees suggested as the low, medium and high fees.
+var (
+	lowFeePercentile    = 25
+	mediumFeePercentile = 50
+	highFeePercentile   = 90
+)
+
+// FeeAPI serves the fee estimation method of the chain_ namespace.
+type FeeAPI struct {
+	BlockchainDB *dbstore.BlockchainDB
+	TxPool       *txpool.TxPool
+}
+
+// RPCFeeEstimate is the JSON-RPC representation of the suggested fees, as decimal
+// strings. Higher fees are more likely to be mined promptly.
+type RPCFeeEstimate struct {
+	Low    string `json:"low"`
+	Medium string `json:"medium"`
+	High   string `json:"high"`
+	Floor  string `json:"floor"` // Lowest fee accepted, the larger of the minimum fee and the next base fee
+}
+
+// EstimateFee suggests low, medium and high fees from the percentiles of the fees paid
+// by the transactions of the recent blocks and of the txpool. No suggestion is below
+// the lowest fee the txpool accepts, which all of them are without recent transactions.
+func (api *FeeAPI) EstimateFee(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	fees := []*big.Int{}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for n, i := latest.Number.Uint64(), uint64(0); n > 0 && i < feeHistoryBlocks; n, i = n-1, i+1 {
+		block, err := api.BlockchainDB.GetBlockByNumber(new(big.Int).SetUint64(n))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			fees = append(fees, tx.EffectiveFee(block.BaseFee))
+		}
+	}
+
+	for _, tx := range api.TxPool.Pending() {
+		fees = append(fees, tx.FeeCap())
+	}
+
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].Cmp(fees[j]) < 0
+	})
+
+	floor := api.TxPool.FeeFloor()
+
+	suggest := func(percentile int) string {
+		if len(fees) == 0 {
+			return floor.String()
+		}
+
+		fee := fees[(len(fees)-1)*percentile/100]
+		if fee.Cmp(floor) < 0 {
+			return floor.String()
+		}
+
+		return fee.String()
+	}
+
+	return &RPCFeeEstimate{
+		Low:    suggest(lowFeePercentile),
+		Medium: suggest(mediumFeePercentile),
+		High:   suggest(highFeePercentile),
+		Floor:  floor.String(),
+	}, nil
+}
@@ -0,0 +1,104 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+)
+
+// HealthPath is the HTTP path of the health check.
+const HealthPath = "/health"
+
+// Node reports the state of the node checked by the health endpoint, and its peers.
+type Node interface {
+	Height() uint64
+	PeerCount() int
+	Peers() []*Peer
+	HighestPeerHeight() uint64
+	Syncing() bool
+	Mining() bool
+}
+
+// Health is the state of the node returned by chain_status and the health endpoint.
+type Health struct {
+	Healthy bool   `json:"healthy"`
+	Syncing bool   `json:"syncing"`
+	Peers   int    `json:"peers"`
+	Height  uint64 `json:"height"`
+	Mining  bool   `json:"mining"`
+}
+
+// SyncStatus is the progress of the node towards the highest peer, returned by
+// chain_syncStatus.
+type SyncStatus struct {
+	CurrentHeight uint64  `json:"currentHeight"`
+	HighestHeight uint64  `json:"highestHeight"` // Highest height advertised by the connected peers
+	Percentage    float64 `json:"percentage"`
+	Synced        bool    `json:"synced"`
+}
+
+// HealthAPI serves chain_status and the health endpoint.
+type HealthAPI struct {
+	Node     Node
+	MinPeers int // Connected peers required to be healthy
+}
+
+// health returns the state of the node. It is unhealthy while it has less than
+// MinPeers peers.
+func (api *HealthAPI) health() *Health {
+	peers := api.Node.PeerCount()
+
+	return &Health{
+		Healthy: peers >= api.MinPeers,
+		Syncing: api.Node.Syncing(),
+		Peers:   peers,
+		Height:  api.Node.Height(),
+		Mining:  api.Node.Mining(),
+	}
+}
+
+// Status returns the state of the node.
+func (api *HealthAPI) Status(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	return api.health(), nil
+}
+
+// SyncStatus returns the height of the node against the highest height of its peers.
+// The node is synced once no connected peer is ahead of it.
+func (api *HealthAPI) SyncStatus(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	status := &SyncStatus{
+		CurrentHeight: api.Node.Height(),
+		HighestHeight: api.Node.HighestPeerHeight(),
+		Percentage:    100,
+		Synced:        true,
+	}
+
+	if status.HighestHeight > status.CurrentHeight {
+		status.Percentage = math.Floor(float64(status.CurrentHeight)/float64(status.HighestHeight)*10000) / 100
+		status.Synced = false
+	}
+
+	return status, nil
+}
+
+// ServeHTTP answers with the state of the node, with a 200 status if it is healthy and
+// 503 otherwise.
+func (api *HealthAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := api.health()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !health.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	// nolint : errchkjson
+	json.NewEncoder(w).Encode(health)
+}
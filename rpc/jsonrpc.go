@@ -0,0 +1,257 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const jsonrpcVersion = "2.0"
+
+// JSON-RPC 2.0 error codes.
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewInvalidParamsError returns an invalid params JSON-RPC error.
+func NewInvalidParamsError(format string, args ...interface{}) *Error {
+	return &Error{Code: ErrCodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// methodFunc handles a JSON-RPC method called with positional params.
+type methodFunc func(params []json.RawMessage) (interface{}, error)
+
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// RegisterMethod registers a JSON-RPC method under the given name.
+func (s *RPCServer) RegisterMethod(name string, method methodFunc) {
+	s.methods[name] = method
+}
+
+// ServeHTTP serves JSON-RPC 2.0 requests sent over HTTP POST. A batch of requests sent
+// as an array is answered with the array of the responses in the same order, leaving out
+// the notifications. Bodies larger than the maximum body size get a 413 status.
+func (s *RPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxBodyBytes := s.maxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	if r.ContentLength > maxBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var body json.RawMessage
+
+	var res interface{}
+
+	var tooLarge *http.MaxBytesError
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&body); errors.As(err, &tooLarge) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		res = errorResponse(nil, &Error{Code: ErrCodeParse, Message: "parse error"})
+	} else if isBatch(body) {
+		res = s.handleBatch(body, clientIP(r))
+	} else {
+		var req jsonrpcRequest
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			res = errorResponse(nil, &Error{Code: ErrCodeInvalidRequest, Message: "invalid request"})
+		} else if !s.allowRequest(&req, clientIP(r), 1) {
+			res = errorResponse(req.ID, &Error{Code: ErrCodeRateLimited, Message: "rate limited"})
+		} else {
+			res = s.handle(&req)
+		}
+	}
+
+	// A batch of notifications only gets an empty body.
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// nolint : errchkjson
+	json.NewEncoder(w).Encode(res)
+}
+
+// isBatch reports whether the request body is an array of requests.
+func isBatch(body json.RawMessage) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch handles each request of the batch from the client IP in order. Batches of
+// more than the maximum batch size are refused as a whole. Each request takes its cost
+// from the rate limit of the client, the HTTP request having paid for one token, and is
+// refused as rate limited once there are not enough left. It returns nil if the batch
+// only holds notifications, which get no response.
+func (s *RPCServer) handleBatch(body json.RawMessage, ip string) interface{} {
+	var reqs []json.RawMessage
+
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		return errorResponse(nil, &Error{Code: ErrCodeParse, Message: "parse error"})
+	}
+
+	if len(reqs) == 0 {
+		return errorResponse(nil, &Error{Code: ErrCodeInvalidRequest, Message: "empty batch"})
+	}
+
+	maxBatchSize := s.maxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+
+	if len(reqs) > maxBatchSize {
+		return errorResponse(nil, &Error{Code: ErrCodeInvalidRequest, Message: fmt.Sprintf("batch too large : %d requests, maximum %d", len(reqs), maxBatchSize)})
+	}
+
+	responses := []*jsonrpcResponse{}
+
+	for i, raw := range reqs {
+		var req jsonrpcRequest
+
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses = append(responses, errorResponse(nil, &Error{Code: ErrCodeInvalidRequest, Message: "invalid request"}))
+			continue
+		}
+
+		paid := 0
+		if i == 0 {
+			paid = 1
+		}
+
+		var res *jsonrpcResponse
+
+		if !s.allowRequest(&req, ip, paid) {
+			res = errorResponse(req.ID, &Error{Code: ErrCodeRateLimited, Message: "rate limited"})
+		} else {
+			res = s.handle(&req)
+		}
+
+		if len(req.ID) == 0 {
+			continue
+		}
+
+		responses = append(responses, res)
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return responses
+}
+
+// allowRequest takes the cost of the request, less the tokens already paid, from the rate
+// limit of the client IP, reporting whether there were enough tokens left.
+func (s *RPCServer) allowRequest(req *jsonrpcRequest, ip string, paid int) bool {
+	if s.limiter == nil {
+		return true
+	}
+
+	return s.limiter.allowN(ip, requestCost(req)-paid)
+}
+
+// requestCost returns the number of rate limit tokens the request takes : one, or one per
+// transaction of a chain_sendRawTransactions batch.
+func requestCost(req *jsonrpcRequest) int {
+	if req.Method != "chain_sendRawTransactions" {
+		return 1
+	}
+
+	var params []json.RawMessage
+
+	var txs []json.RawMessage
+
+	if json.Unmarshal(req.Params, &params) != nil || len(params) != 1 || json.Unmarshal(params[0], &txs) != nil || len(txs) == 0 {
+		return 1
+	}
+
+	return len(txs)
+}
+
+func (s *RPCServer) handle(req *jsonrpcRequest) *jsonrpcResponse {
+	if req.Version != jsonrpcVersion || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: ErrCodeInvalidRequest, Message: "invalid request"})
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		return errorResponse(req.ID, &Error{Code: ErrCodeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist", req.Method)})
+	}
+
+	var params []json.RawMessage
+
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req.ID, NewInvalidParamsError("params must be an array"))
+		}
+	}
+
+	result, err := method(params)
+	if err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return errorResponse(req.ID, rpcErr)
+		}
+
+		return errorResponse(req.ID, &Error{Code: ErrCodeInternal, Message: err.Error()})
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: ErrCodeInternal, Message: err.Error()})
+	}
+
+	return &jsonrpcResponse{Version: jsonrpcVersion, ID: responseID(req.ID), Result: encoded}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *jsonrpcResponse {
+	return &jsonrpcResponse{Version: jsonrpcVersion, ID: responseID(id), Error: err}
+}
+
+func responseID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+
+	return id
+}
@@ -0,0 +1,38 @@
+package rpc
+
+import "encoding/json"
+
+// Miner turns block sealing on and off on a running node.
+type Miner interface {
+	Start() error
+	Stop()
+}
+
+// MinerAPI serves the methods of the miner_ namespace.
+type MinerAPI struct {
+	Miner Miner
+}
+
+// Start resumes mining new blocks.
+func (api *MinerAPI) Start(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	if err := api.Miner.Start(); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+// Stop stops mining new blocks. Pending transactions stay in the txpool.
+func (api *MinerAPI) Stop(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	api.Miner.Stop()
+
+	return true, nil
+}
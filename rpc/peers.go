@@ -0,0 +1,30 @@
+package rpc
+
+import "encoding/json"
+
+// Peer is a connected peer returned by chain_peers.
+type Peer struct {
+	Addr            string `json:"addr"`
+	Direction       string `json:"direction"` // "inbound" or "outbound"
+	ProtocolVersion uint64 `json:"protocolVersion"`
+	Height          uint64 `json:"height"`
+}
+
+const (
+	PeerInbound  = "inbound"
+	PeerOutbound = "outbound"
+)
+
+// PeersAPI serves chain_peers.
+type PeersAPI struct {
+	Node Node
+}
+
+// Peers returns the peers the node is connected to.
+func (api *PeersAPI) Peers(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	return api.Node.Peers(), nil
+}
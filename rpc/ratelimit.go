@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCodeRateLimited is the JSON-RPC error code of requests refused by the rate limiter.
+const ErrCodeRateLimited = -32005
+
+// bucketSweepInterval is how often buckets which refilled completely are dropped.
+var bucketSweepInterval = time.Minute
+
+// bucket is the token bucket of a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits the request rate of each client IP with a token bucket refilled
+// at rate tokens per second, holding at most burst tokens.
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	whitelist []string
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing rate requests per second per client,
+// with bursts of up to burst requests. A burst below one uses the rate, rounded up.
+// Whitelisted IPs, or "localhost" for loopback addresses, aren't limited.
+func newRateLimiter(rate float64, burst int, whitelist []string) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		whitelist: whitelist,
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}
+}
+
+// whitelisted reports whether the IP bypasses the limit.
+func (l *rateLimiter) whitelisted(ip string) bool {
+	parsed := net.ParseIP(ip)
+
+	for _, entry := range l.whitelist {
+		if entry == "localhost" && parsed != nil && parsed.IsLoopback() {
+			return true
+		}
+
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allow takes a token from the bucket of the IP, reporting whether one was left.
+func (l *rateLimiter) allow(ip string) bool {
+	return l.allowN(ip, 1)
+}
+
+// allowN takes n tokens from the bucket of the IP, reporting whether there were as many
+// left. None are taken otherwise.
+func (l *rateLimiter) allowN(ip string, n int) bool {
+	if n <= 0 || l.whitelisted(ip) {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+
+	return true
+}
+
+// sweep drops the buckets which refilled completely, they are recreated full on the
+// next request of their client.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// clientIP returns the IP of the client sending the request.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// middleware refuses the requests of clients over their limit with a 429 status and a
+// JSON-RPC rate limited error.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(clientIP(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/l.rate))))
+		w.WriteHeader(http.StatusTooManyRequests)
+
+		// nolint : errchkjson
+		json.NewEncoder(w).Encode(errorResponse(nil, &Error{Code: ErrCodeRateLimited, Message: "rate limited"}))
+	})
+}
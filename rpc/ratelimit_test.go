@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/txpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	l := newRateLimiter(2, 3, nil)
+	l.now = func() time.Time { return now }
+
+	// The burst is served at once, then the bucket is empty.
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.allow("10.0.0.1"))
+	}
+
+	assert.False(t, l.allow("10.0.0.1"))
+
+	// Other clients have their own bucket.
+	assert.True(t, l.allow("10.0.0.2"))
+
+	// Two tokens are added per second, never more than the burst.
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.allow("10.0.0.1"))
+	assert.False(t, l.allow("10.0.0.1"))
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.allow("10.0.0.1"))
+	}
+
+	assert.False(t, l.allow("10.0.0.1"))
+
+	// Refilled buckets are swept.
+	now = now.Add(time.Hour)
+	l.allow("10.0.0.3")
+	assert.Len(t, l.buckets, 1)
+
+	// The burst defaults to the rate.
+	assert.Equal(t, float64(3), newRateLimiter(2.5, 0, nil).burst)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	s := &RPCServer{methods: make(map[string]methodFunc)}
+	s.RegisterMethod("test_ping", func(params []json.RawMessage) (interface{}, error) { return "pong", nil })
+
+	handler := newRateLimiter(1, 5, []string{"localhost", "10.0.0.9"}).middleware(s)
+
+	call := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"test_ping"}`))
+		req.RemoteAddr = remoteAddr
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	throttled := func(remoteAddr string) int {
+		count := 0
+
+		for i := 0; i < 20; i++ {
+			if call(remoteAddr).Code == http.StatusTooManyRequests {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	// 20 requests in a row are well over 1 per second with bursts of 5.
+	assert.GreaterOrEqual(t, throttled("10.0.0.1:4000"), 14)
+
+	rec := call("10.0.0.1:4001")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	var res jsonrpcResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, ErrCodeRateLimited, res.Error.Code)
+
+	// Whitelisted clients are never throttled.
+	assert.Equal(t, 0, throttled("127.0.0.1:4000"))
+	assert.Equal(t, 0, throttled("[::1]:4000"))
+	assert.Equal(t, 0, throttled("10.0.0.9:4000"))
+	assert.Equal(t, http.StatusOK, call("127.0.0.1:4000").Code)
+}
+
+func TestBatchLimits(t *testing.T) {
+	t.Parallel()
+
+	limiter := newRateLimiter(1, 5, nil)
+
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	s := &RPCServer{methods: make(map[string]methodFunc), limiter: limiter, maxBatchSize: 10}
+	s.RegisterMethod("test_ping", func(params []json.RawMessage) (interface{}, error) { return "pong", nil })
+
+	handler := limiter.middleware(s)
+
+	call := func(size int) []*jsonrpcResponse {
+		reqs := make([]string, size)
+		for i := range reqs {
+			reqs[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"test_ping"}`, i)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+strings.Join(reqs, ",")+"]"))
+		req.RemoteAddr = "10.0.0.1:4000"
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var batch []*jsonrpcResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil {
+			var res jsonrpcResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+			return []*jsonrpcResponse{&res}
+		}
+
+		return batch
+	}
+
+	// Batches over the maximum size are refused as a whole, without taking more tokens.
+	res := call(11)
+	assert.Len(t, res, 1)
+	assert.Equal(t, ErrCodeInvalidRequest, res[0].Error.Code)
+
+	// Each request of a batch takes a token, those past the burst are rate limited.
+	res = call(8)
+	assert.Len(t, res, 8)
+
+	limited := 0
+
+	for _, r := range res {
+		if r.Error != nil {
+			assert.Equal(t, ErrCodeRateLimited, r.Error.Code)
+			limited++
+		}
+	}
+
+	assert.Equal(t, 4, limited)
+}
+
+func TestSendRawTransactionsLimits(t *testing.T) {
+	t.Parallel()
+
+	limiter := newRateLimiter(1, 5, nil)
+
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	s := &RPCServer{methods: make(map[string]methodFunc), limiter: limiter}
+	pool := &TxPoolAPI{TxPool: txpool.NewTxPool(config.DefaultConfig(), nil, nil), MaxTxs: 4}
+	s.RegisterMethod("chain_sendRawTransactions", pool.SendRawTransactions)
+
+	handler := limiter.middleware(s)
+
+	call := func(txs int) *jsonrpcResponse {
+		raws, err := json.Marshal(make([]string, txs))
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"chain_sendRawTransactions","params":[%s]}`, raws)))
+		req.RemoteAddr = "10.0.0.1:4000"
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var res jsonrpcResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+		return &res
+	}
+
+	// Each transaction takes a token, a call holding more than the tokens left is refused.
+	assert.Nil(t, call(3).Error)
+	assert.Equal(t, ErrCodeRateLimited, call(3).Error.Code)
+
+	// So are calls over the maximum number of transactions.
+	now = now.Add(time.Minute)
+	assert.Equal(t, ErrCodeInvalidParams, call(5).Error.Code)
+}
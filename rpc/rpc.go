@@ -1,29 +1,112 @@
 package rpc
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"net/rpc"
-	"sync"
+	"time"
 
+	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/txpool"
+	"golang.org/x/net/websocket"
 )
 
 type RPCServer struct {
 	Server     *rpc.Server
 	Addr       string
 	HttpServer *http.Server
+
+	methods map[string]methodFunc
+	subs    *subscriptionHub
+	limiter *rateLimiter
+	health  *HealthAPI
+
+	minPeersForHealthy int
+	debug              bool
+	corsOrigins        []string
+	authToken          string
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
+	maxBodyBytes       int64
+	maxBatchSize       int
 }
 
-type RPCDomains struct {
-	TxPool *txpool.TxPool
+// Defaults of the ServerOptions bounding requests.
+var (
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 30 * time.Second
+	defaultMaxBodyBytes = int64(5 * 1024 * 1024)
+	defaultMaxBatchSize = 100
+)
+
+// ServerOptions configure the HTTP server of the RPC endpoint. Nil options serve every
+// request.
+type ServerOptions struct {
+	// RateLimit is the number of requests per second served per client IP, with bursts
+	// of up to RateBurst requests. Zero disables the limit.
+	RateLimit float64
+	RateBurst int
+
+	// RateLimitWhitelist are the client IPs, or "localhost" for loopback clients, which
+	// bypass the rate limit.
+	RateLimitWhitelist []string
+
+	// MinPeersForHealthy is the number of connected peers below which the health check
+	// reports the node as unhealthy.
+	MinPeersForHealthy int
+
+	// ReadTimeout and WriteTimeout bound the time taken to read a request and to write
+	// its response, and MaxBodyBytes the size of a JSON-RPC request body. Zero uses
+	// 30 seconds timeouts and a 5 MiB maximum.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	MaxBodyBytes int64
+
+	// MaxBatchSize is the maximum number of requests of a batch, and of transactions of
+	// a chain_sendRawTransactions call, 100 if zero.
+	MaxBatchSize int
+
+	// Debug serves the debug_ namespace.
+	Debug bool
+
+	// CORSOrigins are the origins, or "*" for any of them, whose pages may call the
+	// server from a browser. Empty allows none.
+	CORSOrigins []string
+
+	// AuthToken is the token requests must carry in an "Authorization: Bearer" header.
+	// Empty serves requests without one.
+	AuthToken string
 }
 
-var registerOnce sync.Once
+type RPCDomains struct {
+	TxPool        *txpool.TxPool
+	BlockchainDB  *dbstore.BlockchainDB
+	StateDB       *dbstore.StateDB
+	Miner         Miner
+	Node          Node
+	ChainID       uint64 // Chain id transactions are signed for
+	FinalityDepth uint64 // Blocks a block has to be buried under to be final, zero if never
+}
 
-func NewRPCServer(addr string, domains *RPCDomains) *RPCServer {
+func NewRPCServer(addr string, domains *RPCDomains, opts *ServerOptions) *RPCServer {
 	srv := rpc.NewServer()
-	rpcServer := &RPCServer{Server: srv, Addr: addr}
+	rpcServer := &RPCServer{Server: srv, Addr: addr, methods: make(map[string]methodFunc), subs: newSubscriptionHub()}
+
+	if opts != nil {
+		rpcServer.minPeersForHealthy = opts.MinPeersForHealthy
+		rpcServer.readTimeout = opts.ReadTimeout
+		rpcServer.writeTimeout = opts.WriteTimeout
+		rpcServer.maxBodyBytes = opts.MaxBodyBytes
+		rpcServer.maxBatchSize = opts.MaxBatchSize
+		rpcServer.debug = opts.Debug
+		rpcServer.corsOrigins = opts.CORSOrigins
+		rpcServer.authToken = opts.AuthToken
+
+		if opts.RateLimit > 0 {
+			rpcServer.limiter = newRateLimiter(opts.RateLimit, opts.RateBurst, opts.RateLimitWhitelist)
+		}
+	}
 
 	if err := rpcServer.ActivateModules(domains); err != nil {
 		log.Fatalf("Couldn't activate modules. Error %s", err)
@@ -35,12 +118,49 @@ func NewRPCServer(addr string, domains *RPCDomains) *RPCServer {
 }
 
 func (s *RPCServer) Start(addr string) {
-	registerOnce.Do(func() {
-		rpc.HandleHTTP()
-	})
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, s.Server)
+	mux.Handle(WebSocketPath, websocket.Server{Handler: s.serveWebSocket})
+	mux.Handle("/", s)
 
-	// nolint : gosec
-	srv := &http.Server{Addr: addr}
+	if s.health != nil {
+		mux.Handle(HealthPath, s.health)
+	}
+
+	var handler http.Handler = mux
+	if s.authToken != "" {
+		handler = authMiddleware(s.authToken, handler)
+	}
+
+	// Requests with a wrong token count against the rate limit, slowing down guesses.
+	if s.limiter != nil {
+		handler = s.limiter.middleware(handler)
+	}
+
+	// Preflight requests, which browsers send without the Authorization header, don't
+	// count against the rate limit, and refused requests still carry the CORS headers the
+	// page needs to read the error.
+	if len(s.corsOrigins) > 0 {
+		handler = corsMiddleware(s.corsOrigins, handler)
+	}
+
+	readTimeout := s.readTimeout
+	if readTimeout <= 0 {
+		readTimeout = defaultReadTimeout
+	}
+
+	writeTimeout := s.writeTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = defaultWriteTimeout
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
 
 	log.Println("Serving RPC handler")
 
@@ -55,10 +175,80 @@ func (s *RPCServer) Start(addr string) {
 	s.HttpServer = srv
 }
 
+// shutdownTimeout is how long Stop waits for in-flight requests to finish.
+var shutdownTimeout = 5 * time.Second
+
+// Stop gracefully shuts down the HTTP server, draining in-flight requests, and closes
+// the WebSocket connections.
+func (s *RPCServer) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.HttpServer.Shutdown(ctx)
+	s.subs.closeAll()
+
+	return err
+}
+
 func (s *RPCServer) ActivateModules(domains *RPCDomains) error {
 	if domains.TxPool != nil {
 		// nolint : errcheck
-		rpc.Register(domains.TxPool)
+		s.Server.Register(domains.TxPool)
+
+		pool := &TxPoolAPI{TxPool: domains.TxPool, MaxTxs: s.maxBatchSize}
+		s.RegisterMethod("chain_pendingTransactions", pool.PendingTransactions)
+		s.RegisterMethod("chain_sendRawTransactions", pool.SendRawTransactions)
+		s.RegisterMethod("chain_simulateTransaction", pool.SimulateTransaction)
+		s.RegisterMethod("chain_getTransactionCount", pool.GetTransactionCount)
+		s.RegisterMethod("txpool_content", pool.Content)
+	}
+
+	if domains.BlockchainDB != nil {
+		chain := &ChainAPI{BlockchainDB: domains.BlockchainDB, ChainID: domains.ChainID, FinalityDepth: domains.FinalityDepth}
+		s.RegisterMethod("chain_getBlockNumber", chain.GetBlockNumber)
+		s.RegisterMethod("chain_chainId", chain.GetChainID)
+		s.RegisterMethod("chain_getBlockByNumber", chain.GetBlockByNumber)
+		s.RegisterMethod("chain_getBlockByHash", chain.GetBlockByHash)
+		s.RegisterMethod("chain_isFinalized", chain.IsFinalized)
+		s.RegisterMethod("chain_getTransactionByHash", chain.GetTransactionByHash)
+		s.RegisterMethod("chain_getTransactions", chain.GetTransactions)
+		s.RegisterMethod("chain_getTransactionProof", chain.GetTransactionProof)
+		s.RegisterMethod("chain_getTransactionReceipt", chain.GetTransactionReceipt)
+	}
+
+	if domains.BlockchainDB != nil && domains.TxPool != nil {
+		fees := &FeeAPI{BlockchainDB: domains.BlockchainDB, TxPool: domains.TxPool}
+		s.RegisterMethod("chain_estimateFee", fees.EstimateFee)
+	}
+
+	if domains.StateDB != nil {
+		state := &StateAPI{StateDB: domains.StateDB, BlockchainDB: domains.BlockchainDB}
+		s.RegisterMethod("chain_getBalance", state.GetBalance)
+		s.RegisterMethod("chain_getAccounts", state.GetAccounts)
+
+		if domains.BlockchainDB != nil {
+			s.RegisterMethod("chain_getBalanceAt", state.GetBalanceAt)
+		}
+
+		if s.debug {
+			s.RegisterMethod("debug_dumpState", state.DumpState)
+		}
+	}
+
+	if domains.Node != nil {
+		s.health = &HealthAPI{Node: domains.Node, MinPeers: s.minPeersForHealthy}
+		s.RegisterMethod("chain_status", s.health.Status)
+		s.RegisterMethod("chain_syncStatus", s.health.SyncStatus)
+
+		peers := &PeersAPI{Node: domains.Node}
+		s.RegisterMethod("chain_peers", peers.Peers)
+	}
+
+	// Controlling the miner is only served to callers holding the auth token.
+	if domains.Miner != nil && s.authToken != "" {
+		miner := &MinerAPI{Miner: domains.Miner}
+		s.RegisterMethod("miner_start", miner.Start)
+		s.RegisterMethod("miner_stop", miner.Stop)
 	}
 
 	return nil
@@ -1,13 +1,18 @@
 package rpc
 
 import (
+	"encoding/json"
 	"log"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"net/rpc"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/txpool"
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
@@ -22,13 +27,15 @@ func TestTxpoolRPC(t *testing.T) {
 	rpcPort := ":1711"
 	pkey := util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
 	ua := util.NewUnlockedAccount(pkey)
+	ua2 := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
 
-	txpool := txpool.NewTxPool(config.DefaultConfig().MinFee, nil, nil)
-	NewRPCServer(rpcPort, &RPCDomains{TxPool: txpool})
+	txpool := txpool.NewTxPool(config.DefaultConfig(), nil, nil)
+	NewRPCServer(rpcPort, &RPCDomains{TxPool: txpool}, nil)
 	time.Sleep(2 * time.Second)
 
 	// Send add Transacation request 1
-	tx1 := newTransaction(t, []byte{0x01}, []byte{0x02}, "hello", 100, 100, 0)
+	tx1 := newTransaction(t, ua.Address().Bytes(), []byte{0x02}, "hello", 100, 100, 0)
+	tx1.ChainID = 1
 	tx1.Sign(ua)
 
 	res, err := SendRpcRequest(t, "TxPool.AddTx_RPC", tx1, rpcPort)
@@ -41,7 +48,9 @@ func TestTxpoolRPC(t *testing.T) {
 	}
 
 	// Send add Transacation request 2
-	tx2 := newTransaction(t, []byte{0x02}, []byte{0x03}, "hello1", 101, 101, 1)
+	tx2 := newTransaction(t, ua2.Address().Bytes(), []byte{0x03}, "hello1", 101, 101, 1)
+	tx2.ChainID = 1
+	tx2.Sign(ua2)
 
 	res, err = SendRpcRequest(t, "TxPool.AddTx_RPC", tx2, rpcPort)
 	if err != nil {
@@ -75,6 +84,43 @@ func TestTxpoolRPC(t *testing.T) {
 	assert.Equal(t, tx1, txs.Array()[1])
 }
 
+func TestMaxBodyBytes(t *testing.T) {
+	t.Parallel()
+
+	s := &RPCServer{methods: make(map[string]methodFunc), maxBodyBytes: 64}
+	s.RegisterMethod("test_ping", func(params []json.RawMessage) (interface{}, error) { return "pong", nil })
+
+	call := func(body string, contentLength int64) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.ContentLength = contentLength
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	small := `{"jsonrpc":"2.0","id":1,"method":"test_ping"}`
+	large := `{"jsonrpc":"2.0","id":1,"method":"test_ping","params":["` + strings.Repeat("a", 64) + `"]}`
+
+	assert.Equal(t, http.StatusOK, call(small, int64(len(small))))
+
+	// Oversized bodies are refused whether their length is announced or not.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, call(large, int64(len(large))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, call(large, -1))
+}
+
+func TestServerTimeouts(t *testing.T) {
+	t.Parallel()
+
+	srv := NewRPCServer(":1713", &RPCDomains{}, &ServerOptions{ReadTimeout: 2 * time.Second})
+	defer srv.Stop()
+
+	assert.Equal(t, 2*time.Second, srv.HttpServer.ReadTimeout)
+	assert.Equal(t, 2*time.Second, srv.HttpServer.ReadHeaderTimeout)
+	assert.Equal(t, defaultWriteTimeout, srv.HttpServer.WriteTimeout)
+}
+
 func SendRpcRequest(t *testing.T, method string, params interface{}, addr string) (interface{}, error) {
 	t.Helper()
 
@@ -107,3 +153,41 @@ func newTransaction(t *testing.T, from, to []byte, msg string, fee, value int64,
 		Nonce: big.NewInt(nonce),
 	}
 }
+
+func TestDebugMethodsDisabled(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewMemoryDBInstance()
+	assert.NoError(t, err)
+
+	domains := &RPCDomains{StateDB: dbstore.NewStateDB(db)}
+
+	s := &RPCServer{Server: rpc.NewServer(), methods: make(map[string]methodFunc)}
+	assert.NoError(t, s.ActivateModules(domains))
+	assert.NotContains(t, s.methods, "debug_dumpState")
+
+	s = &RPCServer{Server: rpc.NewServer(), methods: make(map[string]methodFunc), debug: true}
+	assert.NoError(t, s.ActivateModules(domains))
+	assert.Contains(t, s.methods, "debug_dumpState")
+}
+
+type testMiner struct{}
+
+func (testMiner) Start() error { return nil }
+func (testMiner) Stop()        {}
+
+func TestMinerMethodsRequireAuthToken(t *testing.T) {
+	t.Parallel()
+
+	domains := &RPCDomains{Miner: testMiner{}}
+
+	s := &RPCServer{Server: rpc.NewServer(), methods: make(map[string]methodFunc)}
+	assert.NoError(t, s.ActivateModules(domains))
+	assert.NotContains(t, s.methods, "miner_start")
+	assert.NotContains(t, s.methods, "miner_stop")
+
+	s = &RPCServer{Server: rpc.NewServer(), methods: make(map[string]methodFunc), authToken: "s3cret"}
+	assert.NoError(t, s.ActivateModules(domains))
+	assert.Contains(t, s.methods, "miner_start")
+	assert.Contains(t, s.methods, "miner_stop")
+}
@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/dbstore"
+	"github.com/0xsharma/compact-chain/util"
+	lutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrCodeStatePruned is the JSON-RPC error code of reads of the state at blocks whose
+// state history was pruned.
+const ErrCodeStatePruned = -32007
+
+// dumpStatePageSize is the default and maximum number of accounts returned by a single
+// debug_dumpState call.
+var dumpStatePageSize = 1000
+
+// getAccountsLimit is the maximum number of addresses queried by a single chain_getAccounts
+// call.
+var getAccountsLimit = 1000
+
+// StateDump is a page of the account balances, in address order.
+type StateDump struct {
+	Accounts map[string]string `json:"accounts"`       // Decimal balances by address
+	Next     string            `json:"next,omitempty"` // Address the next page starts at, empty on the last page
+}
+
+// RPCAccount is the JSON-RPC representation of the state of an address.
+type RPCAccount struct {
+	Address string `json:"address"`
+	Balance string `json:"balance"` // Decimal
+	Nonce   string `json:"nonce"`   // Hex nonce of the next transaction of the address
+}
+
+// StateAPI serves the account state methods of the chain_ namespace.
+type StateAPI struct {
+	StateDB      *dbstore.StateDB
+	BlockchainDB *dbstore.BlockchainDB // Resolves the block numbers of historical reads
+}
+
+// GetBalance returns the balance of the given address as a decimal string.
+// Unknown addresses have a zero balance.
+func (api *StateAPI) GetBalance(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	address, err := parseAddress(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := api.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, address.String()))
+	if err != nil {
+		return "0", nil
+	}
+
+	return new(big.Int).SetBytes(balance).String(), nil
+}
+
+// GetBalanceAt returns the balance of the given address after the block with the given
+// number, as a decimal string, read from the state history. Blocks past the head are
+// invalid params, and blocks below the retained history get an ErrCodeStatePruned error.
+func (api *StateAPI) GetBalanceAt(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, NewInvalidParamsError("expected 2 params, got %d", len(params))
+	}
+
+	address, err := parseAddress(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := api.BlockchainDB.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := parseBlockNumber(params[1], latest.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	if number.Cmp(latest.Number) > 0 {
+		return nil, NewInvalidParamsError("unknown block %s, the head is block %s", number, latest.Number)
+	}
+
+	balance, _, err := api.StateDB.GetStateAt(dbstore.PrefixKey(dbstore.BalanceKey, address.String()), number.Uint64())
+	if errors.Is(err, dbstore.ErrStatePruned) {
+		return nil, &Error{Code: ErrCodeStatePruned, Message: err.Error()}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(balance).String(), nil
+}
+
+// GetAccounts returns the balances and nonces of the given addresses, in their order,
+// as of the head state. The nonces are the ones of the next transactions, ignoring the
+// txpool. Unknown addresses have a zero balance and nonce.
+func (api *StateAPI) GetAccounts(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(params[0], &raws); err != nil {
+		return nil, NewInvalidParamsError("addresses must be an array of hex strings")
+	}
+
+	if len(raws) > getAccountsLimit {
+		return nil, NewInvalidParamsError("at most %d addresses, got %d", getAccountsLimit, len(raws))
+	}
+
+	accounts := make([]*RPCAccount, len(raws))
+
+	for i, raw := range raws {
+		address, err := parseAddress(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		account := &RPCAccount{Address: address.String(), Balance: "0", Nonce: encodeBig(big.NewInt(0))}
+
+		if balance, err := api.StateDB.DB.Get(dbstore.PrefixKey(dbstore.BalanceKey, address.String())); err == nil {
+			account.Balance = new(big.Int).SetBytes(balance).String()
+		}
+
+		// The state holds the nonce of the last transaction of the address.
+		if nonce, err := api.StateDB.DB.Get(dbstore.PrefixKey(dbstore.NonceKey, address.String())); err == nil {
+			account.Nonce = encodeBig(new(big.Int).Add(new(big.Int).SetBytes(nonce), big.NewInt(1)))
+		}
+
+		accounts[i] = account
+	}
+
+	return accounts, nil
+}
+
+// DumpState returns the balances of the accounts of the current state, from the optional
+// start address on and up to the optional number of accounts. Large states are dumped a
+// page at a time, each call passing the next address of the previous one. Pages are read
+// from the state at the time of each call.
+func (api *StateAPI) DumpState(params []json.RawMessage) (interface{}, error) {
+	if len(params) > 2 {
+		return nil, NewInvalidParamsError("expected at most 2 params, got %d", len(params))
+	}
+
+	prefix := lutil.BytesPrefix([]byte(dbstore.BalanceKey))
+
+	if len(params) > 0 {
+		start, err := parseAddress(params[0])
+		if err != nil {
+			return nil, err
+		}
+
+		prefix.Start = []byte(dbstore.PrefixKey(dbstore.BalanceKey, start.String()))
+	}
+
+	limit := dumpStatePageSize
+
+	if len(params) > 1 {
+		if err := json.Unmarshal(params[1], &limit); err != nil || limit <= 0 {
+			return nil, NewInvalidParamsError("limit must be a positive integer")
+		}
+
+		if limit > dumpStatePageSize {
+			limit = dumpStatePageSize
+		}
+	}
+
+	dump := &StateDump{Accounts: make(map[string]string)}
+
+	iter := api.StateDB.DB.LevelDb.NewIterator(prefix, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		address := string(iter.Key()[len(dbstore.BalanceKey):])
+
+		// Rolled back transactions leave zero balances behind.
+		if len(iter.Value()) == 0 {
+			continue
+		}
+
+		if len(dump.Accounts) == limit {
+			dump.Next = address
+			break
+		}
+
+		dump.Accounts[address] = new(big.Int).SetBytes(iter.Value()).String()
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return dump, nil
+}
+
+// parseAddress parses a hex encoded address param.
+func parseAddress(raw json.RawMessage) (*util.Address, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return nil, NewInvalidParamsError("address must be a hex string")
+	}
+
+	address, err := util.HexToAddress(str)
+	if err != nil {
+		return nil, NewInvalidParamsError("%s", err)
+	}
+
+	return address, nil
+}
@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/0xsharma/compact-chain/txpool"
+	"github.com/0xsharma/compact-chain/types"
+)
+
+// TxPoolAPI serves the txpool inspection methods of the chain_ namespace.
+type TxPoolAPI struct {
+	TxPool *txpool.TxPool
+	MaxTxs int // Maximum number of transactions of a chain_sendRawTransactions batch, 100 if zero
+}
+
+// RPCPendingTransaction is the JSON-RPC representation of a transaction waiting in the txpool.
+type RPCPendingTransaction struct {
+	Hash  string `json:"hash"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+	Fee   string `json:"fee"` // Maximum fee of dynamic fee transactions
+	Nonce string `json:"nonce"`
+	Gas   string `json:"gas"`
+
+	MaxFee         string `json:"maxFee,omitempty"`
+	MaxPriorityFee string `json:"maxPriorityFee,omitempty"`
+}
+
+// RPCPendingTransactions lists the transactions of the txpool. Pending ones are ready to
+// be mined, queued ones wait for a nonce gap of their sender to fill.
+type RPCPendingTransactions struct {
+	Pending []*RPCPendingTransaction `json:"pending"`
+	Queued  []*RPCPendingTransaction `json:"queued"`
+}
+
+// NewRPCPendingTransaction converts a txpool transaction into its JSON-RPC representation.
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCPendingTransaction {
+	rpcTx := &RPCPendingTransaction{
+		Hash:  tx.Hash().String(),
+		From:  tx.From.String(),
+		To:    tx.To.String(),
+		Value: tx.Value.String(),
+		Fee:   tx.FeeCap().String(),
+		Nonce: encodeBig(tx.Nonce),
+		Gas:   encodeBig(new(big.Int).SetUint64(tx.Gas())),
+	}
+
+	if tx.IsDynamicFee() {
+		rpcTx.MaxFee = tx.MaxFee.String()
+		rpcTx.MaxPriorityFee = tx.MaxPriorityFee.String()
+	}
+
+	return rpcTx
+}
+
+// PendingTransactions returns the pending and queued transactions of the txpool, each
+// sorted by sender and nonce.
+func (api *TxPoolAPI) PendingTransactions(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	return &RPCPendingTransactions{
+		Pending: bySenderAndNonce(api.TxPool.Pending()),
+		Queued:  bySenderAndNonce(api.TxPool.QueuedTxs()),
+	}, nil
+}
+
+// GetTransactionCount returns the nonce of the next transaction of the given address
+// as hex, following its transactions pending in the txpool.
+func (api *TxPoolAPI) GetTransactionCount(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	address, err := parseAddress(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeBig(api.TxPool.NextNonce(*address)), nil
+}
+
+// RPCTxPoolContent lists the transactions of the txpool by sender address, each sender's
+// transactions sorted by nonce. Pending ones are ready to be mined, queued ones wait for
+// a nonce gap of their sender to fill.
+type RPCTxPoolContent struct {
+	Pending map[string][]*RPCPendingTransaction `json:"pending"`
+	Queued  map[string][]*RPCPendingTransaction `json:"queued"`
+}
+
+// Content returns the pending and queued transactions of the txpool grouped by sender.
+// Senders without transactions in a group are left out of it.
+func (api *TxPoolAPI) Content(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, NewInvalidParamsError("expected 0 params, got %d", len(params))
+	}
+
+	return &RPCTxPoolContent{
+		Pending: bySender(api.TxPool.Pending()),
+		Queued:  bySender(api.TxPool.QueuedTxs()),
+	}, nil
+}
+
+// RPCSendResult is the outcome of submitting one transaction of a batch : the hash of
+// the transaction if it was accepted, the reason it was refused otherwise.
+type RPCSendResult struct {
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// SendRawTransactions adds a batch of signed transactions, each hex encoded as
+// serialized by Transaction.Serialize, to the txpool. The results are in the order of
+// the batch, and a refused transaction doesn't prevent the others from being added.
+// Batches of more than MaxTxs transactions are refused as a whole.
+func (api *TxPoolAPI) SendRawTransactions(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	var raws []string
+	if err := json.Unmarshal(params[0], &raws); err != nil {
+		return nil, NewInvalidParamsError("transactions must be an array of hex strings")
+	}
+
+	maxTxs := api.MaxTxs
+	if maxTxs <= 0 {
+		maxTxs = defaultMaxBatchSize
+	}
+
+	if len(raws) > maxTxs {
+		return nil, NewInvalidParamsError("too many transactions : %d, maximum %d", len(raws), maxTxs)
+	}
+
+	results := make([]*RPCSendResult, len(raws))
+	for i, raw := range raws {
+		results[i] = api.sendRawTransaction(raw)
+	}
+
+	return results, nil
+}
+
+func (api *TxPoolAPI) sendRawTransaction(raw string) *RPCSendResult {
+	data, err := hex.DecodeString(strings.TrimPrefix(raw, "0x"))
+	if err != nil {
+		return &RPCSendResult{Error: "invalid hex string"}
+	}
+
+	tx, err := types.DecodeTransaction(data)
+	if err != nil {
+		return &RPCSendResult{Error: err.Error()}
+	}
+
+	if err := tx.VerifySignature(); err != nil {
+		return &RPCSendResult{Error: err.Error()}
+	}
+
+	if err := api.TxPool.AddTx(tx); err != nil {
+		return &RPCSendResult{Error: err.Error()}
+	}
+
+	return &RPCSendResult{Hash: tx.Hash().String()}
+}
+
+// RPCSimulateResult is the outcome of simulating a transaction : whether it would be
+// accepted, the reason it would be refused otherwise, and what it would cost the sender.
+type RPCSimulateResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Queued  bool   `json:"queued"` // Whether it would wait for a nonce gap of the sender to fill
+	Gas     string `json:"gas"`
+	Fee     string `json:"fee"`  // Maximum fee of dynamic fee transactions
+	Cost    string `json:"cost"` // Value plus fee
+}
+
+// SimulateTransaction runs the checks of SendRawTransactions on a signed transaction,
+// hex encoded as serialized by Transaction.Serialize, against the current head and
+// txpool. Neither the state nor the txpool are modified.
+func (api *TxPoolAPI) SimulateTransaction(params []json.RawMessage) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	var raw string
+	if err := json.Unmarshal(params[0], &raw); err != nil {
+		return nil, NewInvalidParamsError("transaction must be a hex string")
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(raw, "0x"))
+	if err != nil {
+		return nil, NewInvalidParamsError("invalid hex string")
+	}
+
+	tx, err := types.DecodeTransaction(data)
+	if err != nil {
+		return nil, NewInvalidParamsError("%s", err)
+	}
+
+	result := &RPCSimulateResult{
+		Gas:  encodeBig(new(big.Int).SetUint64(tx.Gas())),
+		Fee:  tx.FeeCap().String(),
+		Cost: new(big.Int).Add(tx.Value, tx.FeeCap()).String(),
+	}
+
+	if err := tx.VerifySignature(); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	queued, err := api.TxPool.Simulate(tx)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	result.Success = true
+	result.Queued = queued
+
+	return result, nil
+}
+
+func bySenderAndNonce(txs []*types.Transaction) []*RPCPendingTransaction {
+	sort.SliceStable(txs, func(i, j int) bool {
+		if c := bytes.Compare(txs[i].From.Bytes(), txs[j].From.Bytes()); c != 0 {
+			return c < 0
+		}
+
+		return txs[i].Nonce.Cmp(txs[j].Nonce) < 0
+	})
+
+	out := make([]*RPCPendingTransaction, len(txs))
+	for i, tx := range txs {
+		out[i] = NewRPCPendingTransaction(tx)
+	}
+
+	return out
+}
+
+// bySender groups the transactions by sender address, each group sorted by nonce.
+func bySender(txs []*types.Transaction) map[string][]*RPCPendingTransaction {
+	groups := make(map[string][]*RPCPendingTransaction)
+
+	for _, tx := range bySenderAndNonce(txs) {
+		groups[tx.From] = append(groups[tx.From], tx)
+	}
+
+	return groups
+}
@@ -0,0 +1,522 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketPath is the path the WebSocket endpoint is served on.
+const WebSocketPath = "/ws"
+
+// NewHeadsTopic is the subscription topic notified on every new head of the chain.
+const NewHeadsTopic = "newHeads"
+
+// TxStatusTopic is the subscription topic notified once the transaction subscribed to is
+// included in a block, dropped from the txpool or replaced in it.
+const TxStatusTopic = "txStatus"
+
+// Statuses of the txStatus notifications. A subscription which got none of the others
+// within txSubscriptionLifetime, its transaction being neither included nor dropped from
+// the txpool, is expired.
+const (
+	TxStatusIncluded = "included"
+	TxStatusDropped  = "dropped"
+	TxStatusReplaced = "replaced"
+	TxStatusExpired  = "expired"
+)
+
+// maxTxSubscriptions is the number of txStatus subscriptions a connection may hold at once.
+var maxTxSubscriptions = 256
+
+// txSubscriptionLifetime is how long a txStatus subscription waits for the status of its
+// transaction before expiring.
+var txSubscriptionLifetime = time.Hour
+
+// wsQueueSize is the number of messages queued per connection, notifications
+// beyond it are dropped for that connection.
+var wsQueueSize = 64
+
+// wsMaxRateLimited is the number of messages in a row refused by the rate limiter after
+// which a WebSocket connection is closed.
+var wsMaxRateLimited = 20
+
+// RPCHeader is the JSON-RPC representation of a block header.
+type RPCHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// NewRPCHeader converts the header of a block into its JSON-RPC representation.
+func NewRPCHeader(b *types.Block) *RPCHeader {
+	return &RPCHeader{
+		Number:     encodeBig(b.Number),
+		Hash:       b.DeriveHash().String(),
+		ParentHash: b.ParentHash.String(),
+		Timestamp:  encodeBig(new(big.Int).SetUint64(b.Timestamp)),
+	}
+}
+
+// RPCTxStatus is the JSON-RPC representation of the status of a transaction, the block
+// fields being set when it is included and replacedBy when it is replaced.
+type RPCTxStatus struct {
+	Hash        string `json:"hash"`
+	Status      string `json:"status"`
+	BlockNumber string `json:"blockNumber,omitempty"`
+	BlockHash   string `json:"blockHash,omitempty"`
+	ReplacedBy  string `json:"replacedBy,omitempty"`
+}
+
+type jsonrpcNotification struct {
+	Version string              `json:"jsonrpc"`
+	Method  string              `json:"method"`
+	Params  *subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// wsConn is a WebSocket client connection. All writes go through the out queue,
+// which is never closed; done is closed once the connection is gone.
+type wsConn struct {
+	conn *websocket.Conn
+	out  chan interface{}
+	done chan struct{}
+}
+
+// subscription is a topic a connection subscribed to, tx being the hash of the transaction
+// of txStatus subscriptions and expires the time they expire at.
+type subscription struct {
+	conn    *wsConn
+	topic   string
+	tx      string
+	expires time.Time
+}
+
+// subscriptionHub tracks the WebSocket connections and their subscriptions. The txStatus
+// subscriptions are indexed by transaction hash, and counted by connection.
+type subscriptionHub struct {
+	mu     sync.RWMutex
+	conns  map[*wsConn]int // Number of txStatus subscriptions by connection
+	subs   map[string]*subscription
+	txSubs map[string]map[string]*subscription // txStatus subscriptions by transaction hash, then id
+	expiry time.Time                           // Earliest expiry of the txStatus subscriptions
+	now    func() time.Time
+}
+
+func newSubscriptionHub() *subscriptionHub {
+	return &subscriptionHub{
+		conns:  make(map[*wsConn]int),
+		subs:   make(map[string]*subscription),
+		txSubs: make(map[string]map[string]*subscription),
+		now:    time.Now,
+	}
+}
+
+func (h *subscriptionHub) addConn(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.conns[c] = 0
+}
+
+// removeConn drops the connection along with all of its subscriptions.
+func (h *subscriptionHub) removeConn(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, sub := range h.subs {
+		if sub.conn == c {
+			h.remove(id, sub)
+		}
+	}
+
+	delete(h.conns, c)
+}
+
+// subscribe adds a subscription of the connection to the topic, refusing txStatus
+// subscriptions over maxTxSubscriptions for the connection.
+func (h *subscriptionHub) subscribe(c *wsConn, topic string, tx string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	id := "0x" + hex.EncodeToString(b)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscription{conn: c, topic: topic, tx: tx}
+
+	if topic == TxStatusTopic {
+		h.expire()
+
+		if h.conns[c] >= maxTxSubscriptions {
+			return "", &Error{Code: ErrCodeInvalidRequest, Message: fmt.Sprintf("too many %s subscriptions, maximum %d", TxStatusTopic, maxTxSubscriptions)}
+		}
+
+		sub.expires = h.now().Add(txSubscriptionLifetime)
+
+		if h.txSubs[tx] == nil {
+			h.txSubs[tx] = make(map[string]*subscription)
+		}
+
+		h.txSubs[tx][id] = sub
+		h.conns[c]++
+
+		if h.expiry.IsZero() || sub.expires.Before(h.expiry) {
+			h.expiry = sub.expires
+		}
+	}
+
+	h.subs[id] = sub
+
+	return id, nil
+}
+
+// remove drops the subscription of the id. The caller must hold the lock.
+func (h *subscriptionHub) remove(id string, sub *subscription) {
+	delete(h.subs, id)
+
+	if sub.topic != TxStatusTopic {
+		return
+	}
+
+	delete(h.txSubs[sub.tx], id)
+
+	if len(h.txSubs[sub.tx]) == 0 {
+		delete(h.txSubs, sub.tx)
+	}
+
+	if _, ok := h.conns[sub.conn]; ok {
+		h.conns[sub.conn]--
+	}
+}
+
+// expire notifies and drops the txStatus subscriptions past their expiry. The scan only
+// runs once the earliest of them expired. The caller must hold the lock.
+func (h *subscriptionHub) expire() {
+	now := h.now()
+
+	if h.expiry.IsZero() || now.Before(h.expiry) {
+		return
+	}
+
+	h.expiry = time.Time{}
+
+	for _, subs := range h.txSubs {
+		for id, sub := range subs {
+			if now.Before(sub.expires) {
+				if h.expiry.IsZero() || sub.expires.Before(h.expiry) {
+					h.expiry = sub.expires
+				}
+
+				continue
+			}
+
+			sub.send(id, &RPCTxStatus{Hash: sub.tx, Status: TxStatusExpired})
+			h.remove(id, sub)
+		}
+	}
+}
+
+// unsubscribe removes the subscription if it belongs to the connection.
+func (h *subscriptionHub) unsubscribe(c *wsConn, id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub, ok := h.subs[id]
+	if !ok || sub.conn != c {
+		return false
+	}
+
+	h.remove(id, sub)
+
+	return true
+}
+
+// notify queues the result for every subscription to the topic without blocking on slow
+// clients.
+func (h *subscriptionHub) notify(topic string, result interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for id, sub := range h.subs {
+		if sub.topic == topic {
+			sub.send(id, result)
+		}
+	}
+}
+
+// notifyTx queues the status for every txStatus subscription to the transaction. The
+// status is final, so the subscriptions are removed. Expired subscriptions are notified
+// and removed along the way.
+func (h *subscriptionHub) notifyTx(status *RPCTxStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, sub := range h.txSubs[status.Hash] {
+		sub.send(id, status)
+		h.remove(id, sub)
+	}
+
+	h.expire()
+}
+
+func (sub *subscription) send(id string, result interface{}) {
+	msg := &jsonrpcNotification{
+		Version: jsonrpcVersion,
+		Method:  "subscription",
+		Params:  &subscriptionResult{Subscription: id, Result: result},
+	}
+
+	select {
+	case sub.conn.out <- msg:
+	default:
+		log.Println("Dropping notification for slow subscriber", id)
+	}
+}
+
+// closeAll closes every WebSocket connection, as they outlive the HTTP server shutdown.
+func (h *subscriptionHub) closeAll() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.conns {
+		// nolint : errcheck
+		c.conn.Close()
+	}
+}
+
+// NotifyNewHead notifies the newHeads subscribers of a block appended to the chain, and
+// the txStatus subscribers whose subscription expired.
+func (s *RPCServer) NotifyNewHead(b *types.Block) {
+	s.subs.notify(NewHeadsTopic, NewRPCHeader(b))
+
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+
+	s.subs.expire()
+}
+
+// NotifyTxsIncluded notifies the txStatus subscribers of the transactions of a block
+// which became canonical.
+func (s *RPCServer) NotifyTxsIncluded(b *types.Block) {
+	hash := b.DeriveHash().String()
+
+	for _, tx := range b.Transactions {
+		s.subs.notifyTx(&RPCTxStatus{Hash: tx.Hash().String(), Status: TxStatusIncluded, BlockNumber: encodeBig(b.Number), BlockHash: hash})
+	}
+}
+
+// NotifyTxDropped notifies the txStatus subscribers of a transaction which left the
+// txpool without being included, replaced by replacement if it isn't nil.
+func (s *RPCServer) NotifyTxDropped(tx, replacement *types.Transaction) {
+	status := &RPCTxStatus{Hash: tx.Hash().String(), Status: TxStatusDropped}
+
+	if replacement != nil {
+		status.Status = TxStatusReplaced
+		status.ReplacedBy = replacement.Hash().String()
+	}
+
+	s.subs.notifyTx(status)
+}
+
+// serveWebSocket serves JSON-RPC requests over a WebSocket connection, adding the
+// subscribe and unsubscribe methods to the ones served over HTTP.
+func (s *RPCServer) serveWebSocket(ws *websocket.Conn) {
+	// The read and write timeouts of the HTTP server only apply to the handshake, the
+	// connection stays open for the subscriptions.
+	// nolint : errcheck
+	ws.SetDeadline(time.Time{})
+
+	c := &wsConn{conn: ws, out: make(chan interface{}, wsQueueSize), done: make(chan struct{})}
+
+	s.subs.addConn(c)
+
+	defer func() {
+		s.subs.removeConn(c)
+		close(c.done)
+
+		// nolint : errcheck
+		ws.Close()
+	}()
+
+	go c.writeLoop()
+
+	ip := clientIP(ws.Request())
+	limited := 0
+
+	for {
+		var req jsonrpcRequest
+
+		var res *jsonrpcResponse
+
+		err := websocket.JSON.Receive(ws, &req)
+
+		var syntaxErr *json.SyntaxError
+
+		// Every message takes its cost from the rate limit of the client, the connection
+		// being closed once too many in a row are refused.
+		switch {
+		case err != nil && !errors.As(err, &syntaxErr):
+			if !errors.Is(err, io.EOF) {
+				log.Println("Closing WebSocket connection", err)
+			}
+
+			return
+		case !s.allowRequest(&req, ip, 0):
+			limited++
+			if limited >= wsMaxRateLimited {
+				log.Println("Closing WebSocket connection", ip, "rate limited")
+				return
+			}
+
+			res = errorResponse(req.ID, &Error{Code: ErrCodeRateLimited, Message: "rate limited"})
+		case err != nil:
+			limited = 0
+			res = errorResponse(nil, &Error{Code: ErrCodeParse, Message: "parse error"})
+		default:
+			limited = 0
+			res = s.handleWebSocket(c, &req)
+		}
+
+		select {
+		case c.out <- res:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (s *RPCServer) handleWebSocket(c *wsConn, req *jsonrpcRequest) *jsonrpcResponse {
+	if req.Version != jsonrpcVersion {
+		return s.handle(req)
+	}
+
+	var result interface{}
+
+	switch req.Method {
+	case "subscribe":
+		topic, tx, perr := parseSubscribeParams(req.Params)
+		if perr != nil {
+			return errorResponse(req.ID, perr)
+		}
+
+		id, err := s.subs.subscribe(c, topic, tx)
+		if rpcErr, ok := err.(*Error); ok {
+			return errorResponse(req.ID, rpcErr)
+		}
+
+		if err != nil {
+			return errorResponse(req.ID, &Error{Code: ErrCodeInternal, Message: err.Error()})
+		}
+
+		result = id
+	case "unsubscribe":
+		var id string
+
+		if err := parseSingleParam(req.Params, &id); err != nil {
+			return errorResponse(req.ID, err)
+		}
+
+		result = s.subs.unsubscribe(c, id)
+	default:
+		return s.handle(req)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: ErrCodeInternal, Message: err.Error()})
+	}
+
+	return &jsonrpcResponse{Version: jsonrpcVersion, ID: responseID(req.ID), Result: encoded}
+}
+
+// parseSubscribeParams decodes the topic of a subscription, followed by the hex hash of
+// the transaction for the txStatus topic.
+func parseSubscribeParams(raw json.RawMessage) (string, string, *Error) {
+	var params []string
+
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return "", "", NewInvalidParamsError("params must be an array of strings")
+	}
+
+	if len(params) == 0 {
+		return "", "", NewInvalidParamsError("expected a subscription topic")
+	}
+
+	switch topic := params[0]; topic {
+	case NewHeadsTopic:
+		if len(params) != 1 {
+			return "", "", NewInvalidParamsError("expected 1 param, got %d", len(params))
+		}
+
+		return topic, "", nil
+	case TxStatusTopic:
+		if len(params) != 2 {
+			return "", "", NewInvalidParamsError("expected 2 params, got %d", len(params))
+		}
+
+		hash, err := util.HexToHash(params[1])
+		if err != nil {
+			return "", "", NewInvalidParamsError("%s", err)
+		}
+
+		return topic, hash.String(), nil
+	default:
+		return "", "", NewInvalidParamsError("unknown subscription topic %s", topic)
+	}
+}
+
+// parseSingleParam decodes params holding exactly one string.
+func parseSingleParam(raw json.RawMessage, v *string) *Error {
+	var params []json.RawMessage
+
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return NewInvalidParamsError("params must be an array")
+	}
+
+	if len(params) != 1 {
+		return NewInvalidParamsError("expected 1 param, got %d", len(params))
+	}
+
+	if err := json.Unmarshal(params[0], v); err != nil {
+		return NewInvalidParamsError("expected a string param")
+	}
+
+	return nil
+}
+
+func (c *wsConn) writeLoop() {
+	for {
+		select {
+		case msg := <-c.out:
+			if err := websocket.JSON.Send(c.conn, msg); err != nil {
+				// Unblock the read loop, which cleans up the connection.
+				// nolint : errcheck
+				c.conn.Close()
+
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func subscriptionCount(s *RPCServer) (int, int) {
+	s.subs.mu.RLock()
+	defer s.subs.mu.RUnlock()
+
+	return len(s.subs.conns), len(s.subs.subs)
+}
+
+func TestWebSocketUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	rpcPort := ":1712"
+	srv := NewRPCServer(rpcPort, &RPCDomains{}, nil)
+
+	defer srv.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	ws, err := websocket.Dial("ws://localhost"+rpcPort+WebSocketPath, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method string, param string) json.RawMessage {
+		err := websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": []string{param}})
+		assert.NoError(t, err)
+
+		var res jsonrpcResponse
+		assert.NoError(t, websocket.JSON.Receive(ws, &res))
+		assert.Nil(t, res.Error)
+
+		return res.Result
+	}
+
+	// Unknown topics are rejected.
+	err = websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": []string{"unknown"}})
+	assert.NoError(t, err)
+
+	var res jsonrpcResponse
+	assert.NoError(t, websocket.JSON.Receive(ws, &res))
+	assert.Equal(t, ErrCodeInvalidParams, res.Error.Code)
+
+	var id string
+	assert.NoError(t, json.Unmarshal(call("subscribe", NewHeadsTopic), &id))
+	call("subscribe", NewHeadsTopic)
+
+	conns, subs := subscriptionCount(srv)
+	assert.Equal(t, 1, conns)
+	assert.Equal(t, 2, subs)
+
+	assert.Equal(t, json.RawMessage("true"), call("unsubscribe", id))
+	assert.Equal(t, json.RawMessage("false"), call("unsubscribe", id))
+
+	_, subs = subscriptionCount(srv)
+	assert.Equal(t, 1, subs)
+
+	// Disconnecting drops the remaining subscription and notifying afterwards is safe.
+	assert.NoError(t, ws.Close())
+
+	assert.Eventually(t, func() bool {
+		conns, subs := subscriptionCount(srv)
+		return conns == 0 && subs == 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	srv.NotifyNewHead(types.NewBlock(big.NewInt(1), util.HashData([]byte("0x0")), []byte{}))
+}
+
+func TestWebSocketRateLimit(t *testing.T) {
+	t.Parallel()
+
+	rpcPort := ":1716"
+	srv := NewRPCServer(rpcPort, &RPCDomains{}, &ServerOptions{RateLimit: 0.1, RateBurst: 2})
+
+	defer srv.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	ws, err := websocket.Dial("ws://localhost"+rpcPort+WebSocketPath, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nolint : errcheck
+	defer ws.Close()
+
+	call := func() (*jsonrpcResponse, error) {
+		if err := websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": []string{NewHeadsTopic}}); err != nil {
+			return nil, err
+		}
+
+		var res jsonrpcResponse
+		err := websocket.JSON.Receive(ws, &res)
+
+		return &res, err
+	}
+
+	// The handshake and the first message take the burst.
+	res, err := call()
+	assert.NoError(t, err)
+	assert.Nil(t, res.Error)
+
+	// Messages over the limit are refused, until the connection gets closed.
+	for i := 1; i < wsMaxRateLimited; i++ {
+		res, err := call()
+		assert.NoError(t, err)
+		assert.Equal(t, ErrCodeRateLimited, res.Error.Code)
+	}
+
+	_, err = call()
+	assert.Error(t, err)
+
+	assert.Eventually(t, func() bool {
+		conns, _ := subscriptionCount(srv)
+		return conns == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestTxSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	h := newSubscriptionHub()
+	h.now = func() time.Time { return now }
+
+	c := &wsConn{out: make(chan interface{}, maxTxSubscriptions), done: make(chan struct{})}
+	h.addConn(c)
+
+	status := func() *RPCTxStatus {
+		msg := (<-c.out).(*jsonrpcNotification)
+		return msg.Params.Result.(*RPCTxStatus)
+	}
+
+	tx := util.HashData([]byte("tx")).String()
+
+	// A connection can only hold so many txStatus subscriptions.
+	for i := 0; i < maxTxSubscriptions; i++ {
+		_, err := h.subscribe(c, TxStatusTopic, util.HashData([]byte{byte(i), byte(i >> 8)}).String())
+		assert.NoError(t, err)
+	}
+
+	_, err := h.subscribe(c, TxStatusTopic, tx)
+	assert.Equal(t, ErrCodeInvalidRequest, err.(*Error).Code)
+
+	_, err = h.subscribe(c, NewHeadsTopic, "")
+	assert.NoError(t, err)
+
+	// Subscriptions past their lifetime expire, freeing room for new ones.
+	now = now.Add(txSubscriptionLifetime)
+
+	id, err := h.subscribe(c, TxStatusTopic, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, TxStatusExpired, status().Status)
+	assert.Equal(t, 1, h.conns[c])
+	assert.Len(t, h.txSubs, 1)
+
+	for len(c.out) > 0 {
+		assert.Equal(t, TxStatusExpired, status().Status)
+	}
+
+	// The subscription to the transaction gets its status, and is closed.
+	h.notifyTx(&RPCTxStatus{Hash: tx, Status: TxStatusIncluded})
+	assert.Equal(t, TxStatusIncluded, status().Status)
+	assert.NotContains(t, h.subs, id)
+	assert.Equal(t, 0, h.conns[c])
+	assert.Empty(t, h.txSubs)
+	assert.Len(t, h.subs, 1)
+}
@@ -5,175 +5,722 @@ import (
 	"fmt"
 	"math/big"
 	"sort"
+	"sync"
+	"time"
 
+	"github.com/0xsharma/compact-chain/config"
 	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/types"
+	"github.com/0xsharma/compact-chain/util"
 	"github.com/golang/groupcache/lru"
 )
 
 var (
 	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrAlreadyKnown       = errors.New("transaction already known")
+	ErrTxPoolFull         = errors.New("txpool is full and transaction fee is below the lowest pending fee")
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	ErrNonceTooLow        = errors.New("nonce too low")
+	ErrUnderpriced        = errors.New("transaction fee below base fee")
+	ErrIntrinsicGas       = errors.New("gas limit below intrinsic gas")
+	ErrGasLimit           = errors.New("gas limit exceeds block gas limit")
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrDataTooLarge       = errors.New("transaction data too large")
+	ErrSenderLimit        = errors.New("too many transactions from sender")
+	ErrTipAboveFeeCap     = errors.New("max priority fee above max fee")
 )
 
+// defaultMaxPoolSize is the default maximum number of transactions in the txpool.
+var defaultMaxPoolSize = 5000
+
+// defaultPriceBumpPercent is the default minimum fee bump required to replace a pending transaction.
+var defaultPriceBumpPercent = 10
+
+// defaultMaxDataBytes is the default maximum size of the data of a transaction.
+var defaultMaxDataBytes = 32 * 1024
+
+// maxSweepInterval is the maximum delay between two sweeps of the expired transactions.
+var maxSweepInterval = time.Minute
+
+// nopValidator is the validator of a txpool without a custom admission policy.
+type nopValidator struct{}
+
+func (nopValidator) ValidateTx(*types.Transaction) error {
+	return nil
+}
+
 type TxPool struct {
 	MinFee       *big.Int
+	MaxPoolSize  int
+	PriceBump    int
+	BaseFee      *big.Int
+	GasLimit     uint64 // Block gas limit, zero if unlimited
+	MaxDataBytes int    // Maximum size of the data of a transaction
+	MaxPerSender int    // Maximum number of pending and queued transactions of a sender, zero if unlimited
+	ChainID      uint64
+	LegacyTxs    bool              // Whether transactions without a chain id are accepted
+	Lifetime     time.Duration     // How long a transaction which can't be mined is kept, zero for ever
+	Validator    types.TxValidator // Custom admission policy, run after the standard checks
 	State        *dbstore.DB
-	Transactions []*types.Transaction
+	Transactions []*types.Transaction // Pending transactions, executable on top of the state
+	Queued       []*types.Transaction // Future transactions, waiting for a nonce gap to fill
+
+	mu       sync.RWMutex
+	arrivals map[string]time.Time // Arrival time of the transactions by hash, if they expire
+	now      func() time.Time
+	onDrop   func(tx, replacement *types.Transaction)
+
+	quit      chan struct{} // Closed by Close to stop the loops
+	closeOnce sync.Once
 
 	TxPoolCh chan *types.Transaction
 
 	LatestIncludedTxs *lru.Cache
 }
 
-func NewTxPool(minFee *big.Int, db *dbstore.DB, txpoolCh chan *types.Transaction) *TxPool {
+func NewTxPool(c *config.Config, db *dbstore.DB, txpoolCh chan *types.Transaction) *TxPool {
 	if db == nil {
 		fmt.Println("DB is nil, running in mock mode for tests")
 	}
 
+	maxPoolSize := defaultMaxPoolSize
+	if c.MaxPoolSize > 0 {
+		maxPoolSize = c.MaxPoolSize
+	}
+
+	priceBump := defaultPriceBumpPercent
+	if c.PriceBumpPercent != nil {
+		priceBump = *c.PriceBumpPercent
+	}
+
+	maxDataBytes := defaultMaxDataBytes
+	if c.MaxTxDataBytes > 0 {
+		maxDataBytes = c.MaxTxDataBytes
+	}
+
+	var validator types.TxValidator = nopValidator{}
+	if c.TxValidator != nil {
+		validator = c.TxValidator
+	}
+
 	txpool := &TxPool{
-		MinFee:            minFee,
+		MinFee:            c.MinFee,
+		MaxPoolSize:       maxPoolSize,
+		PriceBump:         priceBump,
+		GasLimit:          c.BlockGasLimit,
+		MaxDataBytes:      maxDataBytes,
+		MaxPerSender:      c.MaxTxPerSender,
+		ChainID:           c.NetworkID,
+		Lifetime:          c.TxPoolLifetime,
+		Validator:         validator,
 		State:             db,
 		TxPoolCh:          txpoolCh,
 		LatestIncludedTxs: lru.New(1000),
+		arrivals:          make(map[string]time.Time),
+		now:               time.Now,
+		quit:              make(chan struct{}),
 	}
 
 	go txpool.loop()
 
-	return txpool
-}
+	if txpool.Lifetime > 0 {
+		go txpool.sweepLoop()
+	}
 
-func intToBool(n int) bool {
-	return n >= 0
+	return txpool
 }
 
 func (txp *TxPool) loop() {
-	// nolint : gosimple
 	for {
 		select {
 		case tx := <-txp.TxPoolCh:
+			// nolint : errcheck
 			txp.AddTx(tx)
+		case <-txp.quit:
+			return
 		}
 	}
 }
 
+// Close stops the loops of the txpool. It is safe to call more than once.
+func (txp *TxPool) Close() {
+	txp.closeOnce.Do(func() {
+		close(txp.quit)
+	})
+}
+
 func (txp *TxPool) IsValid(tx *types.Transaction) bool {
 	if txp.State == nil {
 		return true
 	}
 
-	if tx.Fee.Cmp(txp.MinFee) < 0 {
+	if tx.FeeCap().Cmp(txp.MinFee) < 0 {
 		return false
 	}
 
-	from := tx.From
-
-	signOk := tx.Verify()
-	if !signOk {
+	if err := tx.VerifySignature(); err != nil {
 		return false
 	}
 
-	balance, err := txp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, from.String()))
-	if err != nil {
-		return false
+	return true
+}
+
+// checkFunds returns ErrInsufficientFunds if the balance of the sender can't pay for the
+// value and highest fee of the transaction on top of its other transactions in the
+// txpool. A transaction with the same nonce isn't counted, as it would be replaced.
+func (tp *TxPool) checkFunds(tx *types.Transaction) error {
+	if tp.State == nil {
+		return nil
 	}
 
-	balanceBig := new(big.Int).SetBytes(balance)
+	balance := big.NewInt(0)
+
+	value, err := tp.State.Get(dbstore.PrefixKey(dbstore.BalanceKey, tx.From.String()))
+	if err == nil {
+		balance.SetBytes(value)
+	}
 
-	// Add Fee to Value
-	totalValue := big.NewInt(0).Add(tx.Value, tx.Fee)
+	cost := new(big.Int).Add(tx.Value, tx.FeeCap())
 
-	// nolint : gosimple
-	if balanceBig.Cmp(totalValue) < 0 {
-		return false
+	for _, tx2 := range tp.all() {
+		if tx2.From == tx.From && tx2.Nonce.Cmp(tx.Nonce) != 0 {
+			cost.Add(cost, tx2.Value)
+			cost.Add(cost, tx2.FeeCap())
+		}
 	}
 
-	// TODO : Write nonce logic in txpool and enable this check
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("%w : balance %s, cost %s", ErrInsufficientFunds, balance, cost)
+	}
 
-	// var nonceBig *big.Int
+	return nil
+}
 
-	// nonce, err := txp.State.Get(dbstore.PrefixKey(dbstore.NonceKey, from.String()))
-	// if err != nil {
-	// 	nonceBig = big.NewInt(-1)
-	// } else {
-	// 	nonceBig = new(big.Int).SetBytes(nonce)
-	// }
+// AddTx adds a transaction to the txpool. Transactions which can be executed on top of
+// the current state are kept as pending, while those with a nonce gap are queued until
+// the missing nonces arrive. A transaction with the same sender and nonce is replaced if
+// the new fee is at least PriceBump percent higher. When the txpool is full, the
+// transaction with the lowest fee per gas is evicted to make room for a transaction paying
+// a higher one. Transactions which can't fit in a block because of their gas limit are refused,
+// and so are transactions the sender can't pay for along with its other transactions. A
+// sender holding MaxPerSender transactions can only replace them.
+func (tp *TxPool) AddTx(tx *types.Transaction) error {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	replaced, err := tp.validate(tx)
+	if err != nil {
+		return err
+	}
 
-	// if big.NewInt(0).Sub(tx.Nonce, nonceBig).Cmp(big.NewInt(1)) != 0 {
-	// 	return false
-	// }
+	if replaced != nil {
+		fmt.Println("Replacing Tx :", "hash :", replaced.Hash().String(), "with :", tx.Hash().String())
+		tp.remove(replaced)
+		tp.dropped(replaced, tx)
+	} else if tp.full() {
+		lowest := tp.lowestFee()
+
+		fmt.Println("Txpool full, evicting Tx :", "hash :", lowest.Hash().String(), "fee :", lowest.FeeCap())
+		tp.remove(lowest)
+		tp.dropped(lowest, nil)
+		tp.reclassify(lowest.From)
+	}
 
-	return true
+	if tp.Lifetime > 0 {
+		tp.arrivals[tx.Hash().String()] = tp.now()
+	}
+
+	tp.Queued = append(tp.Queued, tx)
+	tp.reclassify(tx.From)
+
+	return nil
 }
 
-func (tp *TxPool) AddTx(tx *types.Transaction) {
+// Simulate runs the checks of AddTx against the current state and txpool without adding
+// the transaction. It returns the reason the transaction would be refused, if any, and
+// whether it would be queued waiting for a nonce gap to fill.
+func (tp *TxPool) Simulate(tx *types.Transaction) (bool, error) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	if _, err := tp.validate(tx); err != nil {
+		return false, err
+	}
+
+	next := tp.stateNonce(tx.From)
+	if next == nil {
+		return false, nil
+	}
+
+	// The pending transactions of the sender have consecutive nonces from the state nonce.
+	for _, tx2 := range tp.Transactions {
+		if tx2.From == tx.From {
+			next.Add(next, big.NewInt(1))
+		}
+	}
+
+	return tx.Nonce.Cmp(next) > 0, nil
+}
+
+// validate returns the reason AddTx would refuse the transaction, if any, along with the
+// transaction of the same sender and nonce it would replace. It must be called with the
+// lock held.
+func (tp *TxPool) validate(tx *types.Transaction) (*types.Transaction, error) {
 	if !tp.IsValid(tx) {
-		return
+		return nil, ErrInvalidTransaction
+	}
+
+	if tx.GasLimit != 0 && tx.GasLimit < types.TxGas {
+		return nil, ErrIntrinsicGas
+	}
+
+	if tp.GasLimit != 0 && tx.Gas() > tp.GasLimit {
+		return nil, ErrGasLimit
+	}
+
+	if len(tx.Data) > tp.MaxDataBytes {
+		return nil, fmt.Errorf("%w : %d bytes, maximum %d", ErrDataTooLarge, len(tx.Data), tp.MaxDataBytes)
+	}
+
+	if err := tx.VerifyChainID(tp.ChainID, tp.LegacyTxs); err != nil {
+		return nil, err
+	}
+
+	if tx.IsDynamicFee() && tx.MaxPriorityFee.Cmp(tx.MaxFee) > 0 {
+		return nil, ErrTipAboveFeeCap
+	}
+
+	// The fee, or the maximum fee of dynamic fee transactions, has to cover the base fee
+	// owed for the whole gas limit.
+	if !tx.CoversBaseFee(tp.BaseFee) {
+		return nil, ErrUnderpriced
 	}
 
 	_, ok := tp.LatestIncludedTxs.Get(tx.Hash().String())
 	if ok {
-		return
+		return nil, ErrAlreadyKnown
 	}
 
-	for _, tx2 := range tp.Transactions {
+	if nonce := tp.stateNonce(tx.From); nonce != nil && tx.Nonce.Cmp(nonce) < 0 {
+		return nil, ErrNonceTooLow
+	}
+
+	if err := tp.checkFunds(tx); err != nil {
+		return nil, err
+	}
+
+	var replaced *types.Transaction
+
+	for _, tx2 := range tp.all() {
 		if tx2.Hash().String() == tx.Hash().String() {
-			return
+			return nil, ErrAlreadyKnown
+		}
+
+		if tx2.From == tx.From && tx2.Nonce.Cmp(tx.Nonce) == 0 {
+			// Fee cap required for replacement : oldFeeCap * (100 + PriceBump) / 100
+			threshold := new(big.Int).Mul(tx2.FeeCap(), big.NewInt(int64(100+tp.PriceBump)))
+			threshold.Div(threshold, big.NewInt(100))
+
+			if tx.FeeCap().Cmp(threshold) < 0 {
+				return nil, ErrReplaceUnderpriced
+			}
+
+			replaced = tx2
+
+			break
 		}
 	}
 
-	txs := append(tp.Transactions, tx)
-	sort.Slice(txs, func(i, j int) bool {
-		return intToBool(txs[i].Fee.Cmp(txs[j].Fee))
-	})
+	if replaced == nil && tp.MaxPerSender > 0 {
+		if count := tp.senderCount(tx.From); count >= tp.MaxPerSender {
+			return nil, fmt.Errorf("%w : %s holds %d transactions, maximum %d", ErrSenderLimit, tx.From.String(), count, tp.MaxPerSender)
+		}
+	}
+
+	if replaced == nil && tp.full() && tx.CmpFeePerGas(tp.lowestFee()) <= 0 {
+		return nil, ErrTxPoolFull
+	}
+
+	// The validator runs with the txpool locked, it must not call back into it.
+	if err := tp.Validator.ValidateTx(tx); err != nil {
+		return nil, err
+	}
+
+	return replaced, nil
+}
 
-	tp.Transactions = txs
+// full reports whether the txpool holds its maximum number of transactions.
+func (tp *TxPool) full() bool {
+	return len(tp.Transactions)+len(tp.Queued) >= tp.MaxPoolSize
 }
 
+// senderCount returns the number of pending and queued transactions of the sender.
+func (tp *TxPool) senderCount(from util.Address) int {
+	count := 0
+
+	for _, tx := range tp.all() {
+		if tx.From == from {
+			count++
+		}
+	}
+
+	return count
+}
+
+// AddTxs adds a batch of transactions to the txpool, skipping the rejected ones.
 func (tp *TxPool) AddTxs(txs []*types.Transaction) {
-	validTxs := make([]*types.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		// nolint : errcheck
+		tp.AddTx(tx)
+	}
+}
+
+// remove transaction from txpool. Transactions of the same sender whose nonce was used
+// up by the state are dropped and queued ones which became executable are promoted.
+func (tp *TxPool) RemoveTx(tx *types.Transaction) error {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	found := tp.remove(tx)
+	tp.reclassify(tx.From)
+
+	if !found {
+		return errors.New("transaction not found")
+	}
+
+	return nil
+}
+
+// Reinject returns the transactions of blocks dropped by a reorg to the txpool, skipping
+// the ones which are no longer valid.
+func (tp *TxPool) Reinject(txs []*types.Transaction) {
+	tp.mu.Lock()
+	for _, tx := range txs {
+		tp.LatestIncludedTxs.Remove(tx.Hash().String())
+	}
+	tp.mu.Unlock()
 
 	for _, tx := range txs {
-		_, ok := tp.LatestIncludedTxs.Get(tx.Hash().String())
-		if ok {
+		if err := tp.AddTx(tx); err != nil {
+			fmt.Println("Dropping reorged Tx :", "hash :", tx.Hash().String(), "error :", err)
+		}
+	}
+}
+
+// SetBaseFee sets the base fee of the next block. Transactions paying less are refused
+// and pending ones are held back until the base fee drops.
+func (tp *TxPool) SetBaseFee(baseFee *big.Int) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.BaseFee = baseFee
+}
+
+// SetDropHook sets the function called with the transactions leaving the txpool other
+// than through RemoveTx, along with the transaction replacing them if any. Transactions whose
+// nonce got used up by a block are passed too, whether or not the block includes them.
+// The hook runs with the txpool locked, it must not call back into it.
+func (tp *TxPool) SetDropHook(hook func(tx, replacement *types.Transaction)) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.onDrop = hook
+}
+
+// dropped passes the transaction to the drop hook, if any. It must be called with the
+// lock held.
+func (tp *TxPool) dropped(tx, replacement *types.Transaction) {
+	if tp.onDrop != nil {
+		tp.onDrop(tx, replacement)
+	}
+}
+
+// FeeFloor returns the lowest fee the txpool accepts, the larger of MinFee and the base
+// fee of the next block.
+func (tp *TxPool) FeeFloor() *big.Int {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	floor := big.NewInt(0)
+	if tp.MinFee != nil {
+		floor.Set(tp.MinFee)
+	}
+
+	if tp.BaseFee != nil && tp.BaseFee.Cmp(floor) > 0 {
+		floor.Set(tp.BaseFee)
+	}
+
+	return floor
+}
+
+// SetLegacyTxs sets whether transactions signed without a chain id are accepted. Once
+// they aren't anymore, the pending and queued ones are dropped.
+func (tp *TxPool) SetLegacyTxs(allowed bool) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.LegacyTxs = allowed
+
+	if allowed || tp.ChainID == 0 {
+		return
+	}
+
+	for _, tx := range tp.all() {
+		if tx.ChainID == 0 {
+			fmt.Println("Dropping legacy Tx :", "hash :", tx.Hash().String())
+			tp.remove(tx)
+			tp.dropped(tx, nil)
+			tp.reclassify(tx.From)
+		}
+	}
+}
+
+// sweepLoop evicts the expired transactions, checking a few times per lifetime.
+func (tp *TxPool) sweepLoop() {
+	interval := tp.Lifetime / 4
+	if interval <= 0 || interval > maxSweepInterval {
+		interval = maxSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tp.EvictExpired()
+		case <-tp.quit:
+			return
+		}
+	}
+}
+
+// EvictExpired drops the transactions older than the lifetime which can't be mined,
+// because they wait for a nonce gap to fill or pay less than the base fee. Minable
+// transactions are kept whatever their age. It returns the dropped transactions.
+func (tp *TxPool) EvictExpired() []*types.Transaction {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	if tp.Lifetime <= 0 {
+		return nil
+	}
+
+	now := tp.now()
+	evicted := []*types.Transaction{}
+	pooled := make(map[string]bool)
+
+	for _, tx := range tp.all() {
+		hash := tx.Hash().String()
+		pooled[hash] = true
+
+		arrival, ok := tp.arrivals[hash]
+		if !ok || now.Sub(arrival) < tp.Lifetime || tp.minable(tx) {
 			continue
 		}
 
-		for _, tx2 := range tp.Transactions {
-			if tx2.Hash().String() == tx.Hash().String() {
-				return
-			}
+		fmt.Println("Evicting expired Tx :", "hash :", hash, "age :", now.Sub(arrival))
+		tp.remove(tx)
+		tp.dropped(tx, nil)
+		delete(tp.arrivals, hash)
+
+		evicted = append(evicted, tx)
+	}
+
+	for _, tx := range evicted {
+		tp.reclassify(tx.From)
+	}
+
+	// Forget the arrival of transactions which left the pool.
+	for hash := range tp.arrivals {
+		if !pooled[hash] {
+			delete(tp.arrivals, hash)
+		}
+	}
+
+	return evicted
+}
+
+// minable reports whether the transaction is pending and pays at least the base fee.
+func (tp *TxPool) minable(tx *types.Transaction) bool {
+	if !tx.CoversBaseFee(tp.BaseFee) {
+		return false
+	}
+
+	for _, tx2 := range tp.Transactions {
+		if tx2 == tx {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetTxs returns the pending transactions paying at least the base fee, in the canonical
+// order they are included in a block : transactions of the same sender by ascending
+// nonce, senders by descending fee per gas. It takes the write lock, since it records
+// the transactions in LatestIncludedTxs, an LRU cache which isn't safe for concurrent use.
+func (tp *TxPool) GetTxs() []*types.Transaction {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	txs := []*types.Transaction{}
+
+	for _, tx := range types.CanonicalOrder(tp.Transactions) {
+		if tx.CoversBaseFee(tp.BaseFee) {
+			txs = append(txs, tx)
 		}
+	}
+
+	for _, tx := range txs {
+		tp.LatestIncludedTxs.Add(tx.Hash().String(), []byte{})
+	}
+
+	return txs
+}
+
+// Pending returns a copy of the pending transactions, sorted by fee per gas.
+func (tp *TxPool) Pending() []*types.Transaction {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	txs := make([]*types.Transaction, len(tp.Transactions))
+	copy(txs, tp.Transactions)
+
+	return txs
+}
+
+// QueuedTxs returns a copy of the queued transactions waiting for a nonce gap to fill.
+func (tp *TxPool) QueuedTxs() []*types.Transaction {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	txs := make([]*types.Transaction, len(tp.Queued))
+	copy(txs, tp.Queued)
+
+	return txs
+}
+
+// NextNonce returns the nonce of the next transaction of the sender, following its
+// pending transactions. Queued transactions are past a nonce gap, which the next
+// transaction has to fill first.
+func (tp *TxPool) NextNonce(from util.Address) *big.Int {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	next := tp.stateNonce(from)
+	if next == nil {
+		next = big.NewInt(0)
+	}
+
+	for _, tx := range tp.Transactions {
+		if tx.From == from && tx.Nonce.Cmp(next) >= 0 {
+			next = new(big.Int).Add(tx.Nonce, big.NewInt(1))
+		}
+	}
+
+	return next
+}
+
+// Stats returns the number of pending and queued transactions.
+func (tp *TxPool) Stats() (int, int) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
 
-		if tp.IsValid(tx) {
-			validTxs = append(validTxs, tx)
+	return len(tp.Transactions), len(tp.Queued)
+}
+
+// stateNonce returns the next nonce expected from the sender by the state, nil in mock mode.
+func (tp *TxPool) stateNonce(from util.Address) *big.Int {
+	if tp.State == nil {
+		return nil
+	}
+
+	nonce, err := tp.State.Get(dbstore.PrefixKey(dbstore.NonceKey, from.String()))
+	if err != nil {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Add(new(big.Int).SetBytes(nonce), big.NewInt(1))
+}
+
+// reclassify splits the transactions of the sender into pending ones, with consecutive
+// nonces starting at the state nonce, and queued ones. Transactions with a nonce already
+// used by the state are dropped. In mock mode every transaction is pending.
+func (tp *TxPool) reclassify(from util.Address) {
+	var senderTxs []*types.Transaction
+
+	for _, tx := range tp.all() {
+		if tx.From == from {
+			senderTxs = append(senderTxs, tx)
+			tp.remove(tx)
 		}
 	}
 
-	txpoolTxs := append(tp.Transactions, validTxs...)
-	sort.Slice(txpoolTxs, func(i, j int) bool {
-		return intToBool(txpoolTxs[i].Fee.Cmp(txpoolTxs[j].Fee))
+	sort.SliceStable(senderTxs, func(i, j int) bool {
+		return senderTxs[i].Nonce.Cmp(senderTxs[j].Nonce) < 0
 	})
 
-	tp.Transactions = txpoolTxs
+	next := tp.stateNonce(from)
+
+	for _, tx := range senderTxs {
+		switch {
+		case next == nil:
+			tp.Transactions = append(tp.Transactions, tx)
+		case tx.Nonce.Cmp(next) < 0:
+			fmt.Println("Dropping stale Tx :", "hash :", tx.Hash().String(), "nonce :", tx.Nonce)
+			tp.dropped(tx, nil)
+		case tx.Nonce.Cmp(next) == 0:
+			tp.Transactions = append(tp.Transactions, tx)
+			next = new(big.Int).Add(next, big.NewInt(1))
+		default:
+			tp.Queued = append(tp.Queued, tx)
+		}
+	}
+
+	sort.SliceStable(tp.Transactions, func(i, j int) bool {
+		return tp.Transactions[i].CmpFeePerGas(tp.Transactions[j]) > 0
+	})
 }
 
-// remove transaction from txpool
-func (tp *TxPool) RemoveTx(tx *types.Transaction) error {
+// remove deletes the transaction from the pending or queued set, reporting whether it was found.
+func (tp *TxPool) remove(tx *types.Transaction) bool {
 	for i, tx2 := range tp.Transactions {
 		if tx2.Hash().String() == tx.Hash().String() {
 			tp.Transactions = append(tp.Transactions[:i], tp.Transactions[i+1:]...)
-			return nil
+			return true
 		}
 	}
 
-	return errors.New("transaction not found")
+	for i, tx2 := range tp.Queued {
+		if tx2.Hash().String() == tx.Hash().String() {
+			tp.Queued = append(tp.Queued[:i], tp.Queued[i+1:]...)
+			return true
+		}
+	}
+
+	return false
 }
 
-func (tp *TxPool) GetTxs() []*types.Transaction {
-	txs := tp.Transactions
-	for _, tx := range txs {
-		tp.LatestIncludedTxs.Add(tx.Hash().String(), []byte{})
+// all returns a copy of both the pending and the queued transactions.
+func (tp *TxPool) all() []*types.Transaction {
+	txs := make([]*types.Transaction, 0, len(tp.Transactions)+len(tp.Queued))
+	txs = append(txs, tp.Transactions...)
+
+	return append(txs, tp.Queued...)
+}
+
+// lowestFee returns the transaction paying the lowest fee per gas, preferring queued transactions.
+func (tp *TxPool) lowestFee() *types.Transaction {
+	var lowest *types.Transaction
+
+	candidates := append(append([]*types.Transaction{}, tp.Queued...), tp.Transactions...)
+
+	for _, tx := range candidates {
+		if lowest == nil || tx.CmpFeePerGas(lowest) < 0 {
+			lowest = tx
+		}
 	}
 
-	return txs
+	return lowest
 }
@@ -8,7 +8,13 @@ import (
 type Empty struct{}
 
 func (tp *TxPool) AddTx_RPC(args *types.Transaction, reply *types.RPCResponse) error {
-	tp.AddTx(args)
+	if err := args.VerifySignature(); err != nil {
+		return err
+	}
+
+	if err := tp.AddTx(args); err != nil {
+		return err
+	}
 
 	*reply = types.RPCResponse{Success: true}
 
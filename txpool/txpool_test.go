@@ -1,10 +1,14 @@
 package txpool
 
 import (
+	"fmt"
 	"math/big"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/0xsharma/compact-chain/config"
+	"github.com/0xsharma/compact-chain/dbstore"
 	"github.com/0xsharma/compact-chain/types"
 	"github.com/0xsharma/compact-chain/util"
 	"github.com/stretchr/testify/assert"
@@ -22,10 +26,14 @@ func NewRandomTx(t *testing.T) *types.Transaction {
 func TestTxpoolAdd(t *testing.T) {
 	t.Parallel()
 
-	txpool := NewTxPool(big.NewInt(0), nil, nil)
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, nil, nil)
 
 	for i := 0; i < 100; i++ {
-		txpool.AddTx(NewRandomTx(t))
+		tx := NewRandomTx(t)
+		tx.Nonce = big.NewInt(int64(i))
+
+		// nolint : errcheck
+		txpool.AddTx(tx)
 	}
 
 	txs := txpool.Transactions
@@ -38,3 +46,454 @@ func TestTxpoolAdd(t *testing.T) {
 		assert.Equal(t, true, txs[i-1].Fee.Cmp(txs[i].Fee) >= 0)
 	}
 }
+
+func newFeeTx(t *testing.T, fee int64, nonce int64) *types.Transaction {
+	t.Helper()
+
+	return &types.Transaction{From: util.Address{}, To: util.Address{}, Value: big.NewInt(1), Msg: []byte{}, Fee: big.NewInt(fee), Nonce: big.NewInt(nonce)}
+}
+
+func TestTxpoolEvictsLowestFee(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), MaxPoolSize: 3}, nil, nil)
+
+	cheapest := newFeeTx(t, 200, 0)
+	assert.NoError(t, txpool.AddTx(cheapest))
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 300, 1)))
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 400, 2)))
+
+	// A higher fee transaction evicts the cheapest one.
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 500, 3)))
+	assert.Equal(t, 3, len(txpool.Transactions))
+
+	for _, tx := range txpool.Transactions {
+		assert.NotEqual(t, cheapest.Hash(), tx.Hash())
+	}
+
+	assert.Equal(t, big.NewInt(300), txpool.Transactions[2].Fee)
+
+	// A transaction cheaper than the lowest pending one is rejected.
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := newFeeTx(t, 100, 4)
+	tx.From = *ua.Address()
+	tx.Sign(ua)
+
+	var reply types.RPCResponse
+
+	err := txpool.AddTx_RPC(tx, &reply)
+	assert.ErrorIs(t, err, ErrTxPoolFull)
+	assert.Equal(t, 3, len(txpool.Transactions))
+}
+
+func TestTxpoolReplaceByFee(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, nil, nil)
+
+	original := newFeeTx(t, 1000, 0)
+	assert.NoError(t, txpool.AddTx(original))
+
+	// A bump below 10% is rejected and keeps the original transaction.
+	assert.ErrorIs(t, txpool.AddTx(newFeeTx(t, 1099, 0)), ErrReplaceUnderpriced)
+	assert.Equal(t, 1, len(txpool.Transactions))
+	assert.Equal(t, original.Hash(), txpool.Transactions[0].Hash())
+
+	// A bump of 10% replaces the original transaction.
+	replacement := newFeeTx(t, 1100, 0)
+	assert.NoError(t, txpool.AddTx(replacement))
+	assert.Equal(t, 1, len(txpool.Transactions))
+	assert.Equal(t, replacement.Hash(), txpool.Transactions[0].Hash())
+
+	// A new nonce is added next to the existing transaction regardless of its fee.
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 1, 1)))
+	assert.Equal(t, 2, len(txpool.Transactions))
+
+	// Without a bump, a transaction paying the same fee replaces the original one.
+	noBump := 0
+	txpool = NewTxPool(&config.Config{MinFee: big.NewInt(0), PriceBumpPercent: &noBump}, nil, nil)
+	assert.NoError(t, txpool.AddTx(original))
+
+	replacement = newFeeTx(t, 1000, 0)
+	replacement.Msg = []byte("replacement")
+	assert.NoError(t, txpool.AddTx(replacement))
+	assert.Equal(t, []*types.Transaction{replacement}, txpool.Transactions)
+}
+
+func TestTxpoolDropHook(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), MaxPoolSize: 2}, nil, nil)
+
+	type drop struct{ tx, replacement *types.Transaction }
+
+	drops := []drop{}
+	txpool.SetDropHook(func(tx, replacement *types.Transaction) {
+		drops = append(drops, drop{tx, replacement})
+	})
+
+	original := newFeeTx(t, 200, 0)
+	assert.NoError(t, txpool.AddTx(original))
+
+	// Replacements pass the transaction replacing the dropped one.
+	replacement := newFeeTx(t, 300, 0)
+	assert.NoError(t, txpool.AddTx(replacement))
+	assert.Equal(t, []drop{{original, replacement}}, drops)
+
+	// Evictions don't.
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 400, 1)))
+	assert.NoError(t, txpool.AddTx(newFeeTx(t, 500, 2)))
+	assert.Equal(t, []drop{{original, replacement}, {replacement, nil}}, drops)
+
+	// Transactions leaving through RemoveTx aren't passed.
+	assert.NoError(t, txpool.RemoveTx(txpool.Transactions[0]))
+	assert.Len(t, drops, 2)
+}
+
+func TestTxpoolDynamicFee(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, nil, nil)
+	txpool.SetBaseFee(big.NewInt(100))
+
+	dynamicFeeTx := func(maxFee, maxPriorityFee int64, nonce int64) *types.Transaction {
+		tx := newFeeTx(t, 0, nonce)
+		tx.Fee = nil
+		tx.MaxFee = big.NewInt(maxFee)
+		tx.MaxPriorityFee = big.NewInt(maxPriorityFee)
+
+		return tx
+	}
+
+	// The maximum fee has to cover the base fee, and the tip fit in the maximum fee.
+	assert.ErrorIs(t, txpool.AddTx(dynamicFeeTx(99, 0, 0)), ErrUnderpriced)
+	assert.ErrorIs(t, txpool.AddTx(dynamicFeeTx(150, 151, 0)), ErrTipAboveFeeCap)
+
+	tx := dynamicFeeTx(100, 0, 0)
+	assert.NoError(t, txpool.AddTx(tx))
+	assert.Equal(t, []*types.Transaction{tx}, txpool.GetTxs())
+
+	// Replacements bump the maximum fee.
+	assert.ErrorIs(t, txpool.AddTx(dynamicFeeTx(109, 50, 0)), ErrReplaceUnderpriced)
+	assert.NoError(t, txpool.AddTx(dynamicFeeTx(110, 0, 0)))
+
+	// The base fee is owed for the whole gas limit.
+	tx = dynamicFeeTx(150, 0, 1)
+	tx.GasLimit = 2 * types.TxGas
+	assert.ErrorIs(t, txpool.AddTx(tx), ErrUnderpriced)
+
+	tx.MaxFee = big.NewInt(200)
+	assert.NoError(t, txpool.AddTx(tx))
+}
+
+func TestTxpoolRPCRejectsInvalidSignature(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, nil, nil)
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := newFeeTx(t, 100, 0)
+	tx.From = *ua.Address()
+	tx.Sign(ua)
+
+	// Tamper with the value after signing.
+	tx.Value = big.NewInt(100000)
+
+	var reply types.RPCResponse
+
+	err := txpool.AddTx_RPC(tx, &reply)
+	assert.ErrorIs(t, err, types.ErrInvalidSignature)
+	assert.Equal(t, 0, len(txpool.Transactions))
+}
+
+func TestTxpoolNonceGap(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	from := ua.Address().String()
+
+	// The account has used nonce 4, the next executable nonce is 5.
+	assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.BalanceKey, from), big.NewInt(1000000).Bytes()))
+	assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.NonceKey, from), big.NewInt(4).Bytes()))
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, db, nil)
+
+	newSignedTx := func(nonce int64) *types.Transaction {
+		tx := newFeeTx(t, 100, nonce)
+		tx.From = *ua.Address()
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	tx5, tx6, tx7 := newSignedTx(5), newSignedTx(6), newSignedTx(7)
+
+	assert.NoError(t, txpool.AddTx(tx5))
+	assert.NoError(t, txpool.AddTx(tx7))
+
+	// Only nonce 5 is minable, nonce 7 waits for nonce 6.
+	assert.Equal(t, []*types.Transaction{tx5}, txpool.Pending())
+	assert.Equal(t, []*types.Transaction{tx7}, txpool.QueuedTxs())
+	assert.Equal(t, []*types.Transaction{tx5}, txpool.GetTxs())
+
+	// Filling the gap promotes nonce 7.
+	assert.NoError(t, txpool.AddTx(tx6))
+	assert.Equal(t, 3, len(txpool.Pending()))
+	assert.Equal(t, 0, len(txpool.QueuedTxs()))
+	assert.Equal(t, []*types.Transaction{tx5, tx6, tx7}, txpool.GetTxs())
+
+	// Nonces already used by the state are rejected.
+	assert.ErrorIs(t, txpool.AddTx(newSignedTx(4)), ErrNonceTooLow)
+}
+
+func TestTxpoolInsufficientFunds(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	// The account can pay for one transaction of value 1 and fee 100, not for two.
+	assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.BalanceKey, ua.Address().String()), big.NewInt(150).Bytes()))
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, db, nil)
+
+	newSignedTx := func(fee int64, nonce int64) *types.Transaction {
+		tx := newFeeTx(t, fee, nonce)
+		tx.From = *ua.Address()
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	assert.NoError(t, txpool.AddTx(newSignedTx(100, 0)))
+	assert.ErrorIs(t, txpool.AddTx(newSignedTx(100, 1)), ErrInsufficientFunds)
+	assert.Equal(t, 1, len(txpool.Pending()))
+
+	// A replacement only pays for itself.
+	replacement := newSignedTx(140, 0)
+	assert.NoError(t, txpool.AddTx(replacement))
+	assert.Equal(t, []*types.Transaction{replacement}, txpool.Pending())
+
+	assert.ErrorIs(t, txpool.AddTx(newSignedTx(150, 0)), ErrInsufficientFunds)
+	assert.Equal(t, []*types.Transaction{replacement}, txpool.Pending())
+
+	// Senders without a balance can't pay for anything.
+	ub := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+	tx := newFeeTx(t, 100, 0)
+	tx.From = *ub.Address()
+	tx.Sign(ub)
+
+	assert.ErrorIs(t, txpool.AddTx(tx), ErrInsufficientFunds)
+}
+
+func TestTxpoolGetTxsNonceOrder(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0)}, nil, nil)
+
+	// A later nonce paying a higher fee must not be mined before the earlier one.
+	low := newFeeTx(t, 100, 0)
+	high := newFeeTx(t, 500, 1)
+
+	assert.NoError(t, txpool.AddTx(high))
+	assert.NoError(t, txpool.AddTx(low))
+
+	assert.Equal(t, high.Hash(), txpool.Transactions[0].Hash())
+	assert.Equal(t, []*types.Transaction{low, high}, txpool.GetTxs())
+}
+
+func TestTxpoolLifetime(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.BalanceKey, ua.Address().String()), big.NewInt(1000000).Bytes()))
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), TxPoolLifetime: time.Hour}, db, nil)
+
+	now := time.Unix(1700000000, 0)
+	txpool.now = func() time.Time { return now }
+
+	newSignedTx := func(nonce int64, fee int64) *types.Transaction {
+		tx := newFeeTx(t, fee, nonce)
+		tx.From = *ua.Address()
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	// Nonce 0 is minable, nonce 2 waits for nonce 1 which never comes.
+	tx0, tx2 := newSignedTx(0, 100), newSignedTx(2, 100)
+	assert.NoError(t, txpool.AddTx(tx0))
+	assert.NoError(t, txpool.AddTx(tx2))
+
+	now = now.Add(59 * time.Minute)
+	assert.Empty(t, txpool.EvictExpired())
+
+	// Nonce 3 arrives later, it expires later as well.
+	tx3 := newSignedTx(3, 100)
+	assert.NoError(t, txpool.AddTx(tx3))
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, []*types.Transaction{tx2}, txpool.EvictExpired())
+	assert.Equal(t, []*types.Transaction{tx0}, txpool.Pending())
+	assert.Equal(t, []*types.Transaction{tx3}, txpool.QueuedTxs())
+
+	// A transaction which became minable before expiring is kept.
+	tx1, tx2 := newSignedTx(1, 100), newSignedTx(2, 100)
+	assert.NoError(t, txpool.AddTx(tx1))
+	assert.NoError(t, txpool.AddTx(tx2))
+
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, txpool.EvictExpired())
+	assert.Equal(t, 4, len(txpool.Pending()))
+
+	// Pending transactions below a rising base fee expire as well.
+	txpool.SetBaseFee(big.NewInt(200))
+	assert.Len(t, txpool.EvictExpired(), 4)
+	assert.Empty(t, txpool.Pending())
+	assert.Empty(t, txpool.arrivals)
+}
+
+func TestTxpoolClose(t *testing.T) {
+	t.Parallel()
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), TxPoolLifetime: time.Hour}, nil, make(chan *types.Transaction))
+
+	txpool.Close()
+	txpool.Close()
+
+	// The loops return once the txpool is closed.
+	done := make(chan struct{})
+
+	go func() {
+		txpool.loop()
+		txpool.sweepLoop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("txpool loops still running after Close")
+	}
+}
+
+// senderBlocklist rejects the transactions of the blocked senders.
+type senderBlocklist map[util.Address]bool
+
+func (b senderBlocklist) ValidateTx(tx *types.Transaction) error {
+	if b[tx.From] {
+		return fmt.Errorf("sender %s is blocked", tx.From.String())
+	}
+
+	return nil
+}
+
+func TestTxpoolValidator(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	allowed := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	blocked := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+	unfunded := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a8"))
+
+	for _, ua := range []*util.UnlockedAccount{allowed, blocked} {
+		assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.BalanceKey, ua.Address().String()), big.NewInt(1000).Bytes()))
+	}
+
+	validator := senderBlocklist{*blocked.Address(): true, *unfunded.Address(): true}
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), TxValidator: validator}, db, nil)
+
+	newSignedTx := func(ua *util.UnlockedAccount) *types.Transaction {
+		tx := newFeeTx(t, 100, 0)
+		tx.From = *ua.Address()
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	assert.NoError(t, txpool.AddTx(newSignedTx(allowed)))
+
+	err = txpool.AddTx(newSignedTx(blocked))
+	assert.EqualError(t, err, "sender "+blocked.Address().String()+" is blocked")
+
+	_, err = txpool.Simulate(newSignedTx(blocked))
+	assert.EqualError(t, err, "sender "+blocked.Address().String()+" is blocked")
+
+	// The standard checks come first.
+	assert.ErrorIs(t, txpool.AddTx(newSignedTx(unfunded)), ErrInsufficientFunds)
+
+	assert.Len(t, txpool.Pending(), 1)
+	assert.Equal(t, *allowed.Address(), txpool.Pending()[0].From)
+
+	// Without a validator every transaction passing the standard checks is accepted.
+	txpool = NewTxPool(&config.Config{MinFee: big.NewInt(0)}, db, nil)
+	assert.NoError(t, txpool.AddTx(newSignedTx(blocked)))
+}
+
+func TestTxpoolMaxTxPerSender(t *testing.T) {
+	t.Parallel()
+
+	db, err := dbstore.NewDBInstance(t.TempDir())
+	assert.NoError(t, err)
+
+	defer db.Close()
+
+	sender := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	other := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+
+	for _, ua := range []*util.UnlockedAccount{sender, other} {
+		assert.NoError(t, db.Put(dbstore.PrefixKey(dbstore.BalanceKey, ua.Address().String()), big.NewInt(10000).Bytes()))
+	}
+
+	txpool := NewTxPool(&config.Config{MinFee: big.NewInt(0), MaxTxPerSender: 3}, db, nil)
+
+	newSignedTx := func(ua *util.UnlockedAccount, fee int64, nonce int64) *types.Transaction {
+		tx := newFeeTx(t, fee, nonce)
+		tx.From = *ua.Address()
+		tx.Sign(ua)
+
+		return tx
+	}
+
+	// Queued transactions count towards the limit along with the pending ones.
+	assert.NoError(t, txpool.AddTx(newSignedTx(sender, 100, 0)))
+	assert.NoError(t, txpool.AddTx(newSignedTx(sender, 100, 1)))
+	assert.NoError(t, txpool.AddTx(newSignedTx(sender, 100, 5)))
+	assert.Len(t, txpool.Pending(), 2)
+	assert.Len(t, txpool.QueuedTxs(), 1)
+
+	for _, nonce := range []int64{2, 6} {
+		err := txpool.AddTx(newSignedTx(sender, 100, nonce))
+		assert.ErrorIs(t, err, ErrSenderLimit)
+		assert.ErrorContains(t, err, "holds 3 transactions, maximum 3")
+	}
+
+	// Replacements are still accepted.
+	assert.NoError(t, txpool.AddTx(newSignedTx(sender, 200, 1)))
+	assert.Len(t, txpool.Pending(), 2)
+
+	// Other senders are unaffected.
+	assert.NoError(t, txpool.AddTx(newSignedTx(other, 100, 0)))
+	assert.Len(t, txpool.Pending(), 3)
+	assert.Len(t, txpool.QueuedTxs(), 1)
+}
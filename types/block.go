@@ -2,35 +2,66 @@ package types
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"encoding/gob"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/0xsharma/compact-chain/util"
 	"github.com/cbergoon/merkletree"
 )
 
+const (
+	// DefaultMaxBlockBytes is the maximum size of a serialized block when none is configured.
+	DefaultMaxBlockBytes = 1 << 20
+
+	// DefaultMaxBlockTxs is the maximum number of transactions of a block when none is configured.
+	DefaultMaxBlockTxs = 2000
+
+	// RLPHashVersion is the version of the blocks whose hash is the one of the RLP encoding
+	// of their header, rather than of its fields joined, which bytes can move between.
+	RLPHashVersion uint64 = 1
+)
+
+var (
+	// ErrInvalidBlockEncoding is returned when decoding malformed block bytes.
+	ErrInvalidBlockEncoding = errors.New("invalid block encoding")
+
+	// ErrBlockTooLarge is returned when decoding a block over the maximum size.
+	ErrBlockTooLarge = errors.New("block too large")
+
+	// ErrTooManyTxs is returned when decoding a block with more transactions than allowed.
+	ErrTooManyTxs = errors.New("too many transactions in block")
+)
+
 // Block is the basic unit of the blockchain.
 type Block struct {
 	Number       *big.Int
 	ParentHash   *util.Hash
+	Timestamp    uint64
+	Difficulty   uint64
+	BaseFee      *big.Int
 	ExtraData    []byte
 	Nonce        *big.Int
 	Transactions []*Transaction
 	TxRoot       *util.Hash
+	StateRoot    *util.Hash    // Root of the state after the block, nil for blocks mined before it was committed to
+	CoinbaseAddr *util.Address // Credited with the fees and the reward instead of the signer when set
+	Version      uint64        // RLPHashVersion, or zero for blocks hashing their joined header fields
 
 	R         *big.Int
 	S         *big.Int
 	PublicKey *util.CompactPublicKey
 }
 
-// NewBlock creates a new block and sets the hash.
+// NewBlock creates a new block, hashing the RLP encoding of its header.
 func NewBlock(number *big.Int, parentHash *util.Hash, data []byte) *Block {
 	block := &Block{
 		Number:     number,
 		ParentHash: parentHash,
 		ExtraData:  data,
 		Nonce:      big.NewInt(0),
+		Version:    RLPHashVersion,
 	}
 
 	return block
@@ -40,17 +71,63 @@ func NewBlock(number *big.Int, parentHash *util.Hash, data []byte) *Block {
 func (dst *Block) Clone(src *Block) {
 	dst.Number = src.Number
 	dst.ParentHash = src.ParentHash
+	dst.Timestamp = src.Timestamp
+	dst.Difficulty = src.Difficulty
+	dst.BaseFee = src.BaseFee
 	dst.ExtraData = src.ExtraData
 	dst.Nonce = src.Nonce
+	dst.TxRoot = src.TxRoot
+	dst.StateRoot = src.StateRoot
+	dst.CoinbaseAddr = src.CoinbaseAddr
+	dst.Version = src.Version
 }
 
-// DeriveHash derives the hash of the block.
+// DeriveHash derives the hash of the block, from the RLP encoding of its header for
+// blocks of RLPHashVersion, or else from its joined header fields.
 func (b *Block) DeriveHash() *util.Hash {
-	blockHash := bytes.Join([][]byte{b.Number.Bytes(), b.ParentHash.Bytes(), b.ExtraData, b.Nonce.Bytes(), b.TxRootHash().Bytes()}, []byte{})
+	if b.Version >= RLPHashVersion {
+		return util.HashData(b.appendRLPHeader(nil))
+	}
+
+	timestamp := binary.BigEndian.AppendUint64(nil, b.Timestamp)
+	difficulty := binary.BigEndian.AppendUint64(nil, b.Difficulty)
+	baseFee := []byte{}
+
+	if b.BaseFee != nil {
+		baseFee = b.BaseFee.Bytes()
+	}
+
+	// Blocks crediting their signer don't commit to a coinbase, keeping the hashes of the
+	// blocks mined before the coinbase could be set.
+	coinbase := []byte{}
+
+	if b.CoinbaseAddr != nil {
+		coinbase = b.CoinbaseAddr.Bytes()
+	}
+
+	// Likewise for the state root.
+	stateRoot := []byte{}
+
+	if b.StateRoot != nil {
+		stateRoot = b.StateRoot.Bytes()
+	}
+
+	blockHash := bytes.Join([][]byte{b.Number.Bytes(), b.ParentHash.Bytes(), timestamp, difficulty, baseFee, b.ExtraData, b.Nonce.Bytes(), b.txRoot().Bytes(), coinbase, stateRoot}, []byte{})
 
 	return util.HashData(blockHash)
 }
 
+// GasUsed returns the gas consumed by the transactions of the block.
+func (b *Block) GasUsed() uint64 {
+	gasUsed := uint64(0)
+
+	for _, tx := range b.Transactions {
+		gasUsed += tx.Gas()
+	}
+
+	return gasUsed
+}
+
 func (b *Block) TxRootHash() *util.Hash {
 	if len(b.Transactions) == 0 {
 		return util.HashData([]byte{})
@@ -70,38 +147,97 @@ func (b *Block) TxRootHash() *util.Hash {
 	}
 }
 
+// txRoot returns the transactions root committed to by the header. Blocks mined before
+// the root was stored commit to the root of their transactions.
+func (b *Block) txRoot() *util.Hash {
+	if b.TxRoot != nil {
+		return b.TxRoot
+	}
+
+	return b.TxRootHash()
+}
+
+// SetTxRoot stores the root of the transactions of the block in its header.
+func (b *Block) SetTxRoot() {
+	b.TxRoot = b.TxRootHash()
+}
+
+// VerifyTxRoot reports whether the transactions of the block match the root of its header.
+func (b *Block) VerifyTxRoot() bool {
+	return b.TxRoot == nil || *b.TxRoot == *b.TxRootHash()
+}
+
+// VerifyStateRoot reports whether the header commits to the root of the state after
+// applying the block. Blocks without a state root don't.
+func (b *Block) VerifyStateRoot(root *util.Hash) bool {
+	return b.StateRoot != nil && *b.StateRoot == *root
+}
+
+// Coinbase returns the address credited with the fees and the reward of the block, its
+// coinbase address if set or else the address of its signer. It is nil for unsigned
+// blocks without a coinbase address.
+func (b *Block) Coinbase() *util.Address {
+	if b.CoinbaseAddr != nil {
+		return b.CoinbaseAddr
+	}
+
+	if b.PublicKey == nil {
+		return nil
+	}
+
+	return b.PublicKey.Address()
+}
+
 // SetNonce sets the nonce of the block.
 func (b *Block) SetNonce(n *big.Int) {
 	b.Nonce = n
 }
 
-// Serialize serializes the block object into bytes.
+// Serialize serializes the block object into bytes, with the canonical encoding.
 func (b *Block) Serialize() []byte {
-	var res bytes.Buffer
-	encoder := gob.NewEncoder(&res)
-
-	err := encoder.Encode(b)
+	return b.appendRLP([]byte{canonicalEncodingPrefix})
+}
 
+// DeserializeBlock deserializes the block bytes into a block object.
+func DeserializeBlock(data []byte) *Block {
+	block, err := decodeStored(data, &Block{}, decodeRLPBlock)
 	if err != nil {
 		panic(err)
 	}
 
-	return res.Bytes()
+	return block
 }
 
-// DeserializeBlock deserializes the block bytes into a block object.
-func DeserializeBlock(data []byte) *Block {
-	var block Block
+// DecodeBlock decodes a block serialized with Serialize, received from an untrusted
+// source. Unlike DeserializeBlock, it reports malformed data with ErrInvalidBlockEncoding
+// and refuses gob streams. Blocks over maxBytes are refused
+// before being decoded, so that the memory decoding takes stays bounded, and blocks over
+// maxTxs transactions right after.
+func DecodeBlock(data []byte, maxBytes int, maxTxs int) (*Block, error) {
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("%w : %d bytes, maximum %d", ErrBlockTooLarge, len(data), maxBytes)
+	}
+
+	block, err := decodeCanonical(data, decodeRLPBlock)
+	if err != nil {
+		return nil, ErrInvalidBlockEncoding
+	}
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if block.Number == nil || block.ParentHash == nil || block.Nonce == nil || negative(block.Number, block.Nonce, block.BaseFee) {
+		return nil, ErrInvalidBlockEncoding
+	}
 
-	err := decoder.Decode(&block)
+	if len(block.Transactions) > maxTxs {
+		return nil, fmt.Errorf("%w : %d transactions, maximum %d", ErrTooManyTxs, len(block.Transactions), maxTxs)
+	}
 
-	if err != nil {
-		panic(err)
+	for _, tx := range block.Transactions {
+		if tx == nil || tx.Value == nil || tx.Nonce == nil || negative(tx.Value, tx.Nonce) || !tx.validFees() {
+			return nil, ErrInvalidBlockEncoding
+		}
 	}
 
-	return &block
+	return block, nil
 }
 
 func (b *Block) Sign(ua *util.UnlockedAccount) {
@@ -112,10 +248,15 @@ func (b *Block) Sign(ua *util.UnlockedAccount) {
 
 	b.R = r
 	b.S = s
-	b.PublicKey = util.PublicKeyToCompact(ua.PublicKey())
+	b.PublicKey = ua.PublicKey()
 }
 
 func (b *Block) Verify() bool {
-	pubKey := b.PublicKey.PublicKey()
-	return ecdsa.Verify(pubKey, b.DeriveHash().Bytes(), b.R, b.S)
+	return b.PublicKey.Verify(b.DeriveHash().Bytes(), b.R, b.S)
+}
+
+// SignedHash returns the hash of the block along with the signature and public key of
+// its signer, which DeriveHash leaves out.
+func (b *Block) SignedHash() *util.Hash {
+	return signedHash(b.DeriveHash(), b.R, b.S, b.PublicKey)
 }
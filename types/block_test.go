@@ -0,0 +1,65 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBlock(t *testing.T, txs int) *Block {
+	t.Helper()
+
+	tx, _ := newSignedTx(t)
+
+	block := NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte("Block 1"))
+	for i := 0; i < txs; i++ {
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	return block
+}
+
+func TestDecodeBlock(t *testing.T) {
+	t.Parallel()
+
+	block := newTestBlock(t, 3)
+	data := block.Serialize()
+
+	decoded, err := DecodeBlock(data, len(data), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, block.DeriveHash(), decoded.DeriveHash())
+
+	_, err = DecodeBlock(data, len(data), 2)
+	assert.ErrorIs(t, err, ErrTooManyTxs)
+
+	_, err = DecodeBlock(data[:len(data)/2], len(data), 3)
+	assert.ErrorIs(t, err, ErrInvalidBlockEncoding)
+
+	// A well formed encoding missing header fields.
+	_, err = DecodeBlock((&Block{Number: big.NewInt(1)}).Serialize(), len(data), 3)
+	assert.ErrorIs(t, err, ErrInvalidBlockEncoding)
+}
+
+// Not parallel, so that the allocations of other tests aren't counted.
+func TestDecodeBlockTooLarge(t *testing.T) {
+	data := newTestBlock(t, DefaultMaxBlockTxs).Serialize()
+	assert.Greater(t, len(data), 100000)
+
+	_, err := DecodeBlock(data, 1000, DefaultMaxBlockTxs)
+	assert.ErrorIs(t, err, ErrBlockTooLarge)
+
+	// The oversized block is refused before any of it gets decoded, so that refusing it
+	// allocates a small fraction of what decoding it does. The bound leaves headroom for
+	// the allocations of the runtime and of background goroutines.
+	decodeAllocs := testing.AllocsPerRun(1, func() {
+		// nolint : errcheck
+		DecodeBlock(data, len(data), DefaultMaxBlockTxs)
+	})
+	refuseAllocs := testing.AllocsPerRun(10, func() {
+		// nolint : errcheck
+		DecodeBlock(data, 1000, DefaultMaxBlockTxs)
+	})
+	assert.Less(t, refuseAllocs, decodeAllocs/10)
+}
@@ -0,0 +1,567 @@
+package types
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// Transactions and blocks are serialized as RLP lists of their fields, in the order of
+// their declaration, prefixed with canonicalEncodingPrefix. The version of blocks is only
+// encoded when set, so that blocks from before versions keep their encoding. Integers are big endian
+// without leading zeros, so that every value has exactly one encoding, and nil values
+// are the empty list. Public keys are the list of their curve name, X, Y and Ed25519
+// key. Data serialized before, as gob streams, is still decoded.
+//
+// Gob streams start with a length, a byte below 0x80 or a negated byte count of 0xf8 and
+// above, so they never start with the prefix. Neither do snappy compressed blocks.
+const canonicalEncodingPrefix byte = 0x81
+
+var errInvalidRLP = errors.New("invalid rlp")
+
+// rlpNil is the encoding of nil values.
+var rlpNil = []byte{0xc0}
+
+func appendRLPSize(dst []byte, offset byte, size int) []byte {
+	if size <= 55 {
+		return append(dst, offset+byte(size))
+	}
+
+	sizeBytes := uintBytes(uint64(size))
+
+	return append(append(dst, offset+55+byte(len(sizeBytes))), sizeBytes...)
+}
+
+func appendRLPString(dst []byte, b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return append(dst, b[0])
+	}
+
+	return append(appendRLPSize(dst, 0x80, len(b)), b...)
+}
+
+func appendRLPList(dst []byte, payload []byte) []byte {
+	return append(appendRLPSize(dst, 0xc0, len(payload)), payload...)
+}
+
+func appendRLPUint(dst []byte, n uint64) []byte {
+	return appendRLPString(dst, uintBytes(n))
+}
+
+// appendRLPBig appends the absolute value of the integer, or nil.
+func appendRLPBig(dst []byte, n *big.Int) []byte {
+	if n == nil {
+		return append(dst, rlpNil...)
+	}
+
+	return appendRLPString(dst, n.Bytes())
+}
+
+func appendRLPHash(dst []byte, h *util.Hash) []byte {
+	if h == nil {
+		return append(dst, rlpNil...)
+	}
+
+	return appendRLPString(dst, h.Bytes())
+}
+
+func appendRLPAddress(dst []byte, a *util.Address) []byte {
+	if a == nil {
+		return append(dst, rlpNil...)
+	}
+
+	return appendRLPString(dst, a.Bytes())
+}
+
+func appendRLPPublicKey(dst []byte, pub *util.CompactPublicKey) []byte {
+	if pub == nil {
+		return append(dst, rlpNil...)
+	}
+
+	curve := ""
+	if pub.CurveParams != nil {
+		curve = pub.CurveParams.Name
+	}
+
+	payload := appendRLPString(nil, []byte(curve))
+	payload = appendRLPBig(payload, pub.X)
+	payload = appendRLPBig(payload, pub.Y)
+	payload = appendRLPString(payload, pub.Ed25519)
+
+	return appendRLPList(dst, payload)
+}
+
+func uintBytes(n uint64) []byte {
+	return bytes.TrimLeft(binary.BigEndian.AppendUint64(nil, n), "\x00")
+}
+
+// rlpItem is a decoded RLP string, or the encoding of the items of a list.
+type rlpItem struct {
+	list    bool
+	content []byte
+}
+
+// splitRLP splits the first item off data. Encodings other than the shortest one are
+// rejected, so that decoding then encoding gives back the same bytes.
+func splitRLP(data []byte) (rlpItem, []byte, error) {
+	if len(data) == 0 {
+		return rlpItem{}, nil, errInvalidRLP
+	}
+
+	prefix := data[0]
+	if prefix < 0x80 {
+		return rlpItem{content: data[:1]}, data[1:], nil
+	}
+
+	list := prefix >= 0xc0
+
+	offset := byte(0x80)
+	if list {
+		offset = 0xc0
+	}
+
+	size, header := int(prefix-offset), 1
+
+	if size > 55 {
+		sizeLen := size - 55
+
+		// Sizes are bounded to 4 bytes, more than any block.
+		if sizeLen > 4 || len(data) < 1+sizeLen || data[1] == 0 {
+			return rlpItem{}, nil, errInvalidRLP
+		}
+
+		size = 0
+		for _, b := range data[1 : 1+sizeLen] {
+			size = size<<8 | int(b)
+		}
+
+		if size <= 55 {
+			return rlpItem{}, nil, errInvalidRLP
+		}
+
+		header += sizeLen
+	}
+
+	if size > len(data)-header {
+		return rlpItem{}, nil, errInvalidRLP
+	}
+
+	content := data[header : header+size]
+	if !list && size == 1 && content[0] < 0x80 {
+		return rlpItem{}, nil, errInvalidRLP
+	}
+
+	return rlpItem{list: list, content: content}, data[header+size:], nil
+}
+
+// decodeRLP decodes data as a single item.
+func decodeRLP(data []byte) (rlpItem, error) {
+	item, rest, err := splitRLP(data)
+	if err != nil || len(rest) != 0 {
+		return rlpItem{}, errInvalidRLP
+	}
+
+	return item, nil
+}
+
+func (it rlpItem) isNil() bool {
+	return it.list && len(it.content) == 0
+}
+
+// items returns the items of the list, which must have count items unless count is
+// negative.
+func (it rlpItem) items(count int) ([]rlpItem, error) {
+	if !it.list {
+		return nil, errInvalidRLP
+	}
+
+	items := []rlpItem{}
+
+	for rest := it.content; len(rest) > 0; {
+		var (
+			item rlpItem
+			err  error
+		)
+
+		item, rest, err = splitRLP(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if count >= 0 && len(items) != count {
+		return nil, errInvalidRLP
+	}
+
+	return items, nil
+}
+
+// bytes returns a copy of the string, nil if empty.
+func (it rlpItem) bytes() ([]byte, error) {
+	if it.list {
+		return nil, errInvalidRLP
+	}
+
+	if len(it.content) == 0 {
+		return nil, nil
+	}
+
+	return append([]byte{}, it.content...), nil
+}
+
+func (it rlpItem) uint() (uint64, error) {
+	if it.list || len(it.content) > 8 || (len(it.content) > 0 && it.content[0] == 0) {
+		return 0, errInvalidRLP
+	}
+
+	n := uint64(0)
+	for _, b := range it.content {
+		n = n<<8 | uint64(b)
+	}
+
+	return n, nil
+}
+
+func (it rlpItem) big() (*big.Int, error) {
+	if it.isNil() {
+		return nil, nil
+	}
+
+	if it.list || (len(it.content) > 0 && it.content[0] == 0) {
+		return nil, errInvalidRLP
+	}
+
+	return new(big.Int).SetBytes(it.content), nil
+}
+
+func (it rlpItem) fixed(size int) ([]byte, error) {
+	if it.isNil() {
+		return nil, nil
+	}
+
+	if it.list || len(it.content) != size {
+		return nil, errInvalidRLP
+	}
+
+	return it.content, nil
+}
+
+func (it rlpItem) hash() (*util.Hash, error) {
+	b, err := it.fixed(len(util.Hash{}))
+	if b == nil {
+		return nil, err
+	}
+
+	return util.ByteToHash(b), nil
+}
+
+func (it rlpItem) address() (*util.Address, error) {
+	b, err := it.fixed(len(util.Address{}))
+	if b == nil {
+		return nil, err
+	}
+
+	return util.BytesToAddress(b), nil
+}
+
+// curves are the curves of the public keys which can be decoded, by name.
+var curves = map[string]*elliptic.CurveParams{
+	elliptic.P256().Params().Name: elliptic.P256().Params(),
+}
+
+func (it rlpItem) publicKey() (*util.CompactPublicKey, error) {
+	if it.isNil() {
+		return nil, nil
+	}
+
+	items, err := it.items(4)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := &util.CompactPublicKey{}
+
+	curve, err := items[0].bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(curve) > 0 {
+		if pub.CurveParams = curves[string(curve)]; pub.CurveParams == nil {
+			return nil, errInvalidRLP
+		}
+	}
+
+	if pub.X, err = items[1].big(); err != nil {
+		return nil, err
+	}
+
+	if pub.Y, err = items[2].big(); err != nil {
+		return nil, err
+	}
+
+	if pub.Ed25519, err = items[3].bytes(); err != nil {
+		return nil, err
+	}
+
+	return pub, nil
+}
+
+// appendRLP appends the encoding of the transaction, the empty list if nil.
+func (tx *Transaction) appendRLP(dst []byte) []byte {
+	if tx == nil {
+		return append(dst, rlpNil...)
+	}
+
+	payload := appendRLPString(nil, tx.From.Bytes())
+	payload = appendRLPString(payload, tx.To.Bytes())
+	payload = appendRLPBig(payload, tx.Value)
+	payload = appendRLPString(payload, tx.Msg)
+	payload = appendRLPString(payload, tx.Data)
+	payload = appendRLPBig(payload, tx.Fee)
+	payload = appendRLPBig(payload, tx.Nonce)
+	payload = appendRLPUint(payload, tx.GasLimit)
+	payload = appendRLPUint(payload, tx.ChainID)
+	payload = appendRLPBig(payload, tx.R)
+	payload = appendRLPBig(payload, tx.S)
+	payload = appendRLPPublicKey(payload, tx.PublicKey)
+	payload = appendRLPBig(payload, tx.MaxFee)
+	payload = appendRLPBig(payload, tx.MaxPriorityFee)
+
+	return appendRLPList(dst, payload)
+}
+
+// appendRLPUnsigned appends the encoding of the fields of the transaction but its
+// signature, the preimage of the hash of the transactions signed for a chain id.
+func (tx *Transaction) appendRLPUnsigned(dst []byte) []byte {
+	payload := appendRLPString(nil, tx.From.Bytes())
+	payload = appendRLPString(payload, tx.To.Bytes())
+	payload = appendRLPBig(payload, tx.Value)
+	payload = appendRLPString(payload, tx.Msg)
+	payload = appendRLPString(payload, tx.Data)
+	payload = appendRLPBig(payload, tx.Fee)
+	payload = appendRLPBig(payload, tx.Nonce)
+	payload = appendRLPUint(payload, tx.GasLimit)
+	payload = appendRLPUint(payload, tx.ChainID)
+	payload = appendRLPBig(payload, tx.MaxFee)
+	payload = appendRLPBig(payload, tx.MaxPriorityFee)
+
+	return appendRLPList(dst, payload)
+}
+
+func decodeRLPTransaction(it rlpItem) (*Transaction, error) {
+	if it.isNil() {
+		return nil, nil
+	}
+
+	items, err := it.items(14)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{}
+
+	from, err := items[0].fixed(len(util.Address{}))
+	if err != nil || from == nil {
+		return nil, errInvalidRLP
+	}
+
+	to, err := items[1].fixed(len(util.Address{}))
+	if err != nil || to == nil {
+		return nil, errInvalidRLP
+	}
+
+	copy(tx.From[:], from)
+	copy(tx.To[:], to)
+
+	decoders := []func() error{
+		func() (err error) { tx.Value, err = items[2].big(); return },
+		func() (err error) { tx.Msg, err = items[3].bytes(); return },
+		func() (err error) { tx.Data, err = items[4].bytes(); return },
+		func() (err error) { tx.Fee, err = items[5].big(); return },
+		func() (err error) { tx.Nonce, err = items[6].big(); return },
+		func() (err error) { tx.GasLimit, err = items[7].uint(); return },
+		func() (err error) { tx.ChainID, err = items[8].uint(); return },
+		func() (err error) { tx.R, err = items[9].big(); return },
+		func() (err error) { tx.S, err = items[10].big(); return },
+		func() (err error) { tx.PublicKey, err = items[11].publicKey(); return },
+		func() (err error) { tx.MaxFee, err = items[12].big(); return },
+		func() (err error) { tx.MaxPriorityFee, err = items[13].big(); return },
+	}
+
+	for _, decode := range decoders {
+		if err := decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+// appendRLP appends the encoding of the block.
+func (b *Block) appendRLP(dst []byte) []byte {
+	txs := []byte{}
+	for _, tx := range b.Transactions {
+		txs = tx.appendRLP(txs)
+	}
+
+	payload := appendRLPBig(nil, b.Number)
+	payload = appendRLPHash(payload, b.ParentHash)
+	payload = appendRLPUint(payload, b.Timestamp)
+	payload = appendRLPUint(payload, b.Difficulty)
+	payload = appendRLPBig(payload, b.BaseFee)
+	payload = appendRLPString(payload, b.ExtraData)
+	payload = appendRLPBig(payload, b.Nonce)
+	payload = appendRLPList(payload, txs)
+	payload = appendRLPHash(payload, b.TxRoot)
+	payload = appendRLPHash(payload, b.StateRoot)
+	payload = appendRLPAddress(payload, b.CoinbaseAddr)
+	payload = appendRLPBig(payload, b.R)
+	payload = appendRLPBig(payload, b.S)
+	payload = appendRLPPublicKey(payload, b.PublicKey)
+
+	if b.Version != 0 {
+		payload = appendRLPUint(payload, b.Version)
+	}
+
+	return appendRLPList(dst, payload)
+}
+
+// appendRLPHeader appends the encoding of the version and the header fields of the block,
+// committing to its transactions through their root, the preimage of the hash of blocks
+// of RLPHashVersion.
+func (b *Block) appendRLPHeader(dst []byte) []byte {
+	payload := appendRLPUint(nil, b.Version)
+	payload = appendRLPBig(payload, b.Number)
+	payload = appendRLPHash(payload, b.ParentHash)
+	payload = appendRLPUint(payload, b.Timestamp)
+	payload = appendRLPUint(payload, b.Difficulty)
+	payload = appendRLPBig(payload, b.BaseFee)
+	payload = appendRLPString(payload, b.ExtraData)
+	payload = appendRLPBig(payload, b.Nonce)
+	payload = appendRLPHash(payload, b.txRoot())
+	payload = appendRLPHash(payload, b.StateRoot)
+	payload = appendRLPAddress(payload, b.CoinbaseAddr)
+
+	return appendRLPList(dst, payload)
+}
+
+func decodeRLPBlock(it rlpItem) (*Block, error) {
+	items, err := it.items(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) != 14 && len(items) != 15 {
+		return nil, errInvalidRLP
+	}
+
+	txs, err := items[7].items(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Block{}
+
+	for _, item := range txs {
+		tx, err := decodeRLPTransaction(item)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Transactions = append(b.Transactions, tx)
+	}
+
+	decoders := []func() error{
+		func() (err error) { b.Number, err = items[0].big(); return },
+		func() (err error) { b.ParentHash, err = items[1].hash(); return },
+		func() (err error) { b.Timestamp, err = items[2].uint(); return },
+		func() (err error) { b.Difficulty, err = items[3].uint(); return },
+		func() (err error) { b.BaseFee, err = items[4].big(); return },
+		func() (err error) { b.ExtraData, err = items[5].bytes(); return },
+		func() (err error) { b.Nonce, err = items[6].big(); return },
+		func() (err error) { b.TxRoot, err = items[8].hash(); return },
+		func() (err error) { b.StateRoot, err = items[9].hash(); return },
+		func() (err error) { b.CoinbaseAddr, err = items[10].address(); return },
+		func() (err error) { b.R, err = items[11].big(); return },
+		func() (err error) { b.S, err = items[12].big(); return },
+		func() (err error) { b.PublicKey, err = items[13].publicKey(); return },
+	}
+
+	for _, decode := range decoders {
+		if err := decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	// A version of zero is encoded by leaving it out.
+	if len(items) == 15 {
+		if b.Version, err = items[14].uint(); err != nil || b.Version == 0 {
+			return nil, errInvalidRLP
+		}
+	}
+
+	return b, nil
+}
+
+// decodeStored decodes data read from the local DB, serialized with the canonical
+// encoding or else as a gob stream into v, with decode. Gob streams carry the curve
+// parameters of their public keys, so data from peers or RPC goes through decodeCanonical.
+func decodeStored[T any](data []byte, v *T, decode func(rlpItem) (*T, error)) (*T, error) {
+	if len(data) == 0 || data[0] != canonicalEncodingPrefix {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+
+	return decodeCanonical(data, decode)
+}
+
+// decodeCanonical decodes data serialized with the canonical encoding, with decode.
+func decodeCanonical[T any](data []byte, decode func(rlpItem) (*T, error)) (*T, error) {
+	if len(data) == 0 || data[0] != canonicalEncodingPrefix {
+		return nil, errInvalidRLP
+	}
+
+	item, err := decodeRLP(data[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := decode(item)
+	if err == nil && decoded == nil {
+		return nil, errInvalidRLP
+	}
+
+	return decoded, err
+}
+
+// bigBytes returns the absolute value of the integer as big endian bytes, none if nil.
+func bigBytes(n *big.Int) []byte {
+	if n == nil {
+		return nil
+	}
+
+	return n.Bytes()
+}
+
+// negative reports whether any of the integers is negative, which the canonical encoding
+// can't represent.
+func negative(ns ...*big.Int) bool {
+	for _, n := range ns {
+		if n != nil && n.Sign() < 0 {
+			return true
+		}
+	}
+
+	return false
+}
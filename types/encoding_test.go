@@ -0,0 +1,288 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEncodingTestBlock(t *testing.T) *Block {
+	t.Helper()
+
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+	edKey, err := util.HexToEd25519PrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed := util.NewUnlockedAccount(edKey)
+
+	secpTx, _ := newSignedTx(t)
+	edTx := &Transaction{
+		From:     *ed.Address(),
+		To:       *util.BytesToAddress([]byte{0x02}),
+		Value:    new(big.Int).Lsh(big.NewInt(1), 100),
+		Data:     bytes.Repeat([]byte{0xab}, 100),
+		Nonce:    big.NewInt(7),
+		GasLimit: 2 * TxGas,
+		ChainID:  1,
+		// A dynamic fee transaction.
+		MaxFee:         big.NewInt(300),
+		MaxPriorityFee: big.NewInt(0),
+	}
+	edTx.Sign(ed)
+
+	block := NewBlock(big.NewInt(12), util.HashData([]byte("parent")), []byte("Block 12"))
+	block.Timestamp = 1700000000
+	block.Difficulty = 18
+	block.BaseFee = big.NewInt(100)
+	block.Nonce = big.NewInt(123456)
+	block.Transactions = []*Transaction{secpTx, edTx}
+	block.StateRoot = util.HashData([]byte("state"))
+	block.CoinbaseAddr = util.BytesToAddress([]byte{0x03})
+	block.SetTxRoot()
+	block.Sign(ua)
+
+	return block
+}
+
+func TestCanonicalEncodingRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	block := newEncodingTestBlock(t)
+	data := block.Serialize()
+
+	// Encoding is deterministic, and decoding is its inverse.
+	assert.Equal(t, data, block.Serialize())
+
+	decoded, err := DecodeBlock(data, len(data), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, block, decoded)
+	assert.Equal(t, data, decoded.Serialize())
+	assert.Equal(t, block.DeriveHash(), decoded.DeriveHash())
+	assert.True(t, decoded.Verify())
+
+	for _, tx := range block.Transactions {
+		txData := tx.Serialize()
+
+		decodedTx, err := DecodeTransaction(txData)
+		assert.NoError(t, err)
+		assert.Equal(t, tx, decodedTx)
+		assert.Equal(t, txData, decodedTx.Serialize())
+		assert.NoError(t, decodedTx.VerifySignature())
+	}
+
+	// Nil fields stay nil.
+	empty := &Block{Number: big.NewInt(0), ParentHash: &util.Hash{}, Nonce: big.NewInt(0)}
+	assert.Equal(t, empty, DeserializeBlock(empty.Serialize()))
+}
+
+func TestCanonicalEncodingVector(t *testing.T) {
+	t.Parallel()
+
+	tx := &Transaction{
+		From:     *util.BytesToAddress([]byte{0x01}),
+		To:       *util.BytesToAddress([]byte{0x02}),
+		Value:    big.NewInt(1000),
+		Msg:      []byte("hi"),
+		Fee:      big.NewInt(100),
+		Nonce:    big.NewInt(0),
+		GasLimit: TxGas,
+		ChainID:  1,
+	}
+
+	// prefix, list, from, to, value, msg, data, fee, nonce, gas limit, chain id, r, s, public key,
+	// max fee, max priority fee
+	expected := "81" + "f83c" +
+		"94" + "0100000000000000000000000000000000000000" +
+		"94" + "0200000000000000000000000000000000000000" +
+		"8203e8" + "826869" + "80" + "64" + "80" + "825208" + "01" + "c0" + "c0" + "c0" + "c0" + "c0"
+
+	assert.Equal(t, expected, hex.EncodeToString(tx.Serialize()))
+}
+
+func TestCanonicalHashVector(t *testing.T) {
+	t.Parallel()
+
+	// The preimages and hashes match the ones of an independent RLP encoder and SHA-256.
+	tx := &Transaction{
+		From:     *util.BytesToAddress([]byte{0x01}),
+		To:       *util.BytesToAddress([]byte{0x02}),
+		Value:    big.NewInt(1000),
+		Msg:      []byte("hi"),
+		Fee:      big.NewInt(100),
+		Nonce:    big.NewInt(0),
+		GasLimit: TxGas,
+		ChainID:  1,
+	}
+
+	// list, from, to, value, msg, data, fee, nonce, gas limit, chain id, max fee, max priority fee
+	expected := "f839" +
+		"94" + "0100000000000000000000000000000000000000" +
+		"94" + "0200000000000000000000000000000000000000" +
+		"8203e8" + "826869" + "80" + "64" + "80" + "825208" + "01" + "c0" + "c0"
+
+	assert.Equal(t, expected, hex.EncodeToString(tx.appendRLPUnsigned(nil)))
+	assert.Equal(t, "0x1dacf22f8c1ffaf3a2a53ac6975170d61f6eeecac7f1440c73370fc87cbb634a", tx.Hash().String())
+
+	block := NewBlock(big.NewInt(7), util.HashData([]byte("parent")), []byte("Block 7"))
+	block.Timestamp = 1700000000
+	block.Difficulty = 18
+	block.BaseFee = big.NewInt(100)
+	block.Nonce = big.NewInt(42)
+
+	// list, version, number, parent hash, timestamp, difficulty, base fee, extra data, nonce,
+	// transactions root, state root, coinbase
+	expected = "f856" + "01" + "07" +
+		"a0" + "e47125968b3b71049fbc4802d1e40a71ea1359decfabacf70b34588037d4ff0c" +
+		"846553f100" + "12" + "64" + "87426c6f636b2037" + "2a" +
+		"a0" + "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" + "c0" + "c0"
+
+	assert.Equal(t, expected, hex.EncodeToString(block.appendRLPHeader(nil)))
+	assert.Equal(t, "0x88e3efa01834eff09447a94e088e3ba8424a02108ea4fbc4939cd4026fcb2baf", block.DeriveHash().String())
+}
+
+func TestCanonicalHashNotMalleable(t *testing.T) {
+	t.Parallel()
+
+	// Bytes of the value moved to the message, or of the extra data to the nonce, make
+	// other transactions and blocks, which don't share the hash.
+	tx := &Transaction{Value: big.NewInt(0x0168), Msg: []byte("i"), Fee: big.NewInt(100), Nonce: big.NewInt(0), ChainID: 1}
+	moved := &Transaction{Value: big.NewInt(0x01), Msg: []byte("hi"), Fee: big.NewInt(100), Nonce: big.NewInt(0), ChainID: 1}
+	assert.NotEqual(t, tx.Hash(), moved.Hash())
+
+	// Unlike legacy transactions.
+	tx.ChainID, moved.ChainID = 0, 0
+	assert.Equal(t, tx.Hash(), moved.Hash())
+
+	block := NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{0x01})
+	block.Nonce = big.NewInt(0x02)
+
+	movedBlock := NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{})
+	movedBlock.Nonce = big.NewInt(0x0102)
+	assert.NotEqual(t, block.DeriveHash(), movedBlock.DeriveHash())
+
+	// Unlike blocks of version zero.
+	block.Version, movedBlock.Version = 0, 0
+	assert.Equal(t, block.DeriveHash(), movedBlock.DeriveHash())
+}
+
+func TestBlockVersionEncoding(t *testing.T) {
+	t.Parallel()
+
+	block := newEncodingTestBlock(t)
+	legacy := *block
+	legacy.Version = 0
+
+	// Blocks of version zero keep the encoding they had before versions, with one field less.
+	assert.Len(t, rlpItemEncodings(t, legacy.Serialize()), 14)
+
+	decoded, err := DecodeBlock(legacy.Serialize(), len(legacy.Serialize()), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), decoded.Version)
+	assert.Equal(t, legacy.DeriveHash(), decoded.DeriveHash())
+	assert.NotEqual(t, block.DeriveHash(), legacy.DeriveHash())
+
+	items := rlpItemEncodings(t, block.Serialize())
+	assert.Len(t, items, 15)
+
+	// A version of zero has a single encoding, without the field.
+	payload := []byte{}
+	for _, item := range items[:14] {
+		payload = append(payload, item...)
+	}
+
+	payload = appendRLPUint(payload, 0)
+
+	_, err = DecodeBlock(appendRLPList([]byte{canonicalEncodingPrefix}, payload), 1<<20, 2)
+	assert.ErrorIs(t, err, ErrInvalidBlockEncoding)
+}
+
+// rlpItemEncodings returns the encodings of the items of the serialized list.
+func rlpItemEncodings(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+
+	item, err := decodeRLP(data[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodings := [][]byte{}
+
+	for rest := item.content; len(rest) > 0; {
+		_, after, err := splitRLP(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encodings = append(encodings, rest[:len(rest)-len(after)])
+		rest = after
+	}
+
+	return encodings
+}
+
+func TestCanonicalEncodingRejected(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	data := tx.Serialize()
+
+	for name, data := range map[string][]byte{
+		"truncated":          data[:len(data)-1],
+		"trailing bytes":     append(append([]byte{}, data...), 0x00),
+		"non minimal byte":   {canonicalEncodingPrefix, 0xc2, 0x81, 0x01},
+		"non minimal length": {canonicalEncodingPrefix, 0xf8, 0x01, 0x00},
+		"missing fields":     {canonicalEncodingPrefix, 0xc0},
+	} {
+		_, err := DecodeTransaction(data)
+		assert.ErrorIs(t, err, ErrInvalidEncoding, name)
+	}
+
+	// Integers with leading zeros.
+	_, err := rlpItem{content: []byte{0x00, 0x64}}.big()
+	assert.ErrorIs(t, err, errInvalidRLP)
+
+	_, err = rlpItem{content: []byte{0x00, 0x64}}.uint()
+	assert.ErrorIs(t, err, errInvalidRLP)
+}
+
+func TestDecodeGobEncoding(t *testing.T) {
+	t.Parallel()
+
+	block := newEncodingTestBlock(t)
+
+	// Blocks and transactions stored before the canonical encoding.
+	var gobBlock, gobTx bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&gobBlock).Encode(block))
+	assert.NoError(t, gob.NewEncoder(&gobTx).Encode(block.Transactions[0]))
+
+	decoded := DeserializeBlock(gobBlock.Bytes())
+	assert.Equal(t, block.DeriveHash(), decoded.DeriveHash())
+	assert.Equal(t, block.Serialize(), decoded.Serialize())
+
+	decodedTx := DeserializeTransaction(gobTx.Bytes())
+	assert.Equal(t, block.Transactions[0].Serialize(), decodedTx.Serialize())
+
+	// Gob streams from untrusted sources are refused, as they carry the curve of their keys.
+	_, err := DecodeBlock(gobBlock.Bytes(), gobBlock.Len(), 2)
+	assert.ErrorIs(t, err, ErrInvalidBlockEncoding)
+
+	_, err = DecodeTransaction(gobTx.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	forged := *block.Transactions[0]
+	params := *forged.PublicKey.CurveParams
+	params.Gx = big.NewInt(1)
+	forged.PublicKey = &util.CompactPublicKey{CurveParams: &params, X: forged.PublicKey.X, Y: forged.PublicKey.Y}
+	gobTx.Reset()
+	assert.NoError(t, gob.NewEncoder(&gobTx).Encode(&forged))
+
+	_, err = DecodeTransaction(gobTx.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+}
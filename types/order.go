@@ -0,0 +1,67 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/0xsharma/compact-chain/util"
+)
+
+// CanonicalOrder returns the transactions in the order they are included in a block,
+// whatever the order they are given in. The transactions of a sender keep ascending
+// nonces, and at each step the lowest nonce transaction of the sender paying the highest
+// fee per gas goes next. Equal fees per gas go to the lowest sender address, equal nonces
+// of a sender to the lowest hash.
+func CanonicalOrder(txs []*Transaction) []*Transaction {
+	bySender := make(map[util.Address][]*Transaction)
+	senders := []util.Address{}
+
+	for _, tx := range txs {
+		if _, ok := bySender[tx.From]; !ok {
+			senders = append(senders, tx.From)
+		}
+
+		bySender[tx.From] = append(bySender[tx.From], tx)
+	}
+
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i].Bytes(), senders[j].Bytes()) < 0
+	})
+
+	for _, from := range senders {
+		senderTxs := bySender[from]
+		sort.Slice(senderTxs, func(i, j int) bool {
+			if cmp := senderTxs[i].Nonce.Cmp(senderTxs[j].Nonce); cmp != 0 {
+				return cmp < 0
+			}
+
+			return bytes.Compare(senderTxs[i].Hash().Bytes(), senderTxs[j].Hash().Bytes()) < 0
+		})
+	}
+
+	ordered := make([]*Transaction, 0, len(txs))
+
+	for len(ordered) < len(txs) {
+		var best util.Address
+
+		found := false
+
+		// Senders are sorted, so the first of equal fees per gas is kept.
+		for _, from := range senders {
+			senderTxs := bySender[from]
+			if len(senderTxs) == 0 {
+				continue
+			}
+
+			if !found || senderTxs[0].CmpFeePerGas(bySender[best][0]) > 0 {
+				best = from
+				found = true
+			}
+		}
+
+		ordered = append(ordered, bySender[best][0])
+		bySender[best] = bySender[best][1:]
+	}
+
+	return ordered
+}
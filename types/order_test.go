@@ -0,0 +1,35 @@
+package types
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalOrder(t *testing.T) {
+	t.Parallel()
+
+	newTx := func(from byte, nonce int64, fee int64) *Transaction {
+		return &Transaction{From: *util.BytesToAddress([]byte{from}), To: util.Address{}, Value: big.NewInt(1), Msg: []byte{}, Fee: big.NewInt(fee), Nonce: big.NewInt(nonce)}
+	}
+
+	a0, a1 := newTx(0x0a, 0, 100), newTx(0x0a, 1, 500)
+	b0, b1 := newTx(0x0b, 0, 300), newTx(0x0b, 1, 100)
+	c0 := newTx(0x0c, 0, 300)
+
+	// Equal fees go to the lowest sender, and the nonce order of a sender wins over fees :
+	// a1 pays the most but waits for a0, which ties with b1.
+	expected := []*Transaction{b0, c0, a0, a1, b1}
+
+	txs := []*Transaction{a0, a1, b0, b1, c0}
+
+	for i := 0; i < 10; i++ {
+		// nolint : gosec
+		rand.Shuffle(len(txs), func(i, j int) { txs[i], txs[j] = txs[j], txs[i] })
+
+		assert.Equal(t, expected, CanonicalOrder(txs))
+	}
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/cbergoon/merkletree"
+)
+
+// ErrTxNotInBlock is returned when proving a transaction which isn't part of the block.
+var ErrTxNotInBlock = errors.New("transaction not in block")
+
+// TxProof is the Merkle branch from a transaction to the transactions root of a block.
+// Right tells for each level whether the sibling hash is the right one.
+type TxProof struct {
+	Branch []*util.Hash
+	Right  []bool
+}
+
+// TxProof returns the Merkle branch proving that the transaction with the given hash is
+// part of the block.
+func (b *Block) TxProof(hash *util.Hash) (*TxProof, error) {
+	var (
+		target *Transaction
+		list   []merkletree.Content
+	)
+
+	for _, tx := range b.Transactions {
+		if *tx.Hash() == *hash {
+			target = tx
+		}
+
+		list = append(list, tx)
+	}
+
+	if target == nil {
+		return nil, ErrTxNotInBlock
+	}
+
+	t, err := merkletree.NewTree(list)
+	if err != nil {
+		return nil, err
+	}
+
+	path, index, err := t.GetMerklePath(target)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &TxProof{}
+
+	for i, sibling := range path {
+		proof.Branch = append(proof.Branch, util.ByteToHash(sibling))
+		proof.Right = append(proof.Right, index[i] == 1)
+	}
+
+	return proof, nil
+}
+
+// VerifyTxProof reports whether the proof links the transaction hash to the root.
+func VerifyTxProof(root *util.Hash, hash *util.Hash, proof *TxProof) bool {
+	if len(proof.Branch) == 0 || len(proof.Branch) != len(proof.Right) {
+		return false
+	}
+
+	node := hash
+
+	for i, sibling := range proof.Branch {
+		if proof.Right[i] {
+			node = util.HashData(bytes.Join([][]byte{node.Bytes(), sibling.Bytes()}, nil))
+		} else {
+			node = util.HashData(bytes.Join([][]byte{sibling.Bytes(), node.Bytes()}, nil))
+		}
+	}
+
+	return *node == *root
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProofTestBlock(t *testing.T, txCount int) *Block {
+	t.Helper()
+
+	block := NewBlock(big.NewInt(1), util.HashData([]byte("parent")), []byte{})
+
+	for i := 0; i < txCount; i++ {
+		tx, ua := newSignedTx(t)
+		tx.Nonce = big.NewInt(int64(i))
+		tx.Sign(ua)
+
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	block.SetTxRoot()
+
+	return block
+}
+
+func TestTxProof(t *testing.T) {
+	t.Parallel()
+
+	for txCount := 1; txCount <= 5; txCount++ {
+		block := newProofTestBlock(t, txCount)
+
+		for _, tx := range block.Transactions {
+			proof, err := block.TxProof(tx.Hash())
+			assert.NoError(t, err)
+			assert.True(t, VerifyTxProof(block.TxRoot, tx.Hash(), proof), "txs %d", txCount)
+
+			// Another transaction hash doesn't verify against the proof.
+			assert.False(t, VerifyTxProof(block.TxRoot, util.HashData([]byte("other")), proof))
+		}
+	}
+
+	_, err := newProofTestBlock(t, 2).TxProof(util.HashData([]byte("unknown")))
+	assert.ErrorIs(t, err, ErrTxNotInBlock)
+}
+
+func TestTxProofTamperedBranch(t *testing.T) {
+	t.Parallel()
+
+	block := newProofTestBlock(t, 4)
+	tx := block.Transactions[2]
+
+	proof, err := block.TxProof(tx.Hash())
+	assert.NoError(t, err)
+	assert.Len(t, proof.Branch, 2)
+
+	tampered := &TxProof{Branch: append([]*util.Hash{util.HashData([]byte("tampered"))}, proof.Branch[1:]...), Right: proof.Right}
+	assert.False(t, VerifyTxProof(block.TxRoot, tx.Hash(), tampered))
+
+	// Swapping the side of a sibling breaks the proof as well.
+	swapped := &TxProof{Branch: proof.Branch, Right: []bool{!proof.Right[0], proof.Right[1]}}
+	assert.False(t, VerifyTxProof(block.TxRoot, tx.Hash(), swapped))
+
+	assert.False(t, VerifyTxProof(block.TxRoot, tx.Hash(), &TxProof{}))
+}
+
+func TestBlockVerifyTxRoot(t *testing.T) {
+	t.Parallel()
+
+	block := newProofTestBlock(t, 3)
+	hash := block.DeriveHash()
+	assert.True(t, block.VerifyTxRoot())
+
+	// The body can't change without the header noticing.
+	block.Transactions = block.Transactions[:2]
+	assert.False(t, block.VerifyTxRoot())
+	assert.Equal(t, hash, block.DeriveHash())
+
+	// Blocks without a stored root commit to their transactions.
+	block.TxRoot = nil
+	assert.True(t, block.VerifyTxRoot())
+	assert.NotEqual(t, hash, block.DeriveHash())
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/0xsharma/compact-chain/util"
+)
+
+const (
+	ReceiptStatusFailed     uint64 = 0
+	ReceiptStatusSuccessful uint64 = 1
+)
+
+// Receipt is the outcome of a transaction included in a block.
+type Receipt struct {
+	TxHash      *util.Hash
+	BlockHash   *util.Hash
+	BlockNumber *big.Int
+	Index       uint64 // Index of the transaction in the block
+	Status      uint64
+	GasUsed     uint64
+	Fee         *big.Int // Fee paid by the sender, the coinbase getting only the tip of dynamic fee transactions
+}
+
+// Receipts returns the receipts of the transactions of the block. Blocks only include the
+// transactions which executed, so every receipt is successful.
+func (b *Block) Receipts() []*Receipt {
+	hash := b.DeriveHash()
+	receipts := make([]*Receipt, len(b.Transactions))
+
+	for i, tx := range b.Transactions {
+		receipts[i] = &Receipt{
+			TxHash:      tx.Hash(),
+			BlockHash:   hash,
+			BlockNumber: b.Number,
+			Index:       uint64(i),
+			Status:      ReceiptStatusSuccessful,
+			GasUsed:     tx.Gas(),
+			Fee:         tx.EffectiveFee(b.BaseFee),
+		}
+	}
+
+	return receipts
+}
@@ -2,14 +2,28 @@ package types
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"encoding/gob"
+	"encoding/binary"
+	"errors"
 	"math/big"
 
 	"github.com/0xsharma/compact-chain/util"
 	"github.com/cbergoon/merkletree"
 )
 
+// TxGas is the intrinsic gas of a transaction, consumed by transactions without a gas limit.
+const TxGas uint64 = 21000
+
+var (
+	// ErrInvalidSignature is returned when a transaction isn't signed by its sender.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrInvalidEncoding is returned when decoding malformed transaction bytes.
+	ErrInvalidEncoding = errors.New("invalid transaction encoding")
+
+	// ErrInvalidChainID is returned when a transaction is signed for another network.
+	ErrInvalidChainID = errors.New("invalid transaction chain id")
+)
+
 type Transactions []*Transaction
 
 func (txs Transactions) Array() []*Transaction {
@@ -21,17 +35,158 @@ type Transaction struct {
 	To        util.Address
 	Value     *big.Int
 	Msg       []byte
-	Fee       *big.Int
+	Data      []byte   // Payload of the transaction, such as a document hash to notarize
+	Fee       *big.Int // Fee of fixed fee transactions, nil for dynamic fee ones
 	Nonce     *big.Int
+	GasLimit  uint64
+	ChainID   uint64 // Network the transaction is signed for, zero for legacy transactions
 	R         *big.Int
 	S         *big.Int
 	PublicKey *util.CompactPublicKey
+
+	// Dynamic fee transactions pay the base fee of their block plus a priority fee, up to
+	// a maximum fee. Nil for fixed fee transactions.
+	MaxFee         *big.Int
+	MaxPriorityFee *big.Int
 }
 
+// Hash returns the hash of the transaction, which is what gets signed. Transactions
+// signed for a chain id hash the RLP encoding of their fields but the signature. Legacy
+// transactions hash their fields joined instead, which bytes can move between, so that
+// they keep the hash they had before chain ids. It commits to the gas limit, if set, to
+// the data prefixed with its length, if any, and to the fees of dynamic fee transactions
+// prefixed with their lengths.
 func (tx *Transaction) Hash() *util.Hash {
-	txHash := bytes.Join([][]byte{tx.From.Bytes(), tx.To.Bytes(), tx.Value.Bytes(), tx.Msg, tx.Fee.Bytes(), tx.Nonce.Bytes()}, []byte{})
+	if tx.ChainID != 0 {
+		return util.HashData(tx.appendRLPUnsigned(nil))
+	}
+
+	fields := [][]byte{tx.From.Bytes(), tx.To.Bytes(), tx.Value.Bytes(), tx.Msg, bigBytes(tx.Fee), tx.Nonce.Bytes()}
+
+	if tx.GasLimit != 0 {
+		fields = append(fields, binary.BigEndian.AppendUint64(nil, tx.GasLimit))
+	}
+
+	if len(tx.Data) > 0 {
+		fields = append(fields, binary.BigEndian.AppendUint32(nil, uint32(len(tx.Data))), tx.Data)
+	}
 
-	return util.HashData(txHash)
+	if tx.IsDynamicFee() {
+		for _, fee := range []*big.Int{tx.MaxFee, tx.MaxPriorityFee} {
+			fields = append(fields, binary.BigEndian.AppendUint32(nil, uint32(len(fee.Bytes()))), fee.Bytes())
+		}
+	}
+
+	return util.HashData(bytes.Join(fields, []byte{}))
+}
+
+// VerifyChainID checks that the transaction is signed for the given chain. Legacy
+// transactions, signed without a chain id, are only accepted if allowLegacy is set.
+func (tx *Transaction) VerifyChainID(chainID uint64, allowLegacy bool) error {
+	if tx.ChainID == chainID || (tx.ChainID == 0 && allowLegacy) {
+		return nil
+	}
+
+	return ErrInvalidChainID
+}
+
+// Gas returns the gas the transaction consumes in a block, TxGas if no gas limit is set.
+func (tx *Transaction) Gas() uint64 {
+	if tx.GasLimit == 0 {
+		return TxGas
+	}
+
+	return tx.GasLimit
+}
+
+// IsDynamicFee reports whether the transaction pays a dynamic fee rather than a fixed one.
+func (tx *Transaction) IsDynamicFee() bool {
+	return tx.MaxFee != nil
+}
+
+// FeeCap returns the highest fee the transaction may pay : its fee, or its maximum fee
+// for dynamic fee transactions.
+func (tx *Transaction) FeeCap() *big.Int {
+	if tx.IsDynamicFee() {
+		return tx.MaxFee
+	}
+
+	return tx.Fee
+}
+
+// BaseFeeCost returns the base fee the transaction owes in a block of the given base fee.
+// The base fee is charged per unit of gas, priced so that a transaction using TxGas owes
+// exactly the base fee and one using more gas proportionally more, rounded up. A nil base
+// fee is zero.
+func (tx *Transaction) BaseFeeCost(baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return big.NewInt(0)
+	}
+
+	// ceil(baseFee * gas / TxGas)
+	cost := new(big.Int).Mul(baseFee, new(big.Int).SetUint64(tx.Gas()))
+	cost.Add(cost, new(big.Int).SetUint64(TxGas-1))
+
+	return cost.Div(cost, new(big.Int).SetUint64(TxGas))
+}
+
+// CoversBaseFee reports whether the fee cap of the transaction pays the base fee it owes
+// in a block of the given base fee, for all of its gas.
+func (tx *Transaction) CoversBaseFee(baseFee *big.Int) bool {
+	return tx.FeeCap().Cmp(tx.BaseFeeCost(baseFee)) >= 0
+}
+
+// EffectiveFee returns the fee the transaction pays in a block of the given base fee : its
+// fee, or for dynamic fee transactions the base fee it owes plus the priority fee, up to
+// the maximum fee. A nil base fee is zero.
+func (tx *Transaction) EffectiveFee(baseFee *big.Int) *big.Int {
+	if !tx.IsDynamicFee() {
+		return tx.Fee
+	}
+
+	fee := new(big.Int).Add(tx.MaxPriorityFee, tx.BaseFeeCost(baseFee))
+
+	if fee.Cmp(tx.MaxFee) > 0 {
+		return new(big.Int).Set(tx.MaxFee)
+	}
+
+	return fee
+}
+
+// Tip returns the part of the effective fee credited to the coinbase : all of it, or for
+// dynamic fee transactions what is above the base fee it owes.
+func (tx *Transaction) Tip(baseFee *big.Int) *big.Int {
+	fee := tx.EffectiveFee(baseFee)
+	if !tx.IsDynamicFee() || baseFee == nil {
+		return fee
+	}
+
+	tip := new(big.Int).Sub(fee, tx.BaseFeeCost(baseFee))
+	if tip.Sign() < 0 {
+		return big.NewInt(0)
+	}
+
+	return tip
+}
+
+// validFees reports whether the transaction sets either a fee or both a maximum fee and a
+// maximum priority fee, none of them negative.
+func (tx *Transaction) validFees() bool {
+	if tx.IsDynamicFee() {
+		return tx.Fee == nil && tx.MaxPriorityFee != nil && !negative(tx.MaxFee, tx.MaxPriorityFee)
+	}
+
+	return tx.Fee != nil && tx.MaxPriorityFee == nil && !negative(tx.Fee)
+}
+
+// CmpFeePerGas compares the fee cap per unit of gas of the transaction with the one of
+// other, returning -1, 0 or +1 as for big.Int.Cmp.
+func (tx *Transaction) CmpFeePerGas(other *Transaction) int {
+	// fee / gas <=> otherFee / otherGas, compared without rounding
+	x := new(big.Int).Mul(tx.FeeCap(), new(big.Int).SetUint64(other.Gas()))
+	y := new(big.Int).Mul(other.FeeCap(), new(big.Int).SetUint64(tx.Gas()))
+
+	return x.Cmp(y)
 }
 
 func (tx *Transaction) CalculateHash() ([]byte, error) {
@@ -44,41 +199,50 @@ func (tx *Transaction) Equals(other merkletree.Content) (bool, error) {
 	OtherTo := other.(*Transaction).To
 	OtherValue := other.(*Transaction).Value.Bytes()
 	OtherMsg := other.(*Transaction).Msg
-	OtherFee := other.(*Transaction).Fee.Bytes()
+	OtherData := other.(*Transaction).Data
+	OtherFee := bigBytes(other.(*Transaction).Fee)
+	OtherMaxFee := bigBytes(other.(*Transaction).MaxFee)
+	OtherMaxPriorityFee := bigBytes(other.(*Transaction).MaxPriorityFee)
 	OtherNonce := other.(*Transaction).Nonce.Bytes()
+	OtherGasLimit := other.(*Transaction).GasLimit
+	OtherChainID := other.(*Transaction).ChainID
 	OtherR := other.(*Transaction).R.Bytes()
 	OtherS := other.(*Transaction).S.Bytes()
 	OtherPublicKey := other.(*Transaction).PublicKey
 
-	out := tx.From == OtherFrom && tx.To == OtherTo && bytes.Equal(tx.Value.Bytes(), OtherValue) && bytes.Equal(tx.Msg, OtherMsg) && bytes.Equal(tx.Fee.Bytes(), OtherFee) && bytes.Equal(tx.Nonce.Bytes(), OtherNonce) && bytes.Equal(tx.R.Bytes(), OtherR) && bytes.Equal(tx.S.Bytes(), OtherS) && tx.PublicKey == OtherPublicKey
+	out := tx.From == OtherFrom && tx.To == OtherTo && bytes.Equal(tx.Value.Bytes(), OtherValue) && bytes.Equal(tx.Msg, OtherMsg) && bytes.Equal(tx.Data, OtherData) && bytes.Equal(bigBytes(tx.Fee), OtherFee) && tx.IsDynamicFee() == other.(*Transaction).IsDynamicFee() && bytes.Equal(bigBytes(tx.MaxFee), OtherMaxFee) && bytes.Equal(bigBytes(tx.MaxPriorityFee), OtherMaxPriorityFee) && bytes.Equal(tx.Nonce.Bytes(), OtherNonce) && tx.GasLimit == OtherGasLimit && tx.ChainID == OtherChainID && bytes.Equal(tx.R.Bytes(), OtherR) && bytes.Equal(tx.S.Bytes(), OtherS) && tx.PublicKey == OtherPublicKey
 
 	return out, nil
 }
 
+// Serialize serializes the transaction with the canonical encoding.
 func (tx *Transaction) Serialize() []byte {
-	var res bytes.Buffer
-	encoder := gob.NewEncoder(&res)
-
-	err := encoder.Encode(tx)
+	return tx.appendRLP([]byte{canonicalEncodingPrefix})
+}
 
+func DeserializeTransaction(data []byte) *Transaction {
+	tx, err := decodeStored(data, &Transaction{}, decodeRLPTransaction)
 	if err != nil {
 		panic(err)
 	}
 
-	return res.Bytes()
+	return tx
 }
 
-func DeserializeTransaction(data []byte) *Transaction {
-	var tx Transaction
-
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-
-	err := decoder.Decode(&tx)
+// DecodeTransaction decodes a transaction serialized with Serialize, received from an
+// untrusted source. Unlike DeserializeTransaction, it reports malformed data with
+// ErrInvalidEncoding and refuses gob streams.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	tx, err := decodeCanonical(data, decodeRLPTransaction)
 	if err != nil {
-		panic(err)
+		return nil, ErrInvalidEncoding
 	}
 
-	return &tx
+	if tx.Value == nil || tx.Nonce == nil || negative(tx.Value, tx.Nonce) || !tx.validFees() {
+		return nil, ErrInvalidEncoding
+	}
+
+	return tx, nil
 }
 
 func (tx *Transaction) Sign(ua *util.UnlockedAccount) {
@@ -89,10 +253,82 @@ func (tx *Transaction) Sign(ua *util.UnlockedAccount) {
 
 	tx.R = r
 	tx.S = s
-	tx.PublicKey = util.PublicKeyToCompact(ua.PublicKey())
+	tx.PublicKey = ua.PublicKey()
 }
 
 func (tx *Transaction) Verify() bool {
-	pubKey := tx.PublicKey.PublicKey()
-	return ecdsa.Verify(pubKey, tx.Hash().Bytes(), tx.R, tx.S)
+	return tx.PublicKey.Verify(tx.Hash().Bytes(), tx.R, tx.S)
+}
+
+// SignedHash returns the hash of the transaction along with its signature and public
+// key, which Hash leaves out. Two transactions with the same signed hash verify alike.
+func (tx *Transaction) SignedHash() *util.Hash {
+	return signedHash(tx.Hash(), tx.R, tx.S, tx.PublicKey)
+}
+
+// signedHash hashes the hash of a signed object with the signature values and the public
+// key, each prefixed with its length. Missing values hash as empty.
+func signedHash(hash *util.Hash, r *big.Int, s *big.Int, pk *util.CompactPublicKey) *util.Hash {
+	fields := [][]byte{hash.Bytes()}
+
+	for _, v := range []*big.Int{r, s} {
+		if v == nil {
+			fields = append(fields, nil)
+		} else {
+			fields = append(fields, v.Bytes())
+		}
+	}
+
+	if pk != nil {
+		curve := ""
+		if pk.CurveParams != nil {
+			curve = pk.CurveParams.Name
+		}
+
+		fields = append(fields, []byte(curve))
+
+		for _, v := range []*big.Int{pk.X, pk.Y} {
+			if v == nil {
+				fields = append(fields, nil)
+			} else {
+				fields = append(fields, v.Bytes())
+			}
+		}
+
+		fields = append(fields, pk.Ed25519)
+	}
+
+	var buf []byte
+
+	for _, field := range fields {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+		buf = append(buf, field...)
+	}
+
+	return util.HashData(buf)
+}
+
+// VerifySignature verifies the transaction signature and that the signing key owns
+// the sender address. Missing or malformed signature values are reported as
+// ErrInvalidSignature instead of panicking.
+func (tx *Transaction) VerifySignature() error {
+	if tx.R == nil || tx.S == nil || tx.PublicKey == nil {
+		return ErrInvalidSignature
+	}
+
+	if !tx.Verify() {
+		return ErrInvalidSignature
+	}
+
+	if *tx.PublicKey.Address() != tx.From {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// TxValidator applies a custom admission policy, such as a sender blocklist, to the
+// transactions entering the txpool. An error rejects the transaction with its message.
+type TxValidator interface {
+	ValidateTx(tx *Transaction) error
 }
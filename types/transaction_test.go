@@ -0,0 +1,334 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/0xsharma/compact-chain/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedTx(t *testing.T) (*Transaction, *util.UnlockedAccount) {
+	t.Helper()
+
+	// Address = 0xa52c981eee8687b5e4afd69aa5006548c24d7685
+	ua := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"))
+
+	tx := &Transaction{
+		From:  *ua.Address(),
+		To:    *util.BytesToAddress([]byte{0x01}),
+		Value: big.NewInt(1000),
+		Msg:   []byte("hello"),
+		Fee:   big.NewInt(100),
+		Nonce: big.NewInt(0),
+	}
+	tx.Sign(ua)
+
+	return tx, ua
+}
+
+func TestTransactionVerifySignature(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	assert.NoError(t, tx.VerifySignature())
+}
+
+func TestTransactionTamperedValue(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	tx.Value = big.NewInt(1000000)
+
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionForeignSender(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+
+	// Validly signed, but by a key which doesn't own the sender address.
+	other := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+	tx.Sign(other)
+
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionMalformedSignature(t *testing.T) {
+	t.Parallel()
+
+	unsigned, _ := newSignedTx(t)
+	unsigned.R, unsigned.S, unsigned.PublicKey = nil, nil, nil
+	assert.ErrorIs(t, unsigned.VerifySignature(), ErrInvalidSignature)
+
+	short, _ := newSignedTx(t)
+	short.R = new(big.Int).SetBytes([]byte{0x01})
+	short.S = new(big.Int)
+	assert.ErrorIs(t, short.VerifySignature(), ErrInvalidSignature)
+
+	noCurve, _ := newSignedTx(t)
+	noCurve.PublicKey = &util.CompactPublicKey{X: noCurve.PublicKey.X}
+	assert.ErrorIs(t, noCurve.VerifySignature(), ErrInvalidSignature)
+
+	offCurve, _ := newSignedTx(t)
+	offCurve.PublicKey = &util.CompactPublicKey{CurveParams: offCurve.PublicKey.CurveParams, X: big.NewInt(1), Y: big.NewInt(1)}
+	assert.ErrorIs(t, offCurve.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionSwappedGenerator(t *testing.T) {
+	t.Parallel()
+
+	victim, _ := newSignedTx(t)
+
+	// The parameters of the curve with the public key of the victim as generator, which
+	// makes 1 the private key of that public key.
+	params := *elliptic.P256().Params()
+	params.Gx, params.Gy = victim.PublicKey.X, victim.PublicKey.Y
+
+	forged := &Transaction{
+		From:  victim.From,
+		To:    *util.BytesToAddress([]byte{0x02}),
+		Value: big.NewInt(1000000),
+		Msg:   []byte{},
+		Fee:   big.NewInt(100),
+		Nonce: big.NewInt(1),
+	}
+
+	key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: &params, X: params.Gx, Y: params.Gy}, D: big.NewInt(1)}
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, forged.Hash().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged.R, forged.S = r, s
+	forged.PublicKey = &util.CompactPublicKey{CurveParams: &params, X: params.Gx, Y: params.Gy}
+
+	// The signature holds on the forged curve, but isn't one of the sender.
+	assert.True(t, ecdsa.Verify(&key.PublicKey, forged.Hash().Bytes(), r, s))
+	assert.ErrorIs(t, forged.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionFeePerGas(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	assert.Equal(t, TxGas, tx.Gas())
+
+	// Same fee for twice the gas.
+	other := &Transaction{Fee: big.NewInt(100), GasLimit: 2 * TxGas}
+	assert.Equal(t, 2*TxGas, other.Gas())
+	assert.Equal(t, 1, tx.CmpFeePerGas(other))
+	assert.Equal(t, -1, other.CmpFeePerGas(tx))
+
+	other.Fee = big.NewInt(200)
+	assert.Equal(t, 0, tx.CmpFeePerGas(other))
+}
+
+func TestTransactionTamperedGasLimit(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	tx.GasLimit = 2 * TxGas
+
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionHashWithoutGasLimit(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+
+	// Transactions without a gas limit keep the hash they had before gas limits.
+	preimage := bytes.Join([][]byte{tx.From.Bytes(), tx.To.Bytes(), tx.Value.Bytes(), tx.Msg, tx.Fee.Bytes(), tx.Nonce.Bytes()}, []byte{})
+	assert.Equal(t, util.HashData(preimage), tx.Hash())
+
+	// An explicit gas limit is committed to, even if it is the intrinsic gas.
+	withGasLimit := *tx
+	withGasLimit.GasLimit = TxGas
+	assert.NotEqual(t, tx.Hash(), withGasLimit.Hash())
+}
+
+func TestTransactionBaseFeeCost(t *testing.T) {
+	t.Parallel()
+
+	tx := &Transaction{Fee: big.NewInt(100)}
+
+	// The base fee is owed per unit of gas, a TxGas transaction owing exactly the base fee.
+	assert.Equal(t, big.NewInt(0), tx.BaseFeeCost(nil))
+	assert.Equal(t, big.NewInt(100), tx.BaseFeeCost(big.NewInt(100)))
+	assert.True(t, tx.CoversBaseFee(big.NewInt(100)))
+	assert.False(t, tx.CoversBaseFee(big.NewInt(101)))
+
+	tx.GasLimit = 2 * TxGas
+	assert.Equal(t, big.NewInt(200), tx.BaseFeeCost(big.NewInt(100)))
+	assert.False(t, tx.CoversBaseFee(big.NewInt(100)))
+	assert.True(t, tx.CoversBaseFee(big.NewInt(50)))
+
+	// Rounded up.
+	tx.GasLimit = TxGas + 1
+	assert.Equal(t, big.NewInt(2), tx.BaseFeeCost(big.NewInt(1)))
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+
+	decoded, err := DecodeTransaction(tx.Serialize())
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash(), decoded.Hash())
+	assert.NoError(t, decoded.VerifySignature())
+
+	_, err = DecodeTransaction([]byte("not a transaction"))
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	_, err = DecodeTransaction((&Transaction{}).Serialize())
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+}
+
+func TestTransactionChainID(t *testing.T) {
+	t.Parallel()
+
+	tx, ua := newSignedTx(t)
+	legacyHash := tx.Hash()
+
+	// Legacy transactions keep the hash they had before chain ids and gas limits.
+	assert.Equal(t, "0x801835cbe1825f5b072823e61c5ecbc64845e887924e2669ebb85fcdb554e9f4", legacyHash.String())
+
+	tx.ChainID = 1
+	tx.Sign(ua)
+
+	// The chain id is part of the signed hash.
+	assert.NotEqual(t, legacyHash, tx.Hash())
+	assert.NoError(t, tx.VerifySignature())
+
+	tx.ChainID = 2
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+
+	assert.NoError(t, tx.VerifyChainID(2, false))
+	assert.ErrorIs(t, tx.VerifyChainID(1, true), ErrInvalidChainID)
+
+	legacy, _ := newSignedTx(t)
+	assert.Equal(t, legacyHash, legacy.Hash())
+	assert.NoError(t, legacy.VerifyChainID(1, true))
+	assert.ErrorIs(t, legacy.VerifyChainID(1, false), ErrInvalidChainID)
+}
+
+func TestTransactionEd25519Signature(t *testing.T) {
+	t.Parallel()
+
+	edKey, err := util.HexToEd25519PrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ua := util.NewUnlockedAccount(edKey)
+
+	tx := &Transaction{
+		From:    *ua.Address(),
+		To:      *util.BytesToAddress([]byte{0x01}),
+		Value:   big.NewInt(1000),
+		Msg:     []byte("hello"),
+		Fee:     big.NewInt(100),
+		Nonce:   big.NewInt(0),
+		ChainID: 1,
+	}
+	tx.Sign(ua)
+
+	assert.NoError(t, tx.VerifySignature())
+
+	decoded, err := DecodeTransaction(tx.Serialize())
+	assert.NoError(t, err)
+	assert.NoError(t, decoded.VerifySignature())
+
+	tx.Value = big.NewInt(1001)
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+
+	// The key of a secp256k1 account can't sign for it.
+	secp, _ := newSignedTx(t)
+	secp.From = *ua.Address()
+	assert.ErrorIs(t, secp.VerifySignature(), ErrInvalidSignature)
+}
+
+func TestTransactionSignedHash(t *testing.T) {
+	t.Parallel()
+
+	tx, _ := newSignedTx(t)
+	hash := tx.Hash()
+	signedHash := tx.SignedHash()
+
+	assert.Equal(t, signedHash.String(), tx.SignedHash().String())
+
+	// Re-signing by another key keeps the hash but not the signed hash.
+	other := util.NewUnlockedAccount(util.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a7"))
+	tx.Sign(other)
+	assert.Equal(t, hash.String(), tx.Hash().String())
+	assert.NotEqual(t, signedHash.String(), tx.SignedHash().String())
+
+	tx.R, tx.S, tx.PublicKey = nil, nil, nil
+	assert.NotEqual(t, signedHash.String(), tx.SignedHash().String())
+}
+
+func TestTransactionDynamicFee(t *testing.T) {
+	t.Parallel()
+
+	fixed, ua := newSignedTx(t)
+	assert.False(t, fixed.IsDynamicFee())
+	assert.Equal(t, big.NewInt(100), fixed.FeeCap())
+	assert.Equal(t, big.NewInt(100), fixed.EffectiveFee(big.NewInt(60)))
+	assert.Equal(t, big.NewInt(100), fixed.Tip(big.NewInt(60)))
+
+	tx, _ := newSignedTx(t)
+	tx.Fee = nil
+	tx.MaxFee = big.NewInt(150)
+	tx.MaxPriorityFee = big.NewInt(30)
+	tx.Sign(ua)
+
+	// The fees are signed.
+	assert.True(t, tx.IsDynamicFee())
+	assert.NotEqual(t, fixed.Hash(), tx.Hash())
+	assert.NoError(t, tx.VerifySignature())
+
+	tx.MaxPriorityFee = big.NewInt(31)
+	assert.ErrorIs(t, tx.VerifySignature(), ErrInvalidSignature)
+	tx.MaxPriorityFee = big.NewInt(30)
+
+	// The base fee plus the priority fee, up to the maximum fee.
+	assert.Equal(t, big.NewInt(150), tx.FeeCap())
+	assert.Equal(t, big.NewInt(90), tx.EffectiveFee(big.NewInt(60)))
+	assert.Equal(t, big.NewInt(30), tx.Tip(big.NewInt(60)))
+	assert.Equal(t, big.NewInt(150), tx.EffectiveFee(big.NewInt(140)))
+	assert.Equal(t, big.NewInt(10), tx.Tip(big.NewInt(140)))
+	assert.Equal(t, big.NewInt(30), tx.Tip(nil))
+
+	// The base fee owed grows with the gas limit, the tip shrinking.
+	tx.GasLimit = 2 * TxGas
+	assert.Equal(t, big.NewInt(150), tx.EffectiveFee(big.NewInt(60)))
+	assert.Equal(t, big.NewInt(30), tx.Tip(big.NewInt(60)))
+	assert.Equal(t, big.NewInt(150), tx.EffectiveFee(big.NewInt(70)))
+	assert.Equal(t, big.NewInt(10), tx.Tip(big.NewInt(70)))
+	tx.GasLimit = 0
+
+	decoded, err := DecodeTransaction(tx.Serialize())
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash(), decoded.Hash())
+
+	// Either a fee or both dynamic fees.
+	both := *tx
+	both.Fee = big.NewInt(100)
+
+	_, err = DecodeTransaction(both.Serialize())
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	noTip := *tx
+	noTip.MaxPriorityFee = nil
+
+	_, err = DecodeTransaction(noTip.Serialize())
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+}
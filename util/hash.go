@@ -2,9 +2,17 @@ package util
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
 )
 
+// ErrInvalidChecksum is returned when the case of a mixed-case address doesn't match its checksum.
+var ErrInvalidChecksum = errors.New("invalid address checksum")
+
 const (
 	hashLength    = 32 // Length of hash
 	addressLength = 20 // Length of address
@@ -38,6 +46,31 @@ func (a Address) Bytes() []byte {
 	return a[:]
 }
 
+// Checksum returns the EIP-55 representation of the address : the hex letters are upper
+// case where the matching nibble of the keccak256 hash of the lowercase hex is 8 or more.
+func (a Address) Checksum() string {
+	lower := hex.EncodeToString(a[:])
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(lower))
+	hash := hasher.Sum(nil)
+
+	checksummed := []byte(lower)
+
+	for i, c := range checksummed {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksummed)
+}
+
 // ByteToHash converts a byte array to hash.
 func ByteToHash(b []byte) *Hash {
 	hash := Hash{}
@@ -68,3 +101,56 @@ func BytesToAddress(b []byte) *Address {
 func StringToAddress(s string) *Address {
 	return BytesToAddress([]byte(s))
 }
+
+// HexToAddress parses a 0x-prefixed hex string into an address.
+func HexToAddress(s string) (*Address, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, fmt.Errorf("address %s is missing the 0x prefix", s)
+	}
+
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("address %s is not valid hex", s)
+	}
+
+	if len(b) != addressLength {
+		return nil, fmt.Errorf("address %s must be %d bytes, got %d", s, addressLength, len(b))
+	}
+
+	return BytesToAddress(b), nil
+}
+
+// ChecksumHexToAddress parses a 0x-prefixed hex address like HexToAddress. Addresses
+// mixing upper and lower case letters must match their EIP-55 checksum, all lower or
+// all upper case ones carry no checksum.
+func ChecksumHexToAddress(s string) (*Address, error) {
+	address, err := HexToAddress(s)
+	if err != nil {
+		return nil, err
+	}
+
+	digits := s[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && address.Checksum() != "0x"+digits {
+		return nil, fmt.Errorf("%w : %s, expected %s", ErrInvalidChecksum, s, address.Checksum())
+	}
+
+	return address, nil
+}
+
+// HexToHash parses a 0x-prefixed hex string into a hash.
+func HexToHash(s string) (*Hash, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, fmt.Errorf("hash %s is missing the 0x prefix", s)
+	}
+
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("hash %s is not valid hex", s)
+	}
+
+	if len(b) != hashLength {
+		return nil, fmt.Errorf("hash %s must be %d bytes, got %d", s, hashLength, len(b))
+	}
+
+	return ByteToHash(b), nil
+}
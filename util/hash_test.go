@@ -0,0 +1,52 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressChecksum(t *testing.T) {
+	t.Parallel()
+
+	// Test vectors of EIP-55.
+	for _, checksummed := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		address, err := ChecksumHexToAddress(checksummed)
+		assert.NoError(t, err)
+		assert.Equal(t, checksummed, address.Checksum())
+		assert.Equal(t, strings.ToLower(checksummed), address.String())
+
+		// Single-case addresses carry no checksum.
+		for _, s := range []string{strings.ToLower(checksummed), "0x" + strings.ToUpper(checksummed[2:])} {
+			address, err := ChecksumHexToAddress(s)
+			assert.NoError(t, err)
+			assert.Equal(t, checksummed, address.Checksum())
+		}
+	}
+}
+
+func TestChecksumHexToAddressInvalid(t *testing.T) {
+	t.Parallel()
+
+	// One letter with the wrong case.
+	_, err := ChecksumHexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD")
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+	assert.ErrorContains(t, err, "expected 0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+
+	for _, s := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA",     // 19 bytes
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed00", // 21 bytes
+		"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",     // No 0x prefix
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg",   // Not hex
+	} {
+		_, err := ChecksumHexToAddress(s)
+		assert.Error(t, err, s)
+		assert.NotErrorIs(t, err, ErrInvalidChecksum, s)
+	}
+}
@@ -0,0 +1,175 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Names of the signature schemes.
+const (
+	SchemeSecp256k1 = "secp256k1"
+	SchemeEd25519   = "ed25519"
+)
+
+// ed25519AddressPrefix separates Ed25519 addresses from secp256k1 ones, which are
+// taken from the key itself, so that the same bytes never give the same address under
+// both schemes.
+var ed25519AddressPrefix = []byte("ed25519")
+
+var (
+	ErrUnknownScheme     = errors.New("unknown signature scheme")
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	ErrUnsupportedKey    = errors.New("unsupported private key type")
+)
+
+// SignatureScheme signs data, verifies signatures and derives addresses for one kind
+// of key. Signatures are made of two integers, r and s.
+type SignatureScheme interface {
+	Name() string
+	Sign(key crypto.Signer, data []byte) (*big.Int, *big.Int, error)
+	Verify(pub *CompactPublicKey, data []byte, r *big.Int, s *big.Int) bool
+	PublicKey(key crypto.Signer) *CompactPublicKey
+	Address(pub *CompactPublicKey) *Address
+	HexToPrivateKey(hexStr string) (crypto.Signer, error)
+}
+
+// SchemeByName returns the signature scheme with the given name. An empty name is
+// the secp256k1 scheme.
+func SchemeByName(name string) (SignatureScheme, error) {
+	switch name {
+	case "", SchemeSecp256k1:
+		return secp256k1Scheme{}, nil
+	case SchemeEd25519:
+		return ed25519Scheme{}, nil
+	default:
+		return nil, fmt.Errorf("%w : %q", ErrUnknownScheme, name)
+	}
+}
+
+// schemeOfKey returns the signature scheme of a private key, or ErrUnsupportedKey if
+// the key is neither a secp256k1 nor an Ed25519 key.
+func schemeOfKey(key crypto.Signer) (SignatureScheme, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		return secp256k1Scheme{}, nil
+	case ed25519.PrivateKey:
+		return ed25519Scheme{}, nil
+	default:
+		return nil, fmt.Errorf("%w : %T", ErrUnsupportedKey, key)
+	}
+}
+
+// secp256k1Scheme signs with ECDSA keys, addresses are taken from the public key.
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) Name() string {
+	return SchemeSecp256k1
+}
+
+func (secp256k1Scheme) Sign(key crypto.Signer, data []byte) (*big.Int, *big.Int, error) {
+	return ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), data)
+}
+
+// Verify checks the signature on the curve of the scheme, never on the one the key
+// carries : a key claiming other curve parameters, such as another generator, doesn't
+// verify.
+func (secp256k1Scheme) Verify(pub *CompactPublicKey, data []byte, r *big.Int, s *big.Int) bool {
+	if r == nil || s == nil || !isSchemeCurve(pub.CurveParams) || pub.X == nil || pub.Y == nil {
+		return false
+	}
+
+	curve := elliptic.P256()
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return false
+	}
+
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: pub.X, Y: pub.Y}, data, r, s)
+}
+
+// isSchemeCurve reports whether the curve parameters are the ones of the curve secp256k1
+// keys are generated on, comparing every field.
+func isSchemeCurve(params *elliptic.CurveParams) bool {
+	if params == nil || params.P == nil || params.N == nil || params.B == nil || params.Gx == nil || params.Gy == nil {
+		return false
+	}
+
+	curve := elliptic.P256().Params()
+
+	return params.Name == curve.Name && params.BitSize == curve.BitSize && params.P.Cmp(curve.P) == 0 &&
+		params.N.Cmp(curve.N) == 0 && params.B.Cmp(curve.B) == 0 && params.Gx.Cmp(curve.Gx) == 0 && params.Gy.Cmp(curve.Gy) == 0
+}
+
+func (secp256k1Scheme) PublicKey(key crypto.Signer) *CompactPublicKey {
+	return PublicKeyToCompact(&key.(*ecdsa.PrivateKey).PublicKey)
+}
+
+func (secp256k1Scheme) Address(pub *CompactPublicKey) *Address {
+	return PublicKeyToAddress(pub.PublicKey())
+}
+
+func (secp256k1Scheme) HexToPrivateKey(hexStr string) (crypto.Signer, error) {
+	bytes, err := hex.DecodeString(hexStr)
+	if err != nil || len(bytes) == 0 {
+		return nil, fmt.Errorf("%w : expected a hex encoded secp256k1 key", ErrInvalidPrivateKey)
+	}
+
+	return bytesToPrivateKey(bytes), nil
+}
+
+// ed25519Scheme signs with Ed25519 keys. The 64 bytes signature is split in its two
+// 32 bytes halves, r and s.
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Name() string {
+	return SchemeEd25519
+}
+
+func (ed25519Scheme) Sign(key crypto.Signer, data []byte) (*big.Int, *big.Int, error) {
+	sig := ed25519.Sign(key.(ed25519.PrivateKey), data)
+
+	return new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]), nil
+}
+
+func (ed25519Scheme) Verify(pub *CompactPublicKey, data []byte, r *big.Int, s *big.Int) bool {
+	if len(pub.Ed25519) != ed25519.PublicKeySize || pub.CurveParams != nil || pub.X != nil || pub.Y != nil {
+		return false
+	}
+
+	if r == nil || s == nil || r.Sign() < 0 || s.Sign() < 0 || r.BitLen() > 256 || s.BitLen() > 256 {
+		return false
+	}
+
+	sig := make([]byte, ed25519.SignatureSize)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return ed25519.Verify(pub.Ed25519, data, sig)
+}
+
+func (ed25519Scheme) PublicKey(key crypto.Signer) *CompactPublicKey {
+	pub, _ := key.Public().(ed25519.PublicKey)
+
+	return &CompactPublicKey{Ed25519: pub}
+}
+
+func (ed25519Scheme) Address(pub *CompactPublicKey) *Address {
+	hash := HashData(append(append([]byte{}, ed25519AddressPrefix...), pub.Ed25519...))
+
+	return BytesToAddress(hash.Bytes()[hashLength-addressLength:])
+}
+
+func (ed25519Scheme) HexToPrivateKey(hexStr string) (crypto.Signer, error) {
+	bytes, err := hex.DecodeString(hexStr)
+	if err != nil || len(bytes) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w : expected a hex encoded %d bytes ed25519 seed", ErrInvalidPrivateKey, ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(bytes), nil
+}
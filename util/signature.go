@@ -1,21 +1,28 @@
 package util
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
-	"crypto/rand"
 	"encoding/hex"
 	"math/big"
 )
 
+// UnlockedAccount signs data with the private key of an account, under the signature
+// scheme of the key.
 type UnlockedAccount struct {
-	privateKey *ecdsa.PrivateKey
+	privateKey crypto.Signer
+	scheme     SignatureScheme
 }
 
+// CompactPublicKey is the public key of a signer. Secp256k1 keys set the curve point,
+// Ed25519 keys only the Ed25519 field.
 type CompactPublicKey struct {
 	CurveParams *elliptic.CurveParams `json:"Curve"`
 	X           *big.Int              `json:"X"`
 	Y           *big.Int              `json:"Y"`
+	Ed25519     ed25519.PublicKey     `json:"Ed25519,omitempty"`
 }
 
 func (cpk *CompactPublicKey) PublicKey() *ecdsa.PublicKey {
@@ -26,6 +33,26 @@ func (cpk *CompactPublicKey) PublicKey() *ecdsa.PublicKey {
 	}
 }
 
+// Scheme returns the signature scheme of the key.
+func (cpk *CompactPublicKey) Scheme() SignatureScheme {
+	if cpk.Ed25519 != nil {
+		return ed25519Scheme{}
+	}
+
+	return secp256k1Scheme{}
+}
+
+// Verify reports whether r and s are a valid signature of data by the key. Malformed
+// keys never verify.
+func (cpk *CompactPublicKey) Verify(data []byte, r *big.Int, s *big.Int) bool {
+	return cpk.Scheme().Verify(cpk, data, r, s)
+}
+
+// Address returns the address owned by the key.
+func (cpk *CompactPublicKey) Address() *Address {
+	return cpk.Scheme().Address(cpk)
+}
+
 func PublicKeyToCompact(pubkey *ecdsa.PublicKey) *CompactPublicKey {
 	return &CompactPublicKey{
 		CurveParams: pubkey.Curve.Params(),
@@ -34,14 +61,39 @@ func PublicKeyToCompact(pubkey *ecdsa.PublicKey) *CompactPublicKey {
 	}
 }
 
-func NewUnlockedAccount(privateKey *ecdsa.PrivateKey) *UnlockedAccount {
+// NewUnlockedAccount returns an account signing with the given secp256k1 or Ed25519
+// private key. It panics on other key types, keys from user input go through
+// UnlockAccount instead.
+func NewUnlockedAccount(privateKey crypto.Signer) *UnlockedAccount {
+	ua, err := UnlockAccount(privateKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return ua
+}
+
+// UnlockAccount returns an account signing with the given secp256k1 or Ed25519 private
+// key, or ErrUnsupportedKey on other key types.
+func UnlockAccount(privateKey crypto.Signer) (*UnlockedAccount, error) {
+	scheme, err := schemeOfKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
 	return &UnlockedAccount{
 		privateKey: privateKey,
-	}
+		scheme:     scheme,
+	}, nil
 }
 
-func (ua *UnlockedAccount) PublicKey() *ecdsa.PublicKey {
-	return &ua.privateKey.PublicKey
+func (ua *UnlockedAccount) PublicKey() *CompactPublicKey {
+	return ua.scheme.PublicKey(ua.privateKey)
+}
+
+// Scheme returns the signature scheme of the account.
+func (ua *UnlockedAccount) Scheme() SignatureScheme {
+	return ua.scheme
 }
 
 func PublicKeyToAddress(pubkey *ecdsa.PublicKey) *Address {
@@ -54,16 +106,15 @@ func PublicKeyToAddress(pubkey *ecdsa.PublicKey) *Address {
 }
 
 func (ua *UnlockedAccount) Address() *Address {
-	pubkey := ua.privateKey.PublicKey
-	return PublicKeyToAddress(&pubkey)
+	return ua.PublicKey().Address()
 }
 
 func (ua *UnlockedAccount) Sign(data []byte) (*big.Int, *big.Int, error) {
-	return ecdsa.Sign(rand.Reader, ua.privateKey, data)
+	return ua.scheme.Sign(ua.privateKey, data)
 }
 
 func (ua *UnlockedAccount) Verify(data []byte, r *big.Int, s *big.Int) bool {
-	return ecdsa.Verify(ua.PublicKey(), data, r, s)
+	return ua.PublicKey().Verify(data, r, s)
 }
 
 func HexToPrivateKey(hexStr string) *ecdsa.PrivateKey {
@@ -72,6 +123,10 @@ func HexToPrivateKey(hexStr string) *ecdsa.PrivateKey {
 		panic(err)
 	}
 
+	return bytesToPrivateKey(bytes)
+}
+
+func bytesToPrivateKey(bytes []byte) *ecdsa.PrivateKey {
 	k := new(big.Int)
 	k.SetBytes(bytes)
 
@@ -83,3 +138,14 @@ func HexToPrivateKey(hexStr string) *ecdsa.PrivateKey {
 
 	return priv
 }
+
+// HexToEd25519PrivateKey returns the Ed25519 private key of a hex encoded 32 bytes seed,
+// or ErrInvalidPrivateKey if the seed isn't one.
+func HexToEd25519PrivateKey(hexStr string) (ed25519.PrivateKey, error) {
+	key, err := ed25519Scheme{}.HexToPrivateKey(hexStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.(ed25519.PrivateKey), nil
+}
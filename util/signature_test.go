@@ -1,7 +1,12 @@
 package util
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"math/big"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSigning(t *testing.T) {
@@ -24,3 +29,88 @@ func TestSigning(t *testing.T) {
 		t.Fatal("expected true", "got", verified)
 	}
 }
+
+func TestEd25519Signing(t *testing.T) {
+	t.Parallel()
+
+	edKey, err := HexToEd25519PrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ua := NewUnlockedAccount(edKey)
+	assert.Equal(t, SchemeEd25519, ua.Scheme().Name())
+
+	data := []byte("data")
+
+	r, s, err := ua.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, ua.Verify(data, r, s))
+	assert.False(t, ua.Verify([]byte("other data"), r, s))
+
+	// A key claiming both schemes doesn't verify.
+	pub := ua.PublicKey()
+	pub.X = big.NewInt(1)
+	assert.False(t, pub.Verify(data, r, s))
+}
+
+func TestInvalidKeys(t *testing.T) {
+	t.Parallel()
+
+	// Seeds of the wrong length are rejected rather than panicking.
+	for _, seed := range []string{"", "c3fc038a", "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6aa", "not hex"} {
+		_, err := HexToEd25519PrivateKey(seed)
+		assert.ErrorIs(t, err, ErrInvalidPrivateKey, seed)
+	}
+
+	// Keys of other types can't unlock an account.
+	var key crypto.Signer = &rsa.PrivateKey{}
+
+	_, err := UnlockAccount(key)
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+	assert.ErrorContains(t, err, "*rsa.PrivateKey")
+}
+
+func TestSchemeAddresses(t *testing.T) {
+	t.Parallel()
+
+	key := "c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6"
+
+	secp := NewUnlockedAccount(HexToPrivateKey(key))
+	edKey, err := HexToEd25519PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed := NewUnlockedAccount(edKey)
+
+	assert.Equal(t, "0xa52c981eee8687b5e4afd69aa5006548c24d7685", secp.Address().String())
+	assert.NotEqual(t, secp.Address(), ed.Address())
+
+	// The address of an Ed25519 key isn't the one of its bytes read as a secp256k1 key.
+	assert.NotEqual(t, ed.PublicKey().Ed25519[:addressLength], ed.Address().Bytes())
+	assert.Equal(t, ed.Address(), ed.PublicKey().Address())
+}
+
+func TestSchemeByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{SchemeSecp256k1, SchemeEd25519} {
+		scheme, err := SchemeByName(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, scheme.Name())
+
+		key, err := scheme.HexToPrivateKey("c3fc038a9abc0f483e2e1f8a0b4db676bce3eaebd7d9afc68e1e7e28ca8738a6")
+		assert.NoError(t, err)
+		assert.Equal(t, name, NewUnlockedAccount(key).Scheme().Name())
+
+		_, err = scheme.HexToPrivateKey("not hex")
+		assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+	}
+
+	_, err := SchemeByName("rsa")
+	assert.ErrorIs(t, err, ErrUnknownScheme)
+}